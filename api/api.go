@@ -1,13 +1,22 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/go-pg/pg"
 	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/filter"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/secrets"
 	"github.com/im-kulikov/simplinic-task/store"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"go.uber.org/dig"
 	"go.uber.org/zap"
 )
@@ -16,16 +25,37 @@ type (
 	router struct {
 		dig.In
 
-		Echo   *echo.Echo
-		Logger *zap.Logger
-		Scheme store.Schemes
-		Config store.Configs
+		Echo    *echo.Echo
+		Logger  *zap.Logger
+		Scheme  store.Schemes
+		Config  store.Configs
+		Tx      store.Transactions
+		Changes store.Changes
+		Feed    *store.Feed
+		Hooks   store.Webhooks
+		Archive store.Archive
+		Vars    interpolate.Variables
+		Viper   *viper.Viper
 	}
 
 	idRequest struct {
 		ID int64 `param:"id" validate:"required,gt=0" message:"id could not be empty"`
 	}
 
+	slugRequest struct {
+		Slug string `param:"slug" validate:"required" message:"slug could not be empty"`
+	}
+
+	configSlugRequest struct {
+		Scheme string `param:"scheme" validate:"required" message:"scheme slug could not be empty"`
+		Slug   string `param:"slug" validate:"required" message:"slug could not be empty"`
+	}
+
+	renameRequest struct {
+		ID   int64  `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+		Slug string `json:"slug" validate:"required" message:"slug could not be empty"`
+	}
+
 	updateConfigRequest struct {
 		ID       int64           `json:"id" validate:"required,gt=0" message:"id could not be empty"`
 		SchemeID int64           `json:"scheme_id" validate:"required,gt=0" message:"scheme_id could not be empty"`
@@ -42,42 +72,220 @@ type (
 	}
 
 	searchRequest struct {
-		Version int64    `query:"version"`
-		Tags    []string `query:"tags" validate:"required" message:"tags could not be empty"`
+		Version     int64    `query:"version"`
+		Tags        []string `query:"tags"`
+		Query       string   `query:"q"`
+		Filter      string   `query:"filter"`
+		SchemeID    int64    `query:"scheme_id"`
+		Author      string   `query:"author"`
+		CreatedFrom string   `query:"created_from"`
+		CreatedTo   string   `query:"created_to"`
+		UpdatedFrom string   `query:"updated_from"`
+		UpdatedTo   string   `query:"updated_to"`
+		Limit       int      `query:"limit" validate:"gte=0" message:"limit could not be negative"`
+		Offset      int      `query:"offset" validate:"gte=0" message:"offset could not be negative"`
+	}
+
+	historyRequest struct {
+		ID     int64 `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+		Limit  int   `query:"limit" validate:"gte=0" message:"limit could not be negative"`
+		Offset int   `query:"offset" validate:"gte=0" message:"offset could not be negative"`
+	}
+
+	schemeConfigsRequest struct {
+		ID     int64 `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+		Search searchRequest
 	}
 
 	searchResponse struct {
-		Total int           `json:"total"`
-		Items []interface{} `json:"items"`
+		Total  int           `json:"total"`
+		Offset int           `json:"offset"`
+		Limit  int           `json:"limit,omitempty"`
+		Items  []interface{} `json:"items"`
 	}
 )
 
 var Module = module.Module{
-	{Constructor: newRouter},            // connect router
-	{Constructor: store.NewSchemeStore}, // to work with schemes
-	{Constructor: store.NewConfigStore}, // to work with configs
+	{Constructor: newRouter},                   // connect router
+	{Constructor: store.NewCache},              // read-through cache of schemes and configs
+	{Constructor: store.NewBreaker},            // circuit breaker of database calls
+	{Constructor: store.NewCachedSchemeStore},  // to work with schemes
+	{Constructor: store.NewCachedConfigStore},  // to work with configs
+	{Constructor: store.NewCachedTransactions}, // to run batches of operations
+	{Constructor: store.NewChangeStore},        // to read changes
+	{Constructor: store.NewFeed},               // to watch changes
+	{Constructor: store.NewWebhookStore},       // to work with webhooks
+	{Constructor: store.NewCachedArchive},      // to export and import database
+	{Constructor: newVariables},                // to resolve references to variables
 }
 
-func newRouter(r router) http.Handler {
+func newRouter(r router) (http.Handler, error) {
 	e := r.Echo
 
+	identity, err := newIdentity(r.Viper)
+	if err != nil {
+		return nil, err
+	}
+
 	e.Pre(middleware.AddTrailingSlash())
+	e.Use(identity)
+	e.Use(negotiate(func(ctx echo.Context) bool { return ctx.Path() == renderPath }))
 
 	// app routes:
 	s := e.Group("/schemes")
 	s.POST("/", createScheme(r.Scheme))
 	s.GET("/", listSchemes(r.Scheme))
+	s.GET("/by-slug/:slug/", getSchemeBySlug(r.Scheme))
 	s.GET("/:id/", getScheme(r.Scheme))
+	s.GET("/:id/configs/", listSchemeConfigs(r.Scheme, r.Config))
+	s.GET("/:id/history/", schemeHistory(r.Scheme))
 	s.PUT("/:id/", updateScheme(r.Scheme))
+	s.PUT("/:id/slug/", renameScheme(r.Scheme))
 	s.DELETE("/:id/", deleteScheme(r.Scheme))
+	s.PUT("/:id/restore/", restoreScheme(r.Scheme))
 
 	c := e.Group("/configs")
 	c.POST("/", createConfig(r.Config))
 	c.GET("/", listConfigs(r.Config))
-	c.GET("/:id/", getConfig(r.Config))
+	c.GET("/by-slug/:scheme/:slug/", getConfigBySlug(r.Scheme, r.Config, r.Vars))
+	c.GET("/:id/", getConfig(r.Scheme, r.Config, r.Vars))
+	c.GET("/:id/history/", configHistory(r.Config))
+	c.GET("/:id/dependents/", configDependents(r.Config))
+	c.GET("/:id/render/", renderConfig(r.Scheme, r.Config, r.Vars))
+	c.POST("/:id/reveal/", revealConfig(r.Config, r.Logger))
 	c.PUT("/:id/", updateConfig(r.Config))
+	c.PUT("/:id/slug/", renameConfig(r.Config))
 	c.DELETE("/:id/", deleteConfig(r.Config))
+	c.PUT("/:id/restore/", restoreConfig(r.Config))
+
+	w := e.Group("/webhooks")
+	w.POST("/", createWebhook(r.Hooks))
+	w.GET("/", listWebhooks(r.Hooks))
+	w.GET("/:id/", getWebhook(r.Hooks))
+	w.DELETE("/:id/", deleteWebhook(r.Hooks))
+	w.GET("/:id/deliveries/", listDeliveries(r.Hooks))
+
+	e.POST("/batch/", batch(r.Tx))
+	e.POST("/gitops/plan/", gitopsPlan(r.Scheme, r.Config))
+	e.POST("/gitops/apply/", gitopsApply(r.Tx))
+	e.GET("/manifests/", manifests(r.Scheme, r.Config, r.Vars))
+	e.GET("/watch/", watch(r.Changes, r.Feed, r.Logger))
+	e.GET("/changes/", listChanges(r.Changes, r.Feed))
+
+	a := e.Group("/admin")
+	a.GET("/export/", exportArchive(r.Archive, r.Logger))
+	a.POST("/import/", importArchive(r.Archive))
 	// -------- //
 
-	return e
+	return e, nil
+}
+
+// storeRequest converts search request to store.SearchRequest,
+// filter syntax errors are reported with 400 status code
+func (req searchRequest) storeRequest() (store.SearchRequest, error) {
+	var (
+		err    error
+		result = store.SearchRequest{
+			Version:  req.Version,
+			Tags:     req.Tags,
+			Query:    req.Query,
+			SchemeID: req.SchemeID,
+			Author:   req.Author,
+			Limit:    req.Limit,
+			Offset:   req.Offset,
+		}
+	)
+
+	for _, item := range []struct {
+		name  string
+		value string
+		field *time.Time
+	}{
+		{name: "created_from", value: req.CreatedFrom, field: &result.CreatedFrom},
+		{name: "created_to", value: req.CreatedTo, field: &result.CreatedTo},
+		{name: "updated_from", value: req.UpdatedFrom, field: &result.UpdatedFrom},
+		{name: "updated_to", value: req.UpdatedTo, field: &result.UpdatedTo},
+	} {
+		if item.value == "" {
+			continue
+		}
+
+		if *item.field, err = filter.ParseDate(item.value); err != nil {
+			return result, echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("%s should be date (2006-01-02) or RFC3339 time", item.name))
+		}
+	}
+
+	if req.Filter == "" {
+		return result, nil
+	}
+
+	if result.Filter, err = filter.Parse(req.Filter); err != nil {
+		return result, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return result, nil
+}
+
+// response for paginated search
+func (req searchRequest) response(total int, items []interface{}) searchResponse {
+	return searchResponse{
+		Total:  total,
+		Offset: req.Offset,
+		Limit:  req.Limit,
+		Items:  items,
+	}
+}
+
+// response for page of history
+func (req historyRequest) response(total int, items []interface{}) searchResponse {
+	return searchResponse{
+		Total:  total,
+		Offset: req.Offset,
+		Limit:  req.Limit,
+		Items:  items,
+	}
+}
+
+// staleHeaders marks response that contains last-known-good version,
+// served when database is unavailable
+func staleHeaders(ctx echo.Context, stale bool) {
+	if !stale {
+		return
+	}
+
+	ctx.Response().Header().Set("Warning", `110 - "Response is Stale"`)
+	ctx.Response().Header().Set("X-Stale", "true")
+}
+
+// storeError converts known store errors to http errors
+func storeError(err error) error {
+	switch cause := errors.Cause(err); cause {
+	case context.DeadlineExceeded:
+		return echo.NewHTTPError(http.StatusGatewayTimeout)
+	case pg.ErrNoRows:
+		return echo.NewHTTPError(http.StatusNotFound)
+	case store.ErrInvalidSlug:
+		return echo.NewHTTPError(http.StatusBadRequest, cause.Error())
+	case store.ErrSlugConflict:
+		return echo.NewHTTPError(http.StatusConflict, cause.Error())
+	case store.ErrReferenced:
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case interpolate.ErrInvalidReference, interpolate.ErrCycle:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case secrets.ErrInvalidSecret, secrets.ErrUnknownKey:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case secrets.ErrNoKeys:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if store.IsUnavailable(err) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, store.ErrUnavailable.Error())
+	}
+
+	if ferr, ok := errors.Cause(err).(*filter.Error); ok {
+		return echo.NewHTTPError(http.StatusBadRequest, ferr.Error())
+	}
+
+	return err
 }
@@ -2,11 +2,18 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"time"
 
 	"github.com/go-pg/pg"
 	"github.com/im-kulikov/helium"
@@ -17,11 +24,15 @@ import (
 	"github.com/im-kulikov/helium/redis"
 	"github.com/im-kulikov/helium/settings"
 	"github.com/im-kulikov/helium/web"
+	"github.com/im-kulikov/simplinic-task/k8s"
 	"github.com/im-kulikov/simplinic-task/models"
+	"github.com/im-kulikov/simplinic-task/secrets"
 	"github.com/im-kulikov/simplinic-task/store"
 	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
 )
 
 type Params map[string]string
@@ -61,6 +72,7 @@ var _ = Describe("API Suite", func() {
 		db          *pg.DB
 		schemeStore store.Schemes
 		configStore store.Configs
+		txStore     store.Transactions
 	)
 
 	BeforeSuite(func() {
@@ -79,13 +91,16 @@ var _ = Describe("API Suite", func() {
 
 		schemeStore = store.NewSchemeStore(db)
 		configStore = store.NewConfigStore(db)
+		txStore = store.NewTransactions(db)
 
 		_ = configStore
 	})
 
 	AfterSuite(func() {
-		// Close database session
-		Expect(db.Close()).NotTo(HaveOccurred())
+		// Close database session, BeforeSuite could fail before it is opened:
+		if db != nil {
+			Expect(db.Close()).NotTo(HaveOccurred())
+		}
 	})
 
 	Context("Scheme routes", func() {
@@ -169,7 +184,7 @@ var _ = Describe("API Suite", func() {
 				Data:    json.RawMessage(`{"hello":"world"}`),
 			}
 
-			err := schemeStore.Create(&fixture)
+			err := schemeStore.Create(context.Background(), &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
 			ctx, res := createContext(e, buf)
@@ -192,6 +207,75 @@ var _ = Describe("API Suite", func() {
 			Expect(scheme.Version).To(BeEquivalentTo(fixture.Version))
 		})
 
+		It("should rename scheme and read it by old and new slugs", func() {
+			var fixture = store.Scheme{
+				Slug: "api-person",
+				Tags: []string{"a", "b", "c"},
+				Data: json.RawMessage(`{"hello":"world"}`),
+			}
+
+			err := schemeStore.Create(context.Background(), &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = json.NewEncoder(buf).Encode(map[string]string{"slug": "api-human"})
+			Expect(err).NotTo(HaveOccurred())
+
+			req := httptest.NewRequest(echo.PUT, "/", buf)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx := e.NewContext(req, httptest.NewRecorder())
+			setParams(ctx, Params{
+				"id": strconv.FormatInt(fixture.ID, 10),
+			})
+
+			err = renameScheme(schemeStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, slug := range []string{"api-person", "api-human"} {
+				req = httptest.NewRequest(echo.GET, "/", nil)
+				res := httptest.NewRecorder()
+				ctx = e.NewContext(req, res)
+				setParams(ctx, Params{
+					"slug": slug,
+				})
+
+				err = getSchemeBySlug(schemeStore)(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.Code).To(BeEquivalentTo(http.StatusOK))
+
+				var scheme store.Scheme
+
+				err = json.NewDecoder(res.Body).Decode(&scheme)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scheme.ID).To(Equal(fixture.ID))
+				Expect(scheme.Slug).To(Equal("api-human"))
+			}
+		})
+
+		It("create should fail with 409 when slug already used", func() {
+			err := schemeStore.Create(context.Background(), &store.Scheme{
+				Slug: "api-taken",
+				Tags: []string{"a"},
+				Data: json.RawMessage(`{}`),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = json.NewEncoder(buf).Encode(store.Scheme{
+				Slug: "api-taken",
+				Tags: []string{"a"},
+				Data: json.RawMessage(`{}`),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx, _ := createContext(e, buf)
+
+			err = createScheme(schemeStore)(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusConflict))
+		})
+
 		It("get should fail and return 404 status code", func() {
 			ctx, _ := createContext(e, buf)
 			setParams(ctx, Params{
@@ -281,7 +365,7 @@ var _ = Describe("API Suite", func() {
 		)
 
 		BeforeEach(func() {
-			err := schemeStore.Create(&scheme)
+			err := schemeStore.Create(context.Background(), &scheme)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -324,6 +408,292 @@ var _ = Describe("API Suite", func() {
 			Expect(count).To(BeEquivalentTo(1))
 		})
 
+		It("should create config from YAML and read it as TOML", func() {
+			body := "scheme_id: " + strconv.FormatInt(scheme.ID, 10) + `
+tags: [a]
+data:
+  port: 8080
+  ratio: 1.0
+  released: 2018-11-15
+`
+
+			req := httptest.NewRequest(echo.POST, "/", bytes.NewBufferString(body))
+			req.Header.Set(echo.HeaderContentType, "application/yaml")
+			rec := httptest.NewRecorder()
+
+			err := negotiate(middleware.DefaultSkipper)(createConfig(configStore))(e.NewContext(req, rec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Code).To(BeEquivalentTo(http.StatusCreated))
+
+			var config store.Config
+
+			err = json.NewDecoder(rec.Body).Decode(&config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(config.Data)).To(ContainSubstring(`"ratio": 1.0`))
+			Expect(config.Data).To(MatchJSON(`{"port":8080,"ratio":1.0,"released":"2018-11-15"}`))
+
+			rec = httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/?format=toml", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(config.ID, 10)})
+
+			err = negotiate(middleware.DefaultSkipper)(getConfig(schemeStore, configStore, nil))(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Header().Get(echo.HeaderContentType)).To(HavePrefix("application/toml"))
+			Expect(rec.Body.String()).To(ContainSubstring("[data]\nport = 8080\nratio = 1.0\nreleased = \"2018-11-15\"\n"))
+		})
+
+		It("should render config as env file", func() {
+			var config = store.Config{
+				SchemeID: scheme.ID,
+				Tags:     []string{"a"},
+				Data:     json.RawMessage(`{"db":{"host":"localhost","port":5432}}`),
+			}
+
+			err := configStore.Create(context.Background(), &config)
+			Expect(err).NotTo(HaveOccurred())
+
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/?format=dotenv&prefix=APP_", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(config.ID, 10)})
+
+			err = renderConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(Equal("APP_DB__HOST=localhost\nAPP_DB__PORT=5432\n"))
+		})
+
+		It("should render config with secrets only for authenticated client", func() {
+			var secret = store.Scheme{
+				Tags: []string{"secret"},
+				Data: json.RawMessage(`{"type":"object","properties":{"password":{"type":"string","x-secret":true}}}`),
+			}
+
+			err := schemeStore.Create(context.Background(), &secret)
+			Expect(err).NotTo(HaveOccurred())
+
+			var config = store.Config{SchemeID: secret.ID, Tags: []string{"a"}, Data: json.RawMessage(`{"host":"localhost"}`)}
+
+			err = configStore.Create(context.Background(), &config)
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/?format=dotenv", nil), httptest.NewRecorder())
+			setParams(ctx, Params{"id": strconv.FormatInt(config.ID, 10)})
+
+			err = renderConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusUnauthorized))
+
+			rec := httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/?format=dotenv", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(config.ID, 10)})
+			ctx.Set(identityKey, "jane")
+
+			err = renderConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(Equal("HOST=localhost\n"))
+
+			var count int
+			_, err = db.QueryOne(pg.Scan(&count), "SELECT COUNT(*) FROM config_reveals WHERE config_id = ? AND actor = 'jane' AND reason = 'render'", config.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(1))
+		})
+
+		It("should export config of environment as ConfigMap", func() {
+			var config = store.Config{
+				SchemeID: scheme.ID,
+				Tags:     []string{"manifests"},
+				Data:     json.RawMessage(`{"environment":"stage","db":{"host":"localhost"}}`),
+			}
+
+			err := configStore.Create(context.Background(), &config)
+			Expect(err).NotTo(HaveOccurred())
+
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/?tags=manifests&environment=stage&namespace=prod", nil), rec)
+
+			err = manifests(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			var list k8s.List
+
+			Expect(json.Unmarshal(rec.Body.Bytes(), &list)).To(Succeed())
+			Expect(list.Items).To(HaveLen(1))
+			Expect(list.Items[0].Kind).To(Equal("ConfigMap"))
+			Expect(list.Items[0].Metadata.Namespace).To(Equal("prod"))
+			Expect(list.Items[0].Metadata.Labels).To(HaveKeyWithValue(k8s.LabelEnvironment, "stage"))
+			Expect(list.Items[0].Data).To(HaveKeyWithValue("DB__HOST", "localhost"))
+		})
+
+		It("should resolve references of config", func() {
+			var target, dependent = store.Config{
+				SchemeID: scheme.ID,
+				Tags:     []string{"a"},
+				Data:     json.RawMessage(`{"db":{"host":"db.local"}}`),
+			}, store.Config{SchemeID: scheme.ID, Tags: []string{"a"}}
+
+			err := configStore.Create(context.Background(), &target)
+			Expect(err).NotTo(HaveOccurred())
+
+			dependent.Data = json.RawMessage(fmt.Sprintf(`{"url":"postgres://${config:%d#/db/host}"}`, target.ID))
+			err = configStore.Create(context.Background(), &dependent)
+			Expect(err).NotTo(HaveOccurred())
+
+			// references are resolved by default:
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(dependent.ID, 10)})
+
+			err = getConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(ContainSubstring(`"data":{"url":"postgres://db.local"}`))
+
+			rec = httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/?raw=true", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(dependent.ID, 10)})
+
+			err = getConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(ContainSubstring(fmt.Sprintf(`"data":{"url":"postgres://${config:%d#/db/host}"}`, target.ID)))
+
+			rec = httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/?format=dotenv", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(dependent.ID, 10)})
+
+			err = renderConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(Equal("URL=postgres://db.local\n"))
+
+			rec = httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(target.ID, 10)})
+
+			err = configDependents(configStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(MatchJSON(fmt.Sprintf(`[{"config_id":%d,"target_id":%d,"pointer":"/db/host"}]`,
+				dependent.ID, target.ID)))
+		})
+
+		It("should materialize config by scheme", func() {
+			var closed = store.Scheme{
+				Tags: []string{"a"},
+				Data: json.RawMessage(`{"type":"object","additionalProperties":false,"properties":{` +
+					`"port":{"type":"integer","default":8080},"debug":{"type":"boolean"}}}`),
+			}
+
+			err := schemeStore.Create(context.Background(), &closed)
+			Expect(err).NotTo(HaveOccurred())
+
+			var fixture = store.Config{
+				SchemeID: closed.ID,
+				Tags:     []string{"a"},
+				Data:     json.RawMessage(`{"debug":"true","legacy":1}`),
+			}
+
+			err = configStore.Create(context.Background(), &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/?materialize=true", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(fixture.ID, 10)})
+
+			err = getConfig(schemeStore, configStore, nil)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).To(ContainSubstring(`"data":{"debug":true,"port":8080}`))
+		})
+
+		It("should reveal config with audit", func() {
+			var fixture = store.Config{
+				SchemeID: scheme.ID,
+				Tags:     []string{"a"},
+				Data:     json.RawMessage(`{"password":"qwerty"}`),
+			}
+
+			err := configStore.Create(context.Background(), &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			// actor claimed by anonymous client is not trusted:
+			ctx, _ := createContext(e, bytes.NewBufferString(`{"actor":"admin","reason":"check"}`))
+			setParams(ctx, Params{"id": strconv.FormatInt(fixture.ID, 10)})
+
+			err = revealConfig(configStore, zap.NewNop())(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusUnauthorized))
+
+			ctx, rec := createContext(e, bytes.NewBufferString(`{"actor":"admin","reason":"check"}`))
+			setParams(ctx, Params{"id": strconv.FormatInt(fixture.ID, 10)})
+			ctx.Set(identityKey, "jane")
+
+			err = revealConfig(configStore, zap.NewNop())(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Header().Get("Cache-Control")).To(Equal("no-store"))
+			Expect(rec.Body.String()).To(ContainSubstring(`"data":{"password":"qwerty"}`))
+
+			var count int
+			_, err = db.QueryOne(pg.Scan(&count), "SELECT COUNT(*) FROM config_reveals WHERE config_id = ? AND actor = 'jane' AND claimed_actor = 'admin'", fixture.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(1))
+		})
+
+		It("should take author of versions from identity", func() {
+			ctx, rec := createContext(e, bytes.NewBufferString(fmt.Sprintf(`{"scheme_id":%d,"tags":["a"],"data":{},"author":"mallory"}`, scheme.ID)))
+			ctx.Set(identityKey, "jane")
+
+			err := createConfig(configStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			var created store.Config
+			Expect(json.NewDecoder(rec.Body).Decode(&created)).To(Succeed())
+			Expect(created.Author).To(Equal("jane"))
+
+			// anonymous client could not claim author:
+			ctx, _ = createContext(e, bytes.NewBufferString(fmt.Sprintf(`{"id":%d,"scheme_id":%d,"tags":["a"],"data":{},"author":"mallory"}`, created.ID, scheme.ID)))
+
+			err = updateConfig(configStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated, err := configStore.Read(context.Background(), created.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.Version).To(BeEquivalentTo(2))
+			Expect(updated.Author).To(BeEmpty())
+		})
+
+		It("should take identity only from authenticated sources", func() {
+			_, proxy, err := net.ParseCIDR("10.0.0.0/8")
+			Expect(err).NotTo(HaveOccurred())
+
+			var (
+				handler = identify("X-Remote-User", []*net.IPNet{proxy})(func(ctx echo.Context) error {
+					return ctx.String(http.StatusOK, identityOf(ctx))
+				})
+
+				identity = func(remote string, cert *x509.Certificate) string {
+					req := httptest.NewRequest(echo.GET, "/", nil)
+					req.RemoteAddr = remote
+					req.Header.Set("X-Remote-User", "admin")
+
+					if cert != nil {
+						req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+					}
+
+					rec := httptest.NewRecorder()
+					Expect(handler(e.NewContext(req, rec))).To(Succeed())
+
+					return rec.Body.String()
+				}
+			)
+
+			Expect(identity("192.0.2.1:1234", nil)).To(BeEmpty())
+			Expect(identity("10.1.2.3:1234", nil)).To(Equal("admin"))
+			Expect(identity("192.0.2.1:1234", &x509.Certificate{Subject: pkix.Name{CommonName: "jane"}})).To(Equal("jane"))
+		})
+
+		It("manifests should fail without selector", func() {
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/", nil), httptest.NewRecorder())
+
+			err := manifests(schemeStore, configStore, nil)(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+
 		It("create should fail when tags not specified", func() {
 			var fixtures = []struct {
 				error string
@@ -373,7 +743,7 @@ var _ = Describe("API Suite", func() {
 				Data:     json.RawMessage(`{"hello":"world"}`),
 			}
 
-			err := configStore.Create(&fixture)
+			err := configStore.Create(context.Background(), &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
 			ctx, res := createContext(e, buf)
@@ -381,7 +751,7 @@ var _ = Describe("API Suite", func() {
 				"id": strconv.FormatInt(fixture.ID, 10),
 			})
 
-			err = getConfig(configStore)(ctx)
+			err = getConfig(schemeStore, configStore, nil)(ctx)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res.Code).To(BeEquivalentTo(http.StatusOK))
 
@@ -396,13 +766,68 @@ var _ = Describe("API Suite", func() {
 			Expect(config.Version).To(BeEquivalentTo(fixture.Version))
 		})
 
+		It("should list configs of scheme with pagination", func() {
+			for i := 0; i < 3; i++ {
+				err := configStore.Create(context.Background(), &store.Config{
+					SchemeID: scheme.ID,
+					Tags:     []string{"a", "b", "c"},
+					Data:     json.RawMessage(`{"hello":"world"}`),
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			req := httptest.NewRequest(echo.GET, "/?limit=2&offset=1", nil)
+			res := httptest.NewRecorder()
+			ctx := e.NewContext(req, res)
+			setParams(ctx, Params{
+				"id": strconv.FormatInt(scheme.ID, 10),
+			})
+
+			err := listSchemeConfigs(schemeStore, configStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Code).To(BeEquivalentTo(http.StatusOK))
+
+			var result struct {
+				Total  int            `json:"total"`
+				Offset int            `json:"offset"`
+				Limit  int            `json:"limit"`
+				Items  []store.Config `json:"items"`
+			}
+
+			err = json.NewDecoder(res.Body).Decode(&result)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Total).To(Equal(3))
+			Expect(result.Offset).To(Equal(1))
+			Expect(result.Limit).To(Equal(2))
+			Expect(result.Items).To(HaveLen(2))
+
+			for _, item := range result.Items {
+				Expect(item.SchemeID).To(Equal(scheme.ID))
+			}
+		})
+
+		It("list configs of unknown scheme should return 404", func() {
+			req := httptest.NewRequest(echo.GET, "/", nil)
+			ctx := e.NewContext(req, httptest.NewRecorder())
+			setParams(ctx, Params{
+				"id": "10000000000",
+			})
+
+			err := listSchemeConfigs(schemeStore, configStore)(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusNotFound))
+		})
+
 		It("get should fail and return 404 status code", func() {
 			ctx, _ := createContext(e, buf)
 			setParams(ctx, Params{
 				"id": "10000000000",
 			})
 
-			err := getConfig(configStore)(ctx)
+			err := getConfig(schemeStore, configStore, nil)(ctx)
 			Expect(err).To(HaveOccurred())
 
 			herr, ok := err.(*echo.HTTPError)
@@ -476,4 +901,362 @@ var _ = Describe("API Suite", func() {
 			Expect(herr.Code).To(BeEquivalentTo(http.StatusNotFound))
 		})
 	})
+
+	Context("Batch route", func() {
+		var buf = new(bytes.Buffer)
+
+		AfterEach(func() {
+			buf.Reset()
+		})
+
+		It("should create scheme and configs that refer to it in one batch", func() {
+			buf.WriteString(`{"operations": [
+				{"op": "create", "kind": "scheme", "ref": "s", "tags": ["batch"], "data": {"type": "object"}},
+				{"op": "create", "kind": "config", "ref": "c1", "scheme_ref": "s", "tags": ["batch"], "data": {"a": 1}},
+				{"op": "create", "kind": "config", "ref": "c2", "scheme_ref": "s", "tags": ["batch"], "data": {"a": 2}},
+				{"op": "update", "kind": "config", "id_ref": "c1", "tags": ["batch"], "data": {"a": 3}},
+				{"op": "delete", "kind": "config", "id_ref": "c2"}
+			]}`)
+
+			ctx, res := createContext(e, buf)
+
+			err := batch(txStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Code).To(BeEquivalentTo(http.StatusOK))
+
+			var result batchResponse
+
+			err = json.NewDecoder(res.Body).Decode(&result)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Applied).To(BeTrue())
+			Expect(result.Results).To(HaveLen(5))
+
+			Expect(result.Results[1].Status).To(BeEquivalentTo(http.StatusCreated))
+			Expect(result.Results[3].Version).To(BeEquivalentTo(2))
+
+			cfg, err := configStore.Read(context.Background(), result.Results[1].ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.SchemeID).To(Equal(result.Results[0].ID))
+			Expect(cfg.Data).To(MatchJSON(`{"a": 3}`))
+
+			_, err = configStore.Read(context.Background(), result.Results[2].ID)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should roll back whole batch when one operation fails", func() {
+			buf.WriteString(`{"operations": [
+				{"op": "create", "kind": "scheme", "ref": "s", "slug": "batch-rollback", "tags": ["batch"], "data": {}},
+				{"op": "create", "kind": "config", "scheme_ref": "s", "tags": ["batch"], "data": {}},
+				{"op": "update", "kind": "config", "id": 10000000, "tags": ["batch"], "data": {}}
+			]}`)
+
+			ctx, res := createContext(e, buf)
+
+			err := batch(txStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Code).To(BeEquivalentTo(http.StatusNotFound))
+
+			var result batchResponse
+
+			err = json.NewDecoder(res.Body).Decode(&result)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Applied).To(BeFalse())
+			Expect(result.Results).To(HaveLen(3))
+			Expect(result.Results[2].Error).NotTo(BeEmpty())
+
+			_, err = schemeStore.ReadBySlug(context.Background(), "batch-rollback")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Watch route", func() {
+		It("should restore deleted scheme and stream its changes from Last-Event-ID", func() {
+			var scheme = store.Scheme{
+				Tags: []string{"watch"},
+				Data: json.RawMessage(`{"hello":"world"}`),
+			}
+
+			err := schemeStore.Create(context.Background(), &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = schemeStore.Delete(context.Background(), scheme.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx, res := createContext(e, nil)
+			setParams(ctx, Params{"id": strconv.FormatInt(scheme.ID, 10)})
+
+			err = restoreScheme(schemeStore)(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Code).To(BeEquivalentTo(http.StatusOK))
+
+			// already restored:
+			ctx, _ = createContext(e, nil)
+			setParams(ctx, Params{"id": strconv.FormatInt(scheme.ID, 10)})
+
+			err = restoreScheme(schemeStore)(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusNotFound))
+
+			feedCtx, stop := context.WithCancel(context.Background())
+			defer stop()
+
+			reqCtx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			req := httptest.NewRequest(echo.GET, "/?scheme_id="+strconv.FormatInt(scheme.ID, 10), nil).WithContext(reqCtx)
+			req.Header.Set("Last-Event-ID", "0-0")
+			rec := httptest.NewRecorder()
+
+			err = watch(store.NewChangeStore(db), store.NewFeed(feedCtx, db, zap.NewNop()), zap.NewNop())(e.NewContext(req, rec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal("text/event-stream"))
+
+			body := rec.Body.String()
+			Expect(body).To(ContainSubstring("event: create"))
+			Expect(body).To(ContainSubstring("event: delete"))
+			Expect(body).To(ContainSubstring("event: restore"))
+		})
+	})
+
+	Context("Changes route", func() {
+		It("should return changes since cursor and long-poll until timeout", func() {
+			var scheme = store.Scheme{
+				Tags: []string{"changes"},
+				Data: json.RawMessage(`{"hello":"world"}`),
+			}
+
+			err := schemeStore.Create(context.Background(), &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = schemeStore.Update(context.Background(), &store.Scheme{
+				ID:   scheme.ID,
+				Tags: []string{"changes"},
+				Data: json.RawMessage(`{"hello":"changes"}`),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			feedCtx, stop := context.WithCancel(context.Background())
+			defer stop()
+
+			handler := listChanges(store.NewChangeStore(db), store.NewFeed(feedCtx, db, zap.NewNop()))
+
+			query := "/?kind=scheme&scheme_id=" + strconv.FormatInt(scheme.ID, 10)
+			rec := httptest.NewRecorder()
+
+			err = handler(e.NewContext(httptest.NewRequest(echo.GET, query+"&limit=1", nil), rec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Code).To(BeEquivalentTo(http.StatusOK))
+
+			var first changesResponse
+
+			err = json.NewDecoder(rec.Body).Decode(&first)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Items).To(HaveLen(1))
+			Expect(first.Items[0].Op).To(Equal("create"))
+			Expect(first.Cursor).NotTo(BeEmpty())
+
+			rec = httptest.NewRecorder()
+
+			err = handler(e.NewContext(httptest.NewRequest(echo.GET, query+"&since="+first.Cursor, nil), rec))
+			Expect(err).NotTo(HaveOccurred())
+
+			var second changesResponse
+
+			err = json.NewDecoder(rec.Body).Decode(&second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second.Items).To(HaveLen(1))
+			Expect(second.Items[0].Op).To(Equal("update"))
+
+			start := time.Now()
+			rec = httptest.NewRecorder()
+
+			err = handler(e.NewContext(httptest.NewRequest(echo.GET, query+"&wait=300ms&since="+second.Cursor, nil), rec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+
+			var third changesResponse
+
+			err = json.NewDecoder(rec.Body).Decode(&third)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(third.Items).To(BeEmpty())
+			Expect(third.Cursor).To(Equal(second.Cursor))
+		})
+
+		It("should fail with 400 on invalid cursor", func() {
+			rec := httptest.NewRecorder()
+
+			err := listChanges(store.NewChangeStore(db), nil)(e.NewContext(httptest.NewRequest(echo.GET, "/?since=abc", nil), rec))
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusBadRequest))
+		})
+	})
+
+	Context("Webhooks route", func() {
+		It("should create webhook and list its deliveries", func() {
+			keys, err := secrets.New("", map[string][]byte{"test": make([]byte, 32)})
+			Expect(err).NotTo(HaveOccurred())
+
+			hooks := store.NewWebhookStore(db, keys)
+
+			ctx, rec := createContext(e, bytes.NewBufferString(`{"url":"http://localhost/hook","secret":"hook-secret","events":["update"]}`))
+			Expect(createWebhook(hooks)(ctx)).NotTo(HaveOccurred())
+			Expect(rec.Code).To(BeEquivalentTo(http.StatusCreated))
+			Expect(rec.Body.String()).NotTo(ContainSubstring("secret"))
+
+			var hook store.Webhook
+
+			err = json.NewDecoder(rec.Body).Decode(&hook)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hook.ID).NotTo(BeZero())
+			Expect(hook.Events).To(Equal([]string{"update"}))
+
+			var stored string
+			_, err = db.QueryOne(pg.Scan(&stored), "SELECT secret FROM webhooks WHERE id = ?", hook.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secrets.IsSealed(stored)).To(BeTrue())
+
+			rec = httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(hook.ID, 10)})
+
+			Expect(getWebhook(hooks)(ctx)).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).NotTo(ContainSubstring("secret"))
+
+			rec = httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+
+			Expect(listWebhooks(hooks)(ctx)).NotTo(HaveOccurred())
+			Expect(rec.Body.String()).NotTo(ContainSubstring("secret"))
+
+			read, err := hooks.Read(context.Background(), hook.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read.Secret).To(BeEmpty())
+
+			rec = httptest.NewRecorder()
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/?status=pending", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(hook.ID, 10)})
+
+			Expect(listDeliveries(hooks)(ctx)).NotTo(HaveOccurred())
+			Expect(rec.Code).To(BeEquivalentTo(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchJSON(`{"total":0,"offset":0,"items":[]}`))
+
+			ctx = e.NewContext(httptest.NewRequest(echo.GET, "/", nil), httptest.NewRecorder())
+			setParams(ctx, Params{"id": "100500"})
+
+			err = listDeliveries(hooks)(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusNotFound))
+		})
+
+		It("should fail with 400 on invalid webhook", func() {
+			ctx, _ := createContext(e, bytes.NewBufferString(`{"url":"not url","secret":"secret","events":["drop"]}`))
+
+			err := createWebhook(store.NewWebhookStore(db, nil))(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusBadRequest))
+		})
+
+		It("should fail with 422 on webhook without keys", func() {
+			ctx, _ := createContext(e, bytes.NewBufferString(`{"url":"http://localhost/hook","secret":"secret"}`))
+
+			err := createWebhook(store.NewWebhookStore(db, nil))(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusUnprocessableEntity))
+		})
+	})
+
+	Context("History route", func() {
+		It("should return versions of scheme, latest first", func() {
+			var scheme = store.Scheme{
+				Tags: []string{"history"},
+				Data: json.RawMessage(`{"version":1}`),
+			}
+
+			err := schemeStore.Create(context.Background(), &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = schemeStore.Update(context.Background(), &store.Scheme{
+				ID:   scheme.ID,
+				Tags: []string{"history"},
+				Data: json.RawMessage(`{"version":2}`),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/?limit=1&offset=1", nil), rec)
+			setParams(ctx, Params{"id": strconv.FormatInt(scheme.ID, 10)})
+
+			Expect(schemeHistory(schemeStore)(ctx)).NotTo(HaveOccurred())
+			Expect(rec.Code).To(BeEquivalentTo(http.StatusOK))
+
+			var res struct {
+				Total int             `json:"total"`
+				Items []*store.Scheme `json:"items"`
+			}
+
+			err = json.NewDecoder(rec.Body).Decode(&res)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Total).To(Equal(2))
+			Expect(res.Items).To(HaveLen(1))
+			Expect(res.Items[0].Version).To(BeEquivalentTo(1))
+			Expect(res.Items[0].Data).To(MatchJSON(`{"version":1}`))
+		})
+	})
+
+	Context("Admin routes", func() {
+		It("should export archive and reject it as conflicting on import", func() {
+			var (
+				archive = store.NewArchive(db)
+				scheme  = store.Scheme{Slug: "admin-export", Tags: []string{"admin"}, Data: json.RawMessage(`{}`)}
+			)
+
+			err := schemeStore.Create(context.Background(), &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(echo.GET, "/", nil), rec)
+
+			Expect(exportArchive(archive, zap.NewNop())(ctx)).To(Succeed())
+			Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal(mimeNDJSON))
+			Expect(rec.Body.String()).To(ContainSubstring(`"slug":"admin-export"`))
+			Expect(rec.Body.String()).To(HaveSuffix("\n"))
+
+			ctx = e.NewContext(httptest.NewRequest(echo.POST, "/", bytes.NewReader(rec.Body.Bytes())), httptest.NewRecorder())
+
+			err = importArchive(archive)(ctx)
+			Expect(err).To(HaveOccurred())
+
+			herr, ok := err.(*echo.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(herr.Code).To(BeEquivalentTo(http.StatusConflict))
+		})
+
+		It("should fail with 400 on invalid archive or options", func() {
+			for _, target := range []string{"/?conflict=drop", "/?keep_ids=maybe", "/"} {
+				ctx := e.NewContext(httptest.NewRequest(echo.POST, target, bytes.NewBufferString(`not json`)), httptest.NewRecorder())
+
+				err := importArchive(store.NewArchive(db))(ctx)
+				Expect(err).To(HaveOccurred())
+
+				herr, ok := err.(*echo.HTTPError)
+				Expect(ok).To(BeTrue())
+				Expect(herr.Code).To(BeEquivalentTo(http.StatusBadRequest))
+			}
+		})
+	})
 })
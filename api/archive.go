@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	mimeNDJSON = "application/x-ndjson"
+
+	// exportFlush is count of records written between flushes of export
+	exportFlush = 100
+)
+
+// archiveError reports invalid archive with 400 and conflicts of import with 409 status code
+func archiveError(err error) error {
+	switch errors.Cause(err) {
+	case store.ErrInvalidArchive:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case store.ErrImportConflict:
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	return storeError(err)
+}
+
+// exportArchive streams all schemes and configs with every version as NDJSON, see store.Record.
+// Response is already sent when export fails, so failure is only logged
+// and archive without end record is rejected by import.
+func exportArchive(a store.Archive, l *zap.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			count int
+			res   = ctx.Response()
+			enc   = json.NewEncoder(res)
+			name  = fmt.Sprintf("export-%s.ndjson", time.Now().UTC().Format("20060102-150405"))
+		)
+
+		res.Header().Set(echo.HeaderContentType, mimeNDJSON)
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+
+		err := a.Export(ctx.Request().Context(), func(rec *store.Record) error {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+
+			if count++; count%exportFlush == 0 {
+				res.Flush()
+			}
+
+			return nil
+		})
+
+		if err != nil && !res.Committed {
+			return storeError(err)
+		} else if err != nil {
+			l.Error("could not export archive", zap.Int("records", count), zap.Error(err))
+		}
+
+		return nil
+	}
+}
+
+// importArchive loads archive of export in one transaction:
+//
+//	POST /admin/import/?conflict=fail|skip|merge&keep_ids=true
+//
+// Conflict strategy is applied to entities with slugs that are already used,
+// keep_ids restores entities with ids of archive (e.g. to empty database).
+func importArchive(a store.Archive) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err  error
+			opts = store.ImportOptions{Conflict: ctx.QueryParam("conflict")}
+		)
+
+		switch opts.Conflict {
+		case "", store.ConflictFail, store.ConflictSkip, store.ConflictMerge:
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "conflict should be fail, skip or merge")
+		}
+
+		if value := ctx.QueryParam("keep_ids"); value != "" {
+			if opts.KeepIDs, err = strconv.ParseBool(value); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "keep_ids should be boolean")
+			}
+		}
+
+		result, err := a.Import(ctx.Request().Context(), store.DecodeRecords(ctx.Request().Body), opts)
+		if err != nil {
+			return archiveError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, result)
+	}
+}
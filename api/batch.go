@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/im-kulikov/helium/web"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+)
+
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+
+	kindScheme = "scheme"
+	kindConfig = "config"
+)
+
+type (
+	// batchOperation is one step of batch, entities created by previous steps
+	// could be referenced by `ref` name in `id_ref` / `scheme_ref` fields.
+	batchOperation struct {
+		Op        string          `json:"op" validate:"required,oneof=create update delete"`
+		Kind      string          `json:"kind" validate:"required,oneof=scheme config"`
+		Ref       string          `json:"ref"`
+		ID        int64           `json:"id"`
+		IDRef     string          `json:"id_ref"`
+		SchemeID  int64           `json:"scheme_id"`
+		SchemeRef string          `json:"scheme_ref"`
+		Slug      string          `json:"slug"`
+		Tags      []string        `json:"tags"`
+		Data      json.RawMessage `json:"data"`
+	}
+
+	batchRequest struct {
+		Operations []*batchOperation `json:"operations" validate:"required,min=1,dive" message:"operations could not be empty"`
+	}
+
+	batchResult struct {
+		Index   int    `json:"index"`
+		Op      string `json:"op"`
+		Kind    string `json:"kind"`
+		Ref     string `json:"ref,omitempty"`
+		Status  int    `json:"status"`
+		ID      int64  `json:"id,omitempty"`
+		Version int64  `json:"version,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	batchResponse struct {
+		Applied bool           `json:"applied"`
+		Results []*batchResult `json:"results"`
+	}
+
+	batchRef struct {
+		kind string
+		id   int64
+	}
+
+	// batchState applies operations with stores bound to one transaction
+	batchState struct {
+		ctx      context.Context
+		author   string
+		refs     map[string]batchRef
+		schemes  store.Schemes
+		configs  store.Configs
+		validate func(interface{}) error
+	}
+)
+
+// batch applies all operations in one transaction, when any operation fails
+// whole batch is rolled back and response status is status of failed operation.
+func batch(t store.Transactions) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err    error
+			req    batchRequest
+			failed *batchResult
+			res    batchResponse
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		res.Results = make([]*batchResult, 0, len(req.Operations))
+
+		err = t.RunInTransaction(ctx.Request().Context(), func(s store.Schemes, c store.Configs) error {
+			var b = batchState{
+				ctx:      ctx.Request().Context(),
+				author:   identityOf(ctx),
+				refs:     make(map[string]batchRef, len(req.Operations)),
+				schemes:  s,
+				configs:  c,
+				validate: ctx.Validate,
+			}
+
+			for i, op := range req.Operations {
+				var result = &batchResult{Index: i, Op: op.Op, Kind: op.Kind, Ref: op.Ref}
+
+				res.Results = append(res.Results, result)
+
+				if opErr := b.apply(op, result); opErr != nil {
+					result.Status, result.Error = batchError(opErr)
+					failed = result
+					return opErr
+				}
+			}
+
+			return nil
+		})
+
+		switch {
+		case failed != nil:
+			return ctx.JSON(failed.Status, res)
+		case err != nil:
+			return err
+		}
+
+		res.Applied = true
+
+		return ctx.JSON(http.StatusOK, res)
+	}
+}
+
+// batchError converts error of operation to status code and message
+func batchError(err error) (int, string) {
+	if herr, ok := storeError(err).(*echo.HTTPError); ok {
+		return herr.Code, fmt.Sprint(herr.Message)
+	}
+
+	return http.StatusBadRequest, err.Error()
+}
+
+func (b *batchState) apply(op *batchOperation, result *batchResult) error {
+	var err error
+
+	if op.Ref != "" {
+		if op.Op != batchCreate {
+			return echo.NewHTTPError(http.StatusBadRequest, "ref could be used only with create operation")
+		} else if _, ok := b.refs[op.Ref]; ok {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("ref %q already used", op.Ref))
+		}
+	}
+
+	if op.Op != batchCreate {
+		if op.ID, err = b.resolve(op.ID, op.IDRef, op.Kind, "id"); err != nil {
+			return err
+		}
+	}
+
+	if op.Kind == kindConfig && op.Op == batchCreate {
+		if op.SchemeID, err = b.resolve(op.SchemeID, op.SchemeRef, kindScheme, "scheme_id"); err != nil {
+			return err
+		}
+	}
+
+	switch op.Kind {
+	case kindScheme:
+		err = b.scheme(op, result)
+	case kindConfig:
+		err = b.config(op, result)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if op.Ref != "" {
+		b.refs[op.Ref] = batchRef{kind: op.Kind, id: result.ID}
+	}
+
+	return nil
+}
+
+// resolve returns id or id of entity created by previous operation with ref name
+func (b *batchState) resolve(id int64, ref, kind, field string) (int64, error) {
+	if ref == "" {
+		if id <= 0 {
+			return 0, echo.NewHTTPError(http.StatusBadRequest, field+" could not be empty")
+		}
+
+		return id, nil
+	}
+
+	item, ok := b.refs[ref]
+	if !ok || item.kind != kind {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown %s ref %q", kind, ref))
+	}
+
+	return item.id, nil
+}
+
+func (b *batchState) scheme(op *batchOperation, result *batchResult) error {
+	var model = &store.Scheme{
+		ID:     op.ID,
+		Tags:   op.Tags,
+		Data:   op.Data,
+		Author: b.author,
+		Slug:   op.Slug,
+	}
+
+	result.ID = op.ID
+
+	switch op.Op {
+	case batchDelete:
+		result.Status = http.StatusOK
+		return b.schemes.Delete(b.ctx, op.ID)
+	case batchCreate:
+		result.Status = http.StatusCreated
+	case batchUpdate:
+		result.Status = http.StatusOK
+	}
+
+	if err := b.check(model); err != nil {
+		return err
+	}
+
+	if op.Op == batchCreate {
+		if err := b.schemes.Create(b.ctx, model); err != nil {
+			return err
+		}
+	} else if err := b.schemes.Update(b.ctx, model); err != nil {
+		return err
+	}
+
+	result.ID, result.Version = model.ID, model.Version
+
+	return nil
+}
+
+func (b *batchState) config(op *batchOperation, result *batchResult) error {
+	var model = &store.Config{
+		ID:       op.ID,
+		SchemeID: op.SchemeID,
+		Tags:     op.Tags,
+		Data:     op.Data,
+		Author:   b.author,
+		Slug:     op.Slug,
+	}
+
+	result.ID = op.ID
+
+	switch op.Op {
+	case batchDelete:
+		result.Status = http.StatusOK
+		return b.configs.Delete(b.ctx, op.ID)
+	case batchCreate:
+		result.Status = http.StatusCreated
+	case batchUpdate:
+		result.Status = http.StatusOK
+	}
+
+	// scheme of config could not be changed by update, store takes it from current version:
+	if op.Op == batchCreate {
+		if err := b.check(model); err != nil {
+			return err
+		}
+
+		if err := b.configs.Create(b.ctx, model); err != nil {
+			return err
+		}
+	} else if err := b.check(&updateRequest{ID: op.ID, Tags: op.Tags, Data: op.Data}); err != nil {
+		return err
+	} else if err := b.configs.Update(b.ctx, model); err != nil {
+		return err
+	}
+
+	result.ID, result.Version = model.ID, model.Version
+
+	return nil
+}
+
+// check validates payload of operation like binder does for single requests
+func (b *batchState) check(model interface{}) error {
+	if err := b.validate(model); err != nil {
+		if ok, verr := web.CheckErrors(web.ValidateParams{Struct: model, Errors: err}); ok {
+			return verr
+		}
+
+		return err
+	}
+
+	return nil
+}
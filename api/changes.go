@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+)
+
+// maxChangesWait limits long-poll of changes
+const maxChangesWait = time.Minute
+
+type (
+	// changesFilter is common filter of watch and changes routes
+	changesFilter struct {
+		Kind     string   `query:"kind" validate:"omitempty,oneof=scheme config" message:"kind should be scheme or config"`
+		IDs      []int64  `query:"ids"`
+		SchemeID int64    `query:"scheme_id"`
+		Tags     []string `query:"tags"`
+	}
+
+	changesRequest struct {
+		Since  string `query:"since"`
+		Wait   string `query:"wait"`
+		Limit  int    `query:"limit" validate:"gte=0,lte=1000" message:"limit should be between 0 and 1000"`
+		Filter changesFilter
+	}
+
+	changesResponse struct {
+		Cursor string          `json:"cursor"`
+		Items  []*store.Change `json:"items"`
+	}
+)
+
+func (f changesFilter) request(after store.Cursor) store.ChangesRequest {
+	return store.ChangesRequest{
+		After:    after,
+		Entity:   f.Kind,
+		IDs:      f.IDs,
+		SchemeID: f.SchemeID,
+		Tags:     f.Tags,
+	}
+}
+
+// listChanges returns changes after cursor `since` and cursor of last returned change,
+// with `wait` (for example 30s) blocks until new changes arrive or wait expires.
+func listChanges(c store.Changes, f *store.Feed) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err    error
+			req    changesRequest
+			wait   time.Duration
+			after  store.Cursor
+			items  []*store.Change
+			events <-chan struct{}
+			rctx   = ctx.Request().Context()
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if after, err = store.ParseCursor(req.Since); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		if req.Wait != "" {
+			if wait, err = time.ParseDuration(req.Wait); err != nil || wait < 0 || wait > maxChangesWait {
+				return echo.NewHTTPError(http.StatusBadRequest, "wait should be duration up to "+maxChangesWait.String())
+			}
+		}
+
+		sreq := req.Filter.request(after)
+		sreq.Limit = req.Limit
+
+		if wait > 0 {
+			var cancel func()
+
+			// subscribe before first read, changes between read and wait are not lost:
+			events, cancel = f.Subscribe()
+			defer cancel()
+		}
+
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+
+	poll:
+		for {
+			if items, err = c.Since(rctx, sreq); err != nil {
+				return storeError(err)
+			}
+
+			if len(items) > 0 || wait == 0 {
+				break
+			}
+
+			select {
+			case <-events:
+			case <-timeout.C:
+				break poll
+			case <-rctx.Done():
+				return storeError(rctx.Err())
+			}
+		}
+
+		if len(items) > 0 {
+			after = items[len(items)-1].Cursor()
+		} else {
+			items = []*store.Change{}
+		}
+
+		return ctx.JSON(http.StatusOK, changesResponse{
+			Cursor: after.String(),
+			Items:  items,
+		})
+	}
+}
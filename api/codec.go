@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/codec"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/pkg/errors"
+)
+
+// negotiate converts YAML and TOML bodies of requests (by Content-Type) to JSON
+// and JSON responses to format of `?format=json|yaml|toml` or Accept header.
+// Streams (flushed responses, SSE and NDJSON) and requests of skipper are sent as is.
+func negotiate(skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if skipper(ctx) {
+				return next(ctx)
+			}
+
+			var (
+				req    = ctx.Request()
+				res    = ctx.Response()
+				format = ctx.QueryParam("format")
+			)
+
+			if format == "" {
+				format = codec.Negotiate(req.Header.Get(echo.HeaderAccept))
+			} else if !codec.Valid(format) {
+				return echo.NewHTTPError(http.StatusBadRequest, "format should be json, yaml or toml")
+			}
+
+			if err := decodeBody(req); err != nil {
+				return err
+			}
+
+			if format == codec.JSON {
+				return next(ctx)
+			}
+
+			w := &bufferedWriter{ResponseWriter: res.Writer, status: http.StatusOK}
+			res.Writer = w
+
+			defer func() { res.Writer = w.ResponseWriter }()
+
+			// error response should be converted too:
+			if err := next(ctx); err != nil {
+				ctx.Error(err)
+			}
+
+			if w.stream {
+				return nil
+			}
+
+			return w.encode(format)
+		}
+	}
+}
+
+// decodeBody replaces YAML or TOML body of request with JSON
+func decodeBody(req *http.Request) error {
+	format, ok := codec.FromContentType(req.Header.Get(echo.HeaderContentType))
+	if !ok || format == codec.JSON || req.Body == nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return errors.Wrap(err, "could not read body")
+	}
+
+	_ = req.Body.Close()
+
+	if data, err = codec.Decode(format, data); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	req.Header.Set(echo.HeaderContentLength, strconv.Itoa(len(data)))
+
+	return nil
+}
+
+// bufferedWriter keeps JSON response to convert it,
+// other responses and flushed ones are written through
+type bufferedWriter struct {
+	http.ResponseWriter
+
+	buf    bytes.Buffer
+	status int
+	header bool // WriteHeader called
+	stream bool // written through
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status, w.header = code, true
+
+	if !strings.HasPrefix(w.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		w.passthrough()
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	if w.stream {
+		return w.ResponseWriter.Write(data)
+	}
+
+	return w.buf.Write(data)
+}
+
+func (w *bufferedWriter) Flush() {
+	w.passthrough()
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.stream = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// passthrough writes header and buffered data, following writes are written through
+func (w *bufferedWriter) passthrough() {
+	if w.stream {
+		return
+	}
+
+	w.stream = true
+
+	if w.header {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+// encode converts buffered JSON to format, responds with 406 status code
+// when response could not be represented in format (e.g. null in TOML)
+func (w *bufferedWriter) encode(format string) error {
+	var (
+		err    error
+		data   = w.buf.Bytes()
+		status = w.status
+		header = w.ResponseWriter.Header()
+	)
+
+	header.Add(echo.HeaderVary, echo.HeaderAccept)
+
+	if len(data) > 0 {
+		if data, err = codec.Encode(format, data); err != nil {
+			status = http.StatusNotAcceptable
+			data, _ = json.Marshal(echo.Map{"message": err.Error()})
+			format = codec.JSON
+		}
+
+		header.Set(echo.HeaderContentType, codec.ContentType(format))
+		header.Set(echo.HeaderContentLength, strconv.Itoa(len(data)))
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+	_, err = w.ResponseWriter.Write(data)
+
+	return err
+}
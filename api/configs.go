@@ -3,10 +3,9 @@ package api
 import (
 	"net/http"
 
-	"github.com/go-pg/pg"
+	"github.com/im-kulikov/simplinic-task/interpolate"
 	"github.com/im-kulikov/simplinic-task/store"
 	"github.com/labstack/echo"
-	"github.com/pkg/errors"
 )
 
 func createConfig(s store.Configs) echo.HandlerFunc {
@@ -17,8 +16,11 @@ func createConfig(s store.Configs) echo.HandlerFunc {
 			return err
 		}
 
-		if err := s.Create(&model); err != nil {
-			return err
+		// author is identity of client, not author claimed in body:
+		model.Author = identityOf(ctx)
+
+		if err := s.Create(ctx.Request().Context(), &model); err != nil {
+			return storeError(err)
 		}
 
 		return ctx.JSON(http.StatusCreated, model)
@@ -29,36 +31,78 @@ func listConfigs(s store.Configs) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		var (
 			err    error
+			total  int
 			req    searchRequest
+			sreq   store.SearchRequest
 			models []*store.Config
-			result searchResponse
+			items  []interface{}
 		)
 
 		if err = ctx.Bind(&req); err != nil {
 			return err
 		}
 
-		if models, err = s.Search(store.SearchRequest{
-			Version: req.Version,
-			Tags:    req.Tags,
-		}); err != nil {
+		if sreq, err = req.storeRequest(); err != nil {
 			return err
 		}
 
+		if models, total, err = s.Search(ctx.Request().Context(), sreq); err != nil {
+			return storeError(err)
+		}
+
 		for _, item := range models {
-			result.Total++ // or result.Total = len(models)
-			result.Items = append(result.Items, item)
+			items = append(items, item)
 		}
 
-		return ctx.JSON(http.StatusOK, result)
+		return ctx.JSON(http.StatusOK, req.response(total, items))
 	}
 }
 
-func getConfig(s store.Configs) echo.HandlerFunc {
+func listSchemeConfigs(s store.Schemes, c store.Configs) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err    error
+			total  int
+			req    schemeConfigsRequest
+			sreq   store.SearchRequest
+			models []*store.Config
+			items  []interface{}
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if sreq, err = req.Search.storeRequest(); err != nil {
+			return err
+		}
+
+		if _, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		sreq.SchemeID = req.ID
+
+		if models, total, err = c.Search(ctx.Request().Context(), sreq); err != nil {
+			return storeError(err)
+		}
+
+		for _, item := range models {
+			items = append(items, item)
+		}
+
+		return ctx.JSON(http.StatusOK, req.Search.response(total, items))
+	}
+}
+
+// getConfig returns effective latest version of config: references of data are
+// replaced by referenced values (`?raw=true` returns data as it is stored), with
+// `?materialize=true` data is completed by scheme, see schema.Materialize
+func getConfig(sc store.Schemes, s store.Configs, vars interpolate.Variables) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		var (
 			err   error
-			req   idRequest
+			req   resolveRequest
 			model *store.Config
 		)
 
@@ -66,14 +110,24 @@ func getConfig(s store.Configs) echo.HandlerFunc {
 			return err
 		}
 
-		if model, err = s.Read(req.ID); err != nil {
-			if errors.Cause(err) == pg.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		if !req.Raw {
+			if err = resolve(ctx.Request().Context(), s, vars, model); err != nil {
+				return storeError(err)
 			}
+		}
 
-			return err
+		if req.Materialize {
+			if err = materialize(ctx.Request().Context(), sc, model); err != nil {
+				return err
+			}
 		}
 
+		staleHeaders(ctx, model.Stale)
+
 		return ctx.JSON(http.StatusOK, model)
 	}
 }
@@ -96,14 +150,11 @@ func updateConfig(s store.Configs) echo.HandlerFunc {
 			Version:  req.Version,
 			Tags:     req.Tags,
 			Data:     req.Data,
+			Author:   identityOf(ctx),
 		}
 
-		if err = s.Update(model); err != nil {
-			if errors.Cause(err) == pg.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
-			}
-
-			return err
+		if err = s.Update(ctx.Request().Context(), model); err != nil {
+			return storeError(err)
 		}
 
 		return ctx.JSON(http.StatusOK, model)
@@ -121,14 +172,119 @@ func deleteConfig(s store.Configs) echo.HandlerFunc {
 			return err
 		}
 
-		if err = s.Delete(req.ID); err != nil {
-			if errors.Cause(err) == pg.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
+		if err = s.Delete(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, "")
+	}
+}
+
+func restoreConfig(s store.Configs) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   idRequest
+			model *store.Config
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if err = s.Restore(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+func getConfigBySlug(sc store.Schemes, s store.Configs, vars interpolate.Variables) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   resolveSlugRequest
+			model *store.Config
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if model, err = s.ReadBySlug(ctx.Request().Context(), req.Scheme, req.Slug); err != nil {
+			return storeError(err)
+		}
+
+		if !req.Raw {
+			if err = resolve(ctx.Request().Context(), s, vars, model); err != nil {
+				return storeError(err)
 			}
+		}
+
+		if req.Materialize {
+			if err = materialize(ctx.Request().Context(), sc, model); err != nil {
+				return err
+			}
+		}
+
+		staleHeaders(ctx, model.Stale)
 
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+func renameConfig(s store.Configs) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   renameRequest
+			model *store.Config
+		)
+
+		if err = ctx.Bind(&req); err != nil {
 			return err
 		}
 
-		return ctx.JSON(http.StatusOK, "")
+		if err = s.Rename(ctx.Request().Context(), req.ID, req.Slug); err != nil {
+			return storeError(err)
+		}
+
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+// configHistory returns versions of config, latest first
+func configHistory(s store.Configs) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err    error
+			total  int
+			req    historyRequest
+			models []*store.Config
+			items  = []interface{}{}
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if models, total, err = s.History(ctx.Request().Context(), req.ID, req.Limit, req.Offset); err != nil {
+			return storeError(err)
+		}
+
+		for _, item := range models {
+			items = append(items, item)
+		}
+
+		return ctx.JSON(http.StatusOK, req.response(total, items))
 	}
 }
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/gitops"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+type gitopsRequest struct {
+	Resources []*gitops.Resource `json:"resources" validate:"required" message:"resources could not be empty"`
+	Prune     bool               `json:"prune"`
+}
+
+// options of plan, author of new versions is identity of client
+func (req gitopsRequest) options(ctx echo.Context) gitops.Options {
+	return gitops.Options{Prune: req.Prune, Author: identityOf(ctx)}
+}
+
+// gitopsError reports invalid resources with 400 status code
+func gitopsError(err error) error {
+	if gerr, ok := errors.Cause(err).(*gitops.Error); ok {
+		return echo.NewHTTPError(http.StatusBadRequest, gerr.Error())
+	}
+
+	return storeError(err)
+}
+
+// gitopsPlan shows changes that are needed to bring schemes and configs to state of resources
+func gitopsPlan(s store.Schemes, c store.Configs) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var req gitopsRequest
+
+		if err := ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		plan, err := gitops.NewPlan(ctx.Request().Context(), s, c, req.Resources, req.options(ctx))
+		if err != nil {
+			return gitopsError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, plan)
+	}
+}
+
+// gitopsApply plans and applies changes in one transaction
+func gitopsApply(t store.Transactions) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var req gitopsRequest
+
+		if err := ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		plan, err := gitops.Apply(ctx.Request().Context(), t, req.Resources, req.options(ctx))
+		if err != nil {
+			return gitopsError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, plan)
+	}
+}
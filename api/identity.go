@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// identityKey of echo context, see identityOf
+const identityKey = "identity"
+
+// newIdentity reads `api.identity` of settings and returns middleware that
+// authenticates clients, see identify:
+//
+//	api:
+//	  identity:
+//	    header: X-Remote-User      # set by authenticating proxy
+//	    proxies: [127.0.0.1/32]    # networks of trusted proxies
+func newIdentity(v *viper.Viper) (echo.MiddlewareFunc, error) {
+	var proxies []*net.IPNet
+
+	for _, item := range v.GetStringSlice("api.identity.proxies") {
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network of trusted proxy %q", item)
+		}
+
+		proxies = append(proxies, network)
+	}
+
+	return identify(v.GetString("api.identity.header"), proxies), nil
+}
+
+// identify stores identity of client in context, identity is taken only from
+// authenticated sources:
+//   - common name of subject of verified client certificate (mTLS)
+//   - header set by trusted proxy, header of other clients is ignored
+//
+// Identity of anonymous client is empty.
+func identify(header string, proxies []*net.IPNet) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			var req = ctx.Request()
+
+			if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+				ctx.Set(identityKey, req.TLS.VerifiedChains[0][0].Subject.CommonName)
+			} else if name := req.Header.Get(header); header != "" && name != "" && trusted(req, proxies) {
+				ctx.Set(identityKey, name)
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// trusted checks that request is sent by trusted proxy, remote address
+// is used as is, because forwarded headers could be set by client
+func trusted(req *http.Request, proxies []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+
+	for _, network := range proxies {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// identityOf returns identity of authenticated client, empty for anonymous client
+func identityOf(ctx echo.Context) string {
+	name, _ := ctx.Get(identityKey).(string)
+	return name
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/im-kulikov/simplinic-task/filter"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/k8s"
+	"github.com/im-kulikov/simplinic-task/render"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+type manifestsRequest struct {
+	Tags        []string `query:"tags"`
+	Filter      string   `query:"filter"`
+	Environment string   `query:"environment"`
+	SchemeID    int64    `query:"scheme_id"`
+	Namespace   string   `query:"namespace"`
+	HashSuffix  bool     `query:"hash_suffix"`
+	Separator   string   `query:"separator"`
+	Case        string   `query:"case" validate:"omitempty,oneof=keep upper lower" message:"case should be keep, upper or lower"`
+	Prefix      string   `query:"prefix"`
+	Raw         bool     `query:"raw"`
+}
+
+// storeRequest selects configs by tags, filter and scheme,
+// environment is matched with `environment` field of data
+func (req manifestsRequest) storeRequest() (store.SearchRequest, error) {
+	var (
+		err    error
+		result = store.SearchRequest{Tags: req.Tags, SchemeID: req.SchemeID}
+	)
+
+	if req.Filter != "" {
+		if result.Filter, err = filter.Parse(req.Filter); err != nil {
+			return result, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	if req.Environment == "" {
+		return result, nil
+	}
+
+	env := &filter.Compare{
+		Field: filter.Field{Name: "data", Path: []string{"environment"}},
+		Op:    filter.EQ,
+		Value: filter.Value{Kind: filter.KindString, Raw: strconv.Quote(req.Environment), Str: req.Environment},
+	}
+
+	if result.Filter == nil {
+		result.Filter = env
+	} else {
+		result.Filter = &filter.Logical{Op: filter.AND, Left: env, Right: result.Filter}
+	}
+
+	return result, nil
+}
+
+// options of manifests, secrets are revealed to client of request
+func (req manifestsRequest) options(ctx echo.Context, vars interpolate.Variables) k8s.Options {
+	return k8s.Options{
+		Namespace:   req.Namespace,
+		Environment: req.Environment,
+		HashSuffix:  req.HashSuffix,
+		Keys:        render.Options{Separator: req.Separator, Case: req.Case, Prefix: req.Prefix},
+		Audit:       revealAudit(ctx, "manifests"),
+		Raw:         req.Raw,
+		Variables:   vars,
+	}
+}
+
+// manifestsError reports missing selector with 400, secrets requested by anonymous
+// client with 401 and data that could not be flattened with 422 status code
+func manifestsError(err error) error {
+	switch errors.Cause(err) {
+	case k8s.ErrEmptySelector:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case k8s.ErrNoActor:
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	case render.ErrInvalidData, render.ErrInvalidKey, render.ErrKeyConflict, render.ErrInvalidOptions:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return storeError(err)
+}
+
+// manifests returns Kubernetes List of ConfigMap and Secret manifests of latest
+// versions of selected configs, `?format=yaml` could be applied with kubectl:
+//
+//	GET /manifests/?environment=stage&tags=billing&namespace=prod&hash_suffix=true
+//
+// References of configs are resolved unless `?raw=true`.
+func manifests(s store.Schemes, c store.Configs, vars interpolate.Variables) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err  error
+			req  manifestsRequest
+			sreq store.SearchRequest
+			list *k8s.List
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if sreq, err = req.storeRequest(); err != nil {
+			return err
+		}
+
+		if list, err = k8s.Load(ctx.Request().Context(), s, c, sreq, req.options(ctx, vars)); err != nil {
+			return manifestsError(err)
+		}
+
+		ctx.Response().Header().Set("Cache-Control", "no-store")
+
+		return ctx.JSON(http.StatusOK, list)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+)
+
+// materialize fills defaults of scheme of config, strips properties that are not
+// allowed and coerces types, latest version of scheme is applied
+func materialize(ctx context.Context, s store.Schemes, model *store.Config) error {
+	scheme, err := s.Read(ctx, model.SchemeID)
+	if err != nil {
+		return storeError(err)
+	}
+
+	data, err := schema.Materialize(scheme.Data, model.Data)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "could not materialize config: "+err.Error())
+	}
+
+	model.Data = data
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+)
+
+type (
+	// resolveRequest of effective config: references are resolved
+	// unless `?raw=true`, which returns config as it is stored
+	resolveRequest struct {
+		ID          int64 `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+		Raw         bool  `query:"raw"`
+		Materialize bool  `query:"materialize"`
+	}
+
+	resolveSlugRequest struct {
+		Scheme      string `param:"scheme" validate:"required" message:"scheme slug could not be empty"`
+		Slug        string `param:"slug" validate:"required" message:"slug could not be empty"`
+		Raw         bool   `query:"raw"`
+		Materialize bool   `query:"materialize"`
+	}
+)
+
+// newVariables reads `variables` of settings, referenced as `${env.name}`
+func newVariables(v *viper.Viper) interpolate.Variables {
+	return v.GetStringMapString("variables")
+}
+
+// resolve replaces references of config data by referenced values
+func resolve(ctx context.Context, s store.Configs, vars interpolate.Variables, model *store.Config) error {
+	data, err := store.NewResolver(ctx, s, vars).Resolve(model.ID, model.Data)
+	if err != nil {
+		return err
+	}
+
+	model.Data = data
+
+	return nil
+}
+
+// configDependents returns references of other configs to config
+func configDependents(s store.Configs) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err  error
+			req  idRequest
+			refs []*store.Reference
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if refs, err = s.Dependents(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		if refs == nil {
+			refs = []*store.Reference{}
+		}
+
+		return ctx.JSON(http.StatusOK, refs)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/render"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+)
+
+// renderPath is not negotiated, format of query is format of rendered config
+const renderPath = "/configs/:id/render/"
+
+type renderRequest struct {
+	ID        int64  `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+	Format    string `query:"format" validate:"required,oneof=dotenv properties ini hcl" message:"format should be dotenv, properties, ini or hcl"`
+	Separator string `query:"separator"`
+	Case      string `query:"case" validate:"omitempty,oneof=keep upper lower" message:"case should be keep, upper or lower"`
+	Prefix    string `query:"prefix"`
+	Raw       bool   `query:"raw"`
+}
+
+// renderConfig writes flattened data of latest version of config for consumers
+// that read env files, Java properties, INI or HCL:
+//
+//	GET /configs/:id/render/?format=dotenv|properties|ini|hcl&separator=__&case=upper&prefix=APP_
+//
+// Keys that could not be represented in format (or are the same after flattening)
+// are reported with 422 status code, see render.Render. Secret fields are revealed
+// and audited, see revealSecrets. References are resolved unless `?raw=true`.
+func renderConfig(sc store.Schemes, s store.Configs, vars interpolate.Variables) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   renderRequest
+			data  []byte
+			model *store.Config
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		} else if model, err = revealSecrets(ctx, sc, s, model, "render"); err != nil {
+			return err
+		}
+
+		if !req.Raw {
+			if err = resolve(ctx.Request().Context(), s, vars, model); err != nil {
+				return storeError(err)
+			}
+		}
+
+		opts := render.Options{Separator: req.Separator, Case: req.Case, Prefix: req.Prefix}
+		if data, err = render.Render(req.Format, model.Data, opts); err != nil {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
+
+		staleHeaders(ctx, model.Stale)
+
+		return ctx.Blob(http.StatusOK, render.ContentType(req.Format), data)
+	}
+}
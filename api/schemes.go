@@ -3,10 +3,8 @@ package api
 import (
 	"net/http"
 
-	"github.com/go-pg/pg"
 	"github.com/im-kulikov/simplinic-task/store"
 	"github.com/labstack/echo"
-	"github.com/pkg/errors"
 )
 
 func createScheme(s store.Schemes) echo.HandlerFunc {
@@ -17,8 +15,11 @@ func createScheme(s store.Schemes) echo.HandlerFunc {
 			return err
 		}
 
-		if err := s.Create(&model); err != nil {
-			return err
+		// author is identity of client, not author claimed in body:
+		model.Author = identityOf(ctx)
+
+		if err := s.Create(ctx.Request().Context(), &model); err != nil {
+			return storeError(err)
 		}
 
 		return ctx.JSON(http.StatusCreated, model)
@@ -29,28 +30,30 @@ func listSchemes(s store.Schemes) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		var (
 			err    error
+			total  int
 			req    searchRequest
+			sreq   store.SearchRequest
 			models []*store.Scheme
-			result searchResponse
+			items  []interface{}
 		)
 
 		if err = ctx.Bind(&req); err != nil {
 			return err
 		}
 
-		if models, err = s.Search(store.SearchRequest{
-			Version: req.Version,
-			Tags:    req.Tags,
-		}); err != nil {
+		if sreq, err = req.storeRequest(); err != nil {
 			return err
 		}
 
+		if models, total, err = s.Search(ctx.Request().Context(), sreq); err != nil {
+			return storeError(err)
+		}
+
 		for _, item := range models {
-			result.Total++ // or result.Total = len(models)
-			result.Items = append(result.Items, item)
+			items = append(items, item)
 		}
 
-		return ctx.JSON(http.StatusOK, result)
+		return ctx.JSON(http.StatusOK, req.response(total, items))
 	}
 }
 
@@ -66,14 +69,12 @@ func getScheme(s store.Schemes) echo.HandlerFunc {
 			return err
 		}
 
-		if model, err = s.Read(req.ID); err != nil {
-			if errors.Cause(err) == pg.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
-			}
-
-			return err
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
 		}
 
+		staleHeaders(ctx, model.Stale)
+
 		return ctx.JSON(http.StatusOK, model)
 	}
 }
@@ -95,14 +96,11 @@ func updateScheme(s store.Schemes) echo.HandlerFunc {
 			Version: req.Version,
 			Tags:    req.Tags,
 			Data:    req.Data,
+			Author:  identityOf(ctx),
 		}
 
-		if err = s.Update(model); err != nil {
-			if errors.Cause(err) == pg.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
-			}
-
-			return err
+		if err = s.Update(ctx.Request().Context(), model); err != nil {
+			return storeError(err)
 		}
 
 		return ctx.JSON(http.StatusOK, model)
@@ -120,14 +118,107 @@ func deleteScheme(s store.Schemes) echo.HandlerFunc {
 			return err
 		}
 
-		if err = s.Delete(req.ID); err != nil {
-			if errors.Cause(err) == pg.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
-			}
+		if err = s.Delete(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, "")
+	}
+}
+
+func restoreScheme(s store.Schemes) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   idRequest
+			model *store.Scheme
+		)
 
+		if err = ctx.Bind(&req); err != nil {
 			return err
 		}
 
-		return ctx.JSON(http.StatusOK, "")
+		if err = s.Restore(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+func getSchemeBySlug(s store.Schemes) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   slugRequest
+			model *store.Scheme
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if model, err = s.ReadBySlug(ctx.Request().Context(), req.Slug); err != nil {
+			return storeError(err)
+		}
+
+		staleHeaders(ctx, model.Stale)
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+func renameScheme(s store.Schemes) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   renameRequest
+			model *store.Scheme
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if err = s.Rename(ctx.Request().Context(), req.ID, req.Slug); err != nil {
+			return storeError(err)
+		}
+
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+// schemeHistory returns versions of scheme, latest first
+func schemeHistory(s store.Schemes) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err    error
+			total  int
+			req    historyRequest
+			models []*store.Scheme
+			items  = []interface{}{}
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if models, total, err = s.History(ctx.Request().Context(), req.ID, req.Limit, req.Offset); err != nil {
+			return storeError(err)
+		}
+
+		for _, item := range models {
+			items = append(items, item)
+		}
+
+		return ctx.JSON(http.StatusOK, req.response(total, items))
 	}
 }
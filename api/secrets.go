@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type revealRequest struct {
+	ID     int64  `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// revealConfig returns latest version of config with plaintext of secret fields,
+// only authenticated client (see identify) could reveal secrets, every reveal is
+// audited with identity, actor claimed by client, reason and address of client
+func revealConfig(s store.Configs, l *zap.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   revealRequest
+			model *store.Config
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		audit := revealAudit(ctx, req.Reason)
+		if audit.ClaimedActor = req.Actor; audit.Actor == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "secrets could be revealed only by authenticated client")
+		}
+
+		if model, err = s.Reveal(ctx.Request().Context(), req.ID, audit); err != nil {
+			return storeError(err)
+		}
+
+		l.Info("secrets of config revealed",
+			zap.Int64("config", model.ID),
+			zap.Int64("version", model.Version),
+			zap.String("actor", audit.Actor),
+			zap.String("claimed_actor", audit.ClaimedActor),
+			zap.String("reason", audit.Reason),
+			zap.String("address", audit.Address))
+
+		ctx.Response().Header().Set("Cache-Control", "no-store")
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+// revealAudit of reveal by client of request, actor is identity of client
+func revealAudit(ctx echo.Context, reason string) store.Reveal {
+	return store.Reveal{Actor: identityOf(ctx), Reason: reason, Address: ctx.RealIP()}
+}
+
+// revealSecrets returns the same version of config with plaintext of secret fields of
+// its scheme for exports (e.g. renderConfig), secrets are revealed only to authenticated
+// client and audited like revealConfig, config without secret fields is returned as is
+func revealSecrets(ctx echo.Context, sc store.Schemes, s store.Configs, cfg *store.Config, reason string) (*store.Config, error) {
+	scheme, err := sc.Read(ctx.Request().Context(), cfg.SchemeID)
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	paths, err := schema.Secrets(scheme.Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read secrets of scheme #%d", scheme.ID)
+	} else if len(paths) == 0 {
+		return cfg, nil
+	}
+
+	audit := revealAudit(ctx, reason)
+	if audit.Actor == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "config has secrets, they could be exported only by authenticated client")
+	}
+
+	audit.Version = cfg.Version
+
+	revealed, err := s.Reveal(ctx.Request().Context(), cfg.ID, audit)
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	revealed.Stale = cfg.Stale
+
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+
+	return revealed, nil
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+	"go.uber.org/zap"
+)
+
+const (
+	headerLastEventID = "Last-Event-ID"
+
+	// watchHeartbeat keeps connection of idle watcher alive through proxies
+	watchHeartbeat = 15 * time.Second
+
+	// watchRetry is reconnection delay of EventSource in milliseconds
+	watchRetry = 3000
+)
+
+type watchRequest struct {
+	LastEventID string `query:"last_event_id"` // EventSource could not set headers on first connect
+	Filter      changesFilter
+}
+
+// watch streams changes of schemes and configs as Server-Sent Events:
+//
+//	id: 1234-42
+//	event: update
+//	data: {"id":42,"entity":"config","entity_id":3,"scheme_id":1,"version":2,"op":"update",...}
+//
+// Event id is cursor of change, see listChanges.
+// Without Last-Event-ID header (or last_event_id) only new changes are streamed.
+func watch(c store.Changes, f *store.Feed, l *zap.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   watchRequest
+			sreq  store.ChangesRequest
+			items []*store.Change
+			rctx  = ctx.Request().Context()
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		sreq = req.Filter.request(store.Cursor{})
+
+		if last := ctx.Request().Header.Get(headerLastEventID); last != "" {
+			req.LastEventID = last
+		}
+
+		if req.LastEventID == "" {
+			if sreq.After, err = c.Last(rctx); err != nil {
+				return storeError(err)
+			}
+		} else if sreq.After, err = store.ParseCursor(req.LastEventID); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Last-Event-ID should be id of event")
+		}
+
+		events, cancel := f.Subscribe()
+		defer cancel()
+
+		heartbeat := time.NewTicker(watchHeartbeat)
+		defer heartbeat.Stop()
+
+		res := ctx.Response()
+		res.Header().Set(echo.HeaderContentType, "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+
+		if _, err = fmt.Fprintf(res, "retry: %d\n\n", watchRetry); err != nil {
+			return nil
+		}
+
+		for {
+			if items, err = c.Since(rctx, sreq); err != nil {
+				if rctx.Err() == nil {
+					l.Error("could not read changes", zap.Stringer("after", sreq.After), zap.Error(err))
+				}
+
+				// client reconnects with Last-Event-ID:
+				return nil
+			}
+
+			for _, item := range items {
+				data, _ := json.Marshal(item)
+
+				if _, err = fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", item.Cursor(), item.Op, data); err != nil {
+					return nil
+				}
+
+				sreq.After = item.Cursor()
+			}
+
+			res.Flush()
+
+			// there are more changes to read:
+			if len(items) > 0 && len(items) == store.DefaultChangesLimit {
+				continue
+			}
+
+			select {
+			case <-rctx.Done():
+				return nil
+			case <-events:
+			case <-heartbeat.C:
+				if _, err = fmt.Fprint(res, ": ping\n\n"); err != nil {
+					return nil
+				}
+
+				res.Flush()
+			}
+		}
+	}
+}
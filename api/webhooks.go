@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/labstack/echo"
+)
+
+type (
+	webhookRequest struct {
+		URL      string   `json:"url" validate:"required,url" message:"url should be absolute URL"`
+		Secret   string   `json:"secret" validate:"required" message:"secret could not be empty"`
+		Entity   string   `json:"entity" validate:"omitempty,oneof=scheme config" message:"entity should be scheme or config"`
+		SchemeID int64    `json:"scheme_id" validate:"gte=0" message:"scheme_id could not be negative"`
+		Tags     []string `json:"tags"`
+		Events   []string `json:"events" validate:"dive,oneof=create update delete restore" message:"events should be create, update, delete or restore"`
+	}
+
+	deliveriesRequest struct {
+		ID     int64  `param:"id" validate:"required,gt=0" message:"id could not be empty"`
+		Status string `query:"status" validate:"omitempty,oneof=pending delivered dead" message:"status should be pending, delivered or dead"`
+		Limit  int    `query:"limit" validate:"gte=0" message:"limit could not be negative"`
+		Offset int    `query:"offset" validate:"gte=0" message:"offset could not be negative"`
+	}
+)
+
+func createWebhook(s store.Webhooks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var req webhookRequest
+
+		if err := ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		model := store.Webhook{
+			URL:      req.URL,
+			Secret:   req.Secret,
+			Entity:   req.Entity,
+			SchemeID: req.SchemeID,
+			Tags:     req.Tags,
+			Events:   req.Events,
+		}
+
+		if err := s.Create(ctx.Request().Context(), &model); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusCreated, model)
+	}
+}
+
+func listWebhooks(s store.Webhooks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		models, err := s.List(ctx.Request().Context())
+		if err != nil {
+			return storeError(err)
+		}
+
+		if models == nil {
+			models = []*store.Webhook{}
+		}
+
+		return ctx.JSON(http.StatusOK, models)
+	}
+}
+
+func getWebhook(s store.Webhooks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err   error
+			req   idRequest
+			model *store.Webhook
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if model, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, model)
+	}
+}
+
+func deleteWebhook(s store.Webhooks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err error
+			req idRequest
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if err = s.Delete(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		return ctx.JSON(http.StatusOK, "")
+	}
+}
+
+// listDeliveries returns deliveries of webhook with log of attempts, latest first
+func listDeliveries(s store.Webhooks) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		var (
+			err    error
+			total  int
+			req    deliveriesRequest
+			models []*store.Delivery
+			items  = []interface{}{}
+		)
+
+		if err = ctx.Bind(&req); err != nil {
+			return err
+		}
+
+		if _, err = s.Read(ctx.Request().Context(), req.ID); err != nil {
+			return storeError(err)
+		}
+
+		if models, total, err = s.Deliveries(ctx.Request().Context(), store.DeliveriesRequest{
+			WebhookID: req.ID,
+			Status:    req.Status,
+			Limit:     req.Limit,
+			Offset:    req.Offset,
+		}); err != nil {
+			return storeError(err)
+		}
+
+		for _, item := range models {
+			items = append(items, item)
+		}
+
+		return ctx.JSON(http.StatusOK, searchResponse{
+			Total:  total,
+			Offset: req.Offset,
+			Limit:  req.Limit,
+			Items:  items,
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultKeep = 7
+
+	prefix   = "backup-"
+	ext      = ".ndjson.gz"
+	sumExt   = ".sha256"
+	tsLayout = "20060102T150405Z"
+)
+
+// ErrChecksum when snapshot does not match its checksum
+var ErrChecksum = errors.New("checksum of snapshot mismatch")
+
+// Backuper writes snapshots of all schemes and configs with history
+// (see store.Archive) to directory:
+//
+//	backup-20181115T103000Z.ndjson.gz         gzip of NDJSON archive
+//	backup-20181115T103000Z.ndjson.gz.sha256  checksum in `sha256sum` format
+//
+// Checksum is written last, snapshot without it is incomplete and ignored.
+// Only `backup.keep` latest snapshots are kept.
+type Backuper struct {
+	archive store.Archive
+	logger  *zap.Logger
+	dir     string
+	keep    int
+}
+
+var Module = module.Module{
+	{Constructor: NewBackuper},
+}
+
+// NewBackuper of `backup.path` directory, backups are disabled when path is not set,
+// `backup.keep` (7 by default, 0 keeps all) snapshots are kept
+func NewBackuper(v *viper.Viper, a store.Archive, l *zap.Logger) *Backuper {
+	var b = &Backuper{
+		archive: a,
+		logger:  l,
+		dir:     v.GetString("backup.path"),
+		keep:    defaultKeep,
+	}
+
+	if v.IsSet("backup.keep") {
+		b.keep = v.GetInt("backup.keep")
+	}
+
+	return b
+}
+
+// Job writes snapshot and removes snapshots beyond retention
+func (b *Backuper) Job(ctx context.Context) {
+	if b.dir == "" {
+		return
+	}
+
+	path, err := b.Snapshot(ctx)
+	if err != nil {
+		b.logger.Error("could not write backup", zap.String("path", b.dir), zap.Error(err))
+		return
+	}
+
+	b.logger.Info("backup written", zap.String("path", path))
+
+	removed, err := b.Rotate()
+	if err != nil {
+		b.logger.Error("could not rotate backups", zap.String("path", b.dir), zap.Error(err))
+		return
+	}
+
+	for _, item := range removed {
+		b.logger.Info("backup removed", zap.String("path", item))
+	}
+}
+
+// Snapshot writes snapshot to directory, returns path of it
+func (b *Backuper) Snapshot(ctx context.Context) (string, error) {
+	var (
+		name = prefix + time.Now().UTC().Format(tsLayout) + ext
+		path = filepath.Join(b.dir, name)
+		sum  = sha256.New()
+	)
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "could not create %s", b.dir)
+	}
+
+	tmp, err := ioutil.TempFile(b.dir, "."+name)
+	if err != nil {
+		return "", errors.Wrap(err, "could not create snapshot")
+	}
+
+	defer os.Remove(tmp.Name()) // renamed on success
+
+	if err = write(ctx, b.archive, io.MultiWriter(tmp, sum)); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+
+	if err = closeFile(tmp); err != nil {
+		return "", err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return "", errors.Wrapf(err, "could not rename snapshot to %s", path)
+	}
+
+	return path, writeFile(path+sumExt, []byte(hex.EncodeToString(sum.Sum(nil))+"  "+name+"\n"))
+}
+
+// Rotate removes complete snapshots beyond retention, returns paths of removed snapshots
+func (b *Backuper) Rotate() ([]string, error) {
+	items, err := List(b.dir)
+	if err != nil || b.keep <= 0 || len(items) <= b.keep {
+		return nil, err
+	}
+
+	var removed []string
+
+	// latest first:
+	for _, path := range items[b.keep:] {
+		for _, file := range []string{path + sumExt, path} {
+			if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return removed, errors.Wrapf(err, "could not remove %s", file)
+			}
+		}
+
+		removed = append(removed, path)
+	}
+
+	return removed, nil
+}
+
+// List returns paths of complete snapshots of directory, latest first
+func List(dir string) ([]string, error) {
+	items, err := filepath.Glob(filepath.Join(dir, prefix+"*"+ext+sumExt))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list %s", dir)
+	}
+
+	for i := range items {
+		items[i] = strings.TrimSuffix(items[i], sumExt)
+	}
+
+	// names contain time of snapshot:
+	sort.Sort(sort.Reverse(sort.StringSlice(items)))
+
+	return items, nil
+}
+
+// Verify compares checksum of snapshot with checksum file of it
+func Verify(path string) error {
+	data, err := ioutil.ReadFile(path + sumExt)
+	if err != nil {
+		return errors.Wrapf(err, "could not read checksum of %s", path)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return errors.Wrapf(ErrChecksum, "checksum file of %s is empty", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", path)
+	}
+
+	defer file.Close()
+
+	sum := sha256.New()
+
+	if _, err = io.Copy(sum, file); err != nil {
+		return errors.Wrapf(err, "could not read %s", path)
+	}
+
+	if actual := hex.EncodeToString(sum.Sum(nil)); actual != fields[0] {
+		return errors.Wrapf(ErrChecksum, "%s: expected %s, got %s", path, fields[0], actual)
+	}
+
+	return nil
+}
+
+// Open verifies snapshot and returns reader of NDJSON archive, see store.DecodeRecords
+func Open(path string) (io.ReadCloser, error) {
+	if err := Verify(path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", path)
+	}
+
+	zr, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "could not decompress %s", path)
+	}
+
+	return &snapshot{Reader: zr, file: file}, nil
+}
+
+// snapshot closes gzip reader and file
+type snapshot struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (s *snapshot) Close() error {
+	if err := s.Reader.Close(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+
+	return s.file.Close()
+}
+
+// write compressed archive to w
+func write(ctx context.Context, a store.Archive, w io.Writer) error {
+	var (
+		buf = bufio.NewWriter(w)
+		zw  = gzip.NewWriter(buf)
+		enc = json.NewEncoder(zw)
+	)
+
+	if err := a.Export(ctx, func(rec *store.Record) error { return enc.Encode(rec) }); err != nil {
+		return errors.Wrap(err, "could not export archive")
+	}
+
+	if err := zw.Close(); err != nil {
+		return errors.Wrap(err, "could not compress archive")
+	}
+
+	return buf.Flush()
+}
+
+// closeFile syncs file to disk before close
+func closeFile(f *os.File) error {
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "could not sync %s", f.Name())
+	}
+
+	return f.Close()
+}
+
+// writeFile writes file through temporary file, so file is never partially written
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return errors.Wrapf(err, "could not create %s", path)
+	}
+
+	defer os.Remove(tmp.Name()) // renamed on success
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "could not write %s", path)
+	}
+
+	if err = closeFile(tmp); err != nil {
+		return err
+	}
+
+	return errors.Wrapf(os.Rename(tmp.Name(), path), "could not rename %s", path)
+}
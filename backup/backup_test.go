@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// archive exports fixed records
+type archive struct {
+	store.Archive
+	records []*store.Record
+}
+
+func (a *archive) Export(ctx context.Context, fn func(*store.Record) error) error {
+	for _, rec := range a.records {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var _ = Describe("Backup Suite", func() {
+	var (
+		dir      string
+		backuper *Backuper
+		records  = []*store.Record{
+			{Type: store.RecordHeader, Format: store.ArchiveFormat},
+			{Type: store.RecordScheme, ID: 1, Slug: "person"},
+			{Type: store.RecordSchemeVersion, ID: 1, Version: 1, Tags: []string{"a"}, Data: json.RawMessage(`{}`)},
+			{Type: store.RecordEnd, Count: 3},
+		}
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		dir, err = ioutil.TempDir("", "backup")
+		Expect(err).NotTo(HaveOccurred())
+
+		v := viper.New()
+		v.Set("backup.path", dir)
+		v.Set("backup.keep", 2)
+
+		backuper = NewBackuper(v, &archive{records: records}, zap.NewNop())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("should write compressed snapshot with checksum and read it back", func() {
+		path, err := backuper.Snapshot(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path + sumExt).To(BeAnExistingFile())
+		Expect(Verify(path)).To(Succeed())
+
+		r, err := Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+
+		var (
+			result []*store.Record
+			next   = store.DecodeRecords(r)
+		)
+
+		for rec, err := next(); err == nil; rec, err = next() {
+			result = append(result, rec)
+		}
+
+		Expect(result).To(HaveLen(len(records)))
+		Expect(result[1].Slug).To(Equal("person"))
+		Expect(result[3].Count).To(BeEquivalentTo(3))
+	})
+
+	It("should reject snapshot that does not match checksum", func() {
+		path, err := backuper.Snapshot(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(ioutil.WriteFile(path, []byte("corrupted"), 0644)).To(Succeed())
+
+		_, err = Open(path)
+		Expect(errors.Cause(err)).To(Equal(ErrChecksum))
+	})
+
+	It("should keep latest complete snapshots", func() {
+		for _, name := range []string{
+			"backup-20181111T000000Z.ndjson.gz",
+			"backup-20181112T000000Z.ndjson.gz",
+			"backup-20181113T000000Z.ndjson.gz",
+		} {
+			Expect(ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, name+sumExt), nil, 0644)).To(Succeed())
+		}
+
+		// incomplete snapshot, without checksum:
+		Expect(ioutil.WriteFile(filepath.Join(dir, "backup-20181114T000000Z.ndjson.gz"), nil, 0644)).To(Succeed())
+
+		removed, err := backuper.Rotate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(Equal([]string{filepath.Join(dir, "backup-20181111T000000Z.ndjson.gz")}))
+
+		items, err := List(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(Equal([]string{
+			filepath.Join(dir, "backup-20181113T000000Z.ndjson.gz"),
+			filepath.Join(dir, "backup-20181112T000000Z.ndjson.gz"),
+		}))
+	})
+})
@@ -0,0 +1,60 @@
+// Package changes removes old changes of schemes and configs, so feed of
+// changes (see store.Changes) does not grow forever.
+package changes
+
+import (
+	"context"
+	"time"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const defaultRetention = 30 * 24 * time.Hour
+
+// Cleaner removes changes created before retention, changes with pending
+// deliveries of webhooks are kept. Clients that watch changes with cursor
+// older than retention miss removed changes.
+type Cleaner struct {
+	changes   store.Changes
+	logger    *zap.Logger
+	retention time.Duration
+}
+
+var Module = module.Module{
+	{Constructor: NewCleaner},
+}
+
+// NewCleaner with `changes.retention` (720h by default), zero retention keeps changes forever
+func NewCleaner(v *viper.Viper, s store.Changes, l *zap.Logger) *Cleaner {
+	var c = &Cleaner{
+		changes:   s,
+		logger:    l,
+		retention: defaultRetention,
+	}
+
+	if v.IsSet("changes.retention") {
+		c.retention = v.GetDuration("changes.retention")
+	}
+
+	return c
+}
+
+// Job removes changes created before retention
+func (c *Cleaner) Job(ctx context.Context) {
+	if c.retention <= 0 {
+		return
+	}
+
+	count, err := c.changes.Cleanup(ctx, time.Now().Add(-c.retention))
+	if err != nil {
+		c.logger.Error("could not cleanup changes", zap.Error(err))
+		return
+	}
+
+	if count > 0 {
+		c.logger.Info("changes removed", zap.Int("count", count))
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+// Export writes NDJSON archive of all schemes and configs with history to w,
+// export is not retried because part of archive could be already written
+func (c *Client) Export(ctx context.Context, w io.Writer) error {
+	res, err := c.streaming().do(ctx, http.MethodGet, c.url("/admin/export/", nil), nil)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if _, err = io.Copy(w, res.Body); err != nil {
+		return errors.Wrap(err, "could not read archive")
+	}
+
+	return nil
+}
+
+// Import loads NDJSON archive of Export in one transaction, see store.ImportOptions
+func (c *Client) Import(ctx context.Context, r io.Reader, opts store.ImportOptions) (*store.ImportResult, error) {
+	var (
+		result store.ImportResult
+		query  = url.Values{}
+	)
+
+	if opts.Conflict != "" {
+		query.Set("conflict", opts.Conflict)
+	}
+
+	if opts.KeepIDs {
+		query.Set("keep_ids", strconv.FormatBool(opts.KeepIDs))
+	}
+
+	res, err := c.streaming().do(ctx, http.MethodPost, c.url("/admin/import/", query), r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "could not decode result of import")
+	}
+
+	return &result, nil
+}
+
+// do sends NDJSON request (or request without body) and returns response as is,
+// response body should be closed when error is nil
+func (c *Client) do(ctx context.Context, method, address string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, address, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create request %s %s", method, address)
+	}
+
+	req.Header.Set("Accept", "application/x-ndjson, application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+
+	res, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "request %s %s failed", method, address)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		return nil, responseError(res)
+	}
+
+	return res, nil
+}
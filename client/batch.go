@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Operation of batch, entities created by previous operations
+	// are referenced by Ref in IDRef and SchemeRef
+	Operation struct {
+		Op        string          `json:"op"`   // create / update / delete
+		Kind      string          `json:"kind"` // scheme / config
+		Ref       string          `json:"ref,omitempty"`
+		ID        int64           `json:"id,omitempty"`
+		IDRef     string          `json:"id_ref,omitempty"`
+		SchemeID  int64           `json:"scheme_id,omitempty"`
+		SchemeRef string          `json:"scheme_ref,omitempty"`
+		Slug      string          `json:"slug,omitempty"`
+		Tags      []string        `json:"tags,omitempty"`
+		Data      json.RawMessage `json:"data,omitempty"`
+	}
+
+	// OperationResult is result of operation, Error is set for failed operation
+	OperationResult struct {
+		Index   int    `json:"index"`
+		Op      string `json:"op"`
+		Kind    string `json:"kind"`
+		Ref     string `json:"ref,omitempty"`
+		Status  int    `json:"status"`
+		ID      int64  `json:"id,omitempty"`
+		Version int64  `json:"version,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	// BatchResult is result of batch, when batch is not applied
+	// last result is result of failed operation
+	BatchResult struct {
+		Applied bool               `json:"applied"`
+		Results []*OperationResult `json:"results"`
+	}
+
+	batchRequest struct {
+		Operations []*Operation `json:"operations"`
+	}
+)
+
+// Batch applies all operations in one transaction. When any operation fails
+// whole batch is rolled back, result describes failed operation and error
+// has status of failed operation.
+func (c *Client) Batch(ctx context.Context, ops ...*Operation) (*BatchResult, error) {
+	var result = new(BatchResult)
+
+	_, err := c.call(ctx, http.MethodPost, "/batch/", nil, batchRequest{Operations: ops}, result, false)
+	if err == nil {
+		return result, nil
+	}
+
+	e, ok := errors.Cause(err).(*Error)
+	if !ok || json.Unmarshal(e.body, result) != nil || len(result.Results) == 0 {
+		return nil, err
+	}
+
+	failed := result.Results[len(result.Results)-1]
+	e.Message = failed.Error
+
+	return result, e
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+// maxWatchBackoff limits delay between reconnects of Watch
+const maxWatchBackoff = 10 * time.Second
+
+type (
+	// Changes request, empty fields of filter match every change
+	Changes struct {
+		Since    string        // cursor of last read change, empty cursor reads from the beginning
+		Wait     time.Duration // long-poll, blocks until new changes arrive or wait expires
+		Limit    int
+		Kind     string // scheme / config
+		IDs      []int64
+		SchemeID int64
+		Tags     []string
+	}
+
+	// ChangesResult is page of changes, Cursor is cursor of last change
+	// and should be passed as Since of next request
+	ChangesResult struct {
+		Cursor string          `json:"cursor"`
+		Items  []*store.Change `json:"items"`
+	}
+
+	// WatchFunc is called for every change with its cursor,
+	// watch stops when error is returned
+	WatchFunc func(cursor string, change *store.Change) error
+)
+
+func (r Changes) values() url.Values {
+	var q = url.Values{}
+
+	if r.Kind != "" {
+		q.Set("kind", r.Kind)
+	}
+
+	for _, id := range r.IDs {
+		q.Add("ids", strconv.FormatInt(id, 10))
+	}
+
+	if r.SchemeID > 0 {
+		q.Set("scheme_id", strconv.FormatInt(r.SchemeID, 10))
+	}
+
+	for _, tag := range r.Tags {
+		q.Add("tags", tag)
+	}
+
+	return q
+}
+
+// streaming is copy of client without timeout of http client for long-poll
+// and watch, such requests are limited only by context
+func (c *Client) streaming() *Client {
+	var (
+		s = *c
+		h = *c.http
+	)
+
+	h.Timeout = 0
+	s.http = &h
+
+	return &s
+}
+
+// Changes returns changes after cursor, with Wait blocks until new changes arrive
+func (c *Client) Changes(ctx context.Context, r Changes) (*ChangesResult, error) {
+	var (
+		result = new(ChangesResult)
+		q      = r.values()
+	)
+
+	if r.Since != "" {
+		q.Set("since", r.Since)
+	}
+
+	if r.Wait > 0 {
+		q.Set("wait", r.Wait.String())
+	}
+
+	if r.Limit > 0 {
+		q.Set("limit", strconv.Itoa(r.Limit))
+	}
+
+	if _, err := c.streaming().call(ctx, http.MethodGet, "/changes/", q, nil, result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Watch streams changes after cursor of request (only new changes when cursor
+// is empty) until context is done or fn returns error. Watch reconnects
+// when connection is lost and continues from cursor of last change.
+func (c *Client) Watch(ctx context.Context, r Changes, fn WatchFunc) error {
+	var (
+		cursor = r.Since
+		delay  = c.backoff
+		s      = c.streaming()
+		q      = r.values()
+	)
+
+	for {
+		read, err := s.watch(ctx, q, &cursor, fn)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if fatal, ok := err.(*fatalError); ok {
+			return fatal.err
+		} else if status(err) != 0 && !retryable(err) {
+			return err
+		}
+
+		if read {
+			delay = c.backoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			if delay *= 2; delay > maxWatchBackoff {
+				delay = maxWatchBackoff
+			}
+		}
+	}
+}
+
+// fatalError stops watch: error of WatchFunc or change that could not be decoded
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+// watch reads one connection of event stream, returns true when any change is read
+func (c *Client) watch(ctx context.Context, q url.Values, cursor *string, fn WatchFunc) (bool, error) {
+	var read bool
+
+	req, err := http.NewRequest(http.MethodGet, c.url("/watch/", q), nil)
+	if err != nil {
+		return false, errors.Wrap(err, "could not create watch request")
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	if *cursor != "" {
+		req.Header.Set("Last-Event-ID", *cursor)
+	}
+
+	res, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, errors.Wrap(err, "watch request failed")
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, responseError(res)
+	}
+
+	var (
+		id   string
+		data strings.Builder
+		scan = bufio.NewScanner(res.Body)
+	)
+
+	scan.Buffer(make([]byte, 64*1024), 1<<20)
+
+	for scan.Scan() {
+		line := scan.Text()
+
+		switch {
+		case line == "":
+			// end of event:
+			if data.Len() == 0 {
+				continue
+			}
+
+			var change = new(store.Change)
+
+			if err = json.Unmarshal([]byte(data.String()), change); err != nil {
+				return read, &fatalError{err: errors.Wrap(err, "could not decode change")}
+			}
+
+			data.Reset()
+
+			if err = fn(id, change); err != nil {
+				return read, &fatalError{err: err}
+			}
+
+			*cursor, read = id, true
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return read, scan.Err()
+}
@@ -0,0 +1,264 @@
+// Package client is typed client of management API (see api package):
+//
+//	c, err := client.New("http://localhost:8080")
+//	cfg, err := c.ConfigBySlug(ctx, "person", "prod")
+//	if client.IsNotFound(err) { ... }
+//
+//	it := c.SearchConfigs(client.Search{SchemeID: 1, Tags: []string{"prod"}})
+//	for it.Next(ctx) {
+//		fmt.Println(it.Config().ID)
+//	}
+//
+//	if err = it.Err(); err != nil { ... }
+//
+// Idempotent calls (reads, renames, deletes) are retried with exponential
+// backoff when API is unavailable, see WithRetries.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRetries of idempotent calls
+	DefaultRetries = 3
+	// DefaultBackoff is delay before first retry
+	DefaultBackoff = 100 * time.Millisecond
+
+	defaultTimeout = 30 * time.Second
+)
+
+type (
+	// Client of management API, safe for concurrent use
+	Client struct {
+		base    *url.URL
+		http    *http.Client
+		retries int
+		backoff time.Duration
+	}
+
+	// Option of client
+	Option func(*Client)
+
+	// Error is response of API with unexpected status code
+	Error struct {
+		Status  int
+		Message string
+
+		body []byte
+	}
+
+	// errorResponse is body of failed request, see web.captureError
+	errorResponse struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+)
+
+// WithHTTPClient sets http client, by default client with 30s timeout is used
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) {
+		c.http = h
+	}
+}
+
+// WithRetries sets count of retries of idempotent calls and delay before first retry,
+// delay doubles on every retry, zero retries disables retries
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New client of API at address, for example http://localhost:8080
+func New(address string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(address, "/"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse address %q", address)
+	} else if base.Scheme == "" || base.Host == "" {
+		return nil, errors.Errorf("address %q should be absolute URL", address)
+	}
+
+	c := &Client{
+		base:    base,
+		http:    &http.Client{Timeout: defaultTimeout},
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c, nil
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.Status, e.Message)
+}
+
+func status(err error) int {
+	if e, ok := errors.Cause(err).(*Error); ok {
+		return e.Status
+	}
+
+	return 0
+}
+
+// IsNotFound checks that entity not found or deleted
+func IsNotFound(err error) bool {
+	return status(err) == http.StatusNotFound
+}
+
+// IsConflict checks that slug already used
+func IsConflict(err error) bool {
+	return status(err) == http.StatusConflict
+}
+
+// IsValidation checks that request is rejected by validation
+func IsValidation(err error) bool {
+	return status(err) == http.StatusBadRequest
+}
+
+// IsUnavailable checks that database or API is unavailable
+func IsUnavailable(err error) bool {
+	switch status(err) {
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusBadGateway:
+		return true
+	}
+
+	return false
+}
+
+// retryable checks that request could be sent again
+func retryable(err error) bool {
+	if _, ok := errors.Cause(err).(net.Error); ok {
+		return true
+	}
+
+	return IsUnavailable(err) || status(err) == http.StatusTooManyRequests
+}
+
+// url of path with query
+func (c *Client) url(path string, query url.Values) string {
+	u := *c.base
+	u.Path += path
+
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// call sends request and decodes response into result, idempotent requests are retried,
+// returns headers of response
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, result interface{}, idempotent bool) (http.Header, error) {
+	var (
+		err     error
+		header  http.Header
+		data    []byte
+		retries = 0
+		delay   = c.backoff
+	)
+
+	if body != nil {
+		if data, err = json.Marshal(body); err != nil {
+			return nil, errors.Wrapf(err, "could not encode request %s %s", method, path)
+		}
+	}
+
+	if idempotent {
+		retries = c.retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if header, err = c.send(ctx, method, c.url(path, query), data, result); err == nil {
+			return header, nil
+		} else if attempt >= retries || !retryable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, address string, data []byte, result interface{}) (http.Header, error) {
+	var body io.Reader
+
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, address, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create request %s %s", method, address)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "request %s %s failed", method, address)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, responseError(res)
+	}
+
+	if result == nil {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		return res.Header, nil
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(result); err != nil {
+		return nil, errors.Wrapf(err, "could not decode response of %s %s", method, address)
+	}
+
+	return res.Header, nil
+}
+
+// responseError reads message of failed request, body is kept for responses
+// that describe failure, see Batch
+func responseError(res *http.Response) error {
+	var (
+		body errorResponse
+		err  = &Error{Status: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+	)
+
+	err.body, _ = ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+
+	if json.Unmarshal(err.body, &body) == nil {
+		if body.Error != "" {
+			err.Message = body.Error
+		} else if body.Message != "" {
+			err.Message = body.Message
+		}
+	}
+
+	return err
+}
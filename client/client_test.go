@@ -0,0 +1,348 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/helium"
+	"github.com/im-kulikov/helium/grace"
+	"github.com/im-kulikov/helium/logger"
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/helium/orm"
+	"github.com/im-kulikov/helium/redis"
+	"github.com/im-kulikov/helium/settings"
+	"github.com/im-kulikov/helium/web"
+	"github.com/im-kulikov/simplinic-task/api"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var errStop = errors.New("stop")
+
+var testModule = module.Module{
+	{Constructor: testKeyring}, // secrets of configs and webhooks
+}.Append(
+	grace.Module,
+	settings.Module,
+	logger.Module,
+	redis.Module,
+	orm.Module,
+	web.EngineModule,
+	api.Module,
+)
+
+func testKeyring() (*secrets.Keyring, error) {
+	return secrets.New("", map[string][]byte{"test": make([]byte, 32)})
+}
+
+// flaky responds with 503 to first fails requests
+type flaky struct {
+	handler  http.Handler
+	fails    int32
+	requests int32
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&f.requests, 1) <= atomic.LoadInt32(&f.fails) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"database unavailable"}`))
+		return
+	}
+
+	f.handler.ServeHTTP(w, r)
+}
+
+var _ = Describe("Client Suite", func() {
+	var (
+		db  *pg.DB
+		srv *httptest.Server
+		fl  *flaky
+		cli *Client
+		ctx = context.Background()
+	)
+
+	BeforeSuite(func() {
+		h, err := helium.New(&helium.Settings{
+			File:   "../config.yml",
+			Prefix: "TEST",
+		}, testModule)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(h.Invoke(func(pdb *pg.DB, router http.Handler) {
+			db = pdb
+			fl = &flaky{handler: router}
+
+			// Cleanup all tables...
+			_, err := db.Exec("TRUNCATE schemes RESTART IDENTITY CASCADE;")
+			Expect(err).NotTo(HaveOccurred())
+		})).NotTo(HaveOccurred())
+
+		srv = httptest.NewServer(fl)
+
+		cli, err = New(srv.URL, WithRetries(3, time.Millisecond))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterSuite(func() {
+		// BeforeSuite could fail before server and database are opened:
+		if srv != nil {
+			srv.Close()
+		}
+
+		if db != nil {
+			Expect(db.Close()).NotTo(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		atomic.StoreInt32(&fl.fails, 0)
+		atomic.StoreInt32(&fl.requests, 0)
+	})
+
+	It("should reject relative address", func() {
+		_, err := New("localhost:8080")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("Schemes and configs", func() {
+		var (
+			scheme = &store.Scheme{
+				Tags: []string{"client"},
+				Data: json.RawMessage(`{"type":"object"}`),
+				Slug: "client-scheme",
+			}
+
+			config = &store.Config{
+				Tags: []string{"client", "prod"},
+				Data: json.RawMessage(`{"port":8080}`),
+				Slug: "client-config",
+			}
+		)
+
+		It("should create, read, update and rename scheme", func() {
+			Expect(cli.CreateScheme(ctx, scheme)).NotTo(HaveOccurred())
+			Expect(scheme.ID).NotTo(BeZero())
+			Expect(scheme.Version).To(BeEquivalentTo(1))
+
+			res, err := cli.SchemeBySlug(ctx, "client-scheme")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.ID).To(Equal(scheme.ID))
+			Expect(res.Stale).To(BeFalse())
+
+			scheme.Data = json.RawMessage(`{"type":"object","required":["port"]}`)
+			Expect(cli.UpdateScheme(ctx, scheme)).NotTo(HaveOccurred())
+			Expect(scheme.Version).To(BeEquivalentTo(2))
+
+			res, err = cli.RenameScheme(ctx, scheme.ID, "client-scheme-2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Slug).To(Equal("client-scheme-2"))
+			Expect(res.Version).To(BeEquivalentTo(2))
+		})
+
+		It("should create, update and find config", func() {
+			config.SchemeID = scheme.ID
+
+			Expect(cli.CreateConfig(ctx, config)).NotTo(HaveOccurred())
+			Expect(config.ID).NotTo(BeZero())
+
+			config.Data = json.RawMessage(`{"port":9090}`)
+			Expect(cli.UpdateConfig(ctx, config)).NotTo(HaveOccurred())
+			Expect(config.Version).To(BeEquivalentTo(2))
+
+			res, err := cli.ConfigBySlug(ctx, "client-scheme-2", "client-config")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Data).To(MatchJSON(`{"port":9090}`))
+
+			it := cli.SearchConfigs(Search{SchemeID: scheme.ID, Filter: "data.port > 9000"})
+			Expect(it.Next(ctx)).To(BeTrue())
+			Expect(it.Config().ID).To(Equal(config.ID))
+			Expect(it.Next(ctx)).To(BeFalse())
+			Expect(it.Err()).NotTo(HaveOccurred())
+			Expect(it.Total()).To(Equal(1))
+		})
+
+		It("should iterate over history page by page", func() {
+			for i := 0; i < 3; i++ {
+				Expect(cli.UpdateConfig(ctx, config)).NotTo(HaveOccurred())
+			}
+
+			var versions []int64
+
+			it := cli.ConfigHistory(config.ID, History{Limit: 2})
+			for it.Next(ctx) {
+				versions = append(versions, it.Config().Version)
+			}
+
+			Expect(it.Err()).NotTo(HaveOccurred())
+			Expect(it.Total()).To(Equal(5))
+			Expect(versions).To(Equal([]int64{5, 4, 3, 2, 1}))
+		})
+
+		It("should return typed errors", func() {
+			_, err := cli.Scheme(ctx, 1<<40)
+			Expect(IsNotFound(err)).To(BeTrue())
+
+			err = cli.CreateConfig(ctx, &store.Config{SchemeID: scheme.ID})
+			Expect(IsValidation(err)).To(BeTrue())
+
+			_, err = cli.RenameConfig(ctx, config.ID, "client-config")
+			Expect(err).NotTo(HaveOccurred())
+
+			other := &store.Config{SchemeID: scheme.ID, Tags: []string{"a"}, Data: json.RawMessage(`{}`)}
+			Expect(cli.CreateConfig(ctx, other)).NotTo(HaveOccurred())
+
+			_, err = cli.RenameConfig(ctx, other.ID, "client-config")
+			Expect(IsConflict(err)).To(BeTrue())
+
+			it := cli.SearchSchemes(Search{Filter: "data.port >"})
+			Expect(it.Next(ctx)).To(BeFalse())
+			Expect(IsValidation(it.Err())).To(BeTrue())
+		})
+
+		It("should delete and restore config", func() {
+			Expect(cli.DeleteConfig(ctx, config.ID)).NotTo(HaveOccurred())
+
+			_, err := cli.Config(ctx, config.ID)
+			Expect(IsNotFound(err)).To(BeTrue())
+
+			res, err := cli.RestoreConfig(ctx, config.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.ID).To(Equal(config.ID))
+		})
+
+		It("should apply batch and report failed operation", func() {
+			res, err := cli.Batch(ctx,
+				&Operation{Op: "create", Kind: "scheme", Ref: "s", Tags: []string{"batch"}, Data: json.RawMessage(`{}`)},
+				&Operation{Op: "create", Kind: "config", SchemeRef: "s", Tags: []string{"batch"}, Data: json.RawMessage(`{}`)},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Applied).To(BeTrue())
+			Expect(res.Results).To(HaveLen(2))
+
+			res, err = cli.Batch(ctx,
+				&Operation{Op: "create", Kind: "scheme", Tags: []string{"batch"}, Data: json.RawMessage(`{}`)},
+				&Operation{Op: "delete", Kind: "config", ID: 1 << 40},
+			)
+			Expect(IsNotFound(err)).To(BeTrue())
+			Expect(res.Applied).To(BeFalse())
+			Expect(res.Results[1].Status).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Retries", func() {
+		It("should retry idempotent request when API is unavailable", func() {
+			atomic.StoreInt32(&fl.fails, 2)
+
+			_, err := cli.Webhooks(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(atomic.LoadInt32(&fl.requests)).To(BeEquivalentTo(3))
+		})
+
+		It("should give up after all retries", func() {
+			atomic.StoreInt32(&fl.fails, 10)
+
+			_, err := cli.Webhooks(ctx)
+			Expect(IsUnavailable(err)).To(BeTrue())
+			Expect(err.(*Error).Message).To(Equal("database unavailable"))
+			Expect(atomic.LoadInt32(&fl.requests)).To(BeEquivalentTo(4))
+		})
+
+		It("should not retry create", func() {
+			atomic.StoreInt32(&fl.fails, 1)
+
+			err := cli.CreateScheme(ctx, &store.Scheme{Tags: []string{"a"}, Data: json.RawMessage(`{}`)})
+			Expect(IsUnavailable(err)).To(BeTrue())
+			Expect(atomic.LoadInt32(&fl.requests)).To(BeEquivalentTo(1))
+		})
+
+		It("should stop retries when context is done", func() {
+			atomic.StoreInt32(&fl.fails, 10)
+
+			slow, err := New(srv.URL, WithRetries(10, time.Second))
+			Expect(err).NotTo(HaveOccurred())
+
+			cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			_, err = slow.Webhooks(cctx)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Context("Webhooks", func() {
+		It("should create, list and delete webhook", func() {
+			hook, err := cli.CreateWebhook(ctx, Webhook{
+				URL:    "http://localhost:9999/hook",
+				Secret: "secret",
+				Events: []string{"create"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hook.ID).NotTo(BeZero())
+
+			hooks, err := cli.Webhooks(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hooks).NotTo(BeEmpty())
+			Expect(hooks[len(hooks)-1].ID).To(Equal(hook.ID))
+
+			it := cli.Deliveries(hook.ID, Deliveries{})
+			Expect(it.Next(ctx)).To(BeFalse())
+			Expect(it.Err()).NotTo(HaveOccurred())
+
+			Expect(cli.DeleteWebhook(ctx, hook.ID)).NotTo(HaveOccurred())
+
+			_, err = cli.Webhook(ctx, hook.ID)
+			Expect(IsNotFound(err)).To(BeTrue())
+
+			_, err = cli.CreateWebhook(ctx, Webhook{URL: "not url"})
+			Expect(IsValidation(err)).To(BeTrue())
+		})
+	})
+
+	Context("Changes", func() {
+		It("should read changes after cursor", func() {
+			first, err := cli.Changes(ctx, Changes{Kind: "scheme"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Items).NotTo(BeEmpty())
+
+			res, err := cli.Changes(ctx, Changes{Kind: "scheme", Since: first.Cursor, Wait: 100 * time.Millisecond})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Items).To(BeEmpty())
+			Expect(res.Cursor).To(Equal(first.Cursor))
+
+			_, err = cli.Changes(ctx, Changes{Since: "bad"})
+			Expect(IsValidation(err)).To(BeTrue())
+		})
+
+		It("should watch new changes", func() {
+			var (
+				errs    = make(chan error, 1)
+				changes = make(chan *store.Change, 1)
+				wctx    context.Context
+				cancel  context.CancelFunc
+			)
+
+			wctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			go func() {
+				errs <- cli.Watch(wctx, Changes{Kind: "scheme", Since: "0-0"}, func(cursor string, c *store.Change) error {
+					Expect(cursor).NotTo(BeEmpty())
+					changes <- c
+					return errStop
+				})
+			}()
+
+			Eventually(changes).Should(Receive())
+			Eventually(errs).Should(Receive(Equal(errStop)))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/im-kulikov/simplinic-task/store"
+)
+
+// CreateConfig creates config, ID, Version and CreatedAt of config are filled from response
+func (c *Client) CreateConfig(ctx context.Context, cfg *store.Config) error {
+	_, err := c.call(ctx, http.MethodPost, "/configs/", nil, cfg, cfg, false)
+	return err
+}
+
+// Config returns latest version of config as it is stored, references are kept,
+// so config could be changed and updated, see ResolvedConfig for effective config
+func (c *Client) Config(ctx context.Context, id int64) (*store.Config, error) {
+	var result = new(store.Config)
+
+	h, err := c.call(ctx, http.MethodGet, path("configs", id), raw(), nil, result, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stale = stale(h)
+
+	return result, nil
+}
+
+// ConfigBySlug returns latest version of config by slug of scheme and slug of config
+// as it is stored, like Config
+func (c *Client) ConfigBySlug(ctx context.Context, scheme, slug string) (*store.Config, error) {
+	var result = new(store.Config)
+
+	h, err := c.call(ctx, http.MethodGet, path("configs", "by-slug", scheme, slug), raw(), nil, result, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stale = stale(h)
+
+	return result, nil
+}
+
+// ResolvedConfig returns latest version of config with resolved references,
+// see interpolate
+func (c *Client) ResolvedConfig(ctx context.Context, id int64) (*store.Config, error) {
+	var result = new(store.Config)
+
+	h, err := c.call(ctx, http.MethodGet, path("configs", id), nil, nil, result, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stale = stale(h)
+
+	return result, nil
+}
+
+// MaterializedConfig returns latest version of config completed by scheme: defaults
+// are filled, properties that are not allowed are removed and values are coerced to
+// types of scheme, references are resolved before that when resolve is set
+func (c *Client) MaterializedConfig(ctx context.Context, id int64, resolve bool) (*store.Config, error) {
+	var (
+		result = new(store.Config)
+		query  = url.Values{"materialize": {"true"}}
+	)
+
+	if !resolve {
+		query.Set("raw", "true")
+	}
+
+	h, err := c.call(ctx, http.MethodGet, path("configs", id), query, nil, result, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stale = stale(h)
+
+	return result, nil
+}
+
+// Dependents returns references of other configs to config
+func (c *Client) Dependents(ctx context.Context, id int64) ([]*store.Reference, error) {
+	var result []*store.Reference
+
+	if _, err := c.call(ctx, http.MethodGet, path("configs", id, "dependents"), nil, nil, &result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RevealConfig returns latest version of config with plaintext of secret fields,
+// client should be authenticated (client certificate or authenticating proxy),
+// reveal is audited with its identity, claimed actor and reason
+func (c *Client) RevealConfig(ctx context.Context, id int64, actor, reason string) (*store.Config, error) {
+	var (
+		result store.Config
+		body   = revealRequest{Actor: actor, Reason: reason}
+	)
+
+	if _, err := c.call(ctx, http.MethodPost, path("configs", id, "reveal"), nil, body, &result, false); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// UpdateConfig stores new version of config, Version and CreatedAt are filled from response
+func (c *Client) UpdateConfig(ctx context.Context, cfg *store.Config) error {
+	req := updateRequest{ID: cfg.ID, SchemeID: cfg.SchemeID, Tags: cfg.Tags, Data: cfg.Data}
+	_, err := c.call(ctx, http.MethodPut, path("configs", cfg.ID), nil, req, cfg, false)
+
+	return err
+}
+
+// RenameConfig changes slug of config
+func (c *Client) RenameConfig(ctx context.Context, id int64, slug string) (*store.Config, error) {
+	var result = new(store.Config)
+
+	if _, err := c.call(ctx, http.MethodPut, path("configs", id, "slug"), nil, renameRequest{Slug: slug}, result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteConfig marks config as deleted
+func (c *Client) DeleteConfig(ctx context.Context, id int64) error {
+	_, err := c.call(ctx, http.MethodDelete, path("configs", id), nil, nil, nil, true)
+	return err
+}
+
+// RestoreConfig restores deleted config and returns its latest version
+func (c *Client) RestoreConfig(ctx context.Context, id int64) (*store.Config, error) {
+	var result = new(store.Config)
+
+	if _, err := c.call(ctx, http.MethodPut, path("configs", id, "restore"), nil, nil, result, false); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SearchConfigs returns iterator over configs that match search
+func (c *Client) SearchConfigs(s Search) *ConfigIterator {
+	return &ConfigIterator{pager: c.pager("/configs/", s.values(), s.Limit, s.Offset)}
+}
+
+// ConfigHistory returns iterator over versions of config, latest first
+func (c *Client) ConfigHistory(id int64, h History) *ConfigIterator {
+	return &ConfigIterator{pager: c.pager(path("configs", id, "history"), nil, h.Limit, h.Offset)}
+}
+
+// raw query of config reads, API resolves references by default
+func raw() url.Values {
+	return url.Values{"raw": {"true"}}
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/im-kulikov/simplinic-task/gitops"
+)
+
+type gitopsRequest struct {
+	Resources []*gitops.Resource `json:"resources"`
+	gitops.Options
+}
+
+// Plan returns changes that are needed to bring schemes and configs to state of resources,
+// see gitops.Load
+func (c *Client) Plan(ctx context.Context, items []*gitops.Resource, opts gitops.Options) (*gitops.Plan, error) {
+	var result = new(gitops.Plan)
+
+	req := gitopsRequest{Resources: items, Options: opts}
+	if _, err := c.call(ctx, http.MethodPost, "/gitops/plan/", nil, req, result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Apply plans and applies changes in one transaction, applying
+// the same resources again does not create new versions
+func (c *Client) Apply(ctx context.Context, items []*gitops.Resource, opts gitops.Options) (*gitops.Plan, error) {
+	var result = new(gitops.Plan)
+
+	req := gitopsRequest{Resources: items, Options: opts}
+	if _, err := c.call(ctx, http.MethodPost, "/gitops/apply/", nil, req, result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+// DefaultPageSize of iterators when limit of request is not set
+const DefaultPageSize = 100
+
+type (
+	// page of search, history or deliveries, see api.searchResponse
+	page struct {
+		Total  int               `json:"total"`
+		Offset int               `json:"offset"`
+		Limit  int               `json:"limit"`
+		Items  []json.RawMessage `json:"items"`
+	}
+
+	// pager requests pages of route until all items are read
+	pager struct {
+		client *Client
+		path   string
+		query  url.Values
+		size   int
+		offset int
+		total  int
+		done   bool
+		items  []json.RawMessage
+		item   json.RawMessage
+		err    error
+	}
+
+	// SchemeIterator iterates over schemes page by page
+	SchemeIterator struct {
+		pager
+		current *store.Scheme
+	}
+
+	// ConfigIterator iterates over configs page by page
+	ConfigIterator struct {
+		pager
+		current *store.Config
+	}
+
+	// DeliveryIterator iterates over deliveries of webhook page by page
+	DeliveryIterator struct {
+		pager
+		current *store.Delivery
+	}
+)
+
+func (c *Client) pager(path string, query url.Values, limit, offset int) pager {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+
+	return pager{
+		client: c,
+		path:   path,
+		query:  query,
+		size:   limit,
+		offset: offset,
+		total:  -1,
+	}
+}
+
+// next moves to next item, requests next page when current page is read
+func (p *pager) next(ctx context.Context) bool {
+	if p.err != nil {
+		return false
+	}
+
+	if len(p.items) == 0 && !p.done {
+		var res page
+
+		p.query.Set("limit", strconv.Itoa(p.size))
+		p.query.Set("offset", strconv.Itoa(p.offset))
+
+		if _, p.err = p.client.call(ctx, "GET", p.path, p.query, nil, &res, true); p.err != nil {
+			return false
+		}
+
+		p.total = res.Total
+		p.items = res.Items
+		p.offset += len(res.Items)
+		p.done = len(res.Items) < p.size || p.offset >= res.Total
+	}
+
+	if len(p.items) == 0 {
+		return false
+	}
+
+	p.item, p.items = p.items[0], p.items[1:]
+
+	return true
+}
+
+func (p *pager) decode(v interface{}) bool {
+	if err := json.Unmarshal(p.item, v); err != nil {
+		p.err = errors.Wrapf(err, "could not decode item of %s", p.path)
+		return false
+	}
+
+	return true
+}
+
+// Err returns error of last request
+func (p *pager) Err() error {
+	return p.err
+}
+
+// Total count of items, -1 before first page is requested
+func (p *pager) Total() int {
+	return p.total
+}
+
+// Next moves to next scheme, returns false when schemes are over or request failed
+func (it *SchemeIterator) Next(ctx context.Context) bool {
+	it.current = new(store.Scheme)
+	return it.next(ctx) && it.decode(it.current)
+}
+
+// Scheme returns current scheme
+func (it *SchemeIterator) Scheme() *store.Scheme {
+	return it.current
+}
+
+// Next moves to next config, returns false when configs are over or request failed
+func (it *ConfigIterator) Next(ctx context.Context) bool {
+	it.current = new(store.Config)
+	return it.next(ctx) && it.decode(it.current)
+}
+
+// Config returns current config
+func (it *ConfigIterator) Config() *store.Config {
+	return it.current
+}
+
+// Next moves to next delivery, returns false when deliveries are over or request failed
+func (it *DeliveryIterator) Next(ctx context.Context) bool {
+	it.current = new(store.Delivery)
+	return it.next(ctx) && it.decode(it.current)
+}
+
+// Delivery returns current delivery
+func (it *DeliveryIterator) Delivery() *store.Delivery {
+	return it.current
+}
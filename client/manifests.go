@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/im-kulikov/simplinic-task/k8s"
+	"github.com/im-kulikov/simplinic-task/render"
+)
+
+// Manifests request, configs are selected by tags, filter, environment or scheme,
+// at least one of them should be set
+type Manifests struct {
+	Tags        []string
+	Filter      string // expression like `data.port > 1000`
+	Environment string // matches `environment` field of data
+	SchemeID    int64
+	Namespace   string
+	HashSuffix  bool
+	Keys        render.Options
+}
+
+func (r Manifests) values() url.Values {
+	var q = url.Values{}
+
+	for _, tag := range r.Tags {
+		q.Add("tags", tag)
+	}
+
+	if r.SchemeID > 0 {
+		q.Set("scheme_id", strconv.FormatInt(r.SchemeID, 10))
+	}
+
+	if r.HashSuffix {
+		q.Set("hash_suffix", strconv.FormatBool(r.HashSuffix))
+	}
+
+	for name, value := range map[string]string{
+		"filter":      r.Filter,
+		"environment": r.Environment,
+		"namespace":   r.Namespace,
+		"separator":   r.Keys.Separator,
+		"case":        r.Keys.Case,
+		"prefix":      r.Keys.Prefix,
+	} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+
+	return q
+}
+
+// Manifests returns ConfigMap and Secret manifests of latest versions of selected configs
+func (c *Client) Manifests(ctx context.Context, r Manifests) (*k8s.List, error) {
+	var result k8s.List
+
+	if _, err := c.call(ctx, http.MethodGet, "/manifests/", r.values(), nil, &result, true); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
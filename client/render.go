@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/im-kulikov/simplinic-task/render"
+	"github.com/pkg/errors"
+)
+
+// Render returns data of latest version of config flattened to env file,
+// Java properties, INI or HCL, see render.Render
+func (c *Client) Render(ctx context.Context, id int64, format string, opts render.Options) ([]byte, error) {
+	var query = url.Values{"format": {format}}
+
+	for name, value := range map[string]string{
+		"separator": opts.Separator,
+		"case":      opts.Case,
+		"prefix":    opts.Prefix,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	res, err := c.do(ctx, http.MethodGet, c.url(path("configs", id, "render"), query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read rendered config")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+)
+
+type (
+	// Search of schemes or configs, empty fields are not used,
+	// Limit is size of page requested by iterator
+	Search struct {
+		Version     int64
+		Tags        []string
+		Query       string // full-text query
+		Filter      string // expression like `data.port > 1000 and tags has "prod"`
+		SchemeID    int64  // only for configs
+		Author      string
+		CreatedFrom time.Time
+		CreatedTo   time.Time
+		UpdatedFrom time.Time
+		UpdatedTo   time.Time
+		Limit       int
+		Offset      int
+	}
+
+	// History of versions, latest first
+	History struct {
+		Limit  int
+		Offset int
+	}
+
+	// updateRequest is new version, id is bound from body
+	updateRequest struct {
+		ID       int64           `json:"id"`
+		SchemeID int64           `json:"scheme_id,omitempty"`
+		Tags     []string        `json:"tags"`
+		Data     json.RawMessage `json:"data"`
+	}
+
+	renameRequest struct {
+		Slug string `json:"slug"`
+	}
+
+	revealRequest struct {
+		Actor  string `json:"actor"`
+		Reason string `json:"reason,omitempty"`
+	}
+)
+
+// values of search without limit and offset, they are set by iterator
+func (s Search) values() url.Values {
+	var q = url.Values{}
+
+	if s.Version > 0 {
+		q.Set("version", strconv.FormatInt(s.Version, 10))
+	}
+
+	for _, tag := range s.Tags {
+		q.Add("tags", tag)
+	}
+
+	if s.Query != "" {
+		q.Set("q", s.Query)
+	}
+
+	if s.Filter != "" {
+		q.Set("filter", s.Filter)
+	}
+
+	if s.SchemeID > 0 {
+		q.Set("scheme_id", strconv.FormatInt(s.SchemeID, 10))
+	}
+
+	if s.Author != "" {
+		q.Set("author", s.Author)
+	}
+
+	for name, value := range map[string]time.Time{
+		"created_from": s.CreatedFrom,
+		"created_to":   s.CreatedTo,
+		"updated_from": s.UpdatedFrom,
+		"updated_to":   s.UpdatedTo,
+	} {
+		if !value.IsZero() {
+			q.Set(name, value.Format(time.RFC3339))
+		}
+	}
+
+	return q
+}
+
+func path(parts ...interface{}) string {
+	var result string
+
+	for _, part := range parts {
+		switch v := part.(type) {
+		case int64:
+			result += "/" + strconv.FormatInt(v, 10)
+		case string:
+			result += "/" + url.PathEscape(v)
+		}
+	}
+
+	return result + "/"
+}
+
+// stale marks version that is served from cache when database is unavailable
+func stale(h http.Header) bool {
+	return h.Get("X-Stale") == "true"
+}
+
+// CreateScheme creates scheme, ID, Version and CreatedAt of scheme are filled from response
+func (c *Client) CreateScheme(ctx context.Context, s *store.Scheme) error {
+	_, err := c.call(ctx, http.MethodPost, "/schemes/", nil, s, s, false)
+	return err
+}
+
+// Scheme returns latest version of scheme
+func (c *Client) Scheme(ctx context.Context, id int64) (*store.Scheme, error) {
+	var result = new(store.Scheme)
+
+	h, err := c.call(ctx, http.MethodGet, path("schemes", id), nil, nil, result, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stale = stale(h)
+
+	return result, nil
+}
+
+// SchemeBySlug returns latest version of scheme by its slug
+func (c *Client) SchemeBySlug(ctx context.Context, slug string) (*store.Scheme, error) {
+	var result = new(store.Scheme)
+
+	h, err := c.call(ctx, http.MethodGet, path("schemes", "by-slug", slug), nil, nil, result, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stale = stale(h)
+
+	return result, nil
+}
+
+// UpdateScheme stores new version of scheme, Version and CreatedAt are filled from response
+func (c *Client) UpdateScheme(ctx context.Context, s *store.Scheme) error {
+	req := updateRequest{ID: s.ID, Tags: s.Tags, Data: s.Data}
+	_, err := c.call(ctx, http.MethodPut, path("schemes", s.ID), nil, req, s, false)
+
+	return err
+}
+
+// RenameScheme changes slug of scheme
+func (c *Client) RenameScheme(ctx context.Context, id int64, slug string) (*store.Scheme, error) {
+	var result = new(store.Scheme)
+
+	if _, err := c.call(ctx, http.MethodPut, path("schemes", id, "slug"), nil, renameRequest{Slug: slug}, result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteScheme marks scheme and its configs as deleted
+func (c *Client) DeleteScheme(ctx context.Context, id int64) error {
+	_, err := c.call(ctx, http.MethodDelete, path("schemes", id), nil, nil, nil, true)
+	return err
+}
+
+// RestoreScheme restores deleted scheme and returns its latest version
+func (c *Client) RestoreScheme(ctx context.Context, id int64) (*store.Scheme, error) {
+	var result = new(store.Scheme)
+
+	if _, err := c.call(ctx, http.MethodPut, path("schemes", id, "restore"), nil, nil, result, false); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SearchSchemes returns iterator over schemes that match search
+func (c *Client) SearchSchemes(s Search) *SchemeIterator {
+	return &SchemeIterator{pager: c.pager("/schemes/", s.values(), s.Limit, s.Offset)}
+}
+
+// SchemeConfigs returns iterator over configs of scheme that match search
+func (c *Client) SchemeConfigs(id int64, s Search) *ConfigIterator {
+	return &ConfigIterator{pager: c.pager(path("schemes", id, "configs"), s.values(), s.Limit, s.Offset)}
+}
+
+// SchemeHistory returns iterator over versions of scheme, latest first
+func (c *Client) SchemeHistory(id int64, h History) *SchemeIterator {
+	return &SchemeIterator{pager: c.pager(path("schemes", id, "history"), nil, h.Limit, h.Offset)}
+}
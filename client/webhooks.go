@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/im-kulikov/simplinic-task/store"
+)
+
+type (
+	// Webhook request, Secret is key of signature of deliveries and is never returned by API
+	Webhook struct {
+		URL      string   `json:"url"`
+		Secret   string   `json:"secret"`
+		Entity   string   `json:"entity,omitempty"`
+		SchemeID int64    `json:"scheme_id,omitempty"`
+		Tags     []string `json:"tags,omitempty"`
+		Events   []string `json:"events,omitempty"`
+	}
+
+	// Deliveries of webhook, empty status matches every delivery
+	Deliveries struct {
+		Status string
+		Limit  int
+		Offset int
+	}
+)
+
+// CreateWebhook subscribes URL to changes that match filter of webhook
+func (c *Client) CreateWebhook(ctx context.Context, w Webhook) (*store.Webhook, error) {
+	var result = new(store.Webhook)
+
+	if _, err := c.call(ctx, http.MethodPost, "/webhooks/", nil, w, result, false); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Webhooks returns all webhooks
+func (c *Client) Webhooks(ctx context.Context) ([]*store.Webhook, error) {
+	var result []*store.Webhook
+
+	if _, err := c.call(ctx, http.MethodGet, "/webhooks/", nil, nil, &result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Webhook returns webhook by id
+func (c *Client) Webhook(ctx context.Context, id int64) (*store.Webhook, error) {
+	var result = new(store.Webhook)
+
+	if _, err := c.call(ctx, http.MethodGet, path("webhooks", id), nil, nil, result, true); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteWebhook removes webhook with its deliveries
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := c.call(ctx, http.MethodDelete, path("webhooks", id), nil, nil, nil, true)
+	return err
+}
+
+// Deliveries returns iterator over deliveries of webhook, latest first
+func (c *Client) Deliveries(id int64, d Deliveries) *DeliveryIterator {
+	var q = url.Values{}
+
+	if d.Status != "" {
+		q.Set("status", d.Status)
+	}
+
+	return &DeliveryIterator{pager: c.pager(path("webhooks", id, "deliveries"), q, d.Limit, d.Offset)}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cfgctl", func() {
+	Context("Output", func() {
+		It("should keep order of fields in YAML", func() {
+			var buf bytes.Buffer
+
+			Expect(printYAML(&buf, json.RawMessage(`{"z":1,"a":{"y":true,"b":"c"}}`))).To(Succeed())
+			Expect(buf.String()).To(Equal("z: 1\na:\n  \"y\": true\n  b: c\n"))
+		})
+	})
+})
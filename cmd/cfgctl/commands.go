@@ -0,0 +1,961 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/backup"
+	"github.com/im-kulikov/simplinic-task/client"
+	"github.com/im-kulikov/simplinic-task/gitops"
+	"github.com/im-kulikov/simplinic-task/render"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	kindScheme = gitops.KindScheme
+	kindConfig = gitops.KindConfig
+)
+
+type (
+	// version is scheme or config version, see history
+	version struct {
+		Version int64
+		Tags    []string
+		Data    json.RawMessage
+	}
+
+	// tags flag is comma-separated list
+	tagsFlag []string
+)
+
+func (t *tagsFlag) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagsFlag) Set(value string) error {
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+
+	return nil
+}
+
+// flags of command, usage shows usage line of command
+func flags(name string) *flag.FlagSet {
+	var fs = flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: cfgctl %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// kind of argument, plural form is allowed
+func kind(arg string) (string, error) {
+	switch strings.TrimSuffix(arg, "s") {
+	case kindScheme:
+		return kindScheme, nil
+	case kindConfig:
+		return kindConfig, nil
+	}
+
+	return "", errors.Errorf("kind should be %s or %s, got %q", kindScheme, kindConfig, arg)
+}
+
+// args returns kind from first argument and arguments left after flags
+// of command, at least count arguments are required after kind
+func args(fs *flag.FlagSet, in []string, count int) (string, []string, error) {
+	if len(in) == 0 || strings.HasPrefix(in[0], "-") {
+		fs.Usage()
+		return "", nil, errors.New("kind could not be empty")
+	}
+
+	k, err := kind(in[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err = fs.Parse(in[1:]); err != nil {
+		return "", nil, err
+	}
+
+	if fs.NArg() < count {
+		fs.Usage()
+		return "", nil, errors.New("not enough arguments")
+	}
+
+	return k, fs.Args(), nil
+}
+
+// schemeID resolves id or slug of scheme
+func (e *env) schemeID(ctx context.Context, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+
+	s, err := e.client.SchemeBySlug(ctx, ref)
+	if err != nil {
+		return 0, errors.Wrapf(err, "scheme %q", ref)
+	}
+
+	return s.ID, nil
+}
+
+// configID resolves id or `scheme/slug` of config
+func (e *env) configID(ctx context.Context, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+
+	c, err := e.configBySlug(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.ID, nil
+}
+
+func (e *env) configBySlug(ctx context.Context, ref string) (*store.Config, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("config should be referenced by id or scheme/slug, got %q", ref)
+	}
+
+	c, err := e.client.ConfigBySlug(ctx, parts[0], parts[1])
+
+	return c, errors.Wrapf(err, "config %q", ref)
+}
+
+func (e *env) id(ctx context.Context, k, ref string) (int64, error) {
+	if k == kindScheme {
+		return e.schemeID(ctx, ref)
+	}
+
+	return e.configID(ctx, ref)
+}
+
+func (e *env) print(v interface{}) error {
+	return e.printer(e.out, v)
+}
+
+func getCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs          = flags("get")
+		resolve     = fs.Bool("resolve", false, "resolve references of config data")
+		materialize = fs.Bool("materialize", false, "fill defaults of scheme, remove properties that are not allowed and coerce types")
+	)
+
+	k, rest, err := args(fs, in, 1)
+	if err != nil {
+		return err
+	}
+
+	if k == kindScheme {
+		var s *store.Scheme
+
+		if id, perr := strconv.ParseInt(rest[0], 10, 64); perr == nil {
+			s, err = e.client.Scheme(ctx, id)
+		} else {
+			s, err = e.client.SchemeBySlug(ctx, rest[0])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return e.print(schemeItem{s})
+	}
+
+	var c *store.Config
+
+	if *resolve || *materialize {
+		var id int64
+
+		if id, err = e.configID(ctx, rest[0]); err == nil && *materialize {
+			c, err = e.client.MaterializedConfig(ctx, id, *resolve)
+		} else if err == nil {
+			c, err = e.client.ResolvedConfig(ctx, id)
+		}
+	} else if id, perr := strconv.ParseInt(rest[0], 10, 64); perr == nil {
+		c, err = e.client.Config(ctx, id)
+	} else {
+		c, err = e.configBySlug(ctx, rest[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return e.print(configItem{c})
+}
+
+// dependentsCommand prints references of other configs to config
+func dependentsCommand(ctx context.Context, e *env, in []string) error {
+	fs := flags("dependents")
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	} else if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("config could not be empty")
+	}
+
+	id, err := e.configID(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	refs, err := e.client.Dependents(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return e.print(refList(refs))
+}
+
+// revealCommand prints latest version of config with plaintext of secret fields
+func revealCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs     = flags("reveal")
+		actor  = fs.String("actor", os.Getenv("USER"), "who reveals secrets, written to audit next to authenticated identity")
+		reason = fs.String("reason", "", "why secrets are revealed, written to audit")
+	)
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	} else if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("config could not be empty")
+	}
+
+	id, err := e.configID(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client.RevealConfig(ctx, id, *actor, *reason)
+	if err != nil {
+		return err
+	}
+
+	return e.print(configItem{c})
+}
+
+func listCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs     = flags("list")
+		search client.Search
+		tags   tagsFlag
+		scheme = fs.String("scheme", "", "id or slug of scheme of configs")
+		limit  = fs.Int("limit", 0, "max count of items, all items by default")
+	)
+
+	fs.Var(&tags, "tags", "comma-separated tags, all of them should be set")
+	fs.StringVar(&search.Filter, "filter", "", `filter expression, for example 'data.port > 1000 and tags has "prod"'`)
+	fs.StringVar(&search.Query, "q", "", "full-text query")
+	fs.StringVar(&search.Author, "author", "", "author of versions")
+	fs.IntVar(&search.Offset, "offset", 0, "count of items to skip")
+
+	k, _, err := args(fs, in, 0)
+	if err != nil {
+		return err
+	}
+
+	search.Tags = tags
+
+	if *limit > 0 && *limit < client.DefaultPageSize {
+		search.Limit = *limit
+	}
+
+	if *scheme != "" {
+		if search.SchemeID, err = e.schemeID(ctx, *scheme); err != nil {
+			return err
+		}
+	}
+
+	if k == kindScheme {
+		var (
+			items schemeList
+			it    = e.client.SearchSchemes(search)
+		)
+
+		for (*limit <= 0 || len(items) < *limit) && it.Next(ctx) {
+			items = append(items, it.Scheme())
+		}
+
+		if err = it.Err(); err != nil {
+			return err
+		}
+
+		return e.print(items)
+	}
+
+	var (
+		items configList
+		it    = e.client.SearchConfigs(search)
+	)
+
+	for (*limit <= 0 || len(items) < *limit) && it.Next(ctx) {
+		items = append(items, it.Config())
+	}
+
+	if err = it.Err(); err != nil {
+		return err
+	}
+
+	return e.print(items)
+}
+
+func createCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs     = flags("create")
+		tags   tagsFlag
+		data   json.RawMessage
+		slug   = fs.String("slug", "", "slug of scheme or config")
+		scheme = fs.String("scheme", "", "id or slug of scheme, required for config")
+		inline = fs.String("data", "", "JSON document")
+		file   = fs.String("f", "", "file with JSON or YAML document, - for stdin")
+	)
+
+	fs.Var(&tags, "tags", "comma-separated tags")
+
+	k, _, err := args(fs, in, 0)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *inline != "" && *file != "":
+		return errors.New("only one of -data and -f could be used")
+	case *inline != "":
+		data = json.RawMessage(*inline)
+	case *file != "":
+		var raw []byte
+
+		if raw, err = readFile(*file); err != nil {
+			return errors.Wrapf(err, "could not read %s", *file)
+		} else if data, err = gitops.JSON(raw); err != nil {
+			return err
+		}
+	default:
+		return errors.New("document should be set by -data or -f")
+	}
+
+	if k == kindScheme {
+		s := &store.Scheme{Tags: tags, Data: data, Slug: *slug}
+
+		if err = e.client.CreateScheme(ctx, s); err != nil {
+			return err
+		}
+
+		return e.print(schemeItem{s})
+	}
+
+	if *scheme == "" {
+		return errors.New("scheme of config could not be empty")
+	}
+
+	c := &store.Config{Tags: tags, Data: data, Slug: *slug}
+
+	if c.SchemeID, err = e.schemeID(ctx, *scheme); err != nil {
+		return err
+	}
+
+	if err = e.client.CreateConfig(ctx, c); err != nil {
+		return err
+	}
+
+	return e.print(configItem{c})
+}
+
+// resources of file or directory, `-` reads file from stdin
+func resources(path string) ([]*gitops.Resource, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return gitops.Load(path)
+	}
+
+	data, err := readFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+
+	items, err := gitops.Parse(filepath.Base(path), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, gitops.Validate(items)
+}
+
+// readFile reads file or stdin when path is `-`
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// gitopsFlags of plan and apply
+func gitopsFlags(name string, in []string) (*flag.FlagSet, []*gitops.Resource, gitops.Options, error) {
+	var (
+		opts gitops.Options
+		fs   = flags(name)
+		path = fs.String("f", "", "file or directory with resources, - for stdin")
+	)
+
+	fs.BoolVar(&opts.Prune, "prune", false, "delete schemes and configs with slugs that are not described")
+
+	if err := fs.Parse(in); err != nil {
+		return nil, nil, opts, err
+	} else if *path == "" {
+		fs.Usage()
+		return nil, nil, opts, errors.New("file or directory could not be empty")
+	}
+
+	items, err := resources(*path)
+
+	return fs, items, opts, err
+}
+
+// planCommand shows changes that are needed to bring service to state of resources
+func planCommand(ctx context.Context, e *env, in []string) error {
+	_, items, opts, err := gitopsFlags("plan", in)
+	if err != nil {
+		return err
+	}
+
+	plan, err := e.client.Plan(ctx, items, opts)
+	if err != nil {
+		return err
+	}
+
+	if err = e.print(planList{plan}); err != nil || e.format != "table" {
+		return err
+	}
+
+	// diffs are shown only in table, json and yaml contain them as fields:
+	for _, ch := range plan.Changes {
+		fmt.Fprintf(e.out, "\n%s %s %s:\n%s\n", ch.Action, ch.Kind, path.Join(ch.Scheme, ch.Slug), ch.Diff)
+	}
+
+	fmt.Fprintf(e.out, "\n%d to change, %d unchanged\n", len(plan.Changes), plan.Unchanged)
+
+	return nil
+}
+
+// applyCommand creates, updates and (with -prune) deletes schemes and configs
+// in one transaction, unchanged resources do not produce new versions
+func applyCommand(ctx context.Context, e *env, in []string) error {
+	_, items, opts, err := gitopsFlags("apply", in)
+	if err != nil {
+		return err
+	}
+
+	plan, err := e.client.Apply(ctx, items, opts)
+	if err != nil {
+		return err
+	}
+
+	return e.print(planList{plan})
+}
+
+func historyCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs    = flags("history")
+		limit = fs.Int("limit", 20, "max count of versions, 0 for all versions")
+	)
+
+	k, rest, err := args(fs, in, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := e.id(ctx, k, rest[0])
+	if err != nil {
+		return err
+	}
+
+	page := client.History{}
+	if *limit > 0 && *limit < client.DefaultPageSize {
+		page.Limit = *limit
+	}
+
+	if k == kindScheme {
+		var (
+			items schemeList
+			it    = e.client.SchemeHistory(id, page)
+		)
+
+		for (*limit <= 0 || len(items) < *limit) && it.Next(ctx) {
+			items = append(items, it.Scheme())
+		}
+
+		if err = it.Err(); err != nil {
+			return err
+		}
+
+		return e.print(items)
+	}
+
+	var (
+		items configList
+		it    = e.client.ConfigHistory(id, page)
+	)
+
+	for (*limit <= 0 || len(items) < *limit) && it.Next(ctx) {
+		items = append(items, it.Config())
+	}
+
+	if err = it.Err(); err != nil {
+		return err
+	}
+
+	return e.print(items)
+}
+
+// versions returns all versions of scheme or config, latest first
+func (e *env) versions(ctx context.Context, k string, id int64) ([]version, error) {
+	var result []version
+
+	if k == kindScheme {
+		it := e.client.SchemeHistory(id, client.History{})
+		for it.Next(ctx) {
+			result = append(result, version{Version: it.Scheme().Version, Tags: it.Scheme().Tags, Data: it.Scheme().Data})
+		}
+
+		return result, it.Err()
+	}
+
+	it := e.client.ConfigHistory(id, client.History{})
+	for it.Next(ctx) {
+		result = append(result, version{Version: it.Config().Version, Tags: it.Config().Tags, Data: it.Config().Data})
+	}
+
+	return result, it.Err()
+}
+
+func findVersion(versions []version, arg string) (version, error) {
+	number, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return version{}, errors.Errorf("version should be number, got %q", arg)
+	}
+
+	for _, v := range versions {
+		if v.Version == number {
+			return v, nil
+		}
+	}
+
+	return version{}, errors.Errorf("version %d not found", number)
+}
+
+// diffCommand shows difference of tags and data between versions
+func diffCommand(ctx context.Context, e *env, in []string) error {
+	k, rest, err := args(flags("diff"), in, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := e.id(ctx, k, rest[0])
+	if err != nil {
+		return err
+	}
+
+	versions, err := e.versions(ctx, k, id)
+	if err != nil {
+		return err
+	}
+
+	var from, to version
+
+	switch len(rest) {
+	case 1:
+		if len(versions) < 2 {
+			return errors.Errorf("%s %d has only one version", k, id)
+		}
+
+		from, to = versions[1], versions[0]
+	case 2:
+		if from, err = findVersion(versions, rest[1]); err != nil {
+			return err
+		}
+
+		to = versions[0]
+	default:
+		if from, err = findVersion(versions, rest[1]); err != nil {
+			return err
+		} else if to, err = findVersion(versions, rest[2]); err != nil {
+			return err
+		}
+	}
+
+	a, err := gitops.Lines(from.Tags, from.Data)
+	if err != nil {
+		return err
+	}
+
+	b, err := gitops.Lines(to.Tags, to.Data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "--- %s %d version %d\n+++ %s %d version %d\n", k, id, from.Version, k, id, to.Version)
+
+	for _, line := range gitops.Diff(a, b) {
+		fmt.Fprintln(e.out, line)
+	}
+
+	return nil
+}
+
+// revertCommand stores tags and data of old version as new version
+func revertCommand(ctx context.Context, e *env, in []string) error {
+	k, rest, err := args(flags("revert"), in, 2)
+	if err != nil {
+		return err
+	}
+
+	id, err := e.id(ctx, k, rest[0])
+	if err != nil {
+		return err
+	}
+
+	versions, err := e.versions(ctx, k, id)
+	if err != nil {
+		return err
+	}
+
+	v, err := findVersion(versions, rest[1])
+	if err != nil {
+		return err
+	}
+
+	if k == kindScheme {
+		s := &store.Scheme{ID: id, Tags: v.Tags, Data: v.Data}
+		if err = e.client.UpdateScheme(ctx, s); err != nil {
+			return err
+		}
+
+		return e.print(schemeItem{s})
+	}
+
+	c, err := e.client.Config(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	c.Tags, c.Data = v.Tags, v.Data
+	if err = e.client.UpdateConfig(ctx, c); err != nil {
+		return err
+	}
+
+	return e.print(configItem{c})
+}
+
+func deleteCommand(ctx context.Context, e *env, in []string) error {
+	k, rest, err := args(flags("delete"), in, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := e.id(ctx, k, rest[0])
+	if err != nil {
+		return err
+	}
+
+	if k == kindScheme {
+		err = e.client.DeleteScheme(ctx, id)
+	} else {
+		err = e.client.DeleteConfig(ctx, id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s %d deleted\n", k, id)
+
+	return nil
+}
+
+// restoreCommand restores deleted scheme or config, deleted entities
+// could not be found by slug, so id is required
+func restoreCommand(ctx context.Context, e *env, in []string) error {
+	if len(in) > 0 && in[0] == "snapshot" {
+		return restoreSnapshot(ctx, e, in[1:])
+	}
+
+	k, rest, err := args(flags("restore"), in, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil {
+		return errors.Errorf("deleted %s should be referenced by id, got %q", k, rest[0])
+	}
+
+	if k == kindScheme {
+		s, err := e.client.RestoreScheme(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return e.print(schemeItem{s})
+	}
+
+	c, err := e.client.RestoreConfig(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return e.print(configItem{c})
+}
+
+// restoreSnapshot verifies checksum of snapshot of backup worker and imports it,
+// directory of snapshots means latest complete snapshot
+func restoreSnapshot(ctx context.Context, e *env, in []string) error {
+	var (
+		opts   store.ImportOptions
+		fs     = flags("restore")
+		verify = fs.Bool("verify", false, "only verify checksum of snapshot")
+	)
+
+	fs.StringVar(&opts.Conflict, "conflict", store.ConflictFail, "strategy for slugs that are already used: fail, skip or merge")
+	fs.BoolVar(&opts.KeepIDs, "keep-ids", false, "keep ids of snapshot, e.g. to restore to empty database")
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	} else if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("snapshot could not be empty")
+	}
+
+	path := fs.Arg(0)
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		items, err := backup.List(path)
+		if err != nil {
+			return err
+		} else if len(items) == 0 {
+			return errors.Errorf("no snapshots found in %s", path)
+		}
+
+		path = items[0]
+	}
+
+	if *verify {
+		if err := backup.Verify(path); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(e.out, "%s: OK\n", path)
+
+		return nil
+	}
+
+	r, err := backup.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	result, err := e.client.Import(ctx, r, opts)
+	if err != nil {
+		return err
+	}
+
+	return e.print(importItem{result})
+}
+
+// renderCommand writes flattened data of config as is, output format is not used
+func renderCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		opts   render.Options
+		fs     = flags("render")
+		format = fs.String("format", render.Dotenv, "dotenv, properties, ini or hcl")
+	)
+
+	fs.StringVar(&opts.Separator, "separator", "", "separator of nested keys, default of format by default")
+	fs.StringVar(&opts.Case, "case", "", "case of keys: keep, upper or lower, default of format by default")
+	fs.StringVar(&opts.Prefix, "prefix", "", "prefix of keys")
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	} else if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("config could not be empty")
+	}
+
+	id, err := e.configID(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	data, err := e.client.Render(ctx, id, *format, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.out.Write(data)
+
+	return err
+}
+
+// manifestsCommand prints ConfigMap and Secret manifests of selected configs,
+// json output prints List, other outputs print stream of YAML documents for kubectl
+func manifestsCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs     = flags("manifests")
+		req    client.Manifests
+		tags   tagsFlag
+		scheme = fs.String("scheme", "", "id or slug of scheme")
+	)
+
+	fs.Var(&tags, "tags", "comma-separated tags, all of them should be set")
+	fs.StringVar(&req.Filter, "filter", "", `filter expression, for example 'data.port > 1000'`)
+	fs.StringVar(&req.Environment, "environment", "", "value of environment field of data")
+	fs.StringVar(&req.Namespace, "namespace", "", "namespace of manifests")
+	fs.BoolVar(&req.HashSuffix, "hash-suffix", false, "append content hash to names")
+	fs.StringVar(&req.Keys.Separator, "separator", "", "separator of nested keys, __ by default")
+	fs.StringVar(&req.Keys.Case, "case", "", "case of keys: keep, upper or lower, upper by default")
+	fs.StringVar(&req.Keys.Prefix, "prefix", "", "prefix of keys")
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	}
+
+	req.Tags = tags
+
+	if *scheme != "" {
+		var err error
+
+		if req.SchemeID, err = e.schemeID(ctx, *scheme); err != nil {
+			return err
+		}
+	}
+
+	list, err := e.client.Manifests(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if e.format == "json" {
+		return e.print(list)
+	}
+
+	for _, item := range list.Items {
+		if _, err = fmt.Fprintln(e.out, "---"); err != nil {
+			return err
+		}
+
+		if err = printYAML(e.out, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// watchCommand prints changes until interrupted, table output prints change
+// per line, json output prints JSON object per line
+func watchCommand(ctx context.Context, e *env, in []string) error {
+	var (
+		fs     = flags("watch")
+		req    client.Changes
+		tags   tagsFlag
+		scheme = fs.String("scheme", "", "id or slug of scheme, changes of scheme and its configs")
+	)
+
+	fs.Var(&tags, "tags", "comma-separated tags, all of them should be set")
+	fs.StringVar(&req.Kind, "kind", "", "scheme or config")
+	fs.StringVar(&req.Since, "since", "", "cursor of last seen change, only new changes by default")
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	}
+
+	req.Tags = tags
+
+	if *scheme != "" {
+		var err error
+
+		if req.SchemeID, err = e.schemeID(ctx, *scheme); err != nil {
+			return err
+		}
+	}
+
+	return e.client.Watch(ctx, req, func(cursor string, c *store.Change) error {
+		if e.format == "json" {
+			data, err := json.Marshal(struct {
+				Cursor string `json:"cursor"`
+				*store.Change
+			}{Cursor: cursor, Change: c})
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(e.out, "%s\n", data)
+
+			return err
+		}
+
+		if e.format == "yaml" {
+			if _, err := fmt.Fprintln(e.out, "---"); err != nil {
+				return err
+			}
+
+			return e.print(c)
+		}
+
+		_, err := fmt.Fprintf(e.out, "%s\t%s\t%s %d\tv%d\t%s\n",
+			cursor, c.Op, c.Entity, c.EntityID, c.Version, strings.Join(c.Tags, ","))
+
+		return err
+	})
+}
+
+// contextCommand lists contexts of profile or switches current context
+func contextCommand(_ context.Context, e *env, in []string) error {
+	fs := flags("context")
+
+	if err := fs.Parse(in); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		if _, ok := e.profile.Contexts[fs.Arg(0)]; !ok {
+			return errors.Errorf("context %q not found", fs.Arg(0))
+		}
+
+		e.profile.Current = fs.Arg(0)
+
+		return e.profile.Save()
+	}
+
+	var items contextList
+
+	for name, c := range e.profile.Contexts {
+		items = append(items, contextRow{Name: name, Server: c.Server, Current: name == e.profile.Current})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	return e.print(items)
+}
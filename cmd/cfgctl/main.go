@@ -0,0 +1,141 @@
+// Command cfgctl manages schemes and configs through REST API:
+//
+//	cfgctl [-context name] [-server url] [-o table|json|yaml] <command> [flags] [args]
+//
+// Servers are described in profile file (~/.cfgctl.yml or $CFGCTL_CONFIG),
+// see profile.go.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/im-kulikov/simplinic-task/client"
+)
+
+type (
+	// env of command
+	env struct {
+		client  *client.Client
+		profile *Profile
+		out     io.Writer
+		format  string
+		printer printer
+	}
+
+	command struct {
+		usage string
+		help  string
+		// run parses flags of command and executes it
+		run func(ctx context.Context, e *env, args []string) error
+		// offline commands do not need server
+		offline bool
+	}
+)
+
+// commands are filled in init, flags of commands refer to their usage
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":        {usage: "get <scheme|config> [-resolve] [-materialize] <id|slug>", help: "show latest version", run: getCommand},
+		"list":       {usage: "list <schemes|configs> [flags]", help: "search schemes or configs", run: listCommand},
+		"create":     {usage: "create <scheme|config> [flags]", help: "create scheme or config", run: createCommand},
+		"plan":       {usage: "plan -f <file|dir> [-prune]", help: "show changes needed to apply resources of file or directory", run: planCommand},
+		"apply":      {usage: "apply -f <file|dir> [-prune]", help: "apply resources of file or directory in one transaction", run: applyCommand},
+		"dependents": {usage: "dependents <id|slug>", help: "show configs that refer to config", run: dependentsCommand},
+		"history":    {usage: "history <scheme|config> [-limit n] <id|slug>", help: "show versions, latest first", run: historyCommand},
+		"diff":       {usage: "diff <scheme|config> <id|slug> [from] [to]", help: "compare versions, previous and latest by default", run: diffCommand},
+		"revert":     {usage: "revert <scheme|config> <id|slug> <version>", help: "store data and tags of version as new version", run: revertCommand},
+		"delete":     {usage: "delete <scheme|config> <id|slug>", help: "delete scheme or config", run: deleteCommand},
+		"restore":    {usage: "restore <scheme|config|snapshot> <id|file|dir>", help: "restore deleted scheme or config, or import snapshot of backup", run: restoreCommand},
+		"manifests":  {usage: "manifests [flags]", help: "show ConfigMap and Secret manifests of configs", run: manifestsCommand},
+		"reveal":     {usage: "reveal [-actor name] [-reason text] <id|slug>", help: "show config with plaintext of secret fields, audited", run: revealCommand},
+		"render":     {usage: "render [-format f] [flags] <id|slug>", help: "show config as env file, properties, INI or HCL", run: renderCommand},
+		"watch":      {usage: "watch [flags]", help: "stream changes of schemes and configs", run: watchCommand},
+		"context":    {usage: "context [name]", help: "list contexts of profile or switch current context", run: contextCommand, offline: true},
+	}
+}
+
+func usage() {
+	var names []string
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: cfgctl [flags] <command> [flags] [args]\n\nCommands:\n")
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-46s %s\n", commands[name].usage, commands[name].help)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		name    = flag.String("context", "", "context of profile, current context by default")
+		server  = flag.String("server", "", "address of API, overrides context")
+		output  = flag.String("o", "table", "output format: table, json or yaml")
+		profile = flag.String("profile", profilePath(), "profile file")
+	)
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fatal(fmt.Errorf("unknown command %q", flag.Arg(0)))
+	}
+
+	p, err := LoadProfile(*profile)
+	if err != nil {
+		fatal(err)
+	}
+
+	e := &env{profile: p, out: os.Stdout, format: *output}
+
+	if e.printer, err = newPrinter(*output); err != nil {
+		fatal(err)
+	}
+
+	if !cmd.offline {
+		if e.client, err = p.Client(*name, *server); err != nil {
+			fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		cancel()
+	}()
+
+	if err = cmd.run(ctx, e, flag.Args()[1:]); err != nil && err != context.Canceled {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
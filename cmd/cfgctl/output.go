@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/codec"
+	"github.com/im-kulikov/simplinic-task/gitops"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+type (
+	// printer writes result of command
+	printer func(w io.Writer, v interface{}) error
+
+	// tabular is result that could be printed as table
+	tabular interface {
+		header() []string
+		rows() [][]string
+	}
+
+	// detailed is result that has document to print after table
+	detailed interface {
+		document() json.RawMessage
+	}
+
+	schemeList  []*store.Scheme
+	configList  []*store.Config
+	changeList  []*store.Change
+	refList     []*store.Reference
+	schemeItem  struct{ *store.Scheme }
+	configItem  struct{ *store.Config }
+	contextList []contextRow
+	planList    struct{ *gitops.Plan }
+	importItem  struct{ *store.ImportResult }
+
+	contextRow struct {
+		Name    string `json:"name"`
+		Server  string `json:"server"`
+		Current bool   `json:"current"`
+	}
+)
+
+func newPrinter(format string) (printer, error) {
+	switch format {
+	case "table":
+		return printTable, nil
+	case "json":
+		return printJSON, nil
+	case "yaml":
+		return printYAML, nil
+	}
+
+	return nil, errors.Errorf("unknown output format %q, should be table, json or yaml", format)
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(unwrap(v))
+}
+
+// printYAML converts JSON representation to YAML, so documents and
+// fields keep names and order of API
+func printYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(unwrap(v))
+	if err != nil {
+		return err
+	}
+
+	out, err := codec.Encode(codec.YAML, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+
+	return err
+}
+
+func printTable(w io.Writer, v interface{}) error {
+	t, ok := v.(tabular)
+	if !ok {
+		return printYAML(w, v)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(t.header(), "\t"))
+
+	for _, row := range t.rows() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if d, ok := v.(detailed); ok {
+		var buf bytes.Buffer
+
+		if err := json.Indent(&buf, d.document(), "", "  "); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "\n%s\n", buf.String())
+	}
+
+	return nil
+}
+
+// unwrap returns value that is printed as JSON or YAML
+func unwrap(v interface{}) interface{} {
+	switch item := v.(type) {
+	case schemeItem:
+		return item.Scheme
+	case configItem:
+		return item.Config
+	case planList:
+		return item.Plan
+	case importItem:
+		return item.ImportResult
+	}
+
+	return v
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatInt(v int64) string {
+	if v == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(v, 10)
+}
+
+func (schemeList) header() []string {
+	return []string{"ID", "SLUG", "VERSION", "TAGS", "AUTHOR", "CREATED"}
+}
+
+func (l schemeList) rows() [][]string {
+	var result = make([][]string, 0, len(l))
+
+	for _, s := range l {
+		result = append(result, []string{
+			formatInt(s.ID), s.Slug, formatInt(s.Version),
+			strings.Join(s.Tags, ","), s.Author, formatTime(s.CreatedAt),
+		})
+	}
+
+	return result
+}
+
+func (s schemeItem) header() []string          { return schemeList{}.header() }
+func (s schemeItem) rows() [][]string          { return schemeList{s.Scheme}.rows() }
+func (s schemeItem) document() json.RawMessage { return s.Data }
+
+func (configList) header() []string {
+	return []string{"ID", "SCHEME", "SLUG", "VERSION", "TAGS", "AUTHOR", "CREATED"}
+}
+
+func (l configList) rows() [][]string {
+	var result = make([][]string, 0, len(l))
+
+	for _, c := range l {
+		result = append(result, []string{
+			formatInt(c.ID), formatInt(c.SchemeID), c.Slug, formatInt(c.Version),
+			strings.Join(c.Tags, ","), c.Author, formatTime(c.CreatedAt),
+		})
+	}
+
+	return result
+}
+
+func (c configItem) header() []string          { return configList{}.header() }
+func (c configItem) rows() [][]string          { return configList{c.Config}.rows() }
+func (c configItem) document() json.RawMessage { return c.Data }
+
+func (changeList) header() []string {
+	return []string{"ID", "OP", "ENTITY", "ENTITY_ID", "SCHEME", "VERSION", "TAGS", "CREATED"}
+}
+
+func (l changeList) rows() [][]string {
+	var result = make([][]string, 0, len(l))
+
+	for _, c := range l {
+		result = append(result, []string{
+			formatInt(c.ID), c.Op, c.Entity, formatInt(c.EntityID), formatInt(c.SchemeID),
+			formatInt(c.Version), strings.Join(c.Tags, ","), formatTime(c.CreatedAt),
+		})
+	}
+
+	return result
+}
+
+func (refList) header() []string {
+	return []string{"CONFIG", "TARGET", "POINTER"}
+}
+
+func (l refList) rows() [][]string {
+	var result = make([][]string, 0, len(l))
+
+	for _, r := range l {
+		result = append(result, []string{formatInt(r.ConfigID), formatInt(r.TargetID), r.Pointer})
+	}
+
+	return result
+}
+
+func (contextList) header() []string {
+	return []string{"CURRENT", "NAME", "SERVER"}
+}
+
+func (l contextList) rows() [][]string {
+	var result = make([][]string, 0, len(l))
+
+	for _, c := range l {
+		var current string
+
+		if c.Current {
+			current = "*"
+		}
+
+		result = append(result, []string{current, c.Name, c.Server})
+	}
+
+	return result
+}
+
+func (planList) header() []string {
+	return []string{"ACTION", "KIND", "SCHEME", "SLUG", "ID", "VERSION", "PATH"}
+}
+
+func (l planList) rows() [][]string {
+	var rows = make([][]string, 0, len(l.Changes))
+
+	for _, ch := range l.Changes {
+		rows = append(rows, []string{
+			ch.Action, ch.Kind, ch.Scheme, ch.Slug,
+			formatInt(ch.ID), formatInt(ch.Version), ch.Path,
+		})
+	}
+
+	return rows
+}
+
+func (importItem) header() []string {
+	return []string{"KIND", "CREATED", "MERGED", "SKIPPED"}
+}
+
+func (i importItem) rows() [][]string {
+	var row = func(kind string, c store.ImportCounts) []string {
+		return []string{kind, strconv.Itoa(c.Created), strconv.Itoa(c.Merged), strconv.Itoa(c.Skipped)}
+	}
+
+	return [][]string{
+		row("schemes", i.Schemes),
+		row("configs", i.Configs),
+		row("versions", store.ImportCounts{Created: i.Versions}),
+	}
+}
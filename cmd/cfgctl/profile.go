@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/client"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultServer = "http://localhost:8080"
+
+type (
+	// Profile describes servers that could be managed:
+	//
+	//	current: local
+	//	contexts:
+	//	  local:
+	//	    server: http://localhost:8080
+	//	  prod:
+	//	    server: https://configs.example.com
+	//	    timeout: 10s
+	//	    retries: 5
+	Profile struct {
+		Current  string             `yaml:"current"`
+		Contexts map[string]*Server `yaml:"contexts"`
+
+		path string
+	}
+
+	// Server of API, described by context of profile
+	Server struct {
+		Server  string        `yaml:"server"`
+		Timeout time.Duration `yaml:"timeout,omitempty"`
+		Retries *int          `yaml:"retries,omitempty"`
+	}
+)
+
+// profilePath is $CFGCTL_CONFIG or ~/.cfgctl.yml
+func profilePath() string {
+	if path := os.Getenv("CFGCTL_CONFIG"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".cfgctl.yml"
+	}
+
+	return filepath.Join(home, ".cfgctl.yml")
+}
+
+// LoadProfile reads profile, missing profile is empty profile
+func LoadProfile(path string) (*Profile, error) {
+	var p = &Profile{path: path, Contexts: map[string]*Server{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read profile %s", path)
+	}
+
+	if err = yaml.Unmarshal(data, p); err != nil {
+		return nil, errors.Wrapf(err, "could not parse profile %s", path)
+	}
+
+	if p.Contexts == nil {
+		p.Contexts = map[string]*Server{}
+	}
+
+	return p, nil
+}
+
+// Save writes profile to file it was read from
+func (p *Profile) Save() error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "could not encode profile")
+	}
+
+	return errors.Wrapf(ioutil.WriteFile(p.path, data, 0600), "could not write profile %s", p.path)
+}
+
+// Client of context by name (current context when name is empty),
+// server overrides address of context
+func (p *Profile) Client(name, server string) (*client.Client, error) {
+	var c = Server{Server: defaultServer}
+
+	if name == "" {
+		name = p.Current
+	}
+
+	if name != "" {
+		item, ok := p.Contexts[name]
+		if !ok {
+			return nil, errors.Errorf("context %q not found in profile %s", name, p.path)
+		}
+
+		c = *item
+	}
+
+	if server != "" {
+		c.Server = server
+	}
+
+	return c.client()
+}
+
+func (c *Server) client() (*client.Client, error) {
+	var opts []client.Option
+
+	if c.Timeout > 0 {
+		opts = append(opts, client.WithHTTPClient(&http.Client{Timeout: c.Timeout}))
+	}
+
+	if c.Retries != nil {
+		opts = append(opts, client.WithRetries(*c.Retries, client.DefaultBackoff))
+	}
+
+	return client.New(c.Server, opts...)
+}
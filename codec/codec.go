@@ -0,0 +1,168 @@
+// Package codec converts documents between JSON, YAML and TOML.
+//
+// JSON is canonical representation of schemes and configs, YAML and TOML are
+// converted so types are kept on round-trip: integers stay integers, floats
+// keep decimal point (`1.0`), dates and other strings that look like values
+// of other types stay strings.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Formats of documents
+const (
+	JSON = "json"
+	YAML = "yaml"
+	TOML = "toml"
+)
+
+// ErrUnsupported when document could not be represented in format,
+// e.g. null values or arrays of mixed types in TOML
+var ErrUnsupported = errors.New("document could not be represented in format")
+
+var (
+	// contentTypes of formats, first one is used for responses
+	contentTypes = map[string][]string{
+		JSON: {"application/json"},
+		YAML: {"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"},
+		TOML: {"application/toml", "text/toml", "text/x-toml"},
+	}
+
+	// formats by content type
+	formats = make(map[string]string)
+)
+
+func init() {
+	for format, items := range contentTypes {
+		for _, item := range items {
+			formats[item] = format
+		}
+	}
+}
+
+// Valid checks that format is known
+func Valid(format string) bool {
+	_, ok := contentTypes[format]
+	return ok
+}
+
+// ContentType of format, with charset
+func ContentType(format string) string {
+	return contentTypes[format][0] + "; charset=utf-8"
+}
+
+// FromContentType returns format of Content-Type header, false for unknown types
+func FromContentType(value string) (string, bool) {
+	media, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", false
+	}
+
+	format, ok := formats[media]
+
+	return format, ok
+}
+
+// Negotiate returns first format of Accept header that is known, JSON by default
+func Negotiate(accept string) string {
+	for _, item := range strings.Split(accept, ",") {
+		if format, ok := FromContentType(strings.TrimSpace(item)); ok {
+			return format
+		}
+	}
+
+	return JSON
+}
+
+// Decode converts document of format to JSON
+func Decode(format string, data []byte) (json.RawMessage, error) {
+	var (
+		err error
+		doc interface{}
+	)
+
+	switch format {
+	case JSON:
+		if !json.Valid(data) {
+			return nil, errors.New("could not decode JSON: invalid document")
+		}
+
+		return data, nil
+	case YAML:
+		doc, err = decodeYAML(data)
+	case TOML:
+		doc, err = decodeTOML(data)
+	default:
+		return nil, errors.Errorf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err = writeJSON(&buf, doc); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Tree decodes JSON document, objects are decoded to yaml.MapSlice to keep
+// order of keys, numbers to json.Number to keep their text
+func Tree(data json.RawMessage) (interface{}, error) {
+	return decodeJSON(data)
+}
+
+// Document decodes JSON document to maps and slices like json.Unmarshal,
+// numbers are decoded to json.Number, so large integers are not rounded
+func Document(data []byte) (interface{}, error) {
+	var (
+		doc interface{}
+		dec = json.NewDecoder(bytes.NewReader(data))
+	)
+
+	dec.UseNumber()
+
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "could not decode document")
+	}
+
+	return doc, nil
+}
+
+// Encode converts JSON document to format
+func Encode(format string, data json.RawMessage) ([]byte, error) {
+	if format == JSON {
+		return data, nil
+	}
+
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	switch format {
+	case YAML:
+		err = writeYAML(&buf, doc)
+	case TOML:
+		err = writeTOML(&buf, doc)
+	default:
+		return nil, errors.Errorf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
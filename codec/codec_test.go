@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("Codec Suite", func() {
+	const document = `{"z":1,"a":{"y":true,"b":"c"},"f":1.0,"e":1e5,"d":"2018-11-15","s":"yes","m":"a\nb","l":[1,2],"t":[{"x":1},{"x":2,"n":{"q":"w"}}]}`
+
+	Context("formats", func() {
+		It("should negotiate format of Accept header", func() {
+			Expect(Negotiate("")).To(Equal(JSON))
+			Expect(Negotiate("*/*")).To(Equal(JSON))
+			Expect(Negotiate("text/html, application/x-yaml;q=0.9")).To(Equal(YAML))
+			Expect(Negotiate("application/toml")).To(Equal(TOML))
+		})
+
+		It("should return format of Content-Type header", func() {
+			format, ok := FromContentType("application/yaml; charset=utf-8")
+			Expect(ok).To(BeTrue())
+			Expect(format).To(Equal(YAML))
+
+			_, ok = FromContentType("text/plain")
+			Expect(ok).To(BeFalse())
+
+			Expect(ContentType(TOML)).To(Equal("application/toml; charset=utf-8"))
+			Expect(Valid("xml")).To(BeFalse())
+		})
+	})
+
+	Context("YAML", func() {
+		It("should encode JSON in order of keys", func() {
+			out, err := Encode(YAML, json.RawMessage(document))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(`z: 1
+a:
+  "y": true
+  b: c
+f: 1.0
+e: 1e+5
+d: "2018-11-15"
+s: "yes"
+m: "a\nb"
+l:
+  - 1
+  - 2
+t:
+  - x: 1
+  - x: 2
+    "n":
+      q: w
+`))
+		})
+
+		It("should keep types on round-trip", func() {
+			out, err := Encode(YAML, json.RawMessage(document))
+			Expect(err).NotTo(HaveOccurred())
+
+			data, err := Decode(YAML, out)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"z":1,"a":{"y":true,"b":"c"},"f":1.0,"e":100000.0,"d":"2018-11-15","s":"yes","m":"a\nb","l":[1,2],"t":[{"x":1},{"x":2,"n":{"q":"w"}}]}`))
+		})
+
+		It("should decode dates as strings and floats with decimal point", func() {
+			data, err := Decode(YAML, []byte("d: 2018-11-15\nf: 1.0\ni: 3\nl: [a, 1]\nnothing: ~\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"d":"2018-11-15","f":1.0,"i":3,"l":["a",1],"nothing":null}`))
+		})
+
+		It("should fail on invalid documents", func() {
+			_, err := Decode(YAML, []byte("a: [1"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("TOML", func() {
+		It("should encode JSON as tables", func() {
+			out, err := Encode(TOML, json.RawMessage(document))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(`z = 1
+f = 1.0
+e = 1e5
+d = "2018-11-15"
+s = "yes"
+m = "a\nb"
+l = [1, 2]
+
+[a]
+y = true
+b = "c"
+
+[[t]]
+x = 1
+
+[[t]]
+x = 2
+
+[t.n]
+q = "w"
+`))
+		})
+
+		It("should keep types on round-trip", func() {
+			out, err := Encode(TOML, json.RawMessage(document))
+			Expect(err).NotTo(HaveOccurred())
+
+			data, err := Decode(TOML, out)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"a":{"b":"c","y":true},"d":"2018-11-15","e":100000.0,"f":1.0,"l":[1,2],"m":"a\nb","s":"yes","t":[{"x":1},{"n":{"q":"w"},"x":2}],"z":1}`))
+		})
+
+		It("should decode datetimes as strings", func() {
+			data, err := Decode(TOML, []byte("d = 1979-05-27T07:32:00Z\nf = 1.5\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"d":"1979-05-27T07:32:00Z","f":1.5}`))
+		})
+
+		It("should fail on documents that could not be represented", func() {
+			for _, item := range []string{
+				`[1]`,
+				`{"a":null}`,
+				`{"a":[1,"b"]}`,
+				`{"a":[1,2.5]}`,
+				`{"a":12345678901234567890}`,
+			} {
+				_, err := Encode(TOML, json.RawMessage(item))
+				Expect(errors.Cause(err)).To(Equal(ErrUnsupported), item)
+			}
+		})
+	})
+})
@@ -0,0 +1,197 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// decodeJSON decodes JSON document, objects are decoded to yaml.MapSlice
+// to keep order of keys and numbers to json.Number to keep their text
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	doc, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode JSON")
+	}
+
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errors.New("could not decode JSON: unexpected data after document")
+	}
+
+	return doc, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		var result = yaml.MapSlice{}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, yaml.MapItem{Key: key, Value: value})
+		}
+
+		_, err = dec.Token()
+
+		return result, err
+	case json.Delim('['):
+		var result = []interface{}{}
+
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, value)
+		}
+
+		_, err = dec.Token()
+
+		return result, err
+	}
+
+	return tok, nil
+}
+
+// writeJSON encodes decoded YAML, TOML or JSON document, keys of maps
+// (except yaml.MapSlice) are sorted
+func writeJSON(w *bytes.Buffer, v interface{}) error {
+	switch item := v.(type) {
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(item))
+	case string:
+		writeJSONString(w, item)
+	case json.Number:
+		w.WriteString(item.String())
+	case int:
+		w.WriteString(strconv.Itoa(item))
+	case int64:
+		w.WriteString(strconv.FormatInt(item, 10))
+	case uint64:
+		w.WriteString(strconv.FormatUint(item, 10))
+	case float64:
+		s, err := formatFloat(item)
+		if err != nil {
+			return err
+		}
+
+		w.WriteString(s)
+	case time.Time:
+		writeJSONString(w, item.Format(time.RFC3339Nano))
+	case yaml.MapSlice:
+		w.WriteByte('{')
+
+		for i, member := range item {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+
+			writeJSONString(w, fmt.Sprint(member.Key))
+			w.WriteByte(':')
+
+			if err := writeJSON(w, member.Value); err != nil {
+				return err
+			}
+		}
+
+		w.WriteByte('}')
+	case map[string]interface{}:
+		var (
+			keys   = make([]string, 0, len(item))
+			sorted = make(yaml.MapSlice, 0, len(item))
+		)
+
+		for key := range item {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			sorted = append(sorted, yaml.MapItem{Key: key, Value: item[key]})
+		}
+
+		return writeJSON(w, sorted)
+	case map[interface{}]interface{}:
+		var sorted = make(yaml.MapSlice, 0, len(item))
+
+		for key, value := range item {
+			sorted = append(sorted, yaml.MapItem{Key: key, Value: value})
+		}
+
+		sort.Slice(sorted, func(i, j int) bool {
+			return fmt.Sprint(sorted[i].Key) < fmt.Sprint(sorted[j].Key)
+		})
+
+		return writeJSON(w, sorted)
+	case []interface{}:
+		w.WriteByte('[')
+
+		for i, value := range item {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+
+			if err := writeJSON(w, value); err != nil {
+				return err
+			}
+		}
+
+		w.WriteByte(']')
+	default:
+		return errors.Errorf("unsupported value %v of type %T", v, v)
+	}
+
+	return nil
+}
+
+func writeJSONString(w *bytes.Buffer, s string) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // string could always be encoded
+
+	w.Truncate(w.Len() - 1) // newline of Encode
+}
+
+// formatFloat keeps decimal point or exponent, so float is not read as integer
+func formatFloat(f float64) (string, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", errors.Wrapf(ErrUnsupported, "%v could not be represented in JSON", f)
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+
+	return s, nil
+}
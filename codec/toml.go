@@ -0,0 +1,260 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// decodeTOML decodes TOML document, datetimes are converted to RFC 3339 strings
+func decodeTOML(data []byte) (interface{}, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode TOML")
+	}
+
+	return tomlValue(tree), nil
+}
+
+// tomlValue converts trees of tables to maps
+func tomlValue(v interface{}) interface{} {
+	switch item := v.(type) {
+	case *toml.Tree:
+		return tomlValue(item.ToMap())
+	case []*toml.Tree:
+		var result = make([]interface{}, 0, len(item))
+
+		for _, tree := range item {
+			result = append(result, tomlValue(tree))
+		}
+
+		return result
+	case map[string]interface{}:
+		for key, value := range item {
+			item[key] = tomlValue(value)
+		}
+	case []interface{}:
+		for i, value := range item {
+			item[i] = tomlValue(value)
+		}
+	}
+
+	return v
+}
+
+// writeTOML writes JSON document (see decodeJSON), document should be object
+func writeTOML(w *bytes.Buffer, v interface{}) error {
+	m, ok := v.(yaml.MapSlice)
+	if !ok {
+		return errors.Wrap(ErrUnsupported, "TOML document should be table")
+	}
+
+	return writeTOMLTable(w, nil, m, "")
+}
+
+// writeTOMLTable writes header of table (empty for root), key/value pairs
+// and then sub-tables and arrays of tables
+func writeTOMLTable(w *bytes.Buffer, path []string, m yaml.MapSlice, header string) error {
+	var tables []yaml.MapItem
+
+	if header != "" {
+		if w.Len() > 0 {
+			w.WriteString("\n")
+		}
+
+		w.WriteString(header + "\n")
+	}
+
+	for _, member := range m {
+		key := fmt.Sprint(member.Key)
+
+		if isTable(member.Value) || isTableArray(member.Value) {
+			tables = append(tables, member)
+			continue
+		}
+
+		value, err := tomlInline(member.Value)
+		if err != nil {
+			return errors.Wrapf(err, "key %s", strings.Join(append(path, key), "."))
+		}
+
+		w.WriteString(tomlKey(key) + " = " + value + "\n")
+	}
+
+	for _, member := range tables {
+		var (
+			err  error
+			key  = fmt.Sprint(member.Key)
+			sub  = append(append([]string{}, path...), key)
+			name = tomlPath(sub)
+		)
+
+		if table, ok := member.Value.(yaml.MapSlice); ok {
+			if err = writeTOMLTable(w, sub, table, "["+name+"]"); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for _, item := range member.Value.([]interface{}) {
+			if err = writeTOMLTable(w, sub, item.(yaml.MapSlice), "[["+name+"]]"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func isTable(v interface{}) bool {
+	_, ok := v.(yaml.MapSlice)
+	return ok
+}
+
+// isTableArray checks that value is non-empty array of objects
+func isTableArray(v interface{}) bool {
+	items, ok := v.([]interface{})
+	if !ok || len(items) == 0 {
+		return false
+	}
+
+	for _, item := range items {
+		if !isTable(item) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tomlInline formats value of key or array, objects are written as inline tables
+func tomlInline(v interface{}) (string, error) {
+	switch item := v.(type) {
+	case nil:
+		return "", errors.Wrap(ErrUnsupported, "TOML has no null value")
+	case bool:
+		return strconv.FormatBool(item), nil
+	case json.Number:
+		if _, err := item.Int64(); err == nil || strings.ContainsAny(item.String(), ".eE") {
+			return item.String(), nil
+		}
+
+		return "", errors.Wrapf(ErrUnsupported, "integer %s overflows TOML integer", item)
+	case string:
+		return tomlString(item), nil
+	case []interface{}:
+		var values = make([]string, 0, len(item))
+
+		for i, value := range item {
+			if i > 0 && tomlKind(value) != tomlKind(item[0]) {
+				return "", errors.Wrap(ErrUnsupported, "TOML array should contain values of one type")
+			}
+
+			s, err := tomlInline(value)
+			if err != nil {
+				return "", err
+			}
+
+			values = append(values, s)
+		}
+
+		return "[" + strings.Join(values, ", ") + "]", nil
+	case yaml.MapSlice:
+		var values = make([]string, 0, len(item))
+
+		for _, member := range item {
+			s, err := tomlInline(member.Value)
+			if err != nil {
+				return "", err
+			}
+
+			values = append(values, tomlKey(fmt.Sprint(member.Key))+" = "+s)
+		}
+
+		if len(values) == 0 {
+			return "{}", nil
+		}
+
+		return "{ " + strings.Join(values, ", ") + " }", nil
+	}
+
+	return "", errors.Errorf("unsupported value %v of type %T", v, v)
+}
+
+// tomlKind of value, values of array should be of one kind
+func tomlKind(v interface{}) string {
+	if n, ok := v.(json.Number); ok {
+		if strings.ContainsAny(n.String(), ".eE") {
+			return "float"
+		}
+
+		return "integer"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+
+	return tomlString(key)
+}
+
+func tomlPath(keys []string) string {
+	var items = make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		items = append(items, tomlKey(key))
+	}
+
+	return strings.Join(items, ".")
+}
+
+// tomlString is basic string with escaped quotes, backslashes and control characters
+func tomlString(s string) string {
+	var buf strings.Builder
+
+	buf.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+				continue
+			}
+
+			buf.WriteRune(r)
+		}
+	}
+
+	buf.WriteByte('"')
+
+	return buf.String()
+}
@@ -0,0 +1,189 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// decodeYAML decodes YAML document, mappings are decoded to yaml.MapSlice to keep order of keys
+func decodeYAML(data []byte) (interface{}, error) {
+	var (
+		doc interface{}
+		ms  yaml.MapSlice
+	)
+
+	if err := yaml.Unmarshal(data, &ms); err == nil {
+		return ms, nil
+	}
+
+	// document is not mapping:
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not decode YAML")
+	}
+
+	return doc, nil
+}
+
+// writeYAML writes JSON document (see decodeJSON) as block YAML
+func writeYAML(w *bytes.Buffer, v interface{}) error {
+	switch item := v.(type) {
+	case yaml.MapSlice:
+		if len(item) > 0 {
+			return writeYAMLMap(w, item, "", false)
+		}
+	case []interface{}:
+		if len(item) > 0 {
+			return writeYAMLList(w, item, "")
+		}
+	}
+
+	s, err := yamlScalar(v)
+	if err != nil {
+		return err
+	}
+
+	w.WriteString(s + "\n")
+
+	return nil
+}
+
+// writeYAMLMap writes mapping, first key of mapping that is item of list is written after dash
+func writeYAMLMap(w *bytes.Buffer, m yaml.MapSlice, indent string, item bool) error {
+	for i, member := range m {
+		key, err := yamlScalar(fmt.Sprint(member.Key))
+		if err != nil {
+			return err
+		}
+
+		if i > 0 || !item {
+			w.WriteString(indent)
+		}
+
+		w.WriteString(key + ":")
+
+		switch value := member.Value.(type) {
+		case yaml.MapSlice:
+			if len(value) > 0 {
+				w.WriteString("\n")
+				err = writeYAMLMap(w, value, indent+"  ", false)
+				break
+			}
+
+			err = writeYAMLScalar(w, value)
+		case []interface{}:
+			if len(value) > 0 {
+				w.WriteString("\n")
+				err = writeYAMLList(w, value, indent+"  ")
+				break
+			}
+
+			err = writeYAMLScalar(w, value)
+		default:
+			err = writeYAMLScalar(w, value)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeYAMLList(w *bytes.Buffer, l []interface{}, indent string) error {
+	for _, value := range l {
+		var err error
+
+		w.WriteString(indent + "-")
+
+		switch item := value.(type) {
+		case yaml.MapSlice:
+			if len(item) > 0 {
+				w.WriteString(" ")
+				err = writeYAMLMap(w, item, indent+"  ", true)
+				break
+			}
+
+			err = writeYAMLScalar(w, item)
+		case []interface{}:
+			if len(item) > 0 {
+				w.WriteString("\n")
+				err = writeYAMLList(w, item, indent+"  ")
+				break
+			}
+
+			err = writeYAMLScalar(w, item)
+		default:
+			err = writeYAMLScalar(w, item)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeYAMLScalar writes scalar or empty collection after key or dash
+func writeYAMLScalar(w *bytes.Buffer, v interface{}) error {
+	s, err := yamlScalar(v)
+	if err != nil {
+		return err
+	}
+
+	w.WriteString(" " + s + "\n")
+
+	return nil
+}
+
+// yamlScalar formats scalar of JSON document, strings are quoted when they
+// could be read as value of other type, numbers keep their JSON text
+func yamlScalar(v interface{}) (string, error) {
+	switch item := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return fmt.Sprint(item), nil
+	case json.Number:
+		return yamlNumber(item.String()), nil
+	case string:
+		out, err := yaml.Marshal(item)
+		if err != nil {
+			return "", errors.Wrap(err, "could not encode YAML")
+		}
+
+		// multi-line strings are written as block scalars, JSON string is valid YAML scalar:
+		if s := strings.TrimSuffix(string(out), "\n"); !strings.Contains(s, "\n") {
+			return s, nil
+		}
+
+		var buf bytes.Buffer
+
+		writeJSONString(&buf, item)
+
+		return buf.String(), nil
+	case yaml.MapSlice:
+		return "{}", nil
+	case []interface{}:
+		return "[]", nil
+	}
+
+	return "", errors.Errorf("unsupported value %v of type %T", v, v)
+}
+
+// yamlNumber adds sign to exponent of floats (1e5 => 1e+5),
+// otherwise YAML 1.1 reads them as strings
+func yamlNumber(s string) string {
+	i := strings.IndexAny(s, "eE")
+	if i < 0 || strings.ContainsAny(s[i+1:], "+-") {
+		return s
+	}
+
+	return s[:i+1] + "+" + s[i+1:]
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Expr is a node of parsed filter expression
+	Expr interface {
+		Pos() int
+		String() string
+	}
+
+	// Logical joins two expressions with AND / OR
+	Logical struct {
+		Op    Token
+		Left  Expr
+		Right Expr
+	}
+
+	// Not negates expression
+	Not struct {
+		At int
+		X  Expr
+	}
+
+	// Compare checks field against value
+	Compare struct {
+		Field Field
+		Op    Token
+		Value Value
+	}
+
+	// Field of filtered entity, for example `scheme_id` or `data.db.host`
+	Field struct {
+		At   int
+		Name string
+		Path []string
+	}
+
+	// Value literal: string, number, date, boolean or null
+	Value struct {
+		At     int
+		Kind   Kind
+		Raw    string
+		Str    string
+		Number float64
+		Bool   bool
+		Time   time.Time
+	}
+)
+
+func (e *Logical) Pos() int { return e.Left.Pos() }
+func (e *Not) Pos() int     { return e.At }
+func (e *Compare) Pos() int { return e.Field.At }
+
+func (e *Logical) String() string {
+	return "(" + e.Left.String() + " " + e.Op.String() + " " + e.Right.String() + ")"
+}
+
+func (e *Not) String() string {
+	return "NOT " + e.X.String()
+}
+
+func (e *Compare) String() string {
+	return e.Field.String() + " " + e.Op.String() + " " + e.Value.Raw
+}
+
+// String returns dotted name of field
+func (f Field) String() string {
+	return strings.Join(append([]string{f.Name}, f.Path...), ".")
+}
+
+// IsInteger checks that number value has no fraction part
+func (v Value) IsInteger() bool {
+	if v.Kind != KindNumber {
+		return false
+	}
+
+	_, err := strconv.ParseInt(v.Raw, 10, 64)
+	return err == nil
+}
+
+// Interface returns Go representation of value
+func (v Value) Interface() interface{} {
+	switch v.Kind {
+	case KindString:
+		return v.Str
+	case KindNumber:
+		return v.Number
+	case KindBool:
+		return v.Bool
+	case KindDate:
+		return v.Time
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package filter_test
+
+import (
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/filter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter Suite", func() {
+	var fields = filter.Fields{
+		"id":         filter.Integer,
+		"scheme_id":  filter.Integer,
+		"tags":       filter.Set,
+		"data":       filter.Document,
+		"created_at": filter.Time,
+	}
+
+	Context("parse expressions", func() {
+		It("should parse full example into typed AST", func() {
+			expr, err := filter.Parse(`scheme_id = 4 AND tags has "prod" AND data.replicas >= 3 AND created_at > 2026-01-01`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expr.String()).To(Equal(`(((scheme_id = 4 AND tags has "prod") AND data.replicas >= 3) AND created_at > 2026-01-01)`))
+
+			root, ok := expr.(*filter.Logical)
+			Expect(ok).To(BeTrue())
+
+			cmp, ok := root.Right.(*filter.Compare)
+			Expect(ok).To(BeTrue())
+			Expect(cmp.Field.Name).To(Equal("created_at"))
+			Expect(cmp.Op).To(Equal(filter.GT))
+			Expect(cmp.Value.Kind).To(Equal(filter.KindDate))
+			Expect(cmp.Value.Time).To(Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+			Expect(filter.Validate(expr, fields)).NotTo(HaveOccurred())
+		})
+
+		It("should respect operators precedence and parentheses", func() {
+			expr, err := filter.Parse(`NOT id = 1 OR id = 2 and (id = 3 OR data.a.0.b != null)`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expr.String()).To(Equal(`(NOT id = 1 OR (id = 2 AND (id = 3 OR data.a.0.b != null)))`))
+		})
+
+		It("should parse string escapes and negative numbers", func() {
+			expr, err := filter.Parse(`data."some key" = 'it\'s' AND data.delta > -1.5`)
+			Expect(err).NotTo(HaveOccurred())
+
+			root := expr.(*filter.Logical)
+			left, right := root.Left.(*filter.Compare), root.Right.(*filter.Compare)
+
+			Expect(left.Field.Path).To(Equal([]string{"some key"}))
+			Expect(left.Value.Str).To(Equal("it's"))
+			Expect(right.Value.Number).To(Equal(-1.5))
+		})
+
+		It("should report syntax errors with positions", func() {
+			var cases = []struct {
+				filter string
+				pos    int
+				msg    string
+			}{
+				{filter: ``, pos: 1, msg: "empty filter"},
+				{filter: `id =`, pos: 5, msg: "expected value"},
+				{filter: `id = 1 AND`, pos: 11, msg: "expected field name"},
+				{filter: `id 1`, pos: 4, msg: "expected comparison operator"},
+				{filter: `(id = 1`, pos: 8, msg: "expected )"},
+				{filter: `id = 1 id = 2`, pos: 8, msg: "expected AND, OR or end of filter"},
+				{filter: `data.name = "abc`, pos: 13, msg: "unterminated string"},
+				{filter: `id ! 1`, pos: 4, msg: "expected !="},
+				{filter: `created_at > 2026-13-45`, pos: 14, msg: "malformed date"},
+			}
+
+			for _, item := range cases {
+				_, err := filter.Parse(item.filter)
+				Expect(err).To(HaveOccurred(), item.filter)
+
+				ferr, ok := err.(*filter.Error)
+				Expect(ok).To(BeTrue(), item.filter)
+				Expect(ferr.Pos).To(Equal(item.pos), item.filter)
+				Expect(ferr.Msg).To(ContainSubstring(item.msg), item.filter)
+			}
+		})
+	})
+
+	Context("validate expressions", func() {
+		It("should report invalid fields and operators with positions", func() {
+			var cases = []struct {
+				filter string
+				pos    int
+				msg    string
+			}{
+				{filter: `version = 1`, pos: 1, msg: `unknown field "version"`},
+				{filter: `id = 1 AND tags = "prod"`, pos: 12, msg: "supports only `has`"},
+				{filter: `tags has 1`, pos: 10, msg: "expects string"},
+				{filter: `scheme_id = 1.5`, pos: 13, msg: "expects integer"},
+				{filter: `created_at > "yesterday"`, pos: 14, msg: "expects date"},
+				{filter: `data = 1`, pos: 1, msg: "requires path"},
+				{filter: `id.value = 1`, pos: 1, msg: "has no nested fields"},
+				{filter: `data.enabled > true`, pos: 16, msg: "could not be used with boolean"},
+				{filter: `id has 1`, pos: 1, msg: "could not be used"},
+			}
+
+			for _, item := range cases {
+				expr, err := filter.Parse(item.filter)
+				Expect(err).NotTo(HaveOccurred(), item.filter)
+
+				err = filter.Validate(expr, fields)
+				Expect(err).To(HaveOccurred(), item.filter)
+
+				ferr, ok := err.(*filter.Error)
+				Expect(ok).To(BeTrue(), item.filter)
+				Expect(ferr.Pos).To(Equal(item.pos), item.filter)
+				Expect(ferr.Msg).To(ContainSubstring(item.msg), item.filter)
+			}
+		})
+	})
+})
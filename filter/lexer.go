@@ -0,0 +1,252 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token of filter expression
+type Token int
+
+// Kind of literal value
+type Kind int
+
+const (
+	EOF Token = iota
+	IDENT
+	STRING
+	NUMBER
+	DATE
+	LPAREN // (
+	RPAREN // )
+	DOT    // .
+
+	AND
+	OR
+	NOT
+	TRUE
+	FALSE
+	NULL
+
+	EQ  // =
+	NEQ // !=
+	LT  // <
+	LTE // <=
+	GT  // >
+	GTE // >=
+	HAS // has
+)
+
+const (
+	KindNull Kind = iota
+	KindString
+	KindNumber
+	KindBool
+	KindDate
+)
+
+var tokens = map[Token]string{
+	EOF:    "end of filter",
+	IDENT:  "identifier",
+	STRING: "string",
+	NUMBER: "number",
+	DATE:   "date",
+	LPAREN: "(",
+	RPAREN: ")",
+	DOT:    ".",
+	AND:    "AND",
+	OR:     "OR",
+	NOT:    "NOT",
+	TRUE:   "true",
+	FALSE:  "false",
+	NULL:   "null",
+	EQ:     "=",
+	NEQ:    "!=",
+	LT:     "<",
+	LTE:    "<=",
+	GT:     ">",
+	GTE:    ">=",
+	HAS:    "has",
+}
+
+var keywords = map[string]Token{
+	"and":   AND,
+	"or":    OR,
+	"not":   NOT,
+	"true":  TRUE,
+	"false": FALSE,
+	"null":  NULL,
+	"has":   HAS,
+}
+
+var kinds = map[Kind]string{
+	KindNull:   "null",
+	KindString: "string",
+	KindNumber: "number",
+	KindBool:   "boolean",
+	KindDate:   "date",
+}
+
+func (t Token) String() string { return tokens[t] }
+func (k Kind) String() string  { return kinds[k] }
+
+// IsComparison returns true for =, !=, <, <=, >, >= and has
+func (t Token) IsComparison() bool { return t >= EQ && t <= HAS }
+
+// IsOrdering returns true for <, <=, > and >=
+func (t Token) IsOrdering() bool { return t >= LT && t <= GTE }
+
+type lexer struct {
+	src []rune
+	pos int
+}
+
+type item struct {
+	tok Token
+	pos int
+	lit string
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// next scans next token, position is zero-based offset in runes
+func (l *lexer) next() (item, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(l.src[l.pos]) {
+		l.pos++
+	}
+
+	start := l.pos
+
+	if l.pos >= len(l.src) {
+		return item{tok: EOF, pos: start}, nil
+	}
+
+	r := l.src[l.pos]
+
+	switch {
+	case r == '(':
+		l.pos++
+		return item{tok: LPAREN, pos: start, lit: "("}, nil
+	case r == ')':
+		l.pos++
+		return item{tok: RPAREN, pos: start, lit: ")"}, nil
+	case r == '.':
+		l.pos++
+		return item{tok: DOT, pos: start, lit: "."}, nil
+	case r == '=':
+		l.pos++
+		return item{tok: EQ, pos: start, lit: "="}, nil
+	case r == '!':
+		if l.peek(1) == '=' {
+			l.pos += 2
+			return item{tok: NEQ, pos: start, lit: "!="}, nil
+		}
+		return item{}, errorf(start, "unexpected %q, expected !=", r)
+	case r == '<':
+		if l.peek(1) == '=' {
+			l.pos += 2
+			return item{tok: LTE, pos: start, lit: "<="}, nil
+		} else if l.peek(1) == '>' {
+			l.pos += 2
+			return item{tok: NEQ, pos: start, lit: "<>"}, nil
+		}
+		l.pos++
+		return item{tok: LT, pos: start, lit: "<"}, nil
+	case r == '>':
+		if l.peek(1) == '=' {
+			l.pos += 2
+			return item{tok: GTE, pos: start, lit: ">="}, nil
+		}
+		l.pos++
+		return item{tok: GT, pos: start, lit: ">"}, nil
+	case r == '"' || r == '\'':
+		return l.string(r)
+	case r == '-' || unicode.IsDigit(r):
+		return l.number()
+	case isIdentStart(r):
+		for l.pos < len(l.src) && isIdent(l.src[l.pos]) {
+			l.pos++
+		}
+
+		lit := string(l.src[start:l.pos])
+		if tok, ok := keywords[strings.ToLower(lit)]; ok {
+			return item{tok: tok, pos: start, lit: lit}, nil
+		}
+
+		return item{tok: IDENT, pos: start, lit: lit}, nil
+	}
+
+	return item{}, errorf(start, "unexpected character %q", r)
+}
+
+func (l *lexer) peek(n int) rune {
+	if l.pos+n >= len(l.src) {
+		return 0
+	}
+
+	return l.src[l.pos+n]
+}
+
+func (l *lexer) string(quote rune) (item, error) {
+	var (
+		start = l.pos
+		buf   strings.Builder
+	)
+
+	for l.pos++; l.pos < len(l.src); l.pos++ {
+		switch r := l.src[l.pos]; r {
+		case quote:
+			l.pos++
+			return item{tok: STRING, pos: start, lit: buf.String()}, nil
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.src) {
+				return item{}, errorf(start, "unterminated string")
+			}
+
+			switch esc := l.src[l.pos]; esc {
+			case 'n':
+				buf.WriteRune('\n')
+			case 't':
+				buf.WriteRune('\t')
+			case '\\', '"', '\'':
+				buf.WriteRune(esc)
+			default:
+				return item{}, errorf(l.pos-1, "unknown escape sequence \\%c", esc)
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+
+	return item{}, errorf(start, "unterminated string")
+}
+
+// number scans numbers (-1, 3, 2.5, 1e3) and dates (2026-01-01, 2026-01-01T10:00:00Z)
+func (l *lexer) number() (item, error) {
+	start := l.pos
+
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if unicode.IsDigit(r) || unicode.IsLetter(r) || strings.ContainsRune("-+.:", r) {
+			l.pos++
+			continue
+		}
+
+		break
+	}
+
+	lit := string(l.src[start:l.pos])
+
+	if len(lit) >= 10 && lit[4] == '-' && lit[7] == '-' {
+		return item{tok: DATE, pos: start, lit: lit}, nil
+	}
+
+	return item{tok: NUMBER, pos: start, lit: lit}, nil
+}
@@ -0,0 +1,263 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error of parsing or validation with position (1-based, in characters) in filter expression
+type Error struct {
+	Pos int
+	Msg string
+}
+
+type parser struct {
+	lex lexer
+	cur item
+}
+
+// dateLayouts that supported by filter literals
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("filter: position %d: %s", e.Pos, e.Msg)
+}
+
+// Parse filter expression, for example:
+//
+//	scheme_id = 4 AND tags has "prod" AND data.replicas >= 3 AND created_at > 2026-01-01
+func Parse(src string) (Expr, error) {
+	p := &parser{lex: lexer{src: []rune(src)}}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.cur.tok == EOF {
+		return nil, errorf(p.cur.pos, "empty filter")
+	}
+
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.cur.tok != EOF {
+		return nil, p.unexpected("AND, OR or end of filter")
+	}
+
+	return expr, nil
+}
+
+func (p *parser) advance() error {
+	next, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+
+	p.cur = next
+	return nil
+}
+
+func (p *parser) unexpected(expected string) *Error {
+	found := p.cur.tok.String()
+	if p.cur.lit != "" {
+		found = strconv.Quote(p.cur.lit)
+	}
+
+	return errorf(p.cur.pos, "unexpected %s, expected %s", found, expected)
+}
+
+// or := and { OR and }
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.cur.tok == OR {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Logical{Op: OR, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// and := not { AND not }
+func (p *parser) and() (Expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.cur.tok == AND {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Logical{Op: AND, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// not := NOT not | primary
+func (p *parser) not() (Expr, error) {
+	if p.cur.tok != NOT {
+		return p.primary()
+	}
+
+	pos := p.cur.pos
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	x, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Not{At: pos, X: x}, nil
+}
+
+// primary := "(" or ")" | field op value
+func (p *parser) primary() (Expr, error) {
+	if p.cur.tok == LPAREN {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.cur.tok != RPAREN {
+			return nil, p.unexpected(")")
+		}
+
+		return expr, p.advance()
+	}
+
+	field, err := p.field()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.cur.tok.IsComparison() {
+		return nil, p.unexpected("comparison operator")
+	}
+
+	cmp := &Compare{Field: field, Op: p.cur.tok}
+	if err = p.advance(); err != nil {
+		return nil, err
+	}
+
+	if cmp.Value, err = p.value(); err != nil {
+		return nil, err
+	}
+
+	return cmp, nil
+}
+
+// field := IDENT { "." IDENT }
+func (p *parser) field() (Field, error) {
+	if p.cur.tok != IDENT {
+		return Field{}, p.unexpected("field name")
+	}
+
+	field := Field{At: p.cur.pos, Name: p.cur.lit}
+	if err := p.advance(); err != nil {
+		return field, err
+	}
+
+	for p.cur.tok == DOT {
+		if err := p.advance(); err != nil {
+			return field, err
+		}
+
+		switch p.cur.tok {
+		case IDENT, STRING: // data.items or data."some key"
+			field.Path = append(field.Path, p.cur.lit)
+		case NUMBER: // data.items.0 or data.items.0.name
+			field.Path = append(field.Path, strings.Split(p.cur.lit, ".")...)
+		default:
+			return field, p.unexpected("field name")
+		}
+
+		if err := p.advance(); err != nil {
+			return field, err
+		}
+	}
+
+	return field, nil
+}
+
+func (p *parser) value() (Value, error) {
+	var (
+		err error
+		val = Value{At: p.cur.pos, Raw: p.cur.lit}
+	)
+
+	switch p.cur.tok {
+	case STRING:
+		val.Kind = KindString
+		val.Str = p.cur.lit
+		val.Raw = strconv.Quote(p.cur.lit)
+	case NUMBER:
+		val.Kind = KindNumber
+		if val.Number, err = strconv.ParseFloat(p.cur.lit, 64); err != nil {
+			return val, errorf(p.cur.pos, "malformed number %q", p.cur.lit)
+		}
+	case DATE:
+		val.Kind = KindDate
+		if val.Time, err = ParseDate(p.cur.lit); err != nil {
+			return val, errorf(p.cur.pos, "malformed date %q", p.cur.lit)
+		}
+	case TRUE, FALSE:
+		val.Kind = KindBool
+		val.Bool = p.cur.tok == TRUE
+	case NULL:
+		val.Kind = KindNull
+	default:
+		return val, p.unexpected("value")
+	}
+
+	return val, p.advance()
+}
+
+// ParseDate parses dates in formats supported by filter literals:
+// 2026-01-01, 2026-01-01T10:00:00 or RFC3339
+func ParseDate(lit string) (t time.Time, err error) {
+	for _, layout := range dateLayouts {
+		if t, err = time.Parse(layout, lit); err == nil {
+			return
+		}
+	}
+
+	return
+}
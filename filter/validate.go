@@ -0,0 +1,93 @@
+package filter
+
+// Type of filterable field
+type Type int
+
+// Fields describes which fields could be used in filter and their types
+type Fields map[string]Type
+
+const (
+	// Integer fields, e.g. id, scheme_id, version
+	Integer Type = iota
+	// String fields
+	String
+	// Time fields, e.g. created_at
+	Time
+	// Set of strings, e.g. tags, supports only `has`
+	Set
+	// Document is JSON document, field must be used with path: data.db.host
+	Document
+)
+
+// Validate checks that expression uses only known fields
+// and that operators and values are suitable for field types.
+func Validate(expr Expr, fields Fields) error {
+	switch e := expr.(type) {
+	case *Logical:
+		if err := Validate(e.Left, fields); err != nil {
+			return err
+		}
+
+		return Validate(e.Right, fields)
+	case *Not:
+		return Validate(e.X, fields)
+	case *Compare:
+		return validateCompare(e, fields)
+	}
+
+	return errorf(expr.Pos(), "unknown expression %s", expr)
+}
+
+func validateCompare(e *Compare, fields Fields) error {
+	typ, ok := fields[e.Field.Name]
+	if !ok {
+		return errorf(e.Field.At, "unknown field %q", e.Field.Name)
+	}
+
+	if typ == Document {
+		if len(e.Field.Path) == 0 {
+			return errorf(e.Field.At, "field %q requires path, for example %s.key", e.Field.Name, e.Field.Name)
+		}
+
+		if e.Op.IsOrdering() {
+			switch e.Value.Kind {
+			case KindNumber, KindString, KindDate:
+			default:
+				return errorf(e.Value.At, "operator %s could not be used with %s", e.Op, e.Value.Kind)
+			}
+		}
+
+		return nil
+	} else if len(e.Field.Path) > 0 {
+		return errorf(e.Field.At, "field %q has no nested fields", e.Field.Name)
+	}
+
+	switch typ {
+	case Set:
+		if e.Op != HAS {
+			return errorf(e.Field.At, "field %q supports only `has` operator", e.Field.Name)
+		} else if e.Value.Kind != KindString {
+			return errorf(e.Value.At, "field %q expects string, got %s", e.Field.Name, e.Value.Kind)
+		}
+
+		return nil
+	case Integer:
+		if !e.Value.IsInteger() {
+			return errorf(e.Value.At, "field %q expects integer, got %s", e.Field.Name, e.Value.Raw)
+		}
+	case String:
+		if e.Value.Kind != KindString {
+			return errorf(e.Value.At, "field %q expects string, got %s", e.Field.Name, e.Value.Kind)
+		}
+	case Time:
+		if e.Value.Kind != KindDate {
+			return errorf(e.Value.At, "field %q expects date, got %s", e.Field.Name, e.Value.Kind)
+		}
+	}
+
+	if e.Op == HAS {
+		return errorf(e.Field.At, "operator `has` could not be used with field %q", e.Field.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package gitops
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Lines of version to compare, tags are first line and keys of data
+// are sorted, so only changed values are shown
+func Lines(tags []string, data json.RawMessage) ([]string, error) {
+	var (
+		buf strings.Builder
+		doc interface{}
+	)
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not decode data")
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not format data")
+	}
+
+	buf.WriteString("tags: " + strings.Join(tags, ","))
+	buf.WriteString("\n")
+	buf.Write(out)
+
+	return strings.Split(buf.String(), "\n"), nil
+}
+
+// Diff returns lines of a and b prefixed by `-` (removed), `+` (added)
+// or space (unchanged), based on longest common subsequence
+func Diff(a, b []string) []string {
+	var (
+		result []string
+		lcs    = make([][]int, len(a)+1)
+	)
+
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, "-"+a[i])
+			i++
+		default:
+			result = append(result, "+"+b[j])
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		result = append(result, "-"+a[i])
+	}
+
+	for ; j < len(b); j++ {
+		result = append(result, "+"+b[j])
+	}
+
+	return result
+}
@@ -0,0 +1,286 @@
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/helium"
+	"github.com/im-kulikov/helium/grace"
+	"github.com/im-kulikov/helium/logger"
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/helium/orm"
+	"github.com/im-kulikov/helium/settings"
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var testModule = module.Module{}.Append(
+	grace.Module,
+	settings.Module,
+	logger.Module,
+	orm.Module,
+)
+
+var _ = Describe("GitOps Suite", func() {
+	Context("Resources", func() {
+		It("should parse documents and skip empty ones", func() {
+			items, err := Parse("all.yaml", []byte(`
+kind: scheme
+slug: person
+tags: [prod]
+data:
+  type: object
+  required: [name]
+---
+---
+kind: config
+scheme: person
+slug: john
+tags: [prod, eu]
+data: {name: John, age: 42}
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(2))
+			Expect(items[0].Data).To(MatchJSON(`{"type":"object","required":["name"]}`))
+			Expect(items[1].Scheme).To(Equal("person"))
+			Expect(items[1].Data).To(MatchJSON(`{"name":"John","age":42}`))
+		})
+
+		It("should infer kind, slug and scheme from path", func() {
+			items, err := Parse("configs/person/john.yaml", []byte("tags: [prod]\ndata: {name: John}\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Kind).To(Equal(KindConfig))
+			Expect(items[0].Scheme).To(Equal("person"))
+			Expect(items[0].Slug).To(Equal("john"))
+		})
+
+		It("should reject invalid resources", func() {
+			_, err := Parse("john.yaml", []byte("kind: config\nslug: john\ntags: [a]\ndata: {}\n"))
+			Expect(err).To(MatchError(ContainSubstring("scheme of config \"john\" could not be empty")))
+
+			_, err = Parse("john.yaml", []byte("kind: secret\nslug: john\n"))
+			Expect(err).To(BeAssignableToTypeOf(&Error{}))
+			Expect(err).To(MatchError(ContainSubstring("kind should be")))
+		})
+
+		It("should load directory and reject duplicates", func() {
+			dir, err := ioutil.TempDir("", "gitops")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			write := func(name, data string) {
+				Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)).To(Succeed())
+			}
+
+			write("schemes/person.yaml", "tags: [prod]\ndata: {type: object}\n")
+			write("configs/person/john.json", `{"tags": ["prod"], "data": {"name": "John"}}`)
+			write(".git/config.yaml", "not: resource\n")
+			write("README.md", "# resources\n")
+
+			items, err := Load(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(2))
+
+			write("configs/john.yaml", "kind: config\nscheme: person\nslug: john\ntags: [prod]\ndata: {}\n")
+
+			_, err = Load(dir)
+			Expect(err).To(MatchError(ContainSubstring("already described")))
+		})
+
+		It("should read YAML document as JSON", func() {
+			data, err := JSON([]byte("port: 8080\nhosts:\n  - a\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{"port":8080,"hosts":["a"]}`))
+		})
+
+		It("should compare documents regardless of formatting", func() {
+			Expect(sameDocument(json.RawMessage(`{"a":1,"b":[1,2]}`), json.RawMessage(`{ "b": [1, 2], "a": 1 }`))).To(BeTrue())
+			Expect(sameDocument(json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":2}`))).To(BeFalse())
+		})
+
+		It("should compare masked secrets of config by opened values", func() {
+			var (
+				calls int
+				same  bool
+				item  = &Resource{Kind: KindConfig, Slug: "db", Tags: []string{"a"}, Data: json.RawMessage(`{"user":"admin","password":"qwerty"}`)}
+				open  = func() (bool, error) { calls++; return same, nil }
+			)
+
+			same = true
+			ch, err := changed(item, 1, 1, []string{"a"}, json.RawMessage(`{"user":"admin","password":"********"}`), open)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ch).To(BeNil())
+
+			same = false
+			ch, err = changed(item, 1, 1, []string{"a"}, json.RawMessage(`{"user":"admin","password":"********"}`), open)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ch).NotTo(BeNil())
+			Expect(ch.Action).To(Equal(ActionUpdate))
+
+			ch, err = changed(item, 1, 1, []string{"a"}, json.RawMessage(`{"user":"root","password":"********"}`), open)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ch).NotTo(BeNil())
+			Expect(calls).To(Equal(2))
+		})
+	})
+
+	Context("Diff", func() {
+		It("should show removed and added lines", func() {
+			Expect(Diff(
+				[]string{"{", `  "a": 1,`, `  "b": 2`, "}"},
+				[]string{"{", `  "a": 1,`, `  "b": 3`, "}"},
+			)).To(Equal([]string{" {", `   "a": 1,`, `-  "b": 2`, `+  "b": 3`, " }"}))
+		})
+	})
+
+	Context("Plan and apply", func() {
+		var (
+			db  *pg.DB
+			tx  store.Transactions
+			ctx = context.Background()
+		)
+
+		resources := func(docs string) []*Resource {
+			items, err := Parse("resources.yaml", []byte(docs))
+			Expect(err).NotTo(HaveOccurred())
+			return items
+		}
+
+		BeforeEach(func() {
+			h, err := helium.New(&helium.Settings{
+				File:   "../config.yml",
+				Prefix: "TEST",
+			}, testModule)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(h.Invoke(func(pdb *pg.DB) {
+				db = pdb
+				tx = store.NewTransactions(db)
+
+				_, err := db.Exec("TRUNCATE schemes RESTART IDENTITY CASCADE;")
+				Expect(err).NotTo(HaveOccurred())
+			})).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			// BeforeEach could fail before database is opened:
+			if db != nil {
+				Expect(db.Close()).NotTo(HaveOccurred())
+				db = nil
+			}
+		})
+
+		It("should create resources and skip unchanged on next apply", func() {
+			items := resources(`
+kind: config
+scheme: person
+slug: john
+tags: [prod]
+data: {name: John}
+---
+kind: scheme
+slug: person
+tags: [prod]
+data: {type: object}
+`)
+
+			plan, err := NewPlan(ctx, store.NewSchemeStore(db), store.NewConfigStore(db), items, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Changes).To(HaveLen(2))
+			Expect(plan.Changes[0].Kind).To(Equal(KindScheme))
+			Expect(plan.Changes[1].Action).To(Equal(ActionCreate))
+			Expect(plan.Applied).To(BeFalse())
+
+			plan, err = Apply(ctx, tx, items, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Applied).To(BeTrue())
+			Expect(plan.Changes[1].ID).NotTo(BeZero())
+
+			cfg, err := store.NewConfigStore(db).Read(ctx, plan.Changes[1].ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Author).To(Equal(defaultAuthor))
+
+			plan, err = Apply(ctx, tx, items, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Changes).To(BeEmpty())
+			Expect(plan.Unchanged).To(Equal(2))
+		})
+
+		It("should update changed resources and prune removed ones", func() {
+			_, err := Apply(ctx, tx, resources(`
+kind: scheme
+slug: person
+tags: [prod]
+data: {type: object}
+---
+kind: config
+scheme: person
+slug: john
+tags: [prod]
+data: {name: John}
+---
+kind: config
+scheme: person
+slug: jane
+tags: [prod]
+data: {name: Jane}
+`), Options{})
+			Expect(err).NotTo(HaveOccurred())
+
+			items := resources(`
+kind: scheme
+slug: person
+tags: [prod]
+data: {type: object}
+---
+kind: config
+scheme: person
+slug: john
+tags: [prod]
+data: {name: Johnny}
+`)
+
+			plan, err := Apply(ctx, tx, items, Options{Prune: true, Author: "ci"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Unchanged).To(Equal(1))
+			Expect(plan.Changes).To(HaveLen(2))
+
+			Expect(plan.Changes[0].Action).To(Equal(ActionUpdate))
+			Expect(plan.Changes[0].Version).To(Equal(int64(2)))
+			Expect(plan.Changes[0].Diff).To(ContainSubstring(`-  "name": "John"`))
+			Expect(plan.Changes[0].Diff).To(ContainSubstring(`+  "name": "Johnny"`))
+
+			Expect(plan.Changes[1].Action).To(Equal(ActionDelete))
+			Expect(plan.Changes[1].Slug).To(Equal("jane"))
+
+			_, err = store.NewConfigStore(db).Read(ctx, plan.Changes[1].ID)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not change anything when apply fails", func() {
+			_, err := Apply(ctx, tx, resources(`
+kind: scheme
+slug: person
+tags: [prod]
+data: {type: object}
+---
+kind: config
+scheme: company
+slug: acme
+tags: [prod]
+data: {name: ACME}
+`), Options{})
+			Expect(err).To(MatchError(ContainSubstring(`scheme "company" of config "acme" not found`)))
+
+			_, err = store.NewSchemeStore(db).ReadBySlug(ctx, "person")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
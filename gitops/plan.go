@@ -0,0 +1,385 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	defaultAuthor = "gitops"
+)
+
+type (
+	// Options of plan and apply
+	Options struct {
+		// Prune deletes schemes and configs with slugs that are not described by resources,
+		// entities without slugs are never deleted
+		Prune bool `json:"prune"`
+		// Author of new versions, `gitops` by default
+		Author string `json:"author"`
+	}
+
+	// Change of plan, Diff shows tags and data of current and new version
+	Change struct {
+		Action  string `json:"action"`
+		Kind    string `json:"kind"`
+		Slug    string `json:"slug"`
+		Scheme  string `json:"scheme,omitempty"`
+		ID      int64  `json:"id,omitempty"`
+		Version int64  `json:"version,omitempty"` // current version, new version when applied
+		Path    string `json:"path,omitempty"`
+		Diff    string `json:"diff"`
+
+		resource *Resource
+	}
+
+	// Plan is list of changes in order of apply: schemes are created and updated
+	// before configs, configs are deleted before schemes
+	Plan struct {
+		Changes   []*Change `json:"changes"`
+		Unchanged int       `json:"unchanged"`
+		Applied   bool      `json:"applied"`
+	}
+
+	// state is latest versions of schemes and configs with slugs
+	state struct {
+		schemes map[string]*store.Scheme // by slug
+		configs map[string]*store.Config // by key of resource
+		slugs   map[int64]string         // slugs of schemes by id
+	}
+)
+
+// NewPlan compares resources with schemes and configs of stores,
+// unchanged resources do not produce changes
+func NewPlan(ctx context.Context, s store.Schemes, c store.Configs, items []*Resource, opts Options) (*Plan, error) {
+	if err := Validate(items); err != nil {
+		return nil, err
+	}
+
+	cur, err := load(ctx, s, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		p       = &Plan{Changes: []*Change{}}
+		desired = make(map[string]bool, len(items))
+		configs []*Change
+	)
+
+	for _, item := range items {
+		var ch *Change
+
+		desired[item.key()] = true
+
+		if item.Kind == KindScheme {
+			ch, err = diffScheme(cur, item)
+		} else {
+			ch, err = diffConfig(ctx, c, cur, items, item, opts)
+		}
+
+		switch {
+		case err != nil:
+			return nil, err
+		case ch == nil:
+			p.Unchanged++
+		case item.Kind == KindScheme:
+			p.Changes = append(p.Changes, ch)
+		default:
+			configs = append(configs, ch)
+		}
+	}
+
+	p.Changes = append(p.Changes, configs...)
+
+	if !opts.Prune {
+		return p, nil
+	}
+
+	var deletes []*Change
+
+	// configs are deleted before schemes:
+	for key, cfg := range cur.configs {
+		if !desired[key] {
+			deletes = append(deletes, &Change{
+				Kind: KindConfig, Slug: cfg.Slug, Scheme: cur.slugs[cfg.SchemeID],
+				ID: cfg.ID, Version: cfg.Version, Action: ActionDelete,
+			})
+		}
+	}
+
+	for slug, scheme := range cur.schemes {
+		if !desired[KindScheme+"/"+slug] {
+			deletes = append(deletes, &Change{
+				Kind: KindScheme, Slug: slug,
+				ID: scheme.ID, Version: scheme.Version, Action: ActionDelete,
+			})
+		}
+	}
+
+	sort.Slice(deletes, func(i, j int) bool {
+		if deletes[i].Kind != deletes[j].Kind {
+			return deletes[i].Kind == KindConfig
+		}
+
+		return deletes[i].Scheme+"/"+deletes[i].Slug < deletes[j].Scheme+"/"+deletes[j].Slug
+	})
+
+	for _, ch := range deletes {
+		if ch.Kind == KindScheme {
+			err = ch.diff(cur.schemes[ch.Slug].Tags, cur.schemes[ch.Slug].Data, nil, nil)
+		} else {
+			cfg := cur.configs[KindConfig+"/"+ch.Scheme+"/"+ch.Slug]
+			err = ch.diff(cfg.Tags, cfg.Data, nil, nil)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.Changes = append(p.Changes, deletes...)
+
+	return p, nil
+}
+
+// Apply plans and applies changes in one transaction, so plan could not be
+// outdated and nothing is changed when any change fails
+func Apply(ctx context.Context, t store.Transactions, items []*Resource, opts Options) (result *Plan, err error) {
+	if opts.Author == "" {
+		opts.Author = defaultAuthor
+	}
+
+	err = t.RunInTransaction(ctx, func(s store.Schemes, c store.Configs) error {
+		if result, err = NewPlan(ctx, s, c, items, opts); err != nil {
+			return err
+		}
+
+		return result.apply(ctx, s, c, opts.Author)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.Applied = true
+
+	return result, nil
+}
+
+func (p *Plan) apply(ctx context.Context, s store.Schemes, c store.Configs, author string) error {
+	var ids = make(map[string]int64) // ids of schemes by slug
+
+	for _, ch := range p.Changes {
+		var err error
+
+		switch {
+		case ch.Kind == KindScheme && ch.Action == ActionDelete:
+			err = s.Delete(ctx, ch.ID)
+		case ch.Kind == KindConfig && ch.Action == ActionDelete:
+			err = c.Delete(ctx, ch.ID)
+		case ch.Kind == KindScheme:
+			model := &store.Scheme{ID: ch.ID, Slug: ch.Slug, Tags: ch.resource.Tags, Data: ch.resource.Data, Author: author}
+
+			if ch.Action == ActionCreate {
+				err = s.Create(ctx, model)
+			} else {
+				err = s.Update(ctx, model)
+			}
+
+			ch.ID, ch.Version, ids[ch.Slug] = model.ID, model.Version, model.ID
+		default:
+			model := &store.Config{ID: ch.ID, Slug: ch.Slug, Tags: ch.resource.Tags, Data: ch.resource.Data, Author: author}
+
+			if model.SchemeID = ids[ch.Scheme]; model.SchemeID == 0 {
+				var scheme *store.Scheme
+
+				if scheme, err = s.ReadBySlug(ctx, ch.Scheme); err != nil {
+					return errors.Wrapf(err, "could not read scheme %q of config %q", ch.Scheme, ch.Slug)
+				}
+
+				model.SchemeID, ids[ch.Scheme] = scheme.ID, scheme.ID
+			}
+
+			if ch.Action == ActionCreate {
+				err = c.Create(ctx, model)
+			} else {
+				err = c.Update(ctx, model)
+			}
+
+			ch.ID, ch.Version = model.ID, model.Version
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "could not %s %s %q", ch.Action, ch.Kind, ch.Slug)
+		}
+	}
+
+	return nil
+}
+
+// load latest versions of schemes and configs with slugs, search returns all versions
+// ordered by version, so first found version of entity is latest
+func load(ctx context.Context, s store.Schemes, c store.Configs) (*state, error) {
+	var result = &state{
+		schemes: make(map[string]*store.Scheme),
+		configs: make(map[string]*store.Config),
+		slugs:   make(map[int64]string),
+	}
+
+	schemes, _, err := s.Search(ctx, store.SearchRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read schemes")
+	}
+
+	for _, item := range schemes {
+		if prev, ok := result.schemes[item.Slug]; item.Slug == "" || (ok && prev.Version >= item.Version) {
+			continue
+		}
+
+		result.schemes[item.Slug] = item
+		result.slugs[item.ID] = item.Slug
+	}
+
+	configs, _, err := c.Search(ctx, store.SearchRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read configs")
+	}
+
+	for _, item := range configs {
+		scheme, ok := result.slugs[item.SchemeID]
+		if item.Slug == "" || !ok {
+			continue
+		}
+
+		key := KindConfig + "/" + scheme + "/" + item.Slug
+		if prev, ok := result.configs[key]; ok && prev.Version >= item.Version {
+			continue
+		}
+
+		result.configs[key] = item
+	}
+
+	return result, nil
+}
+
+func diffScheme(cur *state, item *Resource) (*Change, error) {
+	if current, ok := cur.schemes[item.Slug]; ok {
+		return changed(item, current.ID, current.Version, current.Tags, current.Data, nil)
+	}
+
+	return created(item)
+}
+
+func diffConfig(ctx context.Context, c store.Configs, cur *state, items []*Resource, item *Resource, opts Options) (*Change, error) {
+	var described = hasScheme(items, item.Scheme)
+
+	// scheme should exist or be described by resources:
+	if _, ok := cur.schemes[item.Scheme]; !ok && !described {
+		return nil, errorf(item.Path, "scheme %q of config %q not found", item.Scheme, item.Slug)
+	} else if opts.Prune && !described {
+		return nil, errorf(item.Path, "scheme %q of config %q is not described and would be pruned", item.Scheme, item.Slug)
+	}
+
+	if current, ok := cur.configs[item.key()]; ok {
+		same := func() (bool, error) { return c.Unchanged(ctx, current.ID, item.Data) }
+		return changed(item, current.ID, current.Version, current.Tags, current.Data, same)
+	}
+
+	return created(item)
+}
+
+func hasScheme(items []*Resource, slug string) bool {
+	for _, item := range items {
+		if item.Kind == KindScheme && item.Slug == slug {
+			return true
+		}
+	}
+
+	return false
+}
+
+func created(item *Resource) (*Change, error) {
+	ch := &Change{
+		Action:   ActionCreate,
+		Kind:     item.Kind,
+		Slug:     item.Slug,
+		Scheme:   item.Scheme,
+		Path:     item.Path,
+		resource: item,
+	}
+
+	return ch, ch.diff(nil, nil, item.Tags, item.Data)
+}
+
+// changed returns nil when tags and data of resource are the same as current version,
+// secrets are masked in current version, so data that differs only by secrets is
+// compared by same, that opens stored secrets (nil for data without secrets)
+func changed(item *Resource, id, version int64, tags []string, data json.RawMessage, same func() (bool, error)) (*Change, error) {
+	if reflect.DeepEqual(tags, item.Tags) && sameDocument(data, secrets.MaskAs(item.Data, data)) {
+		if same == nil || !bytes.Contains(data, []byte(secrets.Mask)) {
+			return nil, nil
+		} else if ok, err := same(); err != nil || ok {
+			return nil, err
+		}
+	}
+
+	ch, err := created(item)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.Action, ch.ID, ch.Version = ActionUpdate, id, version
+
+	return ch, ch.diff(tags, data, item.Tags, item.Data)
+}
+
+// diff of current (nil for create) and new version (nil for delete)
+func (ch *Change) diff(fromTags []string, from json.RawMessage, toTags []string, to json.RawMessage) error {
+	var a, b []string
+
+	if from != nil {
+		lines, err := Lines(fromTags, from)
+		if err != nil {
+			return errors.Wrapf(err, "%s %q", ch.Kind, ch.Slug)
+		}
+
+		a = lines
+	}
+
+	if to != nil {
+		lines, err := Lines(toTags, to)
+		if err != nil {
+			return errors.Wrapf(err, "%s %q", ch.Kind, ch.Slug)
+		}
+
+		b = lines
+	}
+
+	ch.Diff = strings.Join(Diff(a, b), "\n")
+
+	return nil
+}
+
+// sameDocument compares JSON documents regardless of formatting and order of keys
+func sameDocument(a, b json.RawMessage) bool {
+	var va, vb interface{}
+
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
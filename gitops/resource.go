@@ -0,0 +1,298 @@
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	KindScheme = "scheme"
+	KindConfig = "config"
+)
+
+type (
+	// Resource is desired state of scheme or config, read from YAML or JSON file
+	// with manifest header and data:
+	//
+	//	kind: config
+	//	scheme: person
+	//	slug: john
+	//	tags: [prod]
+	//	data:
+	//	  name: John
+	//
+	// Kind, slug and scheme could be omitted for files laid out as
+	// `schemes/<slug>.yaml` and `configs/<scheme>/<slug>.yaml`.
+	Resource struct {
+		Kind   string          `json:"kind"`
+		Slug   string          `json:"slug"`
+		Scheme string          `json:"scheme,omitempty"` // slug of scheme, only for configs
+		Tags   []string        `json:"tags"`
+		Data   json.RawMessage `json:"data"`
+		Path   string          `json:"path,omitempty"` // file of resource, relative to directory
+	}
+
+	// Error of resource, reported as validation error
+	Error struct {
+		Path string
+		Msg  string
+	}
+
+	// document of file, data is decoded from YAML and converted to JSON
+	document struct {
+		Kind   string      `yaml:"kind"`
+		Slug   string      `yaml:"slug"`
+		Scheme string      `yaml:"scheme"`
+		Tags   []string    `yaml:"tags"`
+		Data   interface{} `yaml:"data"`
+	}
+)
+
+func errorf(path, format string, args ...interface{}) *Error {
+	return &Error{Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return "gitops: " + e.Msg
+	}
+
+	return fmt.Sprintf("gitops: %s: %s", e.Path, e.Msg)
+}
+
+// Load reads resources of *.yaml, *.yml and *.json files of directory,
+// hidden files and directories (like .git) are skipped
+func Load(dir string) ([]*Resource, error) {
+	var result []*Resource
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(info.Name(), ".") && path != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "could not read %s", path)
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "could not resolve %s", path)
+		}
+
+		items, err := Parse(filepath.ToSlash(rel), data)
+		if err != nil {
+			return err
+		}
+
+		result = append(result, items...)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, Validate(result)
+}
+
+// Parse resources of file, file could contain several YAML documents separated by `---`,
+// empty documents are skipped. Path is used to fill omitted kind, slug and scheme.
+func Parse(path string, data []byte) ([]*Resource, error) {
+	var (
+		docs   []*document
+		result []*Resource
+		dec    = yaml.NewDecoder(bytes.NewReader(data))
+	)
+
+	for i := 1; ; i++ {
+		var doc = new(document)
+
+		if err := dec.Decode(doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errorf(path, "could not decode document %d: %s", i, err)
+		}
+
+		if doc.Kind == "" && doc.Slug == "" && doc.Data == nil {
+			continue
+		}
+
+		docs = append(docs, doc)
+	}
+
+	for _, doc := range docs {
+		// path describes resource only when it is only resource of file:
+		if len(docs) == 1 {
+			doc.infer(path)
+		}
+
+		res, err := doc.resource(path)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, res)
+	}
+
+	return result, nil
+}
+
+// infer kind, slug and scheme from `schemes/<slug>.yaml` or `configs/<scheme>/<slug>.yaml`
+func (d *document) infer(path string) {
+	var (
+		parts = strings.Split(path, "/")
+		name  = strings.TrimSuffix(parts[len(parts)-1], filepath.Ext(path))
+	)
+
+	switch {
+	case d.Kind == "" && parts[0] == "schemes":
+		d.Kind = KindScheme
+	case d.Kind == "" && parts[0] == "configs":
+		d.Kind = KindConfig
+	}
+
+	if d.Slug == "" {
+		d.Slug = name
+	}
+
+	if d.Kind == KindConfig && d.Scheme == "" && parts[0] == "configs" && len(parts) == 3 {
+		d.Scheme = parts[1]
+	}
+}
+
+func (d *document) resource(path string) (*Resource, error) {
+	data, err := json.Marshal(jsonValue(d.Data))
+	if err != nil {
+		return nil, errorf(path, "could not encode data of %s %q: %s", d.Kind, d.Slug, err)
+	}
+
+	res := &Resource{
+		Kind:   d.Kind,
+		Slug:   d.Slug,
+		Scheme: d.Scheme,
+		Tags:   d.Tags,
+		Data:   data,
+		Path:   path,
+	}
+
+	if d.Data == nil {
+		res.Data = nil
+	}
+
+	return res, res.validate()
+}
+
+func (r *Resource) validate() error {
+	switch {
+	case r.Kind != KindScheme && r.Kind != KindConfig:
+		return errorf(r.Path, "kind should be %s or %s", KindScheme, KindConfig)
+	case r.Slug == "":
+		return errorf(r.Path, "slug could not be empty")
+	case r.Kind == KindConfig && r.Scheme == "":
+		return errorf(r.Path, "scheme of config %q could not be empty", r.Slug)
+	case r.Kind == KindScheme && r.Scheme != "":
+		return errorf(r.Path, "scheme could be set only for config")
+	case len(r.Tags) == 0:
+		return errorf(r.Path, "tags of %s %q could not be empty", r.Kind, r.Slug)
+	case len(r.Data) == 0 || !json.Valid(r.Data):
+		return errorf(r.Path, "data of %s %q should be JSON document", r.Kind, r.Slug)
+	}
+
+	return nil
+}
+
+// key identifies resource, slugs of configs are unique in scheme
+func (r *Resource) key() string {
+	if r.Kind == KindScheme {
+		return KindScheme + "/" + r.Slug
+	}
+
+	return KindConfig + "/" + r.Scheme + "/" + r.Slug
+}
+
+// Validate resources and checks that every resource is described once
+func Validate(items []*Resource) error {
+	var seen = make(map[string]string, len(items))
+
+	for _, item := range items {
+		if err := item.validate(); err != nil {
+			return err
+		}
+
+		if prev, ok := seen[item.key()]; ok {
+			return errorf(item.Path, "%s %q already described in %s", item.Kind, item.Slug, prev)
+		}
+
+		seen[item.key()] = item.Path
+	}
+
+	return nil
+}
+
+// JSON converts YAML or JSON document to JSON
+func JSON(data []byte) (json.RawMessage, error) {
+	if json.Valid(data) {
+		return data, nil
+	}
+
+	var v interface{}
+
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, errors.Wrap(err, "document should be JSON or YAML")
+	}
+
+	return json.Marshal(jsonValue(v))
+}
+
+// jsonValue converts maps decoded from YAML to maps that could be encoded as JSON
+func jsonValue(v interface{}) interface{} {
+	switch item := v.(type) {
+	case map[interface{}]interface{}:
+		var result = make(map[string]interface{}, len(item))
+
+		for key, value := range item {
+			result[fmt.Sprint(key)] = jsonValue(value)
+		}
+
+		return result
+	case []interface{}:
+		var result = make([]interface{}, len(item))
+
+		for i, value := range item {
+			result[i] = jsonValue(value)
+		}
+
+		return result
+	}
+
+	return v
+}
@@ -0,0 +1,85 @@
+package gitops
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Syncer applies resources of local directory on schedule, see `workers.gitops`.
+// Directory is checkout of git repository, updated by `git pull` when `gitops.pull` is set
+// or by external process (for example git-sync sidecar).
+type Syncer struct {
+	tx     store.Transactions
+	logger *zap.Logger
+	path   string
+	pull   bool
+	opts   Options
+}
+
+var Module = module.Module{
+	{Constructor: NewSyncer},
+}
+
+// NewSyncer of `gitops.path` directory, sync is disabled when path is not set,
+// `gitops.prune` deletes entities that are removed from directory
+func NewSyncer(v *viper.Viper, t store.Transactions, l *zap.Logger) *Syncer {
+	return &Syncer{
+		tx:     t,
+		logger: l,
+		path:   v.GetString("gitops.path"),
+		pull:   v.GetBool("gitops.pull"),
+		opts: Options{
+			Prune:  v.GetBool("gitops.prune"),
+			Author: v.GetString("gitops.author"),
+		},
+	}
+}
+
+// Job pulls and applies resources of directory
+func (s *Syncer) Job(ctx context.Context) {
+	if s.path == "" {
+		return
+	}
+
+	if s.pull {
+		if out, err := exec.CommandContext(ctx, "git", "-C", s.path, "pull", "--ff-only").CombinedOutput(); err != nil {
+			s.logger.Error("could not pull gitops repository",
+				zap.String("path", s.path),
+				zap.ByteString("output", out),
+				zap.Error(err))
+			return
+		}
+	}
+
+	plan, err := s.Sync(ctx)
+	if err != nil {
+		s.logger.Error("could not sync gitops directory", zap.String("path", s.path), zap.Error(err))
+		return
+	}
+
+	for _, ch := range plan.Changes {
+		s.logger.Info("gitops change applied",
+			zap.String("action", ch.Action),
+			zap.String("kind", ch.Kind),
+			zap.String("scheme", ch.Scheme),
+			zap.String("slug", ch.Slug),
+			zap.Int64("id", ch.ID),
+			zap.Int64("version", ch.Version))
+	}
+}
+
+// Sync loads and applies resources of directory in one transaction
+func (s *Syncer) Sync(ctx context.Context) (*Plan, error) {
+	items, err := Load(s.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load %s", s.path)
+	}
+
+	return Apply(ctx, s.tx, items, s.opts)
+}
@@ -4,12 +4,20 @@ require (
 	github.com/chapsuk/mserv v0.3.2
 	github.com/chapsuk/worker v0.4.0
 	github.com/go-pg/pg v6.15.1+incompatible
+	github.com/go-redis/redis v6.14.1+incompatible
+	github.com/golang/protobuf v1.2.0
 	github.com/im-kulikov/helium v0.7.0
 	github.com/labstack/echo v3.3.6+incompatible
 	github.com/onsi/ginkgo v1.6.0
 	github.com/onsi/gomega v1.4.2
+	github.com/pelletier/go-toml v1.2.0
 	github.com/pkg/errors v0.8.0
+	github.com/prometheus/client_golang v0.8.0
+	github.com/spf13/viper v1.2.0
 	go.uber.org/dig v1.4.0
 	go.uber.org/zap v1.9.1
+	golang.org/x/net v0.0.0-20180906233101-161cd47e91fd
+	google.golang.org/grpc v1.15.0
+	gopkg.in/yaml.v2 v2.2.1
 	mellium.im/sasl v0.2.1 // indirect
 )
@@ -0,0 +1,541 @@
+// Package interpolate resolves references in string values of config data:
+//
+//	${config:42#/db/host} - value of latest version of config #42 by JSON pointer,
+//	                        without pointer (`${config:42}`) whole data of config
+//	${env.region}         - variable of server, see `variables` of settings
+//	$${env.region}        - escaped reference, written as `${env.region}`
+//
+// String that contains only reference is replaced by referenced value as is
+// (object, number, etc), otherwise referenced values should be scalars and are
+// written into the string. Referenced configs are resolved recursively, cycles
+// of references are errors.
+package interpolate
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/codec"
+	"github.com/pkg/errors"
+)
+
+const (
+	configPrefix = "config:"
+	envPrefix    = "env."
+)
+
+var (
+	// ErrInvalidReference when reference could not be parsed or its target not found
+	ErrInvalidReference = errors.New("invalid reference")
+
+	// ErrCycle when config refers to itself directly or through other configs
+	ErrCycle = errors.New("cycle of references")
+
+	pointerEscape   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescape = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+type (
+	// Ref is reference to field of config or to variable
+	Ref struct {
+		ConfigID int64  `json:"config_id,omitempty"`
+		Pointer  string `json:"pointer,omitempty"`  // JSON pointer in data of config, empty for whole data
+		Variable string `json:"variable,omitempty"` // name of variable, when ConfigID is zero
+	}
+
+	// Variables of server, referenced as `${env.name}`
+	Variables map[string]string
+
+	// Resolver resolves references of documents, every referenced config
+	// is read once per resolver. Resolver is not safe for concurrent use.
+	Resolver struct {
+		// Config returns data of latest version of config
+		Config func(id int64) (json.RawMessage, error)
+
+		// Variables are not resolved when nil, references to them are kept as is
+		Variables Variables
+
+		resolved map[int64]interface{}
+		visiting []int64 // configs that are being resolved, to report cycles
+	}
+
+	// part of string, text or reference
+	part struct {
+		text string
+		ref  *Ref
+	}
+)
+
+// String returns reference as it is written in document
+func (r Ref) String() string {
+	if r.ConfigID == 0 {
+		return "${" + envPrefix + r.Variable + "}"
+	}
+
+	if r.Pointer == "" {
+		return "${" + configPrefix + strconv.FormatInt(r.ConfigID, 10) + "}"
+	}
+
+	return "${" + configPrefix + strconv.FormatInt(r.ConfigID, 10) + "#" + r.Pointer + "}"
+}
+
+// Parse returns unique references of document ordered by config and pointer,
+// variables are last
+func Parse(data json.RawMessage) ([]Ref, error) {
+	doc, err := codec.Document(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		seen   = make(map[Ref]bool)
+		result = make([]Ref, 0)
+	)
+
+	err = walkStrings(doc, "", func(path, value string) error {
+		parts, err := parse(value)
+		if err != nil {
+			return errors.Wrapf(err, "field %q", path)
+		}
+
+		for _, item := range parts {
+			if item.ref != nil && !seen[*item.ref] {
+				seen[*item.ref] = true
+				result = append(result, *item.ref)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+
+		switch {
+		case (a.ConfigID == 0) != (b.ConfigID == 0):
+			return a.ConfigID != 0
+		case a.ConfigID != b.ConfigID:
+			return a.ConfigID < b.ConfigID
+		case a.Pointer != b.Pointer:
+			return a.Pointer < b.Pointer
+		}
+
+		return a.Variable < b.Variable
+	})
+
+	return result, nil
+}
+
+// Rewrite replaces ids of configs in references of document by ids returned by fn,
+// e.g. when configs are imported with new ids. Data without references is returned as is.
+func Rewrite(data json.RawMessage, fn func(id int64) (int64, error)) (json.RawMessage, error) {
+	if !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+
+	doc, err := codec.Document(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc, err = rewrite(doc, fn); err != nil {
+		return nil, err
+	}
+
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, errors.Wrap(err, "could not encode data")
+	}
+
+	return data, nil
+}
+
+// Check returns error when document refers to undefined variable
+func (v Variables) Check(refs []Ref) error {
+	for _, ref := range refs {
+		if _, ok := v[ref.Variable]; ref.ConfigID == 0 && !ok {
+			return errors.Wrapf(ErrInvalidReference, "%s: variable %q is not defined", ref, ref.Variable)
+		}
+	}
+
+	return nil
+}
+
+// Resolve returns data of config with resolved references, id of config is used
+// to detect cycles and could be zero for data that is not stored yet
+func (r *Resolver) Resolve(id int64, data json.RawMessage) (json.RawMessage, error) {
+	doc, err := codec.Document(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if id > 0 {
+		r.visiting = append(r.visiting, id)
+		defer func() { r.visiting = r.visiting[:len(r.visiting)-1] }()
+	}
+
+	if doc, err = r.walk(doc, ""); err != nil {
+		return nil, err
+	}
+
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, errors.Wrap(err, "could not encode resolved data")
+	}
+
+	return data, nil
+}
+
+// Lookup returns value of document by JSON pointer
+func Lookup(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return doc, nil
+	} else if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Errorf("pointer %q should start with /", pointer)
+	}
+
+	for _, key := range strings.Split(pointer[1:], "/") {
+		key = pointerUnescape.Replace(key)
+
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+
+			if doc, ok = v[key]; !ok {
+				return nil, errors.Errorf("field %q not found", key)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, errors.Errorf("index %q not found", key)
+			}
+
+			doc = v[i]
+		default:
+			return nil, errors.Errorf("field %q not found", key)
+		}
+	}
+
+	return doc, nil
+}
+
+// config returns resolved data of config
+func (r *Resolver) config(id int64) (interface{}, error) {
+	if doc, ok := r.resolved[id]; ok {
+		return doc, nil
+	}
+
+	for i, item := range r.visiting {
+		if item != id {
+			continue
+		}
+
+		var chain = make([]string, 0, len(r.visiting)-i+1)
+
+		for _, item := range append(r.visiting[i:], id) {
+			chain = append(chain, "#"+strconv.FormatInt(item, 10))
+		}
+
+		return nil, errors.Wrapf(ErrCycle, "config %s", strings.Join(chain, " -> "))
+	}
+
+	if r.Config == nil {
+		return nil, errors.Wrapf(ErrInvalidReference, "config #%d could not be read", id)
+	}
+
+	raw, err := r.Config(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read config #%d", id)
+	}
+
+	if raw, err = r.Resolve(id, raw); err != nil {
+		return nil, err
+	}
+
+	doc, err := codec.Document(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.resolved == nil {
+		r.resolved = make(map[int64]interface{})
+	}
+
+	r.resolved[id] = doc
+
+	return doc, nil
+}
+
+// value of reference, nil value is valid JSON null
+func (r *Resolver) value(ref Ref) (interface{}, bool, error) {
+	if ref.ConfigID == 0 {
+		if r.Variables == nil {
+			return nil, false, nil
+		}
+
+		value, ok := r.Variables[ref.Variable]
+		if !ok {
+			return nil, false, errors.Wrapf(ErrInvalidReference, "%s: variable %q is not defined", ref, ref.Variable)
+		}
+
+		return value, true, nil
+	}
+
+	doc, err := r.config(ref.ConfigID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if doc, err = Lookup(doc, ref.Pointer); err != nil {
+		return nil, false, errors.Wrapf(ErrInvalidReference, "%s: %v", ref, err)
+	}
+
+	return doc, true, nil
+}
+
+func (r *Resolver) walk(doc interface{}, path string) (interface{}, error) {
+	var err error
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if v[key], err = r.walk(item, path+"/"+pointerEscape.Replace(key)); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if v[i], err = r.walk(item, path+"/"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			}
+		}
+	case string:
+		if doc, err = r.interpolate(v); err != nil {
+			return nil, errors.Wrapf(err, "field %q", path)
+		}
+	}
+
+	return doc, nil
+}
+
+// interpolate returns referenced value when string contains only reference,
+// otherwise writes scalar values of references into the string
+func (r *Resolver) interpolate(value string) (interface{}, error) {
+	parts, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 1 && parts[0].ref != nil {
+		result, ok, err := r.value(*parts[0].ref)
+		if err != nil || ok {
+			return result, err
+		}
+	}
+
+	var buf strings.Builder
+
+	for _, item := range parts {
+		if item.ref == nil {
+			buf.WriteString(item.text)
+			continue
+		}
+
+		result, ok, err := r.value(*item.ref)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			buf.WriteString(item.ref.String())
+			continue
+		}
+
+		switch v := result.(type) {
+		case string:
+			buf.WriteString(v)
+		case json.Number:
+			buf.WriteString(v.String())
+		case bool:
+			buf.WriteString(strconv.FormatBool(v))
+		case nil:
+			buf.WriteString("null")
+		default:
+			return nil, errors.Wrapf(ErrInvalidReference, "%s: only scalar values could be written into string", item.ref)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// parse splits string into text and references, escaped references are text
+func parse(value string) ([]part, error) {
+	var (
+		text   strings.Builder
+		result []part
+	)
+
+	for {
+		i := strings.Index(value, "${")
+		if i < 0 {
+			text.WriteString(value)
+			break
+		}
+
+		// `$${` is escaped `${`:
+		if i > 0 && value[i-1] == '$' {
+			text.WriteString(value[:i-1] + "${")
+			value = value[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[i:], '}')
+		if end < 0 {
+			return nil, errors.Wrapf(ErrInvalidReference, "reference %q is not closed", value[i:])
+		}
+
+		ref, err := parseRef(value[i+2 : i+end])
+		if err != nil {
+			return nil, err
+		}
+
+		text.WriteString(value[:i])
+
+		if text.Len() > 0 {
+			result = append(result, part{text: text.String()})
+			text.Reset()
+		}
+
+		result = append(result, part{ref: ref})
+		value = value[i+end+1:]
+	}
+
+	if text.Len() > 0 || len(result) == 0 {
+		result = append(result, part{text: text.String()})
+	}
+
+	return result, nil
+}
+
+// parseRef parses `config:42#/db/host` or `env.region`
+func parseRef(value string) (*Ref, error) {
+	switch {
+	case strings.HasPrefix(value, envPrefix):
+		var name = strings.TrimPrefix(value, envPrefix)
+
+		if name == "" || strings.IndexFunc(name, invalidVariable) >= 0 {
+			return nil, errors.Wrapf(ErrInvalidReference, "variable %q should contain only letters, digits and underscores", name)
+		}
+
+		return &Ref{Variable: name}, nil
+	case strings.HasPrefix(value, configPrefix):
+		var (
+			ref     Ref
+			id      = strings.TrimPrefix(value, configPrefix)
+			pointer string
+		)
+
+		if i := strings.IndexByte(id, '#'); i >= 0 {
+			id, pointer = id[:i], id[i+1:]
+		}
+
+		num, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || num <= 0 {
+			return nil, errors.Wrapf(ErrInvalidReference, "${%s}: id of config should be positive number", value)
+		} else if pointer != "" && !strings.HasPrefix(pointer, "/") {
+			return nil, errors.Wrapf(ErrInvalidReference, "${%s}: pointer should start with /", value)
+		}
+
+		ref.ConfigID, ref.Pointer = num, pointer
+
+		return &ref, nil
+	}
+
+	return nil, errors.Wrapf(ErrInvalidReference, "${%s}: reference should be ${config:<id>#<pointer>} or ${env.<name>}", value)
+}
+
+func invalidVariable(r rune) bool {
+	return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+}
+
+// rewrite replaces ids of configs in references of string values of document
+func rewrite(doc interface{}, fn func(id int64) (int64, error)) (interface{}, error) {
+	var err error
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if v[key], err = rewrite(item, fn); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if v[i], err = rewrite(item, fn); err != nil {
+				return nil, err
+			}
+		}
+	case string:
+		parts, err := parse(v)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf strings.Builder
+
+		for _, item := range parts {
+			if item.ref == nil {
+				// text is written escaped, like it was parsed:
+				buf.WriteString(strings.Replace(item.text, "${", "$${", -1))
+				continue
+			}
+
+			ref := *item.ref
+
+			if ref.ConfigID != 0 {
+				if ref.ConfigID, err = fn(ref.ConfigID); err != nil {
+					return nil, err
+				}
+			}
+
+			buf.WriteString(ref.String())
+		}
+
+		return buf.String(), nil
+	}
+
+	return doc, nil
+}
+
+// walkStrings calls fn for every string value of document with its JSON pointer
+func walkStrings(doc interface{}, path string, fn func(path, value string) error) error {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		var keys = make([]string, 0, len(v))
+
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := walkStrings(v[key], path+"/"+pointerEscape.Replace(key), fn); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := walkStrings(item, path+"/"+strconv.Itoa(i), fn); err != nil {
+				return err
+			}
+		}
+	case string:
+		return fn(path, v)
+	}
+
+	return nil
+}
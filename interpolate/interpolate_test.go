@@ -0,0 +1,133 @@
+package interpolate
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("Interpolate Suite", func() {
+	var configs = map[int64]json.RawMessage{
+		1: json.RawMessage(`{"db":{"host":"db.local","port":5432},"region":"${env.region}"}`),
+		2: json.RawMessage(`{"url":"postgres://${config:1#/db/host}:${config:1#/db/port}","db":"${config:1#/db}"}`),
+		3: json.RawMessage(`{"next":"${config:4#/next}"}`),
+		4: json.RawMessage(`{"next":"${config:3#/next}"}`),
+	}
+
+	read := func(id int64) (json.RawMessage, error) {
+		data, ok := configs[id]
+		if !ok {
+			return nil, errors.Errorf("config #%d not found", id)
+		}
+
+		return data, nil
+	}
+
+	It("should parse unique references", func() {
+		refs, err := Parse(json.RawMessage(`{
+			"b": "${env.region}-${config:2#/a~1b}",
+			"a": ["${config:2#/a~1b}", "${config:1}", "$${env.escaped}", 10]
+		}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refs).To(Equal([]Ref{
+			{ConfigID: 1},
+			{ConfigID: 2, Pointer: "/a~1b"},
+			{Variable: "region"},
+		}))
+		Expect(refs[1].String()).To(Equal("${config:2#/a~1b}"))
+	})
+
+	It("should fail on invalid references", func() {
+		for _, data := range []string{
+			`{"a": "${config:x#/a}"}`,
+			`{"a": "${config:1#a}"}`,
+			`{"a": "${env.}"}`,
+			`{"a": "${env.a-b}"}`,
+			`{"a": "${secret:1}"}`,
+			`{"a": "${env.region"}`,
+		} {
+			_, err := Parse(json.RawMessage(data))
+			Expect(errors.Cause(err)).To(Equal(ErrInvalidReference), data)
+		}
+	})
+
+	It("should resolve references of configs and variables", func() {
+		r := &Resolver{Config: read, Variables: Variables{"region": "eu-west"}}
+
+		data, err := r.Resolve(0, json.RawMessage(`{
+			"conn": "${config:2#/url}?region=${env.region}",
+			"db": "${config:2#/db}",
+			"region": "${config:1#/region}",
+			"port": "${config:1#/db/port}",
+			"raw": "$${env.region}"
+		}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"conn": "postgres://db.local:5432?region=eu-west",
+			"db": {"host": "db.local", "port": 5432},
+			"region": "eu-west",
+			"port": 5432,
+			"raw": "${env.region}"
+		}`))
+	})
+
+	It("should keep variables when they are not given", func() {
+		data, err := (&Resolver{Config: read}).Resolve(0, json.RawMessage(`{"a":"${config:1#/region}","b":"x-${env.zone}"}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"a":"${env.region}","b":"x-${env.zone}"}`))
+	})
+
+	It("should fail on missing targets", func() {
+		r := &Resolver{Config: read, Variables: Variables{}}
+
+		for _, data := range []string{
+			`{"a": "${config:1#/db/user}"}`,
+			`{"a": "${config:1#/db/host/0}"}`,
+			`{"a": "${env.region}"}`,
+			`{"a": "db=${config:1#/db}"}`,
+		} {
+			_, err := r.Resolve(0, json.RawMessage(data))
+			Expect(errors.Cause(err)).To(Equal(ErrInvalidReference), data)
+		}
+
+		_, err := r.Resolve(0, json.RawMessage(`{"a": "${config:5}"}`))
+		Expect(err).To(MatchError(ContainSubstring("config #5 not found")))
+	})
+
+	It("should detect cycles", func() {
+		_, err := (&Resolver{Config: read}).Resolve(0, json.RawMessage(`{"a": "${config:3#/next}"}`))
+		Expect(errors.Cause(err)).To(Equal(ErrCycle))
+		Expect(err).To(MatchError(ContainSubstring("config #3 -> #4 -> #3")))
+
+		_, err = (&Resolver{Config: read}).Resolve(1, json.RawMessage(`{"a": "${config:2#/url}"}`))
+		Expect(errors.Cause(err)).To(Equal(ErrCycle))
+	})
+
+	It("should rewrite ids of referenced configs", func() {
+		data, err := Rewrite(json.RawMessage(`{"a": ["${config:1#/b}-${env.region}", "$${config:1}"], "b": "${config:2}"}`), func(id int64) (int64, error) {
+			return id + 10, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"a": ["${config:11#/b}-${env.region}", "$${config:1}"], "b": "${config:12}"}`))
+
+		_, err = Rewrite(json.RawMessage(`{"a": "${config:1}"}`), func(int64) (int64, error) {
+			return 0, ErrInvalidReference
+		})
+		Expect(err).To(Equal(ErrInvalidReference))
+
+		plain := json.RawMessage(`{"a":  1}`)
+		Expect(Rewrite(plain, nil)).To(Equal(plain))
+	})
+
+	It("should check variables", func() {
+		vars := Variables{"region": "eu-west"}
+
+		Expect(vars.Check([]Ref{{ConfigID: 1}, {Variable: "region"}})).To(Succeed())
+		Expect(errors.Cause(vars.Check([]Ref{{Variable: "zone"}}))).To(Equal(ErrInvalidReference))
+	})
+})
@@ -2,17 +2,33 @@ package app
 
 import (
 	"github.com/chapsuk/worker"
+	"github.com/im-kulikov/simplinic-task/backup"
+	"github.com/im-kulikov/simplinic-task/changes"
+	"github.com/im-kulikov/simplinic-task/gitops"
+	"github.com/im-kulikov/simplinic-task/outbox"
+	"github.com/im-kulikov/simplinic-task/reencrypt"
+	"github.com/im-kulikov/simplinic-task/webhooks"
 	"go.uber.org/dig"
 )
 
 type jobs struct {
 	dig.In
 
-	// fii dependencies
+	Outbox   *outbox.Relay
+	Webhooks *webhooks.Dispatcher
+	GitOps   *gitops.Syncer
+	Backup   *backup.Backuper
+	Secrets  *reencrypt.Reencryptor
+	Changes  *changes.Cleaner
 }
 
 func newJobs(j jobs) map[string]worker.Job {
 	return map[string]worker.Job{
-		// fill workers map:
+		"outbox":    j.Outbox.Job,   // relay events of outbox to publisher
+		"webhooks":  j.Webhooks.Job, // deliver changes to webhooks
+		"gitops":    j.GitOps.Job,   // sync schemes and configs from directory
+		"backup":    j.Backup.Job,   // write snapshots of schemes and configs
+		"reencrypt": j.Secrets.Job,  // rewrap secrets of configs by primary key
+		"changes":   j.Changes.Job,  // remove changes beyond retention
 	}
 }
@@ -0,0 +1,300 @@
+// Package k8s builds Kubernetes ConfigMap and Secret manifests of configs.
+//
+// Data of config is flattened with keys of env files (DB__HOST, see render.Flatten),
+// so manifests could be used with `envFrom`. Fields that scheme marks as secret
+// (see schema.Secrets) are written to Secret with the same name instead of ConfigMap,
+// plaintext of secrets is read by store.Configs.Reveal, so every export is audited.
+//
+// Manifests are deterministic: names are built from slugs of scheme and config
+// (or id of config), labels carry id, version and scheme of config and content
+// hash is written to annotation (and suffix of name, when requested).
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/render"
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+// Labels and annotations of manifests
+const (
+	LabelManagedBy     = "app.kubernetes.io/managed-by"
+	LabelConfigID      = "simplinic.com/config-id"
+	LabelConfigVersion = "simplinic.com/config-version"
+	LabelSchemeID      = "simplinic.com/scheme-id"
+	LabelEnvironment   = "simplinic.com/environment"
+	AnnotationScheme   = "simplinic.com/scheme"
+	AnnotationHash     = "simplinic.com/content-hash"
+)
+
+const (
+	managedBy     = "simplinic-task"
+	kindConfigMap = "ConfigMap"
+	kindSecret    = "Secret"
+	secretType    = "Opaque"
+
+	// names are DNS subdomains, values of labels are shorter
+	maxNameLength  = 253
+	maxLabelLength = 63
+	hashSuffix     = 10
+)
+
+var (
+	// ErrEmptySelector when configs are not selected by tags, filter or scheme
+	ErrEmptySelector = errors.New("configs should be selected by tags, filter, environment or scheme")
+
+	// ErrNoActor when configs have secrets, but actor of audit is not set
+	ErrNoActor = errors.New("secrets of configs could be exported only by authenticated actor")
+
+	invalidName  = regexp.MustCompile(`[^a-z0-9.-]+`)
+	invalidLabel = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+type (
+	// Options of manifests
+	Options struct {
+		Namespace   string
+		Environment string         // written to labels
+		HashSuffix  bool           // append content hash to names, so changed config is new object
+		Keys        render.Options // keys of data, see render.Dotenv
+		Audit       store.Reveal   // audit of reveals of secrets, actor is required for configs with secrets
+
+		// references of configs are resolved with variables, unless Raw is set
+		Raw       bool
+		Variables interpolate.Variables
+	}
+
+	// List of manifests, could be applied with `kubectl apply -f`
+	List struct {
+		APIVersion string      `json:"apiVersion"`
+		Kind       string      `json:"kind"`
+		Items      []*Manifest `json:"items"`
+	}
+
+	// Manifest of ConfigMap or Secret, values of Secret are encoded with base64
+	Manifest struct {
+		APIVersion string            `json:"apiVersion"`
+		Kind       string            `json:"kind"`
+		Metadata   Metadata          `json:"metadata"`
+		Type       string            `json:"type,omitempty"`
+		Data       map[string]string `json:"data"`
+	}
+
+	// Metadata of manifest
+	Metadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace,omitempty"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	}
+)
+
+// Load builds manifests of latest versions of configs that match search,
+// secrets of configs are revealed with opts.Audit (see store.Configs.Reveal)
+// and references are resolved (see store.NewResolver)
+func Load(ctx context.Context, s store.Schemes, c store.Configs, req store.SearchRequest, opts Options) (*List, error) {
+	if len(req.Tags) == 0 && req.Filter == nil && req.SchemeID == 0 {
+		return nil, ErrEmptySelector
+	}
+
+	req.Latest = true
+
+	configs, _, err := c.Search(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read configs")
+	}
+
+	var (
+		schemes = make(map[int64]*store.Scheme)
+		secrets = make(map[int64][]schema.Path)
+	)
+
+	for i, item := range configs {
+		if _, ok := schemes[item.SchemeID]; !ok {
+			if schemes[item.SchemeID], err = s.Read(ctx, item.SchemeID); err != nil {
+				return nil, errors.Wrapf(err, "could not read scheme #%d", item.SchemeID)
+			} else if secrets[item.SchemeID], err = schema.Secrets(schemes[item.SchemeID].Data); err != nil {
+				return nil, errors.Wrapf(err, "scheme #%d", item.SchemeID)
+			}
+		}
+
+		if len(secrets[item.SchemeID]) > 0 && opts.Audit.Actor == "" {
+			return nil, errors.Wrapf(ErrNoActor, "config #%d", item.ID)
+		} else if len(secrets[item.SchemeID]) > 0 {
+			// search returns masked secrets:
+			if configs[i], err = c.Reveal(ctx, item.ID, opts.Audit); err != nil {
+				return nil, errors.Wrapf(err, "could not reveal secrets of config #%d", item.ID)
+			}
+		}
+
+		if opts.Raw {
+			continue
+		}
+
+		if configs[i].Data, err = store.NewResolver(ctx, c, opts.Variables).Resolve(item.ID, configs[i].Data); err != nil {
+			return nil, errors.Wrapf(err, "could not resolve references of config #%d", item.ID)
+		}
+	}
+
+	return Build(configs, schemes, opts)
+}
+
+// Build manifests of configs ordered by id, schemes of configs should be given
+func Build(configs []*store.Config, schemes map[int64]*store.Scheme, opts Options) (*List, error) {
+	var (
+		secrets = make(map[int64][]schema.Path, len(schemes))
+		result  = &List{APIVersion: "v1", Kind: "List", Items: []*Manifest{}}
+	)
+
+	for id, item := range schemes {
+		paths, err := schema.Secrets(item.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "scheme #%d", id)
+		}
+
+		secrets[id] = paths
+	}
+
+	configs = append([]*store.Config{}, configs...)
+	sort.Slice(configs, func(i, j int) bool { return configs[i].ID < configs[j].ID })
+
+	for _, item := range configs {
+		scheme, ok := schemes[item.SchemeID]
+		if !ok {
+			return nil, errors.Errorf("scheme #%d of config #%d not found", item.SchemeID, item.ID)
+		}
+
+		pairs, err := render.Flatten(render.Dotenv, item.Data, opts.Keys)
+		if err != nil {
+			return nil, errors.Wrapf(err, "config #%d", item.ID)
+		}
+
+		var (
+			name   = Name(scheme, item)
+			public = make(map[string]string)
+			secret = make(map[string]string)
+		)
+
+		for _, pair := range pairs {
+			if matchAny(secrets[item.SchemeID], pair.Path) {
+				secret[pair.Key] = pair.Value
+				continue
+			}
+
+			public[pair.Key] = pair.Value
+		}
+
+		result.Items = append(result.Items, manifest(kindConfigMap, name, scheme, item, public, opts))
+
+		if len(secret) > 0 {
+			for key, value := range secret {
+				secret[key] = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+
+			result.Items = append(result.Items, manifest(kindSecret, name, scheme, item, secret, opts))
+		}
+	}
+
+	return result, nil
+}
+
+// Name of manifests of config: `<scheme slug>-<config slug>` or `config-<id>`,
+// name is valid DNS subdomain
+func Name(scheme *store.Scheme, cfg *store.Config) string {
+	var name = "config-" + strconv.FormatInt(cfg.ID, 10)
+
+	if scheme.Slug != "" && cfg.Slug != "" {
+		name = scheme.Slug + "-" + cfg.Slug
+	}
+
+	name = strings.Trim(invalidName.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+	if len(name) > maxNameLength-hashSuffix-1 {
+		name = strings.TrimRight(name[:maxNameLength-hashSuffix-1], "-.")
+	}
+
+	return name
+}
+
+func manifest(kind, name string, scheme *store.Scheme, cfg *store.Config, data map[string]string, opts Options) *Manifest {
+	var (
+		hash = Hash(data)
+		m    = &Manifest{
+			APIVersion: "v1",
+			Kind:       kind,
+			Data:       data,
+			Metadata: Metadata{
+				Name:      name,
+				Namespace: opts.Namespace,
+				Labels: map[string]string{
+					LabelManagedBy:     managedBy,
+					LabelConfigID:      strconv.FormatInt(cfg.ID, 10),
+					LabelConfigVersion: strconv.FormatInt(cfg.Version, 10),
+					LabelSchemeID:      strconv.FormatInt(cfg.SchemeID, 10),
+				},
+				Annotations: map[string]string{
+					AnnotationHash: "sha256:" + hash,
+				},
+			},
+		}
+	)
+
+	if kind == kindSecret {
+		m.Type = secretType
+	}
+
+	if opts.HashSuffix {
+		m.Metadata.Name += "-" + hash[:hashSuffix]
+	}
+
+	if scheme.Slug != "" {
+		m.Metadata.Annotations[AnnotationScheme] = scheme.Slug
+	}
+
+	if value := labelValue(opts.Environment); value != "" {
+		m.Metadata.Labels[LabelEnvironment] = value
+	}
+
+	return m
+}
+
+// Hash of data is hex of SHA-256 of JSON of data, keys are sorted
+func Hash(data map[string]string) string {
+	// map of strings could always be encoded:
+	out, _ := json.Marshal(data)
+	sum := sha256.Sum256(out)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// labelValue replaces invalid characters of label value, value should
+// begin and end with alphanumeric character
+func labelValue(value string) string {
+	value = strings.Trim(invalidLabel.ReplaceAllString(value, "-"), "-._")
+	if len(value) > maxLabelLength {
+		value = strings.TrimRight(value[:maxLabelLength], "-._")
+	}
+
+	return value
+}
+
+func matchAny(paths []schema.Path, path []string) bool {
+	for _, item := range paths {
+		if item.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
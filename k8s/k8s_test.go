@@ -0,0 +1,186 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+type (
+	// testSchemes reads schemes of map
+	testSchemes struct {
+		store.Schemes
+		items map[int64]*store.Scheme
+	}
+
+	// testConfigs stores configs with sealed secrets, reads are masked like in store
+	testConfigs struct {
+		store.Configs
+		keys    *secrets.Keyring
+		items   []*store.Config
+		reveals []store.Reveal
+	}
+)
+
+func (s *testSchemes) Read(_ context.Context, id int64) (*store.Scheme, error) {
+	return s.items[id], nil
+}
+
+func (s *testConfigs) Search(context.Context, store.SearchRequest) ([]*store.Config, int, error) {
+	var result []*store.Config
+
+	for _, item := range s.items {
+		cfg := *item
+		cfg.Data = secrets.MaskData(cfg.Data, nil)
+		result = append(result, &cfg)
+	}
+
+	return result, len(result), nil
+}
+
+func (s *testConfigs) Reveal(_ context.Context, id int64, audit store.Reveal) (*store.Config, error) {
+	for _, item := range s.items {
+		if item.ID != id {
+			continue
+		}
+
+		var (
+			err error
+			cfg = *item
+		)
+
+		s.reveals = append(s.reveals, audit)
+		cfg.Data, err = s.keys.OpenData(cfg.Data)
+
+		return &cfg, err
+	}
+
+	return nil, errors.New("not found")
+}
+
+var _ = Describe("K8s Suite", func() {
+	var (
+		schemes = map[int64]*store.Scheme{
+			1: {ID: 1, Slug: "Billing", Data: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"db": {"$ref": "#/definitions/db"},
+					"tokens": {"type": "array", "items": {"type": "string", "x-secret": true}}
+				},
+				"definitions": {
+					"db": {"properties": {"host": {"type": "string"}, "password": {"type": "string", "writeOnly": true}}}
+				}
+			}`)},
+			2: {ID: 2, Data: json.RawMessage(`{}`)},
+		}
+
+		configs = []*store.Config{
+			{ID: 7, SchemeID: 2, Version: 1, Data: json.RawMessage(`{"debug":true}`)},
+			{ID: 3, SchemeID: 1, Version: 4, Slug: "eu_west", Data: json.RawMessage(`{"db":{"host":"db","password":"p"},"tokens":["a","b"]}`)},
+		}
+	)
+
+	It("should build config maps and secrets ordered by id", func() {
+		list, err := Build(configs, schemes, Options{Namespace: "prod", Environment: "stage"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Items).To(HaveLen(3))
+
+		cm, secret, other := list.Items[0], list.Items[1], list.Items[2]
+
+		Expect(cm.Kind).To(Equal("ConfigMap"))
+		Expect(cm.Metadata.Name).To(Equal("billing-eu-west"))
+		Expect(cm.Metadata.Namespace).To(Equal("prod"))
+		Expect(cm.Data).To(Equal(map[string]string{"DB__HOST": "db"}))
+		Expect(cm.Metadata.Labels).To(HaveKeyWithValue(LabelConfigID, "3"))
+		Expect(cm.Metadata.Labels).To(HaveKeyWithValue(LabelConfigVersion, "4"))
+		Expect(cm.Metadata.Labels).To(HaveKeyWithValue(LabelEnvironment, "stage"))
+		Expect(cm.Metadata.Annotations).To(HaveKeyWithValue(AnnotationHash, "sha256:"+Hash(cm.Data)))
+
+		Expect(secret.Kind).To(Equal("Secret"))
+		Expect(secret.Type).To(Equal("Opaque"))
+		Expect(secret.Metadata.Name).To(Equal("billing-eu-west"))
+		Expect(secret.Data).To(Equal(map[string]string{
+			"DB__PASSWORD": "cA==",
+			"TOKENS__0":    "YQ==",
+			"TOKENS__1":    "Yg==",
+		}))
+
+		Expect(other.Kind).To(Equal("ConfigMap"))
+		Expect(other.Metadata.Name).To(Equal("config-7"))
+		Expect(other.Data).To(Equal(map[string]string{"DEBUG": "true"}))
+	})
+
+	It("should be deterministic and append hash to names", func() {
+		first, err := Build(configs, schemes, Options{HashSuffix: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		second, err := Build([]*store.Config{configs[1], configs[0]}, schemes, Options{HashSuffix: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		a, err := json.Marshal(first)
+		Expect(err).NotTo(HaveOccurred())
+
+		b, err := json.Marshal(second)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a).To(MatchJSON(b))
+		Expect(first.Items[0].Metadata.Name).To(Equal("billing-eu-west-" + Hash(first.Items[0].Data)[:10]))
+	})
+
+	It("should fail when scheme of config is not given", func() {
+		_, err := Build(configs, map[int64]*store.Scheme{1: schemes[1]}, Options{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reveal sealed secrets of loaded configs with audit", func() {
+		keys, err := secrets.New("", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+		Expect(err).NotTo(HaveOccurred())
+
+		paths, err := schema.Secrets(schemes[1].Data)
+		Expect(err).NotTo(HaveOccurred())
+
+		seal := func(data string) *store.Config {
+			sealed, err := keys.SealData(json.RawMessage(data), paths, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(sealed)).To(ContainSubstring("enc:v1:k1:"))
+
+			return &store.Config{ID: 3, SchemeID: 1, Version: 4, Slug: "eu_west", Data: sealed}
+		}
+
+		var (
+			ss = &testSchemes{items: schemes}
+			cs = &testConfigs{keys: keys, items: []*store.Config{configs[0], seal(`{"db":{"host":"db","password":"p"},"tokens":["a"]}`)}}
+			sr = store.SearchRequest{Tags: []string{"billing"}}
+		)
+
+		_, err = Load(context.Background(), ss, cs, sr, Options{})
+		Expect(errors.Cause(err)).To(Equal(ErrNoActor))
+
+		audit := store.Reveal{Actor: "jane", Reason: "manifests"}
+
+		list, err := Load(context.Background(), ss, cs, sr, Options{Audit: audit})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Items).To(HaveLen(3))
+		Expect(list.Items[1].Kind).To(Equal("Secret"))
+		Expect(list.Items[1].Data).To(Equal(map[string]string{
+			"DB__PASSWORD": base64.StdEncoding.EncodeToString([]byte("p")),
+			"TOKENS__0":    base64.StdEncoding.EncodeToString([]byte("a")),
+		}))
+		Expect(cs.reveals).To(Equal([]store.Reveal{audit}))
+
+		// changed secret changes hash of manifest:
+		cs.items[1] = seal(`{"db":{"host":"db","password":"q"},"tokens":["a"]}`)
+
+		next, err := Load(context.Background(), ss, cs, sr, Options{Audit: audit})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next.Items[1].Metadata.Annotations[AnnotationHash]).NotTo(Equal(list.Items[1].Metadata.Annotations[AnnotationHash]))
+	})
+})
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TRIGGER config_versions__search ON public.config_versions;
+DROP TRIGGER scheme_versions__search ON public.scheme_versions;
+
+DROP FUNCTION config_versions__search_trigger();
+DROP FUNCTION scheme_versions__search_trigger();
+
+ALTER TABLE "public"."config_versions" DROP COLUMN "search";
+ALTER TABLE "public"."scheme_versions" DROP COLUMN "search";
+
+COMMIT;
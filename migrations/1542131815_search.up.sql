@@ -0,0 +1,39 @@
+BEGIN;
+
+-- Full-text search over JSON string values of every version
+ALTER TABLE "public"."scheme_versions" ADD COLUMN "search" tsvector DEFAULT NULL;
+ALTER TABLE "public"."config_versions" ADD COLUMN "search" tsvector DEFAULT NULL;
+
+-- Scheme title / description are weighted higher than the rest of document
+CREATE FUNCTION scheme_versions__search_trigger() RETURNS trigger AS $$
+BEGIN
+    NEW.search :=
+        setweight(to_tsvector('simple', coalesce(NEW.data->>'title', '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(NEW.data->>'description', '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(NEW.data, '{}'::jsonb)), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION config_versions__search_trigger() RETURNS trigger AS $$
+BEGIN
+    NEW.search := setweight(to_tsvector('simple', coalesce(NEW.data, '{}'::jsonb)), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER scheme_versions__search BEFORE INSERT OR UPDATE OF data ON public.scheme_versions
+    FOR EACH ROW EXECUTE PROCEDURE scheme_versions__search_trigger();
+
+CREATE TRIGGER config_versions__search BEFORE INSERT OR UPDATE OF data ON public.config_versions
+    FOR EACH ROW EXECUTE PROCEDURE config_versions__search_trigger();
+
+-- Fill already stored versions
+UPDATE public.scheme_versions SET data = data;
+UPDATE public.config_versions SET data = data;
+
+-- Index Definition
+CREATE INDEX scheme_versions__search ON public.scheme_versions USING gin (search);
+CREATE INDEX config_versions__search ON public.config_versions USING gin (search);
+
+COMMIT;
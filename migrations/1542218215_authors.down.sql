@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX config_versions__scheme_id_version_desc;
+DROP INDEX config_versions__scheme_id;
+DROP INDEX configs__scheme_id;
+
+ALTER TABLE "public"."config_versions" DROP COLUMN "author";
+ALTER TABLE "public"."scheme_versions" DROP COLUMN "author";
+
+COMMIT;
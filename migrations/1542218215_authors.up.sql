@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Who created the version of entity
+ALTER TABLE "public"."scheme_versions" ADD COLUMN "author" varchar(255) DEFAULT NULL;
+ALTER TABLE "public"."config_versions" ADD COLUMN "author" varchar(255) DEFAULT NULL;
+
+-- Index Definition
+CREATE INDEX scheme_versions__author ON public.scheme_versions USING btree (author);
+CREATE INDEX config_versions__author ON public.config_versions USING btree (author);
+
+-- configs of scheme
+CREATE INDEX configs__scheme_id ON public.configs USING btree (scheme_id);
+CREATE INDEX config_versions__scheme_id ON public.config_versions USING btree (scheme_id);
+CREATE INDEX config_versions__scheme_id_version_desc ON public.config_versions USING btree (scheme_id, version DESC);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE "config_slugs";
+DROP TABLE "scheme_slugs";
+
+ALTER TABLE "public"."configs" DROP COLUMN "slug";
+ALTER TABLE "public"."schemes" DROP COLUMN "slug";
+
+COMMIT;
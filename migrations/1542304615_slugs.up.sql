@@ -0,0 +1,30 @@
+BEGIN;
+
+-- Current slug of entity
+ALTER TABLE "public"."schemes" ADD COLUMN "slug" varchar(255) DEFAULT NULL;
+ALTER TABLE "public"."configs" ADD COLUMN "slug" varchar(255) DEFAULT NULL;
+
+-- All slugs (current and old aliases) of entities
+CREATE TABLE "public"."scheme_slugs" (
+    "slug" varchar(255) NOT NULL,
+    "scheme_id" integer REFERENCES "schemes" ON DELETE CASCADE,
+    "created_at" timestamp DEFAULT NOW(),
+    PRIMARY KEY ("slug")
+);
+
+CREATE TABLE "public"."config_slugs" (
+    "scheme_id" integer REFERENCES "schemes" ON DELETE CASCADE,
+    "slug" varchar(255) NOT NULL,
+    "config_id" integer REFERENCES "configs" ON DELETE CASCADE,
+    "created_at" timestamp DEFAULT NOW(),
+    PRIMARY KEY ("scheme_id", "slug")
+);
+
+-- Index Definition
+CREATE UNIQUE INDEX schemes__slug ON public.schemes USING btree (slug) WHERE slug IS NOT NULL;
+CREATE UNIQUE INDEX configs__scheme_id_slug ON public.configs USING btree (scheme_id, slug) WHERE slug IS NOT NULL;
+
+CREATE INDEX scheme_slugs__scheme_id ON public.scheme_slugs USING btree (scheme_id);
+CREATE INDEX config_slugs__config_id ON public.config_slugs USING btree (config_id);
+
+COMMIT;
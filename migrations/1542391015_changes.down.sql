@@ -0,0 +1,16 @@
+BEGIN;
+
+DROP TRIGGER configs__changes ON public.configs;
+DROP TRIGGER schemes__changes ON public.schemes;
+DROP TRIGGER config_versions__changes ON public.config_versions;
+DROP TRIGGER scheme_versions__changes ON public.scheme_versions;
+
+DROP FUNCTION configs__changes_trigger();
+DROP FUNCTION schemes__changes_trigger();
+DROP FUNCTION config_versions__changes_trigger();
+DROP FUNCTION scheme_versions__changes_trigger();
+DROP FUNCTION changes__notify(varchar, integer, integer, integer, varchar, jsonb);
+
+DROP TABLE "changes";
+
+COMMIT;
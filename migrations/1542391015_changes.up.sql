@@ -0,0 +1,99 @@
+BEGIN;
+
+-- Feed of changes of schemes and configs, filled by triggers,
+-- listeners are notified by NOTIFY changes, '<id>' after commit
+CREATE TABLE "public"."changes" (
+    "id" BIGSERIAL,
+    "entity" varchar(16) NOT NULL,
+    "entity_id" integer NOT NULL,
+    "scheme_id" integer NOT NULL,
+    "version" integer DEFAULT NULL,
+    "op" varchar(16) NOT NULL,
+    "tags" jsonb DEFAULT NULL,
+    "created_at" timestamp DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+CREATE FUNCTION changes__notify(_entity varchar, _entity_id integer, _scheme_id integer,
+                                _version integer, _op varchar, _tags jsonb) RETURNS void AS $$
+DECLARE
+    change_id bigint;
+BEGIN
+    INSERT INTO public.changes (entity, entity_id, scheme_id, version, op, tags)
+    VALUES (_entity, _entity_id, _scheme_id, _version, _op, _tags)
+    RETURNING id INTO change_id;
+
+    PERFORM pg_notify('changes', change_id::text);
+END
+$$ LANGUAGE plpgsql;
+
+-- create / update: new version of entity
+CREATE FUNCTION scheme_versions__changes_trigger() RETURNS trigger AS $$
+BEGIN
+    PERFORM changes__notify('scheme', NEW.scheme_id, NEW.scheme_id, NEW.version,
+        CASE WHEN NEW.version = 1 THEN 'create' ELSE 'update' END, NEW.tags);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION config_versions__changes_trigger() RETURNS trigger AS $$
+BEGIN
+    PERFORM changes__notify('config', NEW.config_id, NEW.scheme_id, NEW.version,
+        CASE WHEN NEW.version = 1 THEN 'create' ELSE 'update' END, NEW.tags);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- delete / restore: deleted_at of entity is set or cleared
+CREATE FUNCTION schemes__changes_trigger() RETURNS trigger AS $$
+DECLARE
+    latest public.scheme_versions;
+BEGIN
+    IF (OLD.deleted_at IS NULL) = (NEW.deleted_at IS NULL) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT * INTO latest FROM public.scheme_versions
+     WHERE scheme_id = NEW.id ORDER BY version DESC LIMIT 1;
+
+    PERFORM changes__notify('scheme', NEW.id, NEW.id, latest.version,
+        CASE WHEN NEW.deleted_at IS NULL THEN 'restore' ELSE 'delete' END, latest.tags);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION configs__changes_trigger() RETURNS trigger AS $$
+DECLARE
+    latest public.config_versions;
+BEGIN
+    IF (OLD.deleted_at IS NULL) = (NEW.deleted_at IS NULL) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT * INTO latest FROM public.config_versions
+     WHERE config_id = NEW.id ORDER BY version DESC LIMIT 1;
+
+    PERFORM changes__notify('config', NEW.id, NEW.scheme_id, latest.version,
+        CASE WHEN NEW.deleted_at IS NULL THEN 'restore' ELSE 'delete' END, latest.tags);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER scheme_versions__changes AFTER INSERT ON public.scheme_versions
+    FOR EACH ROW EXECUTE PROCEDURE scheme_versions__changes_trigger();
+
+CREATE TRIGGER config_versions__changes AFTER INSERT ON public.config_versions
+    FOR EACH ROW EXECUTE PROCEDURE config_versions__changes_trigger();
+
+CREATE TRIGGER schemes__changes AFTER UPDATE OF deleted_at ON public.schemes
+    FOR EACH ROW EXECUTE PROCEDURE schemes__changes_trigger();
+
+CREATE TRIGGER configs__changes AFTER UPDATE OF deleted_at ON public.configs
+    FOR EACH ROW EXECUTE PROCEDURE configs__changes_trigger();
+
+-- Index Definition
+CREATE INDEX changes__entity_entity_id ON public.changes USING btree (entity, entity_id);
+CREATE INDEX changes__scheme_id ON public.changes USING btree (scheme_id);
+CREATE INDEX changes__tags ON public.changes USING gin (tags);
+
+COMMIT;
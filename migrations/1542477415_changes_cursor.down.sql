@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE "public"."changes" DROP COLUMN "txid";
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- Transaction of change, changes are read in order of transactions and only
+-- after all earlier transactions are finished, so cursors never skip changes
+ALTER TABLE "public"."changes" ADD COLUMN "txid" bigint NOT NULL DEFAULT txid_current();
+
+-- Index Definition
+CREATE INDEX changes__txid_id ON public.changes USING btree (txid, id);
+
+COMMIT;
@@ -0,0 +1,38 @@
+BEGIN;
+
+-- Subscriptions of webhooks, cursor (after_txid, after_id) points to last
+-- change that was dispatched to deliveries, see store.Cursor, secret is
+-- key of signatures sealed by keyring, see secrets.Keyring
+CREATE TABLE "public"."webhooks" (
+    "id" SERIAL,
+    "url" text NOT NULL,
+    "secret" text NOT NULL,
+    "entity" varchar(16) DEFAULT NULL,
+    "scheme_id" integer DEFAULT NULL,
+    "tags" jsonb DEFAULT NULL,
+    "events" jsonb DEFAULT NULL,
+    "after_txid" bigint NOT NULL DEFAULT 0,
+    "after_id" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamp DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+-- Delivery of change to webhook: pending until receiver responds with 2xx,
+-- dead when all attempts failed, attempts keeps log of every attempt
+CREATE TABLE "public"."webhook_deliveries" (
+    "id" BIGSERIAL,
+    "webhook_id" integer NOT NULL REFERENCES public.webhooks (id) ON DELETE CASCADE,
+    "change_id" bigint NOT NULL REFERENCES public.changes (id) ON DELETE CASCADE,
+    "status" varchar(16) NOT NULL DEFAULT 'pending',
+    "attempts" jsonb NOT NULL DEFAULT '[]',
+    "next_attempt_at" timestamp NOT NULL DEFAULT NOW(),
+    "created_at" timestamp DEFAULT NOW(),
+    "updated_at" timestamp DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+-- Index Definition
+CREATE UNIQUE INDEX webhook_deliveries__webhook_id_change_id ON public.webhook_deliveries USING btree (webhook_id, change_id);
+CREATE INDEX webhook_deliveries__next_attempt_at ON public.webhook_deliveries USING btree (next_attempt_at) WHERE status = 'pending';
+
+COMMIT;
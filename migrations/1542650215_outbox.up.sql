@@ -0,0 +1,20 @@
+BEGIN;
+
+-- Domain events written in transaction of mutation of scheme or config,
+-- relayed to publisher in order of id, published_at is set after relay
+CREATE TABLE "public"."outbox" (
+    "id" BIGSERIAL,
+    "entity" varchar(16) NOT NULL,
+    "entity_id" integer NOT NULL,
+    "op" varchar(16) NOT NULL,
+    "payload" jsonb NOT NULL,
+    "created_at" timestamp DEFAULT NOW(),
+    "published_at" timestamp DEFAULT NULL,
+    PRIMARY KEY ("id")
+);
+
+-- Index Definition
+CREATE INDEX outbox__pending ON public.outbox USING btree (id) WHERE published_at IS NULL;
+CREATE INDEX outbox__published_at ON public.outbox USING btree (published_at);
+
+COMMIT;
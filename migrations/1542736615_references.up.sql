@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Reverse index of references between latest versions of configs,
+-- `${config:<target_id>#<pointer>}` in data of config_id
+CREATE TABLE "public"."config_references" (
+    "config_id" integer REFERENCES "configs" ON DELETE CASCADE,
+    "target_id" integer REFERENCES "configs" ON DELETE CASCADE,
+    "pointer" text NOT NULL DEFAULT '',
+    PRIMARY KEY ("config_id", "target_id", "pointer")
+);
+
+-- Index Definition
+CREATE INDEX config_references__target_id ON public.config_references USING btree (target_id);
+
+COMMIT;
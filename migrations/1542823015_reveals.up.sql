@@ -0,0 +1,18 @@
+BEGIN;
+
+-- Audit of reads of plaintext secrets of configs
+CREATE TABLE "public"."config_reveals" (
+    "id" serial PRIMARY KEY,
+    "config_id" integer REFERENCES "configs" ON DELETE CASCADE,
+    "version" integer NOT NULL,
+    "actor" text NOT NULL,
+    "claimed_actor" text NOT NULL DEFAULT '',
+    "reason" text NOT NULL DEFAULT '',
+    "address" text NOT NULL DEFAULT '',
+    "created_at" timestamp DEFAULT NOW()
+);
+
+-- Index Definition
+CREATE INDEX config_reveals__config_id ON public.config_reveals USING btree (config_id);
+
+COMMIT;
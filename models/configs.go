@@ -8,6 +8,7 @@ import (
 type Config struct {
 	ID        int64     `pg:",pk"`
 	SchemeID  int64     `sql:"scheme_id"`
+	Slug      string    `sql:"slug"`
 	CreatedAt time.Time `sql:"created_at"`
 	DeletedAt time.Time `pg:",soft_delete"`
 }
@@ -8,6 +8,7 @@ import (
 type (
 	Scheme struct {
 		ID        int64     `pg:",pk"`
+		Slug      string    `sql:"slug"`
 		CreatedAt time.Time `sql:"created_at"`
 		DeletedAt time.Time `sql:"deleted_at" pg:",soft_delete"`
 	}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type (
+	SchemeSlug struct {
+		Slug      string `pg:",pk"`
+		SchemeID  int64
+		CreatedAt time.Time
+	}
+
+	ConfigSlug struct {
+		SchemeID  int64  `pg:",pk"`
+		Slug      string `pg:",pk"`
+		ConfigID  int64
+		CreatedAt time.Time
+	}
+)
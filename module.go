@@ -10,6 +10,14 @@ import (
 	"github.com/im-kulikov/helium/web"
 	"github.com/im-kulikov/helium/workers"
 	"github.com/im-kulikov/simplinic-task/api"
+	"github.com/im-kulikov/simplinic-task/backup"
+	"github.com/im-kulikov/simplinic-task/changes"
+	"github.com/im-kulikov/simplinic-task/gitops"
+	"github.com/im-kulikov/simplinic-task/outbox"
+	"github.com/im-kulikov/simplinic-task/reencrypt"
+	"github.com/im-kulikov/simplinic-task/rpc"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/webhooks"
 )
 
 var Module = module.Module{
@@ -26,5 +34,13 @@ var Module = module.Module{
 	redis.Module,      // Redis
 	orm.Module,        // Postgres
 	// App specific modules:
-	api.Module, // API router
+	api.Module,       // API router
+	rpc.Module,       // gRPC server
+	webhooks.Module,  // Webhooks delivery
+	outbox.Module,    // Relay of domain events
+	gitops.Module,    // Sync from directory
+	backup.Module,    // Snapshots of schemes and configs
+	secrets.Module,   // Keys of secret fields
+	reencrypt.Module, // Re-encryption of secrets after rotation of keys
+	changes.Module,   // Retention of changes
 )
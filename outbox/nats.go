@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+)
+
+// NATS publishes events with NATS text protocol:
+//
+//	PUB simplinic.config.update 123\r\n
+//	{"id":42,"entity":"config",...}\r\n
+//	PING\r\n
+//
+// PONG is received after server handled PUB, so event is accepted by broker
+// before it is marked as published. Connection is reopened after any error.
+type NATS struct {
+	mu      sync.Mutex
+	address string
+	subject string
+	timeout time.Duration
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+func NewNATS(address, subject string, timeout time.Duration) *NATS {
+	return &NATS{
+		address: address,
+		subject: subject,
+		timeout: timeout,
+	}
+}
+
+func (n *NATS) Publish(ctx context.Context, e *store.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode event #%d", e.ID)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err = n.publish(ctx, n.subject+"."+e.Entity+"."+e.Op, data); err != nil {
+		n.close()
+		return errors.Wrapf(err, "could not publish event #%d to nats %s", e.ID, n.address)
+	}
+
+	return nil
+}
+
+// Close connection to server
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.close()
+}
+
+func (n *NATS) close() error {
+	if n.conn == nil {
+		return nil
+	}
+
+	err := n.conn.Close()
+	n.conn, n.reader = nil, nil
+
+	return err
+}
+
+func (n *NATS) publish(ctx context.Context, subject string, data []byte) error {
+	var deadline = time.Now().Add(n.timeout)
+
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if n.conn == nil {
+		if err := n.connect(deadline); err != nil {
+			return err
+		}
+	}
+
+	if err := n.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(n.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data); err != nil {
+		return err
+	}
+
+	return n.pong()
+}
+
+// connect reads INFO of server and sends CONNECT
+func (n *NATS) connect(deadline time.Time) error {
+	conn, err := net.DialTimeout("tcp", n.address, time.Until(deadline))
+	if err != nil {
+		return err
+	}
+
+	n.conn, n.reader = conn, bufio.NewReader(conn)
+
+	if err = n.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	line, err := n.reader.ReadString('\n')
+	if err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "INFO") {
+		return errors.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+
+	_, err = fmt.Fprint(n.conn, `CONNECT {"verbose":false,"pedantic":false,"name":"simplinic-task-outbox"}`+"\r\n")
+
+	return err
+}
+
+// pong waits PONG, answers PING of server and fails on -ERR
+func (n *NATS) pong() error {
+	for {
+		line, err := n.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = fmt.Fprint(n.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(line)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/helium"
+	"github.com/im-kulikov/helium/grace"
+	"github.com/im-kulikov/helium/logger"
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/helium/orm"
+	"github.com/im-kulikov/helium/settings"
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var testModule = module.Module{}.Append(
+	grace.Module,
+	settings.Module,
+	logger.Module,
+	orm.Module,
+)
+
+// natsStandIn is local stand-in of NATS server, it accepts PUB and answers PING,
+// fails publish with -ERR when reject is set
+type natsStandIn struct {
+	sync.Mutex
+	net.Listener
+	reject   bool
+	subjects []string
+	payloads []string
+}
+
+func newNATSStandIn() *natsStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	s := &natsStandIn{Listener: ln}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *natsStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"server_id\":\"stand-in\"}\r\n")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch fields := strings.Fields(line); fields[0] {
+		case "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			data := make([]byte, size+2)
+
+			if _, err = io.ReadFull(r, data); err != nil {
+				return
+			}
+
+			s.Lock()
+			if s.reject {
+				s.Unlock()
+				fmt.Fprint(conn, "-ERR 'Permissions Violation'\r\n")
+				return
+			}
+
+			s.subjects = append(s.subjects, fields[1])
+			s.payloads = append(s.payloads, string(data[:size]))
+			s.Unlock()
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		}
+	}
+}
+
+// failing publisher fails on event with id
+type failing struct {
+	*Memory
+	id int64
+}
+
+func (f *failing) Publish(ctx context.Context, e *store.Event) error {
+	if e.ID == f.id {
+		return errors.New("broker unavailable")
+	}
+
+	return f.Memory.Publish(ctx, e)
+}
+
+var _ = Describe("Outbox Suite", func() {
+	var (
+		db      *pg.DB
+		ctx     = context.Background()
+		outbox  store.Outbox
+		schemes store.Schemes
+	)
+
+	BeforeSuite(func() {
+		h, err := helium.New(&helium.Settings{
+			File:   "../config.yml",
+			Prefix: "TEST",
+		}, testModule)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(h.Invoke(func(pdb *pg.DB) {
+			db = pdb
+			outbox = store.NewOutboxStore(db)
+			schemes = store.NewSchemeStore(db)
+		})).NotTo(HaveOccurred())
+	})
+
+	AfterSuite(func() {
+		// BeforeSuite could fail before database is opened:
+		if db != nil {
+			Expect(db.Close()).NotTo(HaveOccurred())
+		}
+	})
+
+	BeforeEach(func() {
+		// Cleanup all tables...
+		_, err := db.Exec("TRUNCATE schemes, outbox RESTART IDENTITY CASCADE;")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("nats publisher", func() {
+		It("should publish event to subject of entity and op", func() {
+			srv := newNATSStandIn()
+			defer srv.Close()
+
+			p := NewNATS(srv.Addr().String(), "simplinic", time.Second)
+			defer p.Close()
+
+			for id := int64(1); id <= 2; id++ {
+				err := p.Publish(ctx, &store.Event{ID: id, Entity: "config", EntityID: 3, Op: "update", Payload: json.RawMessage(`{"id":3}`)})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(srv.subjects).To(Equal([]string{"simplinic.config.update", "simplinic.config.update"}))
+			Expect(srv.payloads[1]).To(ContainSubstring(`"id":2`))
+		})
+
+		It("should fail when server rejects event and reconnect", func() {
+			srv := newNATSStandIn()
+			defer srv.Close()
+
+			p := NewNATS(srv.Addr().String(), "simplinic", time.Second)
+			defer p.Close()
+
+			srv.reject = true
+			Expect(p.Publish(ctx, &store.Event{ID: 1, Entity: "scheme", Op: "create"})).To(HaveOccurred())
+
+			srv.reject = false
+			Expect(p.Publish(ctx, &store.Event{ID: 1, Entity: "scheme", Op: "create"})).NotTo(HaveOccurred())
+			Expect(srv.subjects).To(Equal([]string{"simplinic.scheme.create"}))
+		})
+
+		It("should fail when server is not available", func() {
+			p := NewNATS("127.0.0.1:1", "simplinic", 100*time.Millisecond)
+			Expect(p.Publish(ctx, &store.Event{ID: 1, Entity: "scheme", Op: "create"})).To(HaveOccurred())
+		})
+	})
+
+	Context("relay", func() {
+		It("should publish events of mutations in order", func() {
+			scheme := store.Scheme{Tags: []string{"outbox"}, Data: json.RawMessage(`{"a":1}`)}
+			Expect(schemes.Create(ctx, &scheme)).NotTo(HaveOccurred())
+			Expect(schemes.Update(ctx, &store.Scheme{ID: scheme.ID, Tags: []string{"outbox"}, Data: json.RawMessage(`{"a":2}`)})).NotTo(HaveOccurred())
+			Expect(schemes.Rename(ctx, scheme.ID, "outbox")).NotTo(HaveOccurred())
+			Expect(schemes.Delete(ctx, scheme.ID)).NotTo(HaveOccurred())
+
+			p := NewMemory()
+			r := NewRelay(viper.New(), outbox, p, zap.NewNop())
+			r.Job(ctx)
+
+			var ops []string
+			for _, e := range p.Events() {
+				Expect(e.EntityID).To(Equal(scheme.ID))
+				ops = append(ops, e.Op)
+			}
+
+			Expect(ops).To(Equal([]string{"create", "update", "rename", "delete"}))
+			var update store.Scheme
+
+			Expect(json.Unmarshal(p.Events()[1].Payload, &update)).NotTo(HaveOccurred())
+			Expect(update.Version).To(BeEquivalentTo(2))
+			Expect(update.Data).To(MatchJSON(`{"a":2}`))
+
+			// published events are not published again:
+			r.Job(ctx)
+			Expect(p.Events()).To(HaveLen(4))
+		})
+
+		It("should stop on failure and continue from failed event", func() {
+			for i := 0; i < 3; i++ {
+				scheme := store.Scheme{Tags: []string{"outbox"}, Data: json.RawMessage(`{"a":1}`)}
+				Expect(schemes.Create(ctx, &scheme)).NotTo(HaveOccurred())
+			}
+
+			p := &failing{Memory: NewMemory(), id: 2}
+			r := NewRelay(viper.New(), outbox, p, zap.NewNop())
+			r.Job(ctx)
+			Expect(p.Events()).To(HaveLen(1))
+
+			p.id = 0
+			r.Job(ctx)
+
+			var ids []int64
+			for _, e := range p.Events() {
+				ids = append(ids, e.ID)
+			}
+
+			Expect(ids).To(Equal([]int64{1, 2, 3}))
+		})
+
+		It("should not write events of rolled back transaction", func() {
+			err := store.NewTransactions(db).RunInTransaction(ctx, func(s store.Schemes, _ store.Configs) error {
+				scheme := store.Scheme{Tags: []string{"outbox"}, Data: json.RawMessage(`{"a":1}`)}
+				if err := s.Create(ctx, &scheme); err != nil {
+					return err
+				}
+
+				return errors.New("rollback")
+			})
+			Expect(err).To(HaveOccurred())
+
+			events, err := outbox.Pending(ctx, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultSubject = "simplinic"
+	defaultTimeout = 5 * time.Second
+)
+
+type (
+	// Publisher sends event to broker, event is marked as published
+	// only when Publish returns without error
+	Publisher interface {
+		Publish(ctx context.Context, e *store.Event) error
+	}
+
+	// Memory keeps published events, used when broker is not configured and in tests
+	Memory struct {
+		mu     sync.Mutex
+		events []*store.Event
+	}
+)
+
+// NewPublisher by `outbox.publisher`:
+//
+//	memory - events are kept in memory (default)
+//	nats   - events are published to `outbox.nats.address`,
+//	         subjects are `<outbox.nats.subject>.<entity>.<op>`
+func NewPublisher(v *viper.Viper) (Publisher, error) {
+	switch kind := v.GetString("outbox.publisher"); kind {
+	case "", "memory":
+		return NewMemory(), nil
+	case "nats":
+		var (
+			subject = defaultSubject
+			timeout = defaultTimeout
+		)
+
+		if !v.IsSet("outbox.nats.address") {
+			return nil, errors.New("outbox.nats.address should be set for nats publisher")
+		}
+
+		if v.IsSet("outbox.nats.subject") {
+			subject = v.GetString("outbox.nats.subject")
+		}
+
+		if v.IsSet("outbox.nats.timeout") {
+			timeout = v.GetDuration("outbox.nats.timeout")
+		}
+
+		return NewNATS(v.GetString("outbox.nats.address"), subject, timeout), nil
+	default:
+		return nil, errors.Errorf("unknown outbox.publisher %q, should be memory or nats", kind)
+	}
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(_ context.Context, e *store.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, e)
+
+	return nil
+}
+
+// Events returns copy of published events
+func (m *Memory) Events() []*store.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*store.Event(nil), m.events...)
+}
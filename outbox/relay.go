@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBatch     = 100
+	defaultRetention = 24 * time.Hour
+)
+
+// Relay publishes events of outbox in order of id. Publishing stops on first
+// error and continues from the same event next time, so events are delivered
+// at least once and in order. Relay should run in one instance at a time,
+// see `workers.outbox.lock`.
+type Relay struct {
+	store     store.Outbox
+	publisher Publisher
+	logger    *zap.Logger
+	batch     int
+	retention time.Duration
+}
+
+var Module = module.Module{
+	{Constructor: store.NewOutboxStore},
+	{Constructor: NewPublisher},
+	{Constructor: NewRelay},
+}
+
+// NewRelay with `outbox.batch` (100 by default) events read at once
+// and `outbox.retention` (24h by default) of published events
+func NewRelay(v *viper.Viper, s store.Outbox, p Publisher, l *zap.Logger) *Relay {
+	var r = &Relay{
+		store:     s,
+		publisher: p,
+		logger:    l,
+		batch:     defaultBatch,
+		retention: defaultRetention,
+	}
+
+	if v.IsSet("outbox.batch") {
+		r.batch = v.GetInt("outbox.batch")
+	}
+
+	if v.IsSet("outbox.retention") {
+		r.retention = v.GetDuration("outbox.retention")
+	}
+
+	return r
+}
+
+// Job publishes pending events and removes events published before retention
+func (r *Relay) Job(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, done := r.relay(ctx)
+
+		if err := r.store.Published(ctx, published); err != nil {
+			r.logger.Error("could not mark events as published", zap.Error(err))
+			return
+		}
+
+		if done {
+			break
+		}
+	}
+
+	if _, err := r.store.Cleanup(ctx, time.Now().Add(-r.retention)); err != nil {
+		r.logger.Error("could not cleanup outbox", zap.Error(err))
+	}
+}
+
+// relay publishes batch of events, returns ids of published events
+// and done when there are no more events to publish now
+func (r *Relay) relay(ctx context.Context) ([]int64, bool) {
+	events, err := r.store.Pending(ctx, r.batch)
+	if err != nil {
+		r.logger.Error("could not read outbox", zap.Error(err))
+		return nil, true
+	}
+
+	published := make([]int64, 0, len(events))
+
+	for _, e := range events {
+		if err = r.publisher.Publish(ctx, e); err != nil {
+			r.logger.Error("could not publish event",
+				zap.Int64("event", e.ID),
+				zap.String("entity", e.Entity),
+				zap.Int64("entity_id", e.EntityID),
+				zap.Error(err))
+
+			return published, true
+		}
+
+		published = append(published, e.ID)
+	}
+
+	return published, len(events) < r.batch
+}
@@ -0,0 +1,75 @@
+// Package reencrypt rewraps secret fields of stored configs after rotation
+// of keys and encrypts secrets that were stored before encryption was enabled
+// or before field was marked as secret.
+package reencrypt
+
+import (
+	"context"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const defaultBatch = 100
+
+// Reencryptor walks versions of configs that have values sealed by old keys or
+// secrets stored as is in batches of `secrets.batch`,
+// every batch runs in its own transaction
+type Reencryptor struct {
+	secrets store.Secrets
+	logger  *zap.Logger
+	batch   int
+}
+
+var Module = module.Module{
+	{Constructor: store.NewSecretStore},
+	{Constructor: NewReencryptor},
+}
+
+// NewReencryptor with `secrets.batch` (100 by default) versions per transaction
+func NewReencryptor(v *viper.Viper, s store.Secrets, l *zap.Logger) *Reencryptor {
+	var r = &Reencryptor{
+		secrets: s,
+		logger:  l,
+		batch:   defaultBatch,
+	}
+
+	if v.IsSet("secrets.batch") && v.GetInt("secrets.batch") > 0 {
+		r.batch = v.GetInt("secrets.batch")
+	}
+
+	return r
+}
+
+// Job re-encrypts all versions of configs
+func (r *Reencryptor) Job(ctx context.Context) {
+	count, err := r.Run(ctx)
+	if err != nil {
+		r.logger.Error("could not reencrypt secrets", zap.Int("versions", count), zap.Error(err))
+		return
+	}
+
+	if count > 0 {
+		r.logger.Info("secrets reencrypted", zap.Int("versions", count))
+	}
+}
+
+// Run re-encrypts all versions of configs, returns count of changed versions,
+// batches that are already done are kept when it fails
+func (r *Reencryptor) Run(ctx context.Context) (int, error) {
+	var (
+		total  int
+		cursor store.VersionCursor
+	)
+
+	for {
+		next, count, err := r.secrets.Reencrypt(ctx, cursor, r.batch)
+		if total += count; err != nil || next == (store.VersionCursor{}) {
+			return total, err
+		}
+
+		cursor = next
+	}
+}
@@ -0,0 +1,386 @@
+// Package render writes data of configs in formats of consumers that could
+// not read JSON: env files, Java properties, INI and HCL.
+//
+// Nested objects are flattened, keys of nested values are joined with
+// separator and converted to case of format, e.g. for
+//
+//	{"db": {"host": "localhost", "ports": [5432, 5433]}}
+//
+//	dotenv      DB__HOST=localhost, DB__PORTS__0=5432, DB__PORTS__1=5433
+//	properties  db.host=localhost, db.ports.0=5432, db.ports.1=5433
+//	ini         [db] section with host = localhost, ports.0 = 5432, ports.1 = 5433
+//	hcl         db_host = "localhost", db_ports = [5432, 5433]
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/codec"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of rendered configs
+const (
+	Dotenv     = "dotenv"
+	Properties = "properties"
+	INI        = "ini"
+	HCL        = "hcl"
+)
+
+// Cases of keys
+const (
+	CaseKeep  = "keep"
+	CaseUpper = "upper"
+	CaseLower = "lower"
+)
+
+var (
+	// ErrUnknownFormat when format is not one of Dotenv, Properties, INI or HCL
+	ErrUnknownFormat = errors.New("format should be dotenv, properties, ini or hcl")
+
+	// ErrInvalidOptions when case is unknown
+	ErrInvalidOptions = errors.New("case should be keep, upper or lower")
+
+	// ErrInvalidData when data is not object
+	ErrInvalidData = errors.New("data should be object")
+
+	// ErrInvalidKey when key (e.g. with separator or prefix) could not be used in format
+	ErrInvalidKey = errors.New("key could not be represented in format")
+
+	// ErrKeyConflict when different values have the same flattened key
+	ErrKeyConflict = errors.New("flattened keys of different values are the same")
+)
+
+// Options of keys, empty fields are set to defaults of format
+type Options struct {
+	Separator string // joins keys of nested objects and indexes of arrays
+	Case      string // keep, upper or lower
+	Prefix    string // prepended to keys as is
+}
+
+type (
+	format struct {
+		contentType string
+		defaults    Options
+
+		// segment replaces characters of key that could not be used in format
+		segment func(string) string
+		// valid keys, any key is valid when nil
+		valid *regexp.Regexp
+		// lists of scalars are written as values, otherwise items are flattened
+		lists bool
+
+		assign string
+		key    func(string) string
+		value  func(interface{}) string
+	}
+
+	// Pair of flattened key and value of scalar
+	Pair struct {
+		Path  []string // keys of objects and indexes of arrays in data
+		Key   string
+		Value string
+	}
+
+	item struct {
+		path  []string
+		value interface{}
+	}
+
+	section struct {
+		name  string
+		items []item
+	}
+)
+
+var formats = map[string]*format{
+	Dotenv: {
+		contentType: "text/plain; charset=utf-8",
+		defaults:    Options{Separator: "__", Case: CaseUpper},
+		segment:     replacer(`[^A-Za-z0-9_]`),
+		valid:       regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`),
+		assign:      "=",
+		key:         identity,
+		value:       dotenvValue,
+	},
+	Properties: {
+		contentType: "text/x-java-properties; charset=utf-8",
+		defaults:    Options{Separator: ".", Case: CaseKeep},
+		segment:     identity,
+		assign:      "=",
+		key:         propertiesKey,
+		value:       propertiesValue,
+	},
+	INI: {
+		contentType: "text/plain; charset=utf-8",
+		defaults:    Options{Separator: ".", Case: CaseKeep},
+		segment:     replacer(`[^A-Za-z0-9_.-]`),
+		valid:       regexp.MustCompile(`^[^\s=;#\[\]"']+$`),
+		assign:      " = ",
+		key:         identity,
+		value:       iniValue,
+	},
+	HCL: {
+		contentType: "text/plain; charset=utf-8",
+		defaults:    Options{Separator: "_", Case: CaseKeep},
+		segment:     replacer(`[^A-Za-z0-9_-]`),
+		valid:       regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`),
+		lists:       true,
+		assign:      " = ",
+		key:         identity,
+		value:       hclValue,
+	},
+}
+
+// Valid checks that format is known
+func Valid(name string) bool {
+	_, ok := formats[name]
+	return ok
+}
+
+// ContentType of format
+func ContentType(name string) string {
+	if f, ok := formats[name]; ok {
+		return f.contentType
+	}
+
+	return "text/plain; charset=utf-8"
+}
+
+// Defaults returns options of format: DB__HOST for dotenv,
+// db.host for properties and INI and db_host for HCL
+func Defaults(name string) Options {
+	if f, ok := formats[name]; ok {
+		return f.defaults
+	}
+
+	return Options{}
+}
+
+// Render flattens JSON object and writes it in format, keys keep order of data.
+// For INI nested objects of data are written as sections.
+func Render(name string, data json.RawMessage, opts Options) ([]byte, error) {
+	f, opts, root, err := prepare(name, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		buf      bytes.Buffer
+		sections = []section{{}}
+	)
+
+	for _, member := range root {
+		key := member.Key.(string) // keys of JSON objects
+
+		if table, ok := member.Value.(yaml.MapSlice); ok && name == INI {
+			sections = append(sections, section{name: opts.convert(f.segment(key))})
+			flatten(nil, table, f.lists, &sections[len(sections)-1].items)
+
+			continue
+		}
+
+		flatten([]string{key}, member.Value, f.lists, &sections[0].items)
+	}
+
+	for _, s := range sections {
+		if s.name != "" {
+			if !f.valid.MatchString(s.name) {
+				return nil, errors.Wrapf(ErrInvalidKey, "section %q", s.name)
+			}
+
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+
+			buf.WriteString("[" + s.name + "]\n")
+		}
+
+		if err = f.write(&buf, s.items, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Flatten returns scalars of JSON object with keys of format (e.g. to build
+// key/value pairs for other formats), values are not escaped,
+// nested objects and arrays are always flattened
+func Flatten(name string, data json.RawMessage, opts Options) ([]Pair, error) {
+	var items []item
+
+	f, opts, root, err := prepare(name, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	flatten(nil, root, false, &items)
+
+	keys, err := f.keys(items, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]Pair, 0, len(items))
+
+	for i, it := range items {
+		result = append(result, Pair{Path: it.path, Key: keys[i], Value: scalar(it.value, "", identity)})
+	}
+
+	return result, nil
+}
+
+// prepare checks format and options and decodes data
+func prepare(name string, data json.RawMessage, opts Options) (*format, Options, yaml.MapSlice, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, opts, nil, ErrUnknownFormat
+	}
+
+	if opts = opts.withDefaults(f.defaults); opts.Case != CaseKeep && opts.Case != CaseUpper && opts.Case != CaseLower {
+		return nil, opts, nil, ErrInvalidOptions
+	}
+
+	doc, err := codec.Tree(data)
+	if err != nil {
+		return nil, opts, nil, err
+	}
+
+	root, ok := doc.(yaml.MapSlice)
+	if !ok {
+		return nil, opts, nil, ErrInvalidData
+	}
+
+	return f, opts, root, nil
+}
+
+func (o Options) withDefaults(d Options) Options {
+	if o.Separator == "" {
+		o.Separator = d.Separator
+	}
+
+	if o.Case == "" {
+		o.Case = d.Case
+	}
+
+	return o
+}
+
+// convert changes case of key
+func (o Options) convert(key string) string {
+	switch o.Case {
+	case CaseUpper:
+		return strings.ToUpper(key)
+	case CaseLower:
+		return strings.ToLower(key)
+	}
+
+	return key
+}
+
+// write items of section
+func (f *format) write(w *bytes.Buffer, items []item, opts Options) error {
+	keys, err := f.keys(items, opts)
+	if err != nil {
+		return err
+	}
+
+	for i, it := range items {
+		if value := f.value(it.value); value != "" {
+			w.WriteString(f.key(keys[i]) + f.assign + value + "\n")
+		} else {
+			w.WriteString(f.key(keys[i]) + strings.TrimRight(f.assign, " ") + "\n")
+		}
+	}
+
+	return nil
+}
+
+// keys of items, keys are checked for conflicts
+func (f *format) keys(items []item, opts Options) ([]string, error) {
+	var (
+		result = make([]string, 0, len(items))
+		seen   = make(map[string]struct{}, len(items))
+	)
+
+	for _, it := range items {
+		var segments = make([]string, 0, len(it.path))
+
+		for _, segment := range it.path {
+			segments = append(segments, f.segment(segment))
+		}
+
+		key := opts.Prefix + opts.convert(strings.Join(segments, opts.Separator))
+
+		if f.valid != nil && !f.valid.MatchString(key) {
+			return nil, errors.Wrapf(ErrInvalidKey, "key %q", key)
+		}
+
+		if _, ok := seen[key]; ok {
+			return nil, errors.Wrapf(ErrKeyConflict, "key %q", key)
+		}
+
+		seen[key] = struct{}{}
+		result = append(result, key)
+	}
+
+	return result, nil
+}
+
+// flatten appends scalars of value to items, path is key of value,
+// empty objects and arrays (unless lists are values) have no items
+func flatten(path []string, value interface{}, lists bool, items *[]item) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		for _, member := range v {
+			flatten(with(path, member.Key.(string)), member.Value, lists, items)
+		}
+
+		return
+	case []interface{}:
+		if lists && scalars(v) {
+			break
+		}
+
+		for i, el := range v {
+			flatten(with(path, strconv.Itoa(i)), el, lists, items)
+		}
+
+		return
+	}
+
+	*items = append(*items, item{path: path, value: value})
+}
+
+// with returns copy of path with key
+func with(path []string, key string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), key)
+}
+
+func scalars(items []interface{}) bool {
+	for _, el := range items {
+		switch el.(type) {
+		case yaml.MapSlice, []interface{}:
+			return false
+		}
+	}
+
+	return true
+}
+
+func identity(s string) string { return s }
+
+// replacer of characters that match expression with underscore
+func replacer(expr string) func(string) string {
+	re := regexp.MustCompile(expr)
+
+	return func(s string) string {
+		return re.ReplaceAllString(s, "_")
+	}
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("Render Suite", func() {
+	const data = `{"name":"app","debug":true,"db":{"host":"localhost","ports":[5432,5433],"password":"p a$s\"#1","timeout":1.5,"replica":null},"motd":"héllo\nworld"}`
+
+	render := func(format string, opts Options) string {
+		out, err := Render(format, json.RawMessage(data), opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		return string(out)
+	}
+
+	It("should render env file", func() {
+		Expect(render(Dotenv, Options{})).To(Equal(`NAME=app
+DEBUG=true
+DB__HOST=localhost
+DB__PORTS__0=5432
+DB__PORTS__1=5433
+DB__PASSWORD="p a\$s\"#1"
+DB__TIMEOUT=1.5
+DB__REPLICA=
+MOTD="héllo\nworld"
+`))
+	})
+
+	It("should render Java properties", func() {
+		Expect(render(Properties, Options{})).To(Equal(`name=app
+debug=true
+db.host=localhost
+db.ports.0=5432
+db.ports.1=5433
+db.password=p a$s"\#1
+db.timeout=1.5
+db.replica=
+motd=h\u00E9llo\nworld
+`))
+	})
+
+	It("should render INI with sections of nested objects", func() {
+		Expect(render(INI, Options{})).To(Equal(`name = app
+debug = true
+motd = "héllo\nworld"
+
+[db]
+host = localhost
+ports.0 = 5432
+ports.1 = 5433
+password = "p a$s\"#1"
+timeout = 1.5
+replica =
+`))
+	})
+
+	It("should render HCL with lists of scalars", func() {
+		Expect(render(HCL, Options{})).To(Equal(`name = "app"
+debug = true
+db_host = "localhost"
+db_ports = [5432, 5433]
+db_password = "p a$s\"#1"
+db_timeout = 1.5
+db_replica = null
+motd = "héllo\nworld"
+`))
+	})
+
+	It("should escape interpolation of HCL", func() {
+		out, err := Render(HCL, json.RawMessage(`{"a":"${b} %{c} $d"}`), Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`a = "$${b} %%{c} $d"` + "\n"))
+	})
+
+	It("should apply key conventions", func() {
+		Expect(render(Properties, Options{Separator: "_", Case: CaseUpper, Prefix: "app."})).
+			To(ContainSubstring("app.DB_PORTS_1=5433\n"))
+
+		Expect(render(Dotenv, Options{Separator: "_", Case: CaseLower, Prefix: "APP_"})).
+			To(ContainSubstring("APP_db_host=localhost\n"))
+	})
+
+	It("should fail on keys that could not be represented", func() {
+		for _, item := range []struct {
+			format string
+			data   string
+			opts   Options
+			err    error
+		}{
+			{format: "xml", data: `{}`, err: ErrUnknownFormat},
+			{format: Dotenv, data: `{}`, opts: Options{Case: "camel"}, err: ErrInvalidOptions},
+			{format: Dotenv, data: `[1]`, err: ErrInvalidData},
+			{format: Dotenv, data: `{"1st":1}`, err: ErrInvalidKey},
+			{format: HCL, data: `{"a":{"b":1}}`, opts: Options{Separator: "."}, err: ErrInvalidKey},
+			{format: Dotenv, data: `{"a-b":1,"a_b":2}`, err: ErrKeyConflict},
+		} {
+			_, err := Render(item.format, json.RawMessage(item.data), item.opts)
+			Expect(errors.Cause(err)).To(Equal(item.err), item.data)
+		}
+	})
+})
@@ -0,0 +1,172 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// plain values of env files are written without quotes
+var dotenvPlain = regexp.MustCompile(`^[A-Za-z0-9_./:@%,+=-]*$`)
+
+// scalar formats JSON scalar, strings are formatted by fn
+func scalar(v interface{}, null string, fn func(string) string) string {
+	switch value := v.(type) {
+	case nil:
+		return null
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		return fn(value)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// dotenvValue is double-quoted when needed, escapes are understood by
+// docker compose, godotenv and python-dotenv
+func dotenvValue(v interface{}) string {
+	return scalar(v, "", func(s string) string {
+		if dotenvPlain.MatchString(s) {
+			return s
+		}
+
+		return `"` + strings.NewReplacer(
+			`\`, `\\`,
+			`"`, `\"`,
+			"$", `\$`,
+			"`", "\\`",
+			"\n", `\n`,
+			"\r", `\r`,
+		).Replace(s) + `"`
+	})
+}
+
+// propertiesKey escapes key like java.util.Properties#store
+func propertiesKey(key string) string {
+	return propertiesEscape(key, true)
+}
+
+func propertiesValue(v interface{}) string {
+	return scalar(v, "", func(s string) string {
+		return propertiesEscape(s, false)
+	})
+}
+
+// propertiesEscape escapes separators, comments, control characters and
+// spaces (leading space of values), other than printable ASCII is written as \uXXXX
+func propertiesEscape(s string, key bool) string {
+	var buf strings.Builder
+
+	for i, r := range s {
+		switch r {
+		case ' ':
+			if i == 0 || key {
+				buf.WriteString(`\ `)
+				continue
+			}
+
+			buf.WriteRune(r)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\\', '=', ':', '#', '!':
+			buf.WriteString(`\` + string(r))
+		default:
+			if r >= 0x20 && r <= 0x7e {
+				buf.WriteRune(r)
+				continue
+			}
+
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&buf, `\u%04X`, u)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// iniValue is double-quoted when it has comments, quotes, separators,
+// surrounding spaces or control characters
+func iniValue(v interface{}) string {
+	return scalar(v, "", func(s string) string {
+		if strings.TrimSpace(s) == s && !strings.ContainsAny(s, `"';#=\`) && strings.IndexFunc(s, unicode.IsControl) < 0 {
+			return s
+		}
+
+		return `"` + strings.NewReplacer(
+			`\`, `\\`,
+			`"`, `\"`,
+			"\n", `\n`,
+			"\r", `\r`,
+			"\t", `\t`,
+		).Replace(s) + `"`
+	})
+}
+
+// hclValue writes lists of scalars as tuples and strings as quoted templates,
+// so interpolation sequences are escaped
+func hclValue(v interface{}) string {
+	if items, ok := v.([]interface{}); ok {
+		var values = make([]string, 0, len(items))
+
+		for _, el := range items {
+			values = append(values, hclValue(el))
+		}
+
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+
+	return scalar(v, "null", hclString)
+}
+
+func hclString(s string) string {
+	var buf strings.Builder
+
+	buf.WriteByte('"')
+
+	for i, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '$', '%':
+			// ${ and %{ start template sequences:
+			if strings.HasPrefix(s[i+1:], "{") {
+				buf.WriteRune(r)
+			}
+
+			buf.WriteRune(r)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+				continue
+			}
+
+			buf.WriteRune(r)
+		}
+	}
+
+	buf.WriteByte('"')
+
+	return buf.String()
+}
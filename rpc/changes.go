@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"github.com/im-kulikov/simplinic-task/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func changeProto(c *store.Change) *Change {
+	return &Change{
+		Cursor:    c.Cursor().String(),
+		Id:        c.ID,
+		Entity:    c.Entity,
+		EntityId:  c.EntityID,
+		SchemeId:  c.SchemeID,
+		Version:   c.Version,
+		Op:        c.Op,
+		Tags:      c.Tags,
+		CreatedAt: protoTime(c.CreatedAt),
+	}
+}
+
+// Watch streams changes after cursor of request, cursor of every change
+// could be used to continue stream after reconnect, see store.Cursor
+func (s *changesServer) Watch(req *WatchRequest, stream Changes_WatchServer) error {
+	var (
+		err   error
+		items []*store.Change
+		ctx   = stream.Context()
+		sreq  = store.ChangesRequest{
+			Entity:   req.Kind,
+			IDs:      req.Ids,
+			SchemeID: req.SchemeId,
+			Tags:     req.Tags,
+		}
+	)
+
+	if req.Kind != "" && req.Kind != "scheme" && req.Kind != "config" {
+		return status.Error(codes.InvalidArgument, "kind should be scheme or config")
+	}
+
+	// subscribe before first read, changes between read and wait are not lost:
+	events, cancel := s.feed.Subscribe()
+	defer cancel()
+
+	if req.Cursor == "" {
+		if sreq.After, err = s.changes.Last(ctx); err != nil {
+			return storeError(s.logger, err)
+		}
+	} else if sreq.After, err = store.ParseCursor(req.Cursor); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for {
+		if items, err = s.changes.Since(ctx, sreq); err != nil {
+			return storeError(s.logger, err)
+		}
+
+		for _, item := range items {
+			if err = stream.Send(changeProto(item)); err != nil {
+				return err
+			}
+
+			sreq.After = item.Cursor()
+		}
+
+		// there are more changes to read:
+		if len(items) == store.DefaultChangesLimit {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-events:
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func configProto(s *store.Config) *Config {
+	return &Config{
+		Id:        s.ID,
+		SchemeId:  s.SchemeID,
+		Version:   s.Version,
+		Tags:      s.Tags,
+		Data:      s.Data,
+		Author:    s.Author,
+		Slug:      s.Slug,
+		CreatedAt: protoTime(s.CreatedAt),
+	}
+}
+
+func configsProto(items []*store.Config, total int) *ConfigsResponse {
+	var result = &ConfigsResponse{Total: int32(total)}
+
+	for _, item := range items {
+		result.Items = append(result.Items, configProto(item))
+	}
+
+	return result
+}
+
+func (s *configsServer) Create(ctx context.Context, req *Config) (*Config, error) {
+	if req.SchemeId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "scheme_id could not be empty")
+	} else if err := validVersion(req.Tags, req.Data); err != nil {
+		return nil, err
+	}
+
+	model := &store.Config{
+		SchemeID: req.SchemeId,
+		Tags:     req.Tags,
+		Data:     json.RawMessage(req.Data),
+		Author:   identity(ctx),
+		Slug:     req.Slug,
+	}
+
+	if err := s.store.Create(ctx, model); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return configProto(model), nil
+}
+
+func (s *configsServer) Read(ctx context.Context, req *IDRequest) (*Config, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	model, err := s.store.Read(ctx, req.Id)
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	} else if err = s.resolve(ctx, model, req.Raw); err != nil {
+		return nil, err
+	}
+
+	staleHeader(ctx, model.Stale)
+
+	return configProto(model), nil
+}
+
+func (s *configsServer) ReadBySlug(ctx context.Context, req *SlugRequest) (*Config, error) {
+	if req.Scheme == "" || req.Slug == "" {
+		return nil, status.Error(codes.InvalidArgument, "scheme and slug could not be empty")
+	}
+
+	model, err := s.store.ReadBySlug(ctx, req.Scheme, req.Slug)
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	} else if err = s.resolve(ctx, model, req.Raw); err != nil {
+		return nil, err
+	}
+
+	staleHeader(ctx, model.Stale)
+
+	return configProto(model), nil
+}
+
+// resolve replaces references of config data by referenced values, unless raw is set
+func (s *configsServer) resolve(ctx context.Context, model *store.Config, raw bool) error {
+	if raw {
+		return nil
+	}
+
+	data, err := store.NewResolver(ctx, s.store, s.vars).Resolve(model.ID, model.Data)
+	if err != nil {
+		return storeError(s.logger, err)
+	}
+
+	model.Data = data
+
+	return nil
+}
+
+func (s *configsServer) Update(ctx context.Context, req *Config) (*Config, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	} else if err = validVersion(req.Tags, req.Data); err != nil {
+		return nil, err
+	}
+
+	model := &store.Config{
+		ID:     req.Id,
+		Tags:   req.Tags,
+		Data:   json.RawMessage(req.Data),
+		Author: identity(ctx),
+	}
+
+	if err := s.store.Update(ctx, model); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return configProto(model), nil
+}
+
+func (s *configsServer) Rename(ctx context.Context, req *RenameRequest) (*Config, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Rename(ctx, req.Id, req.Slug); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return s.Read(ctx, &IDRequest{Id: req.Id})
+}
+
+func (s *configsServer) Delete(ctx context.Context, req *IDRequest) (*Empty, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Delete(ctx, req.Id); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return &Empty{}, nil
+}
+
+func (s *configsServer) Restore(ctx context.Context, req *IDRequest) (*Config, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Restore(ctx, req.Id); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return s.Read(ctx, req)
+}
+
+func (s *configsServer) Search(ctx context.Context, req *SearchRequest) (*ConfigsResponse, error) {
+	sreq, err := searchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	items, total, err := s.store.Search(ctx, sreq)
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return configsProto(items, total), nil
+}
+
+func (s *configsServer) History(ctx context.Context, req *HistoryRequest) (*ConfigsResponse, error) {
+	if err := historyRequest(req); err != nil {
+		return nil, err
+	}
+
+	items, total, err := s.store.History(ctx, req.Id, int(req.Limit), int(req.Offset))
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return configsProto(items, total), nil
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// identityKey of context, see identity
+type identityKey struct{}
+
+// newCredentials reads `grpc.tls` of settings, nil credentials when certificate is not set:
+//
+//	grpc:
+//	  tls:
+//	    cert: server.crt    # certificate of server
+//	    key: server.key     # private key of server
+//	    client_ca: ca.crt   # CA of client certificates, optional
+//
+// Client certificate is verified by client CA when client sends it, clients
+// without certificate are anonymous.
+func newCredentials(v *viper.Viper) (credentials.TransportCredentials, error) {
+	if v.GetString("grpc.tls.cert") == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(v.GetString("grpc.tls.cert"), v.GetString("grpc.tls.key"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load certificate of grpc server")
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if path := v.GetString("grpc.tls.client_ca"); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read client CA of grpc server")
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+
+		if !config.ClientCAs.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("client CA %q has no certificates", path)
+		}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// newIdentity reads `grpc.identity` of settings like `api.identity`
+// and returns interceptor that authenticates clients, see identify
+//
+//	grpc:
+//	  identity:
+//	    header: x-remote-user      # metadata set by authenticating proxy
+//	    proxies: [127.0.0.1/32]    # networks of trusted proxies
+func newIdentity(v *viper.Viper) (grpc.UnaryServerInterceptor, error) {
+	var proxies []*net.IPNet
+
+	for _, item := range v.GetStringSlice("grpc.identity.proxies") {
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network of trusted proxy %q", item)
+		}
+
+		proxies = append(proxies, network)
+	}
+
+	return identify(strings.ToLower(v.GetString("grpc.identity.header")), proxies), nil
+}
+
+// identify stores identity of client in context, identity is taken only from
+// authenticated sources:
+//   - common name of subject of verified client certificate (mTLS)
+//   - metadata set by trusted proxy, metadata of other clients is ignored
+//
+// Identity of anonymous client is empty.
+func identify(header string, proxies []*net.IPNet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return next(ctx, req)
+		}
+
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			return next(context.WithValue(ctx, identityKey{}, info.State.VerifiedChains[0][0].Subject.CommonName), req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		if names := md.Get(header); header != "" && len(names) > 0 && names[0] != "" && trusted(p.Addr, proxies) {
+			return next(context.WithValue(ctx, identityKey{}, names[0]), req)
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// trusted checks that request is sent by trusted proxy
+func trusted(addr net.Addr, proxies []*net.IPNet) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, network := range proxies {
+		if network.Contains(tcp.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// identity of authenticated client, empty for anonymous client
+func identity(ctx context.Context) string {
+	name, _ := ctx.Value(identityKey{}).(string)
+	return name
+}
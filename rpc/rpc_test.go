@@ -0,0 +1,281 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/golang/protobuf/proto"
+	"github.com/im-kulikov/helium"
+	"github.com/im-kulikov/helium/grace"
+	"github.com/im-kulikov/helium/logger"
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/helium/orm"
+	"github.com/im-kulikov/helium/settings"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var testModule = module.Module{}.Append(
+	grace.Module,
+	settings.Module,
+	logger.Module,
+	orm.Module,
+)
+
+var _ = Describe("RPC Suite", func() {
+	var (
+		db      *pg.DB
+		srv     *grpc.Server
+		conn    *grpc.ClientConn
+		stop    context.CancelFunc
+		ctx     = context.Background()
+		schemes SchemesClient
+		configs ConfigsClient
+		changes ChangesClient
+	)
+
+	BeforeSuite(func() {
+		h, err := helium.New(&helium.Settings{
+			File:   "../config.yml",
+			Prefix: "TEST",
+		}, testModule)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(h.Invoke(func(pdb *pg.DB) {
+			db = pdb
+
+			// Cleanup all tables...
+			_, err := db.Exec("TRUNCATE schemes RESTART IDENTITY CASCADE;")
+			Expect(err).NotTo(HaveOccurred())
+		})).NotTo(HaveOccurred())
+
+		var feedCtx context.Context
+		feedCtx, stop = context.WithCancel(context.Background())
+
+		_, loopback, err := net.ParseCIDR("127.0.0.1/32")
+		Expect(err).NotTo(HaveOccurred())
+
+		srv = grpc.NewServer(grpc.UnaryInterceptor(identify("x-remote-user", []*net.IPNet{loopback})))
+		Register(srv, Params{
+			Logger:  zap.NewNop(),
+			Schemes: store.NewSchemeStore(db),
+			Configs: store.NewConfigStore(db),
+			Changes: store.NewChangeStore(db),
+			Feed:    store.NewFeed(feedCtx, db, zap.NewNop()),
+		})
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go srv.Serve(ln)
+
+		conn, err = grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+		Expect(err).NotTo(HaveOccurred())
+
+		schemes = NewSchemesClient(conn)
+		configs = NewConfigsClient(conn)
+		changes = NewChangesClient(conn)
+	})
+
+	AfterSuite(func() {
+		// BeforeSuite could fail before connections are opened:
+		if conn != nil {
+			Expect(conn.Close()).NotTo(HaveOccurred())
+		}
+
+		if srv != nil {
+			srv.Stop()
+		}
+
+		if stop != nil {
+			stop()
+		}
+
+		if db != nil {
+			Expect(db.Close()).NotTo(HaveOccurred())
+		}
+	})
+
+	It("should encode messages like protoc-gen-go", func() {
+		msg := &Config{Id: 1, SchemeId: 2, Tags: []string{"a"}, Data: []byte(`{}`)}
+
+		data, err := proto.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal([]byte{0x08, 0x01, 0x10, 0x02, 0x22, 0x01, 'a', 0x2a, 0x02, '{', '}'}))
+
+		var result Config
+		Expect(proto.Unmarshal(data, &result)).NotTo(HaveOccurred())
+		Expect(proto.Equal(&result, msg)).To(BeTrue())
+	})
+
+	It("should create, update, search and read history of scheme and config", func() {
+		scheme, err := schemes.Create(ctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`{"a":1}`), Slug: "rpc"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scheme.Id).NotTo(BeZero())
+		Expect(scheme.CreatedAt).NotTo(BeNil())
+
+		_, err = schemes.Update(ctx, &Scheme{Id: scheme.Id, Tags: []string{"rpc"}, Data: []byte(`{"a":2}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		latest, err := schemes.ReadBySlug(ctx, &SlugRequest{Slug: "rpc"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(latest.Version).To(BeEquivalentTo(2))
+		Expect(latest.Data).To(MatchJSON(`{"a":2}`))
+
+		history, err := schemes.History(ctx, &HistoryRequest{Id: scheme.Id})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(history.Total).To(BeEquivalentTo(2))
+		Expect(history.Items[0].Version).To(BeEquivalentTo(2))
+		Expect(history.Items[1].Version).To(BeEquivalentTo(1))
+
+		cfg, err := configs.Create(ctx, &Config{SchemeId: scheme.Id, Tags: []string{"rpc"}, Data: []byte(`{"b":1}`), Slug: "prod"})
+		Expect(err).NotTo(HaveOccurred())
+
+		found, err := configs.Search(ctx, &SearchRequest{SchemeId: scheme.Id, Filter: `data.b = 1`})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found.Total).To(BeEquivalentTo(1))
+		Expect(found.Items[0].Id).To(Equal(cfg.Id))
+
+		bySlug, err := configs.ReadBySlug(ctx, &SlugRequest{Scheme: "rpc", Slug: "prod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bySlug.Id).To(Equal(cfg.Id))
+	})
+
+	It("should take author of versions from metadata of trusted proxy only", func() {
+		mctx := metadata.AppendToOutgoingContext(ctx, "x-remote-user", "jane")
+
+		scheme, err := schemes.Create(mctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`{}`), Author: "john"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scheme.Author).To(Equal("jane"))
+
+		// the same metadata of client from other network is ignored:
+		_, other, err := net.ParseCIDR("10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+
+		pctx := peer.NewContext(metadata.NewIncomingContext(ctx, metadata.Pairs("x-remote-user", "jane")),
+			&peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}})
+
+		_, err = identify("x-remote-user", []*net.IPNet{other})(pctx, nil, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, _ interface{}) (interface{}, error) {
+				Expect(identity(ctx)).To(BeEmpty())
+				return nil, nil
+			})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should return codes of errors", func() {
+		_, err := schemes.Read(ctx, &IDRequest{Id: 100500})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+		_, err = schemes.Create(ctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`not json`)})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		_, err = configs.Search(ctx, &SearchRequest{Filter: `data.b =`})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		scheme, err := schemes.Create(ctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`{}`), Slug: "taken"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = schemes.Create(ctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`{}`), Slug: "taken"})
+		Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+
+		_, err = schemes.Delete(ctx, &IDRequest{Id: scheme.Id})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = schemes.History(ctx, &HistoryRequest{Id: scheme.Id})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+	})
+
+	It("should resolve references of read configs unless raw", func() {
+		scheme, err := schemes.Create(ctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`{}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		target, err := configs.Create(ctx, &Config{SchemeId: scheme.Id, Tags: []string{"rpc"}, Data: []byte(`{"host":"db"}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		data := []byte(fmt.Sprintf(`{"url":"postgres://${config:%d#/host}"}`, target.Id))
+		dependent, err := configs.Create(ctx, &Config{SchemeId: scheme.Id, Tags: []string{"rpc"}, Data: data})
+		Expect(err).NotTo(HaveOccurred())
+
+		resolved, err := configs.Read(ctx, &IDRequest{Id: dependent.Id})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Data).To(MatchJSON(`{"url":"postgres://db"}`))
+
+		raw, err := configs.Read(ctx, &IDRequest{Id: dependent.Id, Raw: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(raw.Data).To(MatchJSON(data))
+	})
+
+	It("should return codes of reference errors", func() {
+		scheme, err := schemes.Create(ctx, &Scheme{Tags: []string{"rpc"}, Data: []byte(`{}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		target, err := configs.Create(ctx, &Config{SchemeId: scheme.Id, Tags: []string{"rpc"}, Data: []byte(`{"host":"db"}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		data := []byte(fmt.Sprintf(`{"host":"${config:%d#/host}"}`, target.Id))
+		dependent, err := configs.Create(ctx, &Config{SchemeId: scheme.Id, Tags: []string{"rpc"}, Data: data})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = configs.Delete(ctx, &IDRequest{Id: target.Id})
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+
+		_, err = configs.Create(ctx, &Config{SchemeId: scheme.Id, Tags: []string{"rpc"}, Data: []byte(`{"host":"${config:100500}"}`)})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		data = []byte(fmt.Sprintf(`{"host":"${config:%d#/host}"}`, dependent.Id))
+		_, err = configs.Update(ctx, &Config{Id: target.Id, Tags: []string{"rpc"}, Data: data})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("should convert errors of references and secrets", func() {
+		for err, code := range map[error]codes.Code{
+			store.ErrReferenced:                codes.FailedPrecondition,
+			interpolate.ErrInvalidReference:    codes.InvalidArgument,
+			interpolate.ErrCycle:               codes.InvalidArgument,
+			secrets.ErrInvalidSecret:           codes.InvalidArgument,
+			secrets.ErrUnknownKey:              codes.InvalidArgument,
+			secrets.ErrNoKeys:                  codes.FailedPrecondition,
+			errors.New("connection is closed"): codes.Internal,
+		} {
+			Expect(status.Code(storeError(zap.NewNop(), errors.Wrap(err, "wrapped")))).To(Equal(code), err.Error())
+		}
+	})
+
+	It("should stream changes after cursor", func() {
+		wctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		scheme, err := schemes.Create(ctx, &Scheme{Tags: []string{"watch"}, Data: []byte(`{}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		stream, err := changes.Watch(wctx, &WatchRequest{Cursor: "0-0", Kind: "scheme", Ids: []int64{scheme.Id}})
+		Expect(err).NotTo(HaveOccurred())
+
+		created, err := stream.Recv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.Op).To(Equal("create"))
+		Expect(created.Cursor).NotTo(BeEmpty())
+
+		_, err = schemes.Update(ctx, &Scheme{Id: scheme.Id, Tags: []string{"watch"}, Data: []byte(`{"a":1}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated, err := stream.Recv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Op).To(Equal("update"))
+		Expect(updated.Version).To(BeEquivalentTo(2))
+	})
+})
@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/im-kulikov/simplinic-task/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func schemeProto(s *store.Scheme) *Scheme {
+	return &Scheme{
+		Id:        s.ID,
+		Version:   s.Version,
+		Tags:      s.Tags,
+		Data:      s.Data,
+		Author:    s.Author,
+		Slug:      s.Slug,
+		CreatedAt: protoTime(s.CreatedAt),
+	}
+}
+
+func schemesProto(items []*store.Scheme, total int) *SchemesResponse {
+	var result = &SchemesResponse{Total: int32(total)}
+
+	for _, item := range items {
+		result.Items = append(result.Items, schemeProto(item))
+	}
+
+	return result
+}
+
+func (s *schemesServer) Create(ctx context.Context, req *Scheme) (*Scheme, error) {
+	if err := validVersion(req.Tags, req.Data); err != nil {
+		return nil, err
+	}
+
+	model := &store.Scheme{
+		Tags:   req.Tags,
+		Data:   json.RawMessage(req.Data),
+		Author: identity(ctx),
+		Slug:   req.Slug,
+	}
+
+	if err := s.store.Create(ctx, model); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return schemeProto(model), nil
+}
+
+func (s *schemesServer) Read(ctx context.Context, req *IDRequest) (*Scheme, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	model, err := s.store.Read(ctx, req.Id)
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	staleHeader(ctx, model.Stale)
+
+	return schemeProto(model), nil
+}
+
+func (s *schemesServer) ReadBySlug(ctx context.Context, req *SlugRequest) (*Scheme, error) {
+	if req.Slug == "" {
+		return nil, status.Error(codes.InvalidArgument, "slug could not be empty")
+	}
+
+	model, err := s.store.ReadBySlug(ctx, req.Slug)
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	staleHeader(ctx, model.Stale)
+
+	return schemeProto(model), nil
+}
+
+func (s *schemesServer) Update(ctx context.Context, req *Scheme) (*Scheme, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	} else if err = validVersion(req.Tags, req.Data); err != nil {
+		return nil, err
+	}
+
+	model := &store.Scheme{
+		ID:     req.Id,
+		Tags:   req.Tags,
+		Data:   json.RawMessage(req.Data),
+		Author: identity(ctx),
+	}
+
+	if err := s.store.Update(ctx, model); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return schemeProto(model), nil
+}
+
+func (s *schemesServer) Rename(ctx context.Context, req *RenameRequest) (*Scheme, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Rename(ctx, req.Id, req.Slug); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return s.Read(ctx, &IDRequest{Id: req.Id})
+}
+
+func (s *schemesServer) Delete(ctx context.Context, req *IDRequest) (*Empty, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Delete(ctx, req.Id); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return &Empty{}, nil
+}
+
+func (s *schemesServer) Restore(ctx context.Context, req *IDRequest) (*Scheme, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Restore(ctx, req.Id); err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return s.Read(ctx, req)
+}
+
+func (s *schemesServer) Search(ctx context.Context, req *SearchRequest) (*SchemesResponse, error) {
+	sreq, err := searchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	items, total, err := s.store.Search(ctx, sreq)
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return schemesProto(items, total), nil
+}
+
+func (s *schemesServer) History(ctx context.Context, req *HistoryRequest) (*SchemesResponse, error) {
+	if err := historyRequest(req); err != nil {
+		return nil, err
+	}
+
+	items, total, err := s.store.History(ctx, req.Id, int(req.Limit), int(req.Offset))
+	if err != nil {
+		return nil, storeError(s.logger, err)
+	}
+
+	return schemesProto(items, total), nil
+}
@@ -0,0 +1,225 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/chapsuk/mserv"
+	"github.com/go-pg/pg"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/filter"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/dig"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// headerStale is set when last-known-good version is served, see store.Cache
+const headerStale = "x-stale"
+
+type (
+	// Params is dependencies of gRPC server
+	Params struct {
+		dig.In
+
+		Config  *viper.Viper
+		Logger  *zap.Logger
+		Schemes store.Schemes
+		Configs store.Configs
+		Changes store.Changes
+		Feed    *store.Feed
+		Vars    interpolate.Variables `optional:"true"`
+	}
+
+	// Result is gRPC server in `web_server` group, started with other servers
+	Result struct {
+		dig.Out
+
+		Server mserv.Server `group:"web_server"`
+	}
+
+	schemesServer struct {
+		store  store.Schemes
+		logger *zap.Logger
+	}
+
+	configsServer struct {
+		store  store.Configs
+		vars   interpolate.Variables
+		logger *zap.Logger
+	}
+
+	changesServer struct {
+		changes store.Changes
+		feed    *store.Feed
+		logger  *zap.Logger
+	}
+)
+
+var Module = module.Module{
+	{Constructor: NewServer},
+}
+
+// NewServer creates gRPC server bound to `grpc.address`, server is skipped when
+// address is not set. Clients are authenticated by certificates (see newCredentials)
+// or by trusted proxies (see newIdentity).
+func NewServer(p Params) (Result, error) {
+	if !p.Config.IsSet("grpc.address") {
+		p.Logger.Info("empty bind address for grpc server, skip")
+		return Result{}, nil
+	}
+
+	creds, err := newCredentials(p.Config)
+	if err != nil {
+		return Result{}, err
+	}
+
+	interceptor, err := newIdentity(p.Config)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var opts = []grpc.ServerOption{grpc.UnaryInterceptor(interceptor)}
+
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	if p.Config.IsSet("grpc.max_message_size") {
+		opts = append(opts, grpc.MaxRecvMsgSize(p.Config.GetInt("grpc.max_message_size")))
+	}
+
+	s := grpc.NewServer(opts...)
+	Register(s, p)
+
+	p.Logger.Info("create grpc server", zap.String("address", p.Config.GetString("grpc.address")))
+
+	return Result{Server: mserv.NewGRPCServer(p.Config.GetString("grpc.address"), s)}, nil
+}
+
+// Register services of schemes, configs and changes
+func Register(s *grpc.Server, p Params) {
+	RegisterSchemesServer(s, &schemesServer{store: p.Schemes, logger: p.Logger})
+	RegisterConfigsServer(s, &configsServer{store: p.Configs, vars: p.Vars, logger: p.Logger})
+	RegisterChangesServer(s, &changesServer{changes: p.Changes, feed: p.Feed, logger: p.Logger})
+}
+
+// storeError converts known store errors to status of gRPC
+func storeError(l *zap.Logger, err error) error {
+	switch cause := errors.Cause(err); cause {
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, cause.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, cause.Error())
+	case pg.ErrNoRows:
+		return status.Error(codes.NotFound, "not found")
+	case store.ErrInvalidSlug:
+		return status.Error(codes.InvalidArgument, cause.Error())
+	case store.ErrSlugConflict:
+		return status.Error(codes.AlreadyExists, cause.Error())
+	case store.ErrReferenced:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case interpolate.ErrInvalidReference, interpolate.ErrCycle:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case secrets.ErrInvalidSecret, secrets.ErrUnknownKey:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case secrets.ErrNoKeys:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if store.IsUnavailable(err) {
+		return status.Error(codes.Unavailable, store.ErrUnavailable.Error())
+	}
+
+	if ferr, ok := errors.Cause(err).(*filter.Error); ok {
+		return status.Error(codes.InvalidArgument, ferr.Error())
+	}
+
+	l.Error("grpc request failed", zap.Error(err))
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+// staleHeader marks response that contains last-known-good version
+func staleHeader(ctx context.Context, stale bool) {
+	if stale {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(headerStale, "true"))
+	}
+}
+
+func protoTime(t time.Time) *timestamp.Timestamp {
+	ts, _ := ptypes.TimestampProto(t)
+	return ts
+}
+
+// validVersion checks tags and data of new version
+func validVersion(tags []string, data []byte) error {
+	if len(tags) == 0 {
+		return status.Error(codes.InvalidArgument, "tags could not be empty")
+	}
+
+	if len(data) == 0 || !json.Valid(data) {
+		return status.Error(codes.InvalidArgument, "data should be JSON document")
+	}
+
+	return nil
+}
+
+func validID(id int64) error {
+	if id <= 0 {
+		return status.Error(codes.InvalidArgument, "id could not be empty")
+	}
+
+	return nil
+}
+
+func searchRequest(req *SearchRequest) (store.SearchRequest, error) {
+	var (
+		err    error
+		result = store.SearchRequest{
+			Version:  req.Version,
+			Tags:     req.Tags,
+			Query:    req.Q,
+			SchemeID: req.SchemeId,
+			Author:   req.Author,
+			Limit:    int(req.Limit),
+			Offset:   int(req.Offset),
+		}
+	)
+
+	if result.Limit < 0 || result.Offset < 0 {
+		return result, status.Error(codes.InvalidArgument, "limit and offset could not be negative")
+	}
+
+	if req.Filter == "" {
+		return result, nil
+	}
+
+	if result.Filter, err = filter.Parse(req.Filter); err != nil {
+		return result, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return result, nil
+}
+
+func historyRequest(req *HistoryRequest) error {
+	if err := validID(req.Id); err != nil {
+		return err
+	}
+
+	if req.Limit < 0 || req.Offset < 0 {
+		return status.Error(codes.InvalidArgument, "limit and offset could not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,449 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/codec"
+	"github.com/pkg/errors"
+)
+
+// materializer fills defaults, strips and coerces values of document by scheme
+type materializer struct {
+	walker
+}
+
+var number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Materialize returns complete and normalized document of scheme:
+//
+//   - absent properties are filled by `default` of their schemas, defaults of
+//     nested objects and items of arrays are filled too, absent object is created
+//     when it gets any defaults
+//   - properties that are not described by `properties` or `patternProperties`
+//     are removed when `additionalProperties` is false
+//   - values are coerced to `type` of schema when it is possible: numbers and
+//     booleans to strings, numeric strings to numbers and integers, "true" and
+//     "false" to booleans, single values to arrays, strings of secrets are not
+//     coerced, they are masked or sealed values
+//
+// Schemas of `$ref` and `allOf` are merged, `anyOf` and `oneOf` are ambiguous
+// and not applied. Values that could not be coerced are kept as is.
+func Materialize(scheme, data json.RawMessage) (json.RawMessage, error) {
+	var m = materializer{walker: walker{root: scheme}}
+
+	doc, err := codec.Document(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc, _, err = m.value([]json.RawMessage{scheme}, doc, true, nil, 0); err != nil {
+		return nil, err
+	}
+
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, errors.Wrap(err, "could not encode document")
+	}
+
+	return data, nil
+}
+
+// value materializes doc by schemas, absent value is filled by default, returns false
+// when value is still absent. Creating are references followed by absent objects that
+// are created, so objects of recursive schemas are created once.
+func (m *materializer) value(raws []json.RawMessage, doc interface{}, present bool, creating map[string]bool, depth int) (interface{}, bool, error) {
+	if depth > maxDepth {
+		return nil, false, errors.Errorf("document is nested deeper than %d levels", maxDepth)
+	}
+
+	nodes, refs, err := m.collect(raws)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if present {
+		creating = nil
+	}
+
+	for i := 0; !present && i < len(nodes); i++ {
+		if len(nodes[i].Default) == 0 {
+			continue
+		}
+
+		if doc, err = codec.Document(nodes[i].Default); err != nil {
+			return nil, false, errors.Wrap(err, "could not decode default")
+		}
+
+		present = true
+	}
+
+	if !present {
+		return m.create(nodes, refs, creating, depth)
+	}
+
+	// string of secret is masked or sealed value (see secrets), it is kept as is
+	if _, ok := doc.(string); !ok || !secret(nodes) {
+		doc = coerce(doc, types(nodes))
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		return v, true, m.object(nodes, v, creating, depth)
+	case []interface{}:
+		return v, true, m.array(nodes, v, creating, depth)
+	}
+
+	return doc, true, nil
+}
+
+// create returns absent object when it gets defaults
+func (m *materializer) create(nodes []*node, refs []string, creating map[string]bool, depth int) (interface{}, bool, error) {
+	var (
+		doc  = make(map[string]interface{})
+		next = make(map[string]bool, len(creating)+len(refs))
+	)
+
+	if !objects(nodes) {
+		return nil, false, nil
+	}
+
+	for ref := range creating {
+		next[ref] = true
+	}
+
+	for _, ref := range refs {
+		if creating[ref] {
+			return nil, false, nil
+		}
+
+		next[ref] = true
+	}
+
+	if err := m.object(nodes, doc, next, depth); err != nil || len(doc) == 0 {
+		return nil, false, err
+	}
+
+	return doc, true, nil
+}
+
+func (m *materializer) object(nodes []*node, doc map[string]interface{}, creating map[string]bool, depth int) error {
+	var (
+		closed     bool
+		additional []json.RawMessage
+		patterns   = make(map[*regexp.Regexp]json.RawMessage)
+		properties = make(map[string][]json.RawMessage)
+	)
+
+	for _, n := range nodes {
+		for key, raw := range n.Properties {
+			properties[key] = append(properties[key], raw)
+		}
+
+		for pattern, raw := range n.PatternProperties {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return errors.Wrapf(err, "invalid pattern of properties %q", pattern)
+			}
+
+			patterns[re] = raw
+		}
+
+		if raw := bytes.TrimSpace(n.AdditionalProperties); string(raw) == "false" {
+			closed = true
+		} else if len(raw) > 0 {
+			additional = append(additional, raw)
+		}
+	}
+
+	var keys = make([]string, 0, len(properties))
+
+	for key := range properties {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := doc[key]
+
+		value, ok, err := m.value(properties[key], value, ok, creating, depth+1)
+		if err != nil {
+			return errors.WithMessage(err, key)
+		} else if ok {
+			doc[key] = value
+		}
+	}
+
+	for key, value := range doc {
+		if _, ok := properties[key]; ok {
+			continue
+		}
+
+		var schemas []json.RawMessage
+
+		for re, raw := range patterns {
+			if re.MatchString(key) {
+				schemas = append(schemas, raw)
+			}
+		}
+
+		if len(schemas) == 0 && closed {
+			delete(doc, key)
+			continue
+		} else if len(schemas) == 0 {
+			schemas = additional
+		}
+
+		if len(schemas) == 0 {
+			continue
+		}
+
+		value, _, err := m.value(schemas, value, true, nil, depth+1)
+		if err != nil {
+			return errors.WithMessage(err, key)
+		}
+
+		doc[key] = value
+	}
+
+	return nil
+}
+
+func (m *materializer) array(nodes []*node, doc []interface{}, creating map[string]bool, depth int) error {
+	var all, tuple = []json.RawMessage(nil), [][]json.RawMessage(nil)
+
+	for _, n := range nodes {
+		var items []json.RawMessage
+
+		if json.Unmarshal(n.Items, &items) != nil {
+			if len(n.Items) > 0 {
+				all = append(all, n.Items)
+			}
+
+			continue
+		}
+
+		for i, raw := range items {
+			if i >= len(tuple) {
+				tuple = append(tuple, nil)
+			}
+
+			tuple[i] = append(tuple[i], raw)
+		}
+	}
+
+	for i, item := range doc {
+		var schemas = all
+
+		if i < len(tuple) {
+			schemas = append(append([]json.RawMessage{}, tuple[i]...), all...)
+		}
+
+		if len(schemas) == 0 {
+			continue
+		}
+
+		value, _, err := m.value(schemas, item, true, creating, depth+1)
+		if err != nil {
+			return errors.WithMessage(err, strconv.Itoa(i))
+		}
+
+		doc[i] = value
+	}
+
+	return nil
+}
+
+// collect returns schemas with schemas of their references and allOf, schema comes
+// before its references, so its default wins. Returns followed references too.
+func (m *materializer) collect(raws []json.RawMessage) ([]*node, []string, error) {
+	var (
+		nodes []*node
+		refs  []string
+		queue []*node
+		seen  = make(map[string]bool)
+	)
+
+	for _, raw := range raws {
+		queue = appendNode(queue, raw)
+	}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		nodes = append(nodes, n)
+
+		if n.Ref != "" && !seen[n.Ref] {
+			ref, err := m.resolve(n.Ref)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			seen[n.Ref] = true
+			refs = append(refs, n.Ref)
+			queue = append(queue, ref)
+		}
+
+		for _, raw := range n.AllOf {
+			queue = appendNode(queue, raw)
+		}
+	}
+
+	return nodes, refs, nil
+}
+
+// appendNode appends schema, boolean and absent schemas are skipped
+func appendNode(nodes []*node, raw json.RawMessage) []*node {
+	var n node
+
+	if len(raw) == 0 || json.Unmarshal(raw, &n) != nil {
+		return nodes
+	}
+
+	return append(nodes, &n)
+}
+
+// types returns types of first schema that declares `type`
+func types(nodes []*node) []string {
+	for _, n := range nodes {
+		var (
+			name string
+			list []string
+		)
+
+		if len(n.Type) == 0 {
+			continue
+		} else if json.Unmarshal(n.Type, &name) == nil {
+			return []string{name}
+		} else if json.Unmarshal(n.Type, &list) == nil {
+			return list
+		}
+	}
+
+	return nil
+}
+
+// secret returns true when any schema marks value as secret
+func secret(nodes []*node) bool {
+	for _, n := range nodes {
+		if n.Secret || n.WriteOnly {
+			return true
+		}
+	}
+
+	return false
+}
+
+// coerce converts value to one of types, value of type is kept as is
+// and integers are written without fraction
+func coerce(value interface{}, types []string) interface{} {
+	for _, name := range types {
+		if is(value, name) {
+			if n, ok := value.(json.Number); ok && name == "integer" {
+				value, _ = integer(n)
+			}
+
+			return value
+		}
+	}
+
+	for _, name := range types {
+		if result, ok := convert(value, name); ok {
+			return result
+		}
+	}
+
+	return value
+}
+
+func is(value interface{}, name string) bool {
+	switch v := value.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case json.Number:
+		_, ok := integer(v)
+		return name == "number" || (name == "integer" && ok)
+	case map[string]interface{}:
+		return name == "object"
+	case []interface{}:
+		return name == "array"
+	}
+
+	return false
+}
+
+func convert(value interface{}, name string) (interface{}, bool) {
+	switch name {
+	case "string":
+		switch v := value.(type) {
+		case json.Number:
+			return v.String(), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+	case "number", "integer":
+		s, ok := value.(string)
+		if s = strings.TrimSpace(s); !ok || !number.MatchString(s) {
+			return nil, false
+		} else if name == "integer" {
+			return integer(json.Number(s))
+		}
+
+		return json.Number(s), true
+	case "boolean":
+		switch s, _ := value.(string); s {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	case "array":
+		if value != nil {
+			return []interface{}{value}, true
+		}
+	}
+
+	return nil, false
+}
+
+// integer returns number without fraction, false when number is not integer
+func integer(n json.Number) (json.Number, bool) {
+	if _, err := n.Int64(); err == nil {
+		return n, true
+	}
+
+	f, err := n.Float64()
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return n, false
+	}
+
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64)), true
+}
+
+// objects checks that schemas describe object, by type or by properties when type is not declared
+func objects(nodes []*node) bool {
+	var list = types(nodes)
+
+	for _, name := range list {
+		if name == "object" {
+			return true
+		}
+	}
+
+	for _, n := range nodes {
+		if len(list) == 0 && len(n.Properties) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,228 @@
+// Package schema reads annotations of JSON schemas of schemes.
+package schema
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Any matches every key of object or index of array in path
+const Any = "*"
+
+// maxDepth of nested schemas, guards against deep or recursive documents
+const maxDepth = 64
+
+type (
+	// Path of property in document, keys of objects and indexes of arrays
+	Path []string
+
+	// node of JSON schema, only keywords that describe nested documents,
+	// annotations and defaults are decoded
+	node struct {
+		Ref                  string                     `json:"$ref"`
+		Type                 json.RawMessage            `json:"type"`
+		Default              json.RawMessage            `json:"default"`
+		Secret               bool                       `json:"x-secret"`
+		WriteOnly            bool                       `json:"writeOnly"`
+		Properties           map[string]json.RawMessage `json:"properties"`
+		PatternProperties    map[string]json.RawMessage `json:"patternProperties"`
+		AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+		Items                json.RawMessage            `json:"items"`
+		AllOf                []json.RawMessage          `json:"allOf"`
+		AnyOf                []json.RawMessage          `json:"anyOf"`
+		OneOf                []json.RawMessage          `json:"oneOf"`
+	}
+
+	walker struct {
+		root    json.RawMessage
+		refs    map[string]bool // references on current path, recursive schemas are walked once
+		secrets []Path
+	}
+)
+
+// Secrets returns paths of properties marked as secret with `"x-secret": true`
+// or `"writeOnly": true`, items of arrays and additional properties are matched by Any.
+// Local references (`#`, `#/definitions/name`) are resolved.
+func Secrets(scheme json.RawMessage) ([]Path, error) {
+	var w = walker{root: scheme, refs: make(map[string]bool)}
+
+	if err := w.walkRaw(scheme, nil); err != nil {
+		return nil, err
+	}
+
+	return w.secrets, nil
+}
+
+// String returns JSON pointer of path
+func (p Path) String() string {
+	var buf strings.Builder
+
+	for _, key := range p {
+		buf.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key))
+	}
+
+	return buf.String()
+}
+
+// Wildcard checks that path has Any key
+func (p Path) Wildcard() bool {
+	for _, key := range p {
+		if key == Any {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match checks that path is property of p or nested in it
+func (p Path) Match(path []string) bool {
+	if len(path) < len(p) {
+		return false
+	}
+
+	for i, key := range p {
+		if key != Any && key != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w *walker) walk(n *node, path Path) error {
+	if n == nil {
+		return nil
+	}
+
+	if len(path) > maxDepth {
+		return errors.Errorf("scheme is nested deeper than %d levels", maxDepth)
+	}
+
+	if n.Secret || n.WriteOnly {
+		w.secrets = append(w.secrets, path)
+		return nil
+	}
+
+	if n.Ref != "" {
+		if w.refs[n.Ref] {
+			return nil
+		}
+
+		ref, err := w.resolve(n.Ref)
+		if err != nil {
+			return err
+		}
+
+		w.refs[n.Ref] = true
+		defer delete(w.refs, n.Ref)
+
+		if err = w.walk(ref, path); err != nil {
+			return err
+		}
+	}
+
+	var keys = make([]string, 0, len(n.Properties))
+
+	for key := range n.Properties {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := w.walkRaw(n.Properties[key], with(path, key)); err != nil {
+			return err
+		}
+	}
+
+	if err := w.walkRaw(n.AdditionalProperties, with(path, Any)); err != nil {
+		return err
+	}
+
+	// tuple validation, schema for each index:
+	var items []json.RawMessage
+	if json.Unmarshal(n.Items, &items) == nil {
+		for i, item := range items {
+			if err := w.walkRaw(item, with(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	} else if err := w.walkRaw(n.Items, with(path, Any)); err != nil {
+		return err
+	}
+
+	for _, list := range [][]json.RawMessage{n.AllOf, n.AnyOf, n.OneOf} {
+		for _, item := range list {
+			if err := w.walkRaw(item, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkRaw walks schema that could be boolean or absent
+func (w *walker) walkRaw(raw json.RawMessage, path Path) error {
+	var n node
+
+	if len(raw) == 0 || json.Unmarshal(raw, &n) != nil {
+		return nil
+	}
+
+	return w.walk(&n, path)
+}
+
+// resolve local reference, JSON pointer of root document
+func (w *walker) resolve(ref string) (*node, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.Errorf("only local references are supported, got %q", ref)
+	}
+
+	var doc interface{}
+
+	if err := json.Unmarshal(w.root, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not decode scheme")
+	}
+
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			doc = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, errors.Errorf("reference %q not found", ref)
+			}
+
+			doc = v[i]
+		default:
+			return nil, errors.Errorf("reference %q not found", ref)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var n node
+
+	if err = json.Unmarshal(data, &n); err != nil {
+		return nil, errors.Errorf("reference %q is not schema", ref)
+	}
+
+	return &n, nil
+}
+
+// with returns copy of path with key
+func with(path Path, key string) Path {
+	return append(append(make(Path, 0, len(path)+1), path...), key)
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schema Suite", func() {
+	It("should find secret properties", func() {
+		paths, err := Secrets(json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"name": {"type": "string"},
+				"db": {"$ref": "#/definitions/db"},
+				"friends": {"type": "array", "items": {"$ref": "#"}},
+				"pair": {"type": "array", "items": [{"type": "string"}, {"x-secret": true}]},
+				"keys": {"type": "object", "additionalProperties": {"writeOnly": true}},
+				"any": {"anyOf": [{"properties": {"token": {"x-secret": true}}}, true]}
+			},
+			"definitions": {
+				"db": {"properties": {"password": {"type": "string", "writeOnly": true}}}
+			}
+		}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([]Path{
+			{"any", "token"},
+			{"db", "password"},
+			{"friends", "*", "any", "token"},
+			{"friends", "*", "db", "password"},
+			{"friends", "*", "keys", "*"},
+			{"friends", "*", "pair", "1"},
+			{"keys", "*"},
+			{"pair", "1"},
+		}))
+	})
+
+	It("should match nested paths", func() {
+		path := Path{"users", Any, "token"}
+
+		Expect(path.Match([]string{"users", "0", "token"})).To(BeTrue())
+		Expect(path.Match([]string{"users", "0", "token", "value"})).To(BeTrue())
+		Expect(path.Match([]string{"users", "0", "name"})).To(BeFalse())
+		Expect(path.Match([]string{"users"})).To(BeFalse())
+		Expect(Path{"a/b", "c~d"}.String()).To(Equal("/a~1b/c~0d"))
+	})
+
+	It("should fail on unknown references", func() {
+		_, err := Secrets(json.RawMessage(`{"properties": {"a": {"$ref": "#/definitions/none"}}}`))
+		Expect(err).To(HaveOccurred())
+
+		_, err = Secrets(json.RawMessage(`{"$ref": "http://example.com/schema"}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fill defaults of nested objects and arrays", func() {
+		data, err := Materialize(json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"name": {"type": "string", "default": "app"},
+				"db": {
+					"type": "object",
+					"properties": {
+						"host": {"type": "string", "default": "localhost"},
+						"port": {"type": "integer", "default": 5432}
+					}
+				},
+				"servers": {"type": "array", "items": {"$ref": "#/definitions/server"}},
+				"limits": {"type": "object", "default": {"cpu": 1}, "properties": {"memory": {"default": "1Gi"}}},
+				"tree": {"$ref": "#"}
+			},
+			"definitions": {
+				"server": {"allOf": [{"properties": {"weight": {"default": 1}}}, {"properties": {"backup": {"default": false}}}]}
+			}
+		}`), json.RawMessage(`{"db": {"port": 6432}, "servers": [{"host": "a"}, {"host": "b", "weight": 5}]}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"name": "app",
+			"db": {"host": "localhost", "port": 6432},
+			"servers": [{"host": "a", "weight": 1, "backup": false}, {"host": "b", "weight": 5, "backup": false}],
+			"limits": {"cpu": 1, "memory": "1Gi"},
+			"tree": {"name": "app", "db": {"host": "localhost", "port": 5432}, "limits": {"cpu": 1, "memory": "1Gi"}}
+		}`))
+	})
+
+	It("should strip additional properties and coerce types", func() {
+		data, err := Materialize(json.RawMessage(`{
+			"type": "object",
+			"additionalProperties": false,
+			"patternProperties": {"^x-": {"type": "boolean"}},
+			"properties": {
+				"port": {"type": "integer"},
+				"ratio": {"type": "number"},
+				"debug": {"type": "boolean"},
+				"version": {"type": "string"},
+				"hosts": {"type": "array", "items": {"type": "string"}},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"timeout": {"type": ["integer", "null"]},
+				"name": {"type": "string"}
+			}
+		}`), json.RawMessage(`{
+			"port": "8080", "ratio": "0.5", "debug": "true", "version": 2, "hosts": 1,
+			"labels": {"tier": 1, "public": true}, "timeout": 3.0, "name": 42.5,
+			"x-beta": "false", "unknown": 1
+		}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"port": 8080, "ratio": 0.5, "debug": true, "version": "2", "hosts": ["1"],
+			"labels": {"tier": "1", "public": "true"}, "timeout": 3, "name": "42.5",
+			"x-beta": false
+		}`))
+
+		data, err = Materialize(json.RawMessage(`{"properties": {"port": {"type": "integer"}}}`), json.RawMessage(`{"port": "80a"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"port": "80a"}`))
+	})
+
+	It("should keep masked and sealed values of secrets as is", func() {
+		data, err := Materialize(json.RawMessage(`{
+			"properties": {
+				"tokens": {"type": "array", "items": {"type": "string"}, "x-secret": true},
+				"port": {"type": "integer", "writeOnly": true}
+			}
+		}`), json.RawMessage(`{"tokens": "********", "port": "enc:v1:c2VhbGVk"}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"tokens": "********", "port": "enc:v1:c2VhbGVk"}`))
+	})
+})
@@ -0,0 +1,317 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/im-kulikov/simplinic-task/codec"
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/pkg/errors"
+)
+
+// visitor of sealed values and values of secret fields, returns new value
+type visitor struct {
+	paths []schema.Path
+	// secret is called for value of secret field
+	secret func(path []string, value interface{}) (interface{}, error)
+	// sealed is called for sealed values of other fields
+	sealed func(path []string, value string) (interface{}, error)
+}
+
+// SealData encrypts values of secret fields (see schema.Secrets), masked values
+// (see Mask) keep values of the same fields of previous version, sealed values
+// are kept as is. Nil keyring returns ErrNoKeys when data has secret fields,
+// so secrets are never stored as is.
+func (k *Keyring) SealData(data json.RawMessage, paths []schema.Path, previous json.RawMessage) (json.RawMessage, error) {
+	var prev interface{}
+
+	if len(paths) == 0 {
+		return data, nil
+	} else if k == nil {
+		_, err := transform(data, visitor{
+			paths: paths,
+			secret: func(path []string, _ interface{}) (interface{}, error) {
+				return nil, errors.Wrapf(ErrNoKeys, "field %s", schema.Path(path))
+			},
+		})
+
+		return data, err
+	}
+
+	if len(previous) > 0 {
+		var err error
+
+		if prev, err = codec.Document(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	return transform(data, visitor{
+		paths: paths,
+		secret: func(path []string, value interface{}) (interface{}, error) {
+			if value == Mask {
+				var ok bool
+
+				if value, ok = lookup(prev, path); !ok || value == Mask {
+					return nil, errors.Wrapf(ErrInvalidSecret, "masked field %s has no previous value", schema.Path(path))
+				}
+			}
+
+			if s, ok := value.(string); ok && IsSealed(s) {
+				_, err := k.Open(s)
+				return s, err
+			}
+
+			return k.sealValue(value)
+		},
+	})
+}
+
+// Reseal seals values of secret fields that are stored as is and rewraps values
+// sealed by not primary keys, reports whether data is changed
+func (k *Keyring) Reseal(data json.RawMessage, paths []schema.Path) (json.RawMessage, bool, error) {
+	var changed bool
+
+	rewrap := func(_ []string, value string) (interface{}, error) {
+		result, ok, err := k.Rewrap(value)
+		changed = changed || ok
+
+		return result, err
+	}
+
+	result, err := transform(data, visitor{
+		paths:  paths,
+		sealed: rewrap,
+		secret: func(path []string, value interface{}) (interface{}, error) {
+			if s, ok := value.(string); ok && IsSealed(s) {
+				return rewrap(path, s)
+			}
+
+			changed = true
+
+			return k.sealValue(value)
+		},
+	})
+
+	if err != nil || !changed {
+		return data, false, err
+	}
+
+	return result, true, nil
+}
+
+// OpenData decrypts sealed values of data
+func (k *Keyring) OpenData(data json.RawMessage) (json.RawMessage, error) {
+	return transform(data, visitor{
+		sealed: func(path []string, value string) (interface{}, error) {
+			plain, err := k.Open(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %s", schema.Path(path))
+			}
+
+			return codec.Document(plain)
+		},
+	})
+}
+
+// MaskData replaces values of secret fields (see schema.Secrets) and sealed
+// values of other fields by Mask, so secrets stored as is are masked too
+func MaskData(data json.RawMessage, paths []schema.Path) json.RawMessage {
+	if len(paths) == 0 && !bytes.Contains(data, []byte(prefix)) {
+		return data
+	}
+
+	result, err := transform(data, visitor{
+		paths:  paths,
+		secret: func([]string, interface{}) (interface{}, error) { return Mask, nil },
+		sealed: func([]string, string) (interface{}, error) { return Mask, nil },
+	})
+
+	if err != nil {
+		return data
+	}
+
+	return result
+}
+
+// MaskAs masks values of data that are masked in current data, so data
+// could be compared with masked version
+func MaskAs(data, current json.RawMessage) json.RawMessage {
+	if !bytes.Contains(current, []byte(Mask)) {
+		return data
+	}
+
+	doc, err := codec.Document(current)
+	if err != nil {
+		return data
+	}
+
+	var paths []schema.Path
+
+	walk(doc, nil, func(path []string, value interface{}) {
+		if value == Mask {
+			paths = append(paths, append(schema.Path{}, path...))
+		}
+	})
+
+	result, err := transform(data, visitor{
+		paths:  paths,
+		secret: func([]string, interface{}) (interface{}, error) { return Mask, nil },
+	})
+
+	if err != nil {
+		return data
+	}
+
+	return result
+}
+
+// Unmask replaces masked values of data by values of the same fields of
+// previous data, so data could be compared with opened version
+func Unmask(data, previous json.RawMessage) (json.RawMessage, error) {
+	if !bytes.Contains(data, []byte(Mask)) {
+		return data, nil
+	}
+
+	doc, err := codec.Document(data)
+	if err != nil {
+		return nil, err
+	}
+
+	prev, err := codec.Document(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []schema.Path
+
+	walk(doc, nil, func(path []string, value interface{}) {
+		if value == Mask {
+			paths = append(paths, append(schema.Path{}, path...))
+		}
+	})
+
+	return transform(data, visitor{
+		paths: paths,
+		secret: func(path []string, value interface{}) (interface{}, error) {
+			if found, ok := lookup(prev, path); ok {
+				return found, nil
+			}
+
+			return value, nil
+		},
+	})
+}
+
+func (k *Keyring) sealValue(value interface{}) (interface{}, error) {
+	plain, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode secret")
+	}
+
+	return k.Seal(plain)
+}
+
+// transform visits values of data and returns changed data
+func transform(data json.RawMessage, v visitor) (json.RawMessage, error) {
+	doc, err := codec.Document(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc, err = v.visit(doc, nil); err != nil {
+		return nil, err
+	}
+
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, errors.Wrap(err, "could not encode data")
+	}
+
+	return data, nil
+}
+
+func (v visitor) visit(doc interface{}, path []string) (interface{}, error) {
+	var err error
+
+	if v.secret != nil && v.match(path) {
+		return v.secret(path, doc)
+	}
+
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if value[key], err = v.visit(item, with(path, key)); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			if value[i], err = v.visit(item, with(path, strconv.Itoa(i))); err != nil {
+				return nil, err
+			}
+		}
+	case string:
+		if v.sealed != nil && IsSealed(value) {
+			return v.sealed(path, value)
+		}
+	}
+
+	return doc, nil
+}
+
+// match checks that path is secret field, nested fields of secret are not matched
+func (v visitor) match(path []string) bool {
+	for _, item := range v.paths {
+		if len(item) == len(path) && item.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup returns value of document by path
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch value := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+
+			if doc, ok = value[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, false
+			}
+
+			doc = value[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+// walk calls fn for every value of document
+func walk(doc interface{}, path []string, fn func(path []string, value interface{})) {
+	fn(path, doc)
+
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			walk(item, with(path, key), fn)
+		}
+	case []interface{}:
+		for i, item := range value {
+			walk(item, with(path, strconv.Itoa(i)), fn)
+		}
+	}
+}
+
+func with(path []string, key string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), key)
+}
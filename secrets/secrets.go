@@ -0,0 +1,314 @@
+// Package secrets encrypts secret fields of config data at rest.
+//
+// Values are encrypted with envelope encryption: every value is encrypted with
+// its own random data key (AES-256-GCM) and data key is encrypted (wrapped) with
+// key of keyring. Sealed value is string:
+//
+//	enc:v1:<key id>:<wrapped data key>:<encrypted JSON of value>
+//
+// Keys are rotated by adding new primary key to keyring, values sealed by old
+// keys are still opened and could be rewrapped without decrypting values again,
+// see Keyring.Reseal.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// Mask replaces secret values in reads, masked value written to secret
+	// field keeps its previous value
+	Mask = "********"
+
+	prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var (
+	// ErrUnknownKey when value is sealed by key that is not in keyring
+	ErrUnknownKey = errors.New("key of secret not found")
+
+	// ErrInvalidSecret when sealed value could not be opened
+	ErrInvalidSecret = errors.New("secret could not be decrypted")
+
+	// ErrInvalidKeys when keys of settings could not be read
+	ErrInvalidKeys = errors.New("invalid keys of secrets")
+
+	// ErrNoKeys when secret field is written, but keys are not set
+	ErrNoKeys = errors.New("secrets could not be stored without keys")
+
+	keyID    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	encoding = base64.RawStdEncoding
+)
+
+// Keyring holds keys that wrap data keys of secrets, nil keyring
+// means that encryption is disabled and secrets are stored as is
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// Module of keyring
+var Module = module.Module{
+	{Constructor: NewKeyring},
+}
+
+// NewKeyring reads keys of settings, keys are base64 of 32 bytes:
+//
+//	secrets:
+//	  primary: k2      # key that seals new values, required for several keys
+//	  keys:            # id => key
+//	    k1: ...
+//	  key_file: path   # file with line `<id>=<key>` per key, added to keys
+//
+// Encryption is disabled (nil keyring) when keys are not set.
+func NewKeyring(v *viper.Viper) (*Keyring, error) {
+	var keys = make(map[string][]byte)
+
+	for id, value := range v.GetStringMapString("secrets.keys") {
+		key, err := encoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidKeys, "key %q is not base64", id)
+		}
+
+		keys[id] = key
+	}
+
+	if path := v.GetString("secrets.key_file"); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read key file")
+		}
+
+		if err = parseKeys(string(data), keys); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	return New(v.GetString("secrets.primary"), keys)
+}
+
+// New returns keyring of keys, primary key could be empty for single key
+func New(primary string, keys map[string][]byte) (*Keyring, error) {
+	var k = &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+
+	for id, key := range keys {
+		if !keyID.MatchString(id) {
+			return nil, errors.Wrapf(ErrInvalidKeys, "id of key %q should contain only letters, digits, - and _", id)
+		} else if len(key) != keySize {
+			return nil, errors.Wrapf(ErrInvalidKeys, "key %q should be %d bytes", id, keySize)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys[id] = aead
+
+		if len(keys) == 1 && primary == "" {
+			k.primary = id
+		}
+	}
+
+	if _, ok := k.keys[k.primary]; !ok {
+		return nil, errors.Wrapf(ErrInvalidKeys, "primary key %q not found", k.primary)
+	}
+
+	return k, nil
+}
+
+// Primary returns id of key that seals new values
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Keys returns ids of keys except primary, values sealed by them should be rewrapped
+func (k *Keyring) Keys() []string {
+	var result = make([]string, 0, len(k.keys))
+
+	for id := range k.keys {
+		if id != k.primary {
+			result = append(result, id)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// IsSealed checks that string is sealed value
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Seal encrypts value by new data key wrapped by primary key
+func (k *Keyring) Seal(value []byte) (string, error) {
+	var dek = make([]byte, keySize)
+
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", errors.Wrap(err, "could not generate data key")
+	}
+
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := seal(aead, value, []byte(prefix))
+	if err != nil {
+		return "", err
+	}
+
+	return k.wrap(k.primary, dek, data)
+}
+
+// Open decrypts sealed value
+func (k *Keyring) Open(value string) ([]byte, error) {
+	id, dek, data, err := k.unwrap(value)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := open(aead, data, []byte(prefix))
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSecret, "value sealed by key %q", id)
+	}
+
+	return result, nil
+}
+
+// Rewrap wraps data key of sealed value by primary key, value itself is not
+// decrypted, returns false when value is already sealed by primary key
+func (k *Keyring) Rewrap(value string) (string, bool, error) {
+	id, dek, data, err := k.unwrap(value)
+	if err != nil || id == k.primary {
+		return value, false, err
+	}
+
+	value, err = k.wrap(k.primary, dek, data)
+
+	return value, err == nil, err
+}
+
+func (k *Keyring) wrap(id string, dek, data []byte) (string, error) {
+	wrapped, err := seal(k.keys[id], dek, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + id + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(data), nil
+}
+
+// unwrap returns id of key, data key and encrypted value
+func (k *Keyring) unwrap(value string) (string, []byte, []byte, error) {
+	var parts = strings.Split(strings.TrimPrefix(value, prefix), ":")
+
+	if !IsSealed(value) || len(parts) != 3 {
+		return "", nil, nil, errors.Wrap(ErrInvalidSecret, "value is not sealed")
+	}
+
+	if k == nil {
+		return "", nil, nil, errors.Wrapf(ErrUnknownKey, "%q, encryption is disabled", parts[0])
+	}
+
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return "", nil, nil, errors.Wrapf(ErrUnknownKey, "%q", parts[0])
+	}
+
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, errors.Wrap(ErrInvalidSecret, "data key is not base64")
+	}
+
+	data, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, errors.Wrap(ErrInvalidSecret, "value is not base64")
+	}
+
+	dek, err := open(kek, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", nil, nil, errors.Wrapf(ErrInvalidSecret, "data key could not be unwrapped by key %q", parts[0])
+	}
+
+	return parts[0], dek, data, nil
+}
+
+// parseKeys reads lines `<id>=<base64 key>`, empty lines and lines that start with # are skipped
+func parseKeys(data string, keys map[string][]byte) error {
+	for n, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i <= 0 {
+			return errors.Wrapf(ErrInvalidKeys, "line %d of key file should be <id>=<key>", n+1)
+		}
+
+		key, err := encoding.DecodeString(strings.TrimRight(strings.TrimSpace(line[i+1:]), "="))
+		if err != nil {
+			return errors.Wrapf(ErrInvalidKeys, "line %d of key file: key is not base64", n+1)
+		}
+
+		keys[strings.TrimSpace(line[:i])] = key
+	}
+
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create cipher")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create cipher")
+	}
+
+	return aead, nil
+}
+
+// seal returns nonce followed by encrypted data
+func seal(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	var nonce = make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "could not generate nonce")
+	}
+
+	return aead.Seal(nonce, nonce, data, ad), nil
+}
+
+func open(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrInvalidSecret
+	}
+
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], ad)
+}
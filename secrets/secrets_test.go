@@ -0,0 +1,189 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/im-kulikov/simplinic-task/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Secrets Suite", func() {
+	var (
+		k1 = bytes.Repeat([]byte{1}, keySize)
+		k2 = bytes.Repeat([]byte{2}, keySize)
+
+		paths = []schema.Path{{"db", "password"}, {"tokens", schema.Any}}
+	)
+
+	It("should read keys of settings and key file", func() {
+		file, err := ioutil.TempFile("", "keys")
+		Expect(err).NotTo(HaveOccurred())
+
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString("# rotated keys\nk2=" + encoding.EncodeToString(k2) + "\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		v := viper.New()
+		v.Set("secrets.keys", map[string]string{"k1": encoding.EncodeToString(k1)})
+		v.Set("secrets.key_file", file.Name())
+		v.Set("secrets.primary", "k2")
+
+		k, err := NewKeyring(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k.Primary()).To(Equal("k2"))
+		Expect(k.Keys()).To(Equal([]string{"k1"}))
+
+		k, err = NewKeyring(viper.New())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k).To(BeNil())
+
+		v.Set("secrets.primary", "")
+		_, err = NewKeyring(v)
+		Expect(errors.Cause(err)).To(Equal(ErrInvalidKeys))
+
+		_, err = New("", map[string][]byte{"k1": k1[:16]})
+		Expect(errors.Cause(err)).To(Equal(ErrInvalidKeys))
+	})
+
+	It("should seal, open and rewrap values", func() {
+		old, err := New("", map[string][]byte{"k1": k1})
+		Expect(err).NotTo(HaveOccurred())
+
+		sealed, err := old.Seal([]byte(`"secret"`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sealed).To(HavePrefix("enc:v1:k1:"))
+		Expect(sealed).NotTo(ContainSubstring("secret"))
+
+		other, err := old.Seal([]byte(`"secret"`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other).NotTo(Equal(sealed))
+
+		k, err := New("k2", map[string][]byte{"k1": k1, "k2": k2})
+		Expect(err).NotTo(HaveOccurred())
+
+		rewrapped, ok, err := k.Rewrap(sealed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(rewrapped).To(HavePrefix("enc:v1:k2:"))
+		Expect(strings.Split(rewrapped, ":")[4]).To(Equal(strings.Split(sealed, ":")[4]))
+
+		_, ok, err = k.Rewrap(rewrapped)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		plain, err := k.Open(rewrapped)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal(`"secret"`))
+
+		_, err = old.Open(rewrapped)
+		Expect(errors.Cause(err)).To(Equal(ErrUnknownKey))
+
+		parts := strings.Split(rewrapped, ":")
+		parts[4] = parts[4][:len(parts[4])-2] + "AA"
+		_, err = k.Open(strings.Join(parts, ":"))
+		Expect(errors.Cause(err)).To(Equal(ErrInvalidSecret))
+	})
+
+	It("should seal, mask and open secret fields of data", func() {
+		k, err := New("", map[string][]byte{"k1": k1})
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := k.SealData(json.RawMessage(`{
+			"db": {"host": "localhost", "password": "qwerty"},
+			"tokens": {"a": 1, "b": {"nested": true}}
+		}`), paths, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("qwerty"))
+		Expect(string(data)).NotTo(ContainSubstring("nested"))
+
+		Expect(MaskData(data, nil)).To(MatchJSON(`{
+			"db": {"host": "localhost", "password": "********"},
+			"tokens": {"a": "********", "b": "********"}
+		}`))
+
+		// secrets stored as is are masked by paths:
+		Expect(MaskData(json.RawMessage(`{"db": {"host": "localhost", "password": "qwerty"}, "tokens": [1]}`), paths)).
+			To(MatchJSON(`{"db": {"host": "localhost", "password": "********"}, "tokens": ["********"]}`))
+
+		plain, err := k.OpenData(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plain).To(MatchJSON(`{
+			"db": {"host": "localhost", "password": "qwerty"},
+			"tokens": {"a": 1, "b": {"nested": true}}
+		}`))
+
+		next, err := k.SealData(json.RawMessage(`{
+			"db": {"host": "db.local", "password": "********"},
+			"tokens": {"a": 2}
+		}`), paths, data)
+		Expect(err).NotTo(HaveOccurred())
+
+		plain, err = k.OpenData(next)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plain).To(MatchJSON(`{"db": {"host": "db.local", "password": "qwerty"}, "tokens": {"a": 2}}`))
+
+		_, err = k.SealData(json.RawMessage(`{"tokens": {"c": "********"}}`), paths, data)
+		Expect(errors.Cause(err)).To(Equal(ErrInvalidSecret))
+
+		var none *Keyring
+		_, err = none.SealData(json.RawMessage(`{"db": {"password": "qwerty"}}`), paths, nil)
+		Expect(errors.Cause(err)).To(Equal(ErrNoKeys))
+
+		same, err := none.SealData(json.RawMessage(`{"db": {"host": "localhost"}}`), paths, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(same).To(MatchJSON(`{"db": {"host": "localhost"}}`))
+	})
+
+	It("should reseal plain and old values", func() {
+		old, err := New("", map[string][]byte{"k1": k1})
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := old.SealData(json.RawMessage(`{"db": {"password": "qwerty"}}`), paths, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		k, err := New("k2", map[string][]byte{"k1": k1, "k2": k2})
+		Expect(err).NotTo(HaveOccurred())
+
+		data, ok, err := k.Reseal(data, paths)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(string(data)).To(ContainSubstring("enc:v1:k2:"))
+
+		_, ok, err = k.Reseal(data, paths)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		data, ok, err = k.Reseal(json.RawMessage(`{"db": {"password": "plain"}, "name": "x"}`), paths)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		plain, err := k.OpenData(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plain).To(MatchJSON(`{"db": {"password": "plain"}, "name": "x"}`))
+	})
+
+	It("should mask data like masked version", func() {
+		Expect(MaskAs(
+			json.RawMessage(`{"db": {"host": "x", "password": "qwerty"}, "list": [1, 2]}`),
+			json.RawMessage(`{"db": {"host": "y", "password": "********"}, "list": ["********"]}`),
+		)).To(MatchJSON(`{"db": {"host": "x", "password": "********"}, "list": ["********", 2]}`))
+	})
+
+	It("should unmask data by previous version", func() {
+		data, err := Unmask(
+			json.RawMessage(`{"db": {"host": "x", "password": "********"}, "token": "********"}`),
+			json.RawMessage(`{"db": {"host": "y", "password": "qwerty"}}`),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"db": {"host": "x", "password": "qwerty"}, "token": "********"}`))
+	})
+})
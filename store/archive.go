@@ -0,0 +1,747 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/pkg/errors"
+)
+
+// ArchiveFormat is version of logical format of archive, it is changed only
+// when records could not be read by previous version. New record types and
+// fields could be added without changing format, importer skips unknown ones.
+const ArchiveFormat = 1
+
+// Types of archive records
+const (
+	RecordHeader        = "header"         // first record, format and time of export
+	RecordScheme        = "scheme"         // scheme with slug, old slugs and deletion state
+	RecordSchemeVersion = "scheme_version" // version of scheme, after scheme
+	RecordConfig        = "config"         // config, after all schemes
+	RecordConfigVersion = "config_version" // version of config, after config
+	RecordEnd           = "end"            // last record, count of records before it
+)
+
+// Conflict strategies of import, applied when slug of imported entity
+// is already used by existing entity
+const (
+	ConflictFail  = "fail"  // import is rolled back
+	ConflictSkip  = "skip"  // existing entity is kept as is
+	ConflictMerge = "merge" // imported versions are appended to history of existing entity
+)
+
+// archiveBatch is count of entities read by one query of export
+const archiveBatch = 100
+
+var (
+	// ErrInvalidArchive when archive could not be decoded, truncated or records are out of order
+	ErrInvalidArchive = errors.New("invalid archive")
+
+	// ErrImportConflict when imported entity conflicts with existing one, see ConflictFail
+	ErrImportConflict = errors.New("imported entity conflicts with existing one")
+)
+
+type (
+	// Record of archive, NDJSON line of export. Fields are filled by type of record:
+	//
+	//	{"type":"header","format":1,"created_at":"..."}
+	//	{"type":"scheme","id":1,"slug":"person","aliases":["human"],"created_at":"...","deleted_at":null}
+	//	{"type":"scheme_version","id":1,"version":1,"tags":["a"],"data":{},"author":"john","created_at":"..."}
+	//	{"type":"config","id":3,"scheme_id":1,"slug":"john","created_at":"..."}
+	//	{"type":"config_version","id":3,"scheme_id":1,"version":1,"tags":["a"],"data":{},"created_at":"..."}
+	//	{"type":"end","count":5}
+	//
+	// ID of version record is id of scheme or config. Archive describes entities,
+	// not tables, so it could be imported after migrations of database.
+	Record struct {
+		Type      string          `sql:"-" json:"type"`
+		Format    int             `sql:"-" json:"format,omitempty"`
+		Count     int64           `sql:"-" json:"count,omitempty"`
+		ID        int64           `json:"id,omitempty"`
+		SchemeID  int64           `json:"scheme_id,omitempty"`
+		Slug      string          `json:"slug,omitempty"`
+		Aliases   []string        `sql:"-" json:"aliases,omitempty"` // old slugs, still resolved to entity
+		Version   int64           `json:"version,omitempty"`
+		Tags      []string        `json:"tags,omitempty"`
+		Data      json.RawMessage `json:"data,omitempty"`
+		Author    string          `json:"author,omitempty"`
+		CreatedAt time.Time       `json:"created_at"`
+		DeletedAt *time.Time      `json:"deleted_at,omitempty"`
+	}
+
+	ImportOptions struct {
+		// Conflict strategy, ConflictFail by default
+		Conflict string
+		// KeepIDs imports entities with ids of archive instead of new ids,
+		// import fails when id is already used (e.g. restore to empty database)
+		KeepIDs bool
+	}
+
+	ImportCounts struct {
+		Created int `json:"created"`
+		Merged  int `json:"merged"`
+		Skipped int `json:"skipped"`
+	}
+
+	// ImportResult describes imported entities, ids maps ids of archive to ids of instance
+	ImportResult struct {
+		Schemes   ImportCounts    `json:"schemes"`
+		Configs   ImportCounts    `json:"configs"`
+		Versions  int             `json:"versions"`
+		SchemeIDs map[int64]int64 `json:"scheme_ids"`
+		ConfigIDs map[int64]int64 `json:"config_ids"`
+	}
+
+	// Archive exports and imports all schemes and configs with history
+	Archive interface {
+		// Export calls fn with records of consistent snapshot of database
+		Export(ctx context.Context, fn func(*Record) error) error
+		// Import reads records until io.EOF and imports them in one transaction
+		Import(ctx context.Context, next func() (*Record, error), opts ImportOptions) (*ImportResult, error)
+	}
+
+	archive struct {
+		db   *pg.DB
+		vars interpolate.Variables
+	}
+
+	// archiveTables describes tables of schemes or configs
+	archiveTables struct {
+		entity   string // type of entity record, `_version` suffix for versions
+		table    string // entities
+		versions string // versions of entities
+		slugs    string // current and old slugs of entities
+		key      string // column of versions and slugs that refers to entity
+		columns  string // columns of entity
+		version  string // columns of version
+	}
+
+	// importer keeps state of import, entities by ids of archive
+	importer struct {
+		tx      *pg.Tx
+		vars    interpolate.Variables
+		opts    ImportOptions
+		result  *ImportResult
+		count   int64
+		header  bool
+		schemes map[int64]*importedEntity
+		configs map[int64]*importedEntity
+	}
+
+	importedEntity struct {
+		id       int64 // id in instance
+		schemeID int64 // id of scheme in instance, only for configs
+		offset   int64 // latest version of merged entity, added to versions of archive
+		skip     bool  // versions of skipped entity are not imported
+		merged   bool  // versions are appended to existing entity
+	}
+)
+
+var (
+	schemeTables = archiveTables{
+		entity:   RecordScheme,
+		table:    "schemes",
+		versions: "scheme_versions",
+		slugs:    "scheme_slugs",
+		key:      "scheme_id",
+		columns:  "id, slug, created_at, deleted_at",
+		version:  "scheme_id AS id, version, tags, data, author, created_at",
+	}
+
+	configTables = archiveTables{
+		entity:   RecordConfig,
+		table:    "configs",
+		versions: "config_versions",
+		slugs:    "config_slugs",
+		key:      "config_id",
+		columns:  "id, scheme_id, slug, created_at, deleted_at",
+		version:  "config_id AS id, scheme_id, version, tags, data, author, created_at",
+	}
+)
+
+// NewArchive without variables, so imported configs could not refer to them
+func NewArchive(db *pg.DB) Archive {
+	return &archive{db: db}
+}
+
+// DecodeRecords returns reader of NDJSON records for Import
+func DecodeRecords(r io.Reader) func() (*Record, error) {
+	dec := json.NewDecoder(r)
+
+	return func() (*Record, error) {
+		var rec Record
+
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil, err
+		} else if err != nil {
+			return nil, errors.Wrapf(ErrInvalidArchive, "could not decode record: %s", err)
+		}
+
+		return &rec, nil
+	}
+}
+
+// Export reads deleted entities too, every version and old slugs in one
+// read-only transaction, so archive is consistent while writes go on
+func (a *archive) Export(ctx context.Context, fn func(*Record) error) error {
+	return runInTransactionMode(ctx, a.db, "ISOLATION LEVEL REPEATABLE READ, READ ONLY", func(tx *pg.Tx) error {
+		var count int64
+
+		write := func(rec *Record) error {
+			count++
+			return fn(rec)
+		}
+
+		if err := write(&Record{Type: RecordHeader, Format: ArchiveFormat, CreatedAt: time.Now().UTC()}); err != nil {
+			return err
+		}
+
+		for _, t := range []archiveTables{schemeTables, configTables} {
+			if err := t.export(tx, write); err != nil {
+				return err
+			}
+		}
+
+		return fn(&Record{Type: RecordEnd, Count: count})
+	})
+}
+
+// export entities of tables in order of id, batch by batch
+func (t archiveTables) export(tx *pg.Tx, fn func(*Record) error) error {
+	var last int64
+
+	for {
+		var items []*Record
+
+		if _, err := tx.Query(&items, "SELECT ? FROM ? WHERE id > ? ORDER BY id LIMIT ?",
+			pg.Q(t.columns), pg.F(t.table), last, archiveBatch); err != nil {
+			return errors.Wrapf(err, "could not export %s", t.table)
+		} else if len(items) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+
+		aliases, err := t.aliases(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		var versions []*Record
+
+		if _, err = tx.Query(&versions, "SELECT ? FROM ? WHERE ? IN (?) ORDER BY ?, version",
+			pg.Q(t.version), pg.F(t.versions), pg.F(t.key), pg.In(ids), pg.F(t.key)); err != nil {
+			return errors.Wrapf(err, "could not export %s", t.versions)
+		}
+
+		for _, item := range items {
+			item.Type = t.entity
+
+			for _, slug := range aliases[item.ID] {
+				if slug != item.Slug {
+					item.Aliases = append(item.Aliases, slug)
+				}
+			}
+
+			if err = fn(item); err != nil {
+				return err
+			}
+
+			for len(versions) > 0 && versions[0].ID == item.ID {
+				versions[0].Type = t.entity + "_version"
+
+				if err = fn(versions[0]); err != nil {
+					return err
+				}
+
+				versions = versions[1:]
+			}
+		}
+
+		last = ids[len(ids)-1]
+	}
+}
+
+// aliases returns slugs of entities in order of creation
+func (t archiveTables) aliases(tx *pg.Tx, ids []int64) (map[int64][]string, error) {
+	var (
+		items  []*Record
+		result = make(map[int64][]string, len(ids))
+	)
+
+	if _, err := tx.Query(&items, "SELECT ? AS id, slug FROM ? WHERE ? IN (?) ORDER BY created_at, slug",
+		pg.F(t.key), pg.F(t.slugs), pg.F(t.key), pg.In(ids)); err != nil {
+		return nil, errors.Wrapf(err, "could not export %s", t.slugs)
+	}
+
+	for _, item := range items {
+		result[item.ID] = append(result[item.ID], item.Slug)
+	}
+
+	return result, nil
+}
+
+// Import records of archive, entities get new ids (see ImportOptions.KeepIDs) and
+// references of configs are rewritten to them, versions keep authors and time of creation. Versions of imported entities
+// are written to feed of changes like any other versions, created and merged entities are
+// written to outbox in the same transaction, see importer.events.
+func (a *archive) Import(ctx context.Context, next func() (*Record, error), opts ImportOptions) (*ImportResult, error) {
+	var result = &ImportResult{
+		SchemeIDs: make(map[int64]int64),
+		ConfigIDs: make(map[int64]int64),
+	}
+
+	switch opts.Conflict {
+	case "":
+		opts.Conflict = ConflictFail
+	case ConflictFail, ConflictSkip, ConflictMerge:
+	default:
+		return nil, errors.Errorf("unknown conflict strategy %q", opts.Conflict)
+	}
+
+	err := runInTransaction(ctx, a.db, func(tx *pg.Tx) error {
+		imp := &importer{
+			tx:      tx,
+			vars:    a.vars,
+			opts:    opts,
+			result:  result,
+			schemes: make(map[int64]*importedEntity),
+			configs: make(map[int64]*importedEntity),
+		}
+
+		for {
+			rec, err := next()
+			if err == io.EOF {
+				return errors.Wrap(ErrInvalidArchive, "archive is truncated, end record not found")
+			} else if err != nil {
+				return err
+			}
+
+			if rec.Type == RecordEnd {
+				return imp.end(rec)
+			}
+
+			if err = imp.record(rec); err != nil {
+				return err
+			}
+
+			imp.count++
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (i *importer) record(rec *Record) error {
+	if !i.header && rec.Type != RecordHeader {
+		return errors.Wrap(ErrInvalidArchive, "archive should start with header")
+	}
+
+	switch rec.Type {
+	case RecordHeader:
+		if rec.Format < 1 || rec.Format > ArchiveFormat {
+			return errors.Wrapf(ErrInvalidArchive, "unsupported format %d", rec.Format)
+		}
+
+		i.header = true
+	case RecordScheme:
+		return i.entity(schemeTables, rec, i.schemes, &i.result.Schemes, i.result.SchemeIDs)
+	case RecordConfig:
+		scheme, ok := i.schemes[rec.SchemeID]
+		if !ok {
+			return errors.Wrapf(ErrInvalidArchive, "config #%d refers to unknown scheme #%d", rec.ID, rec.SchemeID)
+		}
+
+		rec.SchemeID = scheme.id
+
+		return i.entity(configTables, rec, i.configs, &i.result.Configs, i.result.ConfigIDs)
+	case RecordSchemeVersion:
+		return i.version(schemeTables, rec, i.schemes[rec.ID])
+	case RecordConfigVersion:
+		return i.version(configTables, rec, i.configs[rec.ID])
+	}
+
+	// unknown records are added by newer versions of the same format
+	return nil
+}
+
+// end checks that all records are read and moves sequences after kept ids
+func (i *importer) end(rec *Record) error {
+	if rec.Count != i.count {
+		return errors.Wrapf(ErrInvalidArchive, "archive should contain %d records, but %d read", rec.Count, i.count)
+	}
+
+	if err := i.rewrite(); err != nil {
+		return err
+	}
+
+	if err := i.references(); err != nil {
+		return err
+	}
+
+	if err := i.events(); err != nil {
+		return err
+	}
+
+	if !i.opts.KeepIDs {
+		return nil
+	}
+
+	for _, table := range []string{schemeTables.table, configTables.table} {
+		if _, err := i.tx.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM ?",
+			table, pg.F(table)); err != nil {
+			return errors.Wrapf(err, "could not update sequence of %s", table)
+		}
+	}
+
+	return nil
+}
+
+// rewrite replaces ids of archive in references of imported versions of configs
+// by ids of instance, so configs imported with new ids refer to each other.
+// Configs could refer only to configs of archive, data that could not be parsed
+// is kept as is.
+func (i *importer) rewrite() error {
+	if i.opts.KeepIDs {
+		return nil
+	}
+
+	lookup := func(id int64) (int64, error) {
+		if item, ok := i.configs[id]; ok {
+			return item.id, nil
+		}
+
+		return 0, errors.Wrapf(ErrInvalidArchive, "reference to config #%d that is not in archive", id)
+	}
+
+	for id, item := range i.configs {
+		var versions []*Record
+
+		if item.skip {
+			continue
+		}
+
+		if _, err := i.tx.Query(&versions, "SELECT version, data FROM config_versions WHERE config_id = ? AND version > ?",
+			item.id, item.offset); err != nil {
+			return errors.Wrapf(err, "could not read imported config #%d", item.id)
+		}
+
+		for _, version := range versions {
+			data, err := interpolate.Rewrite(version.Data, lookup)
+			if errors.Cause(err) == ErrInvalidArchive {
+				return errors.Wrapf(err, "config #%d", id)
+			} else if err != nil || bytes.Equal(data, version.Data) {
+				continue
+			}
+
+			if _, err = i.tx.Exec("UPDATE config_versions SET data = ?::jsonb WHERE config_id = ? AND version = ?",
+				string(data), item.id, version.Version); err != nil {
+				return errors.Wrapf(err, "could not rewrite references of config #%d", item.id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// references rebuilds index of references of imported configs by their latest versions,
+// imported configs could refer only to defined variables. Otherwise archive is not
+// validated: references to configs that are not in database and data that could not
+// be parsed are not indexed
+func (i *importer) references() error {
+	for _, item := range i.configs {
+		var data string
+
+		if item.skip {
+			continue
+		}
+
+		if _, err := i.tx.QueryOne(pg.Scan(&data), "SELECT data FROM config_versions WHERE config_id = ? ORDER BY version DESC LIMIT 1",
+			item.id); err == pg.ErrNoRows {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "could not read imported config #%d", item.id)
+		}
+
+		refs, err := interpolate.Parse(json.RawMessage(data))
+		if err != nil {
+			continue
+		} else if err = i.vars.Check(refs); err != nil {
+			return errors.Wrapf(err, "imported config #%d", item.id)
+		}
+
+		if _, err = i.tx.Exec("DELETE FROM config_references WHERE config_id = ?", item.id); err != nil {
+			return errors.Wrapf(err, "could not clear references of config #%d", item.id)
+		}
+
+		for _, ref := range refs {
+			if ref.ConfigID == 0 {
+				continue
+			}
+
+			if _, err = i.tx.Exec(`INSERT INTO config_references (config_id, target_id, pointer)
+				SELECT ?, id, ? FROM configs WHERE id = ?`, item.id, ref.Pointer, ref.ConfigID); err != nil {
+				return errors.Wrapf(err, "could not store reference of config #%d", item.id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// events writes outbox events of latest versions of created (create) and merged (update)
+// entities like stores do, schemes before configs in order of ids. Secrets of configs are
+// masked, deleted entities are not published.
+func (i *importer) events() error {
+	var (
+		s = &schemes{db: i.tx}
+		c = &configs{db: i.tx}
+	)
+
+	for _, item := range imported(i.schemes) {
+		scheme, err := s.read(item.id)
+		if errors.Cause(err) == pg.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err = emit(i.tx, "scheme", item.id, item.op(), scheme); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range imported(i.configs) {
+		cfg, err := c.read(item.id)
+		if errors.Cause(err) == pg.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err = c.mask(cfg); err != nil {
+			return err
+		}
+
+		if err = emit(i.tx, "config", item.id, item.op(), cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// imported entities that are not skipped in order of ids of instance
+func imported(items map[int64]*importedEntity) []*importedEntity {
+	var result = make([]*importedEntity, 0, len(items))
+
+	for _, item := range items {
+		if !item.skip {
+			result = append(result, item)
+		}
+	}
+
+	sort.Slice(result, func(a, b int) bool { return result[a].id < result[b].id })
+
+	return result
+}
+
+// op of outbox event of imported entity
+func (e *importedEntity) op() string {
+	if e.merged {
+		return "update"
+	}
+
+	return "create"
+}
+
+// entity creates entity or resolves conflict with existing entity that has the same slug
+func (i *importer) entity(t archiveTables, rec *Record, seen map[int64]*importedEntity, counts *ImportCounts, ids map[int64]int64) error {
+	if _, ok := seen[rec.ID]; ok {
+		return errors.Wrapf(ErrInvalidArchive, "%s #%d imported twice", t.entity, rec.ID)
+	}
+
+	existing, err := i.lookup(t, rec.SchemeID, rec.Slug)
+	if err != nil {
+		return err
+	}
+
+	var item = &importedEntity{id: existing, schemeID: rec.SchemeID}
+
+	switch {
+	case existing == 0:
+		if item.id, err = i.create(t, rec); err != nil {
+			return err
+		}
+
+		counts.Created++
+	case i.opts.Conflict == ConflictSkip:
+		item.skip = true
+		counts.Skipped++
+	case i.opts.Conflict == ConflictMerge:
+		if _, err = i.tx.QueryOne(pg.Scan(&item.offset), "SELECT COALESCE(MAX(version), 0) FROM ? WHERE ? = ?",
+			pg.F(t.versions), pg.F(t.key), existing); err != nil {
+			return errors.Wrapf(err, "could not read versions of %s #%d", t.entity, existing)
+		}
+
+		item.merged = true
+		counts.Merged++
+	default:
+		return errors.Wrapf(ErrImportConflict, "%s %q already exists", t.entity, rec.Slug)
+	}
+
+	seen[rec.ID], ids[rec.ID] = item, item.id
+
+	return nil
+}
+
+// lookup returns id of entity with slug (current or old one), zero when slug is not used
+func (i *importer) lookup(t archiveTables, schemeID int64, slug string) (int64, error) {
+	var (
+		id  int64
+		err error
+	)
+
+	if slug == "" {
+		return 0, nil
+	}
+
+	if t.entity == RecordScheme {
+		_, err = i.tx.QueryOne(pg.Scan(&id), "SELECT scheme_id FROM scheme_slugs WHERE slug = ?", slug)
+	} else {
+		_, err = i.tx.QueryOne(pg.Scan(&id), "SELECT config_id FROM config_slugs WHERE scheme_id = ? AND slug = ?", schemeID, slug)
+	}
+
+	if err == pg.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Wrapf(err, "could not check slug %q", slug)
+	}
+
+	return id, nil
+}
+
+func (i *importer) create(t archiveTables, rec *Record) (int64, error) {
+	var (
+		id      int64
+		err     error
+		slug    = nullString(rec.Slug)
+		created = nullTime(rec.CreatedAt)
+	)
+
+	switch {
+	case t.entity == RecordScheme && i.opts.KeepIDs:
+		_, err = i.tx.QueryOne(pg.Scan(&id), "INSERT INTO schemes (id, slug, created_at, deleted_at) VALUES (?, ?, COALESCE(?, NOW()), ?) RETURNING id",
+			rec.ID, slug, created, rec.DeletedAt)
+	case t.entity == RecordScheme:
+		_, err = i.tx.QueryOne(pg.Scan(&id), "INSERT INTO schemes (slug, created_at, deleted_at) VALUES (?, COALESCE(?, NOW()), ?) RETURNING id",
+			slug, created, rec.DeletedAt)
+	case i.opts.KeepIDs:
+		_, err = i.tx.QueryOne(pg.Scan(&id), "INSERT INTO configs (id, scheme_id, slug, created_at, deleted_at) VALUES (?, ?, ?, COALESCE(?, NOW()), ?) RETURNING id",
+			rec.ID, rec.SchemeID, slug, created, rec.DeletedAt)
+	default:
+		_, err = i.tx.QueryOne(pg.Scan(&id), "INSERT INTO configs (scheme_id, slug, created_at, deleted_at) VALUES (?, ?, COALESCE(?, NOW()), ?) RETURNING id",
+			rec.SchemeID, slug, created, rec.DeletedAt)
+	}
+
+	if isUniqueViolation(err) {
+		return 0, errors.Wrapf(ErrImportConflict, "%s #%d already exists", t.entity, rec.ID)
+	} else if err != nil {
+		return 0, errors.Wrapf(err, "could not import %s #%d", t.entity, rec.ID)
+	}
+
+	// current slug first, so it is never dropped as conflicting alias:
+	for n, alias := range append([]string{rec.Slug}, rec.Aliases...) {
+		var res orm.Result
+
+		if alias == "" {
+			continue
+		} else if t.entity == RecordScheme {
+			res, err = i.tx.Exec("INSERT INTO scheme_slugs (slug, scheme_id) VALUES (?, ?) ON CONFLICT DO NOTHING", alias, id)
+		} else {
+			res, err = i.tx.Exec("INSERT INTO config_slugs (scheme_id, slug, config_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", rec.SchemeID, alias, id)
+		}
+
+		switch {
+		case err != nil:
+			return 0, errors.Wrapf(err, "could not import slug %q of %s #%d", alias, t.entity, rec.ID)
+		case res.RowsAffected() > 0:
+		case n == 0 || i.opts.Conflict == ConflictFail:
+			return 0, errors.Wrapf(ErrImportConflict, "slug %q of %s #%d already used", alias, t.entity, rec.ID)
+		}
+		// otherwise old slug used by another entity is dropped
+	}
+
+	return id, nil
+}
+
+func (i *importer) version(t archiveTables, rec *Record, item *importedEntity) error {
+	if item == nil {
+		return errors.Wrapf(ErrInvalidArchive, "%s version of unknown %s #%d", t.entity, t.entity, rec.ID)
+	} else if item.skip {
+		return nil
+	}
+
+	tags, err := json.Marshal(rec.Tags)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode tags of %s #%d", t.entity, rec.ID)
+	}
+
+	var (
+		version = item.offset + rec.Version
+		author  = nullString(rec.Author)
+		created = nullTime(rec.CreatedAt)
+	)
+
+	if t.entity == RecordScheme {
+		_, err = i.tx.Exec(`INSERT INTO scheme_versions (scheme_id, version, tags, data, author, created_at)
+			VALUES (?, ?, ?::jsonb, ?::jsonb, ?, COALESCE(?, NOW()))`,
+			item.id, version, string(tags), string(rec.Data), author, created)
+	} else {
+		_, err = i.tx.Exec(`INSERT INTO config_versions (config_id, scheme_id, version, tags, data, author, created_at)
+			VALUES (?, ?, ?, ?::jsonb, ?::jsonb, ?, COALESCE(?, NOW()))`,
+			item.id, item.schemeID, version, string(tags), string(rec.Data), author, created)
+	}
+
+	if isUniqueViolation(err) {
+		return errors.Wrapf(ErrInvalidArchive, "version %d of %s #%d imported twice", rec.Version, t.entity, rec.ID)
+	} else if err != nil {
+		return errors.Wrapf(err, "could not import version %d of %s #%d", rec.Version, t.entity, rec.ID)
+	}
+
+	i.result.Versions++
+
+	return nil
+}
+
+// nullString is NULL for empty string
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// nullTime is NULL for zero time
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
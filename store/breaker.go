@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerTimeout  = 5 * time.Second
+)
+
+type (
+	// Breaker stops calls to database after `breaker.failures` consecutive
+	// failures for `breaker.timeout`, after that one call is allowed to probe
+	// database and closes breaker on success.
+	Breaker struct {
+		mu        sync.Mutex
+		logger    *zap.Logger
+		threshold int
+		timeout   time.Duration
+		failures  int
+		openedAt  time.Time
+		probing   bool
+	}
+
+	guardedSchemes struct {
+		Schemes
+		breaker *Breaker
+	}
+
+	guardedConfigs struct {
+		Configs
+		breaker *Breaker
+	}
+
+	guardedTransactions struct {
+		Transactions
+		breaker *Breaker
+	}
+)
+
+// ErrUnavailable when breaker is open and database is not called
+var ErrUnavailable = errors.New("database unavailable")
+
+var breakerOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "store",
+	Name:      "breaker_open",
+	Help:      "Circuit breaker of database is open (1) or closed (0)",
+})
+
+func init() {
+	prometheus.MustRegister(breakerOpen)
+}
+
+// NewBreaker with `breaker.failures` (5 by default) and `breaker.timeout` (5s by default)
+func NewBreaker(v *viper.Viper, l *zap.Logger) *Breaker {
+	var b = &Breaker{
+		logger:    l,
+		threshold: defaultBreakerFailures,
+		timeout:   defaultBreakerTimeout,
+	}
+
+	if v.IsSet("breaker.failures") {
+		b.threshold = v.GetInt("breaker.failures")
+	}
+
+	if v.IsSet("breaker.timeout") {
+		b.timeout = v.GetDuration("breaker.timeout")
+	}
+
+	return b
+}
+
+// IsUnavailable checks that error caused by connectivity problems of database: errors
+// of network and driver, pg errors of classes 08, 53 and 57P. Timeouts and cancels of
+// context of caller are not unavailability of database.
+func IsUnavailable(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case nil:
+		return false
+	case pg.Error:
+		// connection exception, insufficient resources, operator intervention:
+		code := cause.Field('C')
+		return strings.HasPrefix(code, "08") || strings.HasPrefix(code, "53") || strings.HasPrefix(code, "57P")
+	case net.Error:
+		return true
+	default:
+		return cause == ErrUnavailable ||
+			cause == io.EOF ||
+			cause == io.ErrUnexpectedEOF
+	}
+}
+
+// allow returns false when breaker is open
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.probing || time.Since(b.openedAt) < b.timeout {
+		return false
+	}
+
+	b.probing = true
+
+	return true
+}
+
+// done counts result of allowed call, calls interrupted by context of caller
+// are not counted, so clients with short deadlines do not open breaker
+func (b *Breaker) done(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	if IsUnavailable(err) {
+		b.failures++
+
+		if b.failures >= b.threshold {
+			if b.failures == b.threshold {
+				b.logger.Error("database unavailable, open circuit breaker", zap.Error(err))
+			}
+
+			b.openedAt = time.Now()
+			breakerOpen.Set(1)
+		}
+
+		return
+	}
+
+	if b.failures >= b.threshold {
+		b.logger.Info("database available, close circuit breaker")
+	}
+
+	b.failures = 0
+	breakerOpen.Set(0)
+}
+
+// call fn when breaker is closed
+func (b *Breaker) call(ctx context.Context, fn func() error) error {
+	if !b.allow() {
+		return ErrUnavailable
+	}
+
+	err := fn()
+	b.done(ctx, err)
+
+	return err
+}
+
+func (s *guardedSchemes) Create(ctx context.Context, scheme *Scheme) error {
+	return s.breaker.call(ctx, func() error { return s.Schemes.Create(ctx, scheme) })
+}
+
+func (s *guardedSchemes) Read(ctx context.Context, id int64) (result *Scheme, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Schemes.Read(ctx, id)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *guardedSchemes) ReadBySlug(ctx context.Context, slug string) (result *Scheme, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Schemes.ReadBySlug(ctx, slug)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *guardedSchemes) Rename(ctx context.Context, id int64, slug string) error {
+	return s.breaker.call(ctx, func() error { return s.Schemes.Rename(ctx, id, slug) })
+}
+
+func (s *guardedSchemes) Update(ctx context.Context, scheme *Scheme) error {
+	return s.breaker.call(ctx, func() error { return s.Schemes.Update(ctx, scheme) })
+}
+
+func (s *guardedSchemes) Delete(ctx context.Context, id int64) error {
+	return s.breaker.call(ctx, func() error { return s.Schemes.Delete(ctx, id) })
+}
+
+func (s *guardedSchemes) Restore(ctx context.Context, id int64) error {
+	return s.breaker.call(ctx, func() error { return s.Schemes.Restore(ctx, id) })
+}
+
+func (s *guardedSchemes) Search(ctx context.Context, req SearchRequest) (result []*Scheme, total int, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, total, err = s.Schemes.Search(ctx, req)
+		return err
+	})
+
+	return result, total, err
+}
+
+func (s *guardedSchemes) History(ctx context.Context, id int64, limit, offset int) (result []*Scheme, total int, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, total, err = s.Schemes.History(ctx, id, limit, offset)
+		return err
+	})
+
+	return result, total, err
+}
+
+func (s *guardedConfigs) Create(ctx context.Context, cfg *Config) error {
+	return s.breaker.call(ctx, func() error { return s.Configs.Create(ctx, cfg) })
+}
+
+func (s *guardedConfigs) Read(ctx context.Context, id int64) (result *Config, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Configs.Read(ctx, id)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *guardedConfigs) ReadBySlug(ctx context.Context, scheme, slug string) (result *Config, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Configs.ReadBySlug(ctx, scheme, slug)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *guardedConfigs) Rename(ctx context.Context, id int64, slug string) error {
+	return s.breaker.call(ctx, func() error { return s.Configs.Rename(ctx, id, slug) })
+}
+
+func (s *guardedConfigs) Update(ctx context.Context, cfg *Config) error {
+	return s.breaker.call(ctx, func() error { return s.Configs.Update(ctx, cfg) })
+}
+
+func (s *guardedConfigs) Delete(ctx context.Context, id int64) error {
+	return s.breaker.call(ctx, func() error { return s.Configs.Delete(ctx, id) })
+}
+
+func (s *guardedConfigs) Restore(ctx context.Context, id int64) error {
+	return s.breaker.call(ctx, func() error { return s.Configs.Restore(ctx, id) })
+}
+
+func (s *guardedConfigs) Search(ctx context.Context, req SearchRequest) (result []*Config, total int, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, total, err = s.Configs.Search(ctx, req)
+		return err
+	})
+
+	return result, total, err
+}
+
+func (s *guardedConfigs) History(ctx context.Context, id int64, limit, offset int) (result []*Config, total int, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, total, err = s.Configs.History(ctx, id, limit, offset)
+		return err
+	})
+
+	return result, total, err
+}
+
+func (s *guardedConfigs) Dependents(ctx context.Context, id int64) (result []*Reference, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Configs.Dependents(ctx, id)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *guardedConfigs) Reveal(ctx context.Context, id int64, audit Reveal) (result *Config, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Configs.Reveal(ctx, id, audit)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *guardedConfigs) Unchanged(ctx context.Context, id int64, data json.RawMessage) (result bool, err error) {
+	err = s.breaker.call(ctx, func() error {
+		result, err = s.Configs.Unchanged(ctx, id, data)
+		return err
+	})
+
+	return result, err
+}
+
+// RunInTransaction counts whole transaction as one call
+func (t *guardedTransactions) RunInTransaction(ctx context.Context, fn func(Schemes, Configs) error) error {
+	return t.breaker.call(ctx, func() error { return t.Transactions.RunInTransaction(ctx, fn) })
+}
@@ -0,0 +1,618 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-redis/redis"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCacheTTL      = time.Minute
+	defaultCacheStaleTTL = 7 * 24 * time.Hour
+
+	schemesCacheKey = "schemes"
+	configsCacheKey = "configs"
+
+	schemesGenKey = "schemes:gen"
+)
+
+type (
+	// Cache is read-through cache of latest versions in redis:
+	//
+	//	schemes:<id>               => latest version of scheme
+	//	schemes:<id>:<version>     => JSON of scheme version
+	//	schemes:<id>:last          => JSON of last-known-good version
+	//	schemes:<id>:gen           => generation, incremented by every invalidation
+	//	schemes:<id>:configs       => ids of cached configs of scheme
+	//	schemes:gen                => generation of all schemes
+	//	schemes:slug:<slug>        => id of scheme
+	//	configs:slug:<scheme/slug> => id of config
+	//
+	// versions never changes, so writes invalidate only head keys.
+	// Reader takes generation before it reads database and stores version
+	// only when generation is not changed and newer version is not stored,
+	// so version read before concurrent write is not cached after it.
+	// Last-known-good versions and slugs are kept for `cache.stale_ttl`
+	// and served when database is unavailable, see IsUnavailable.
+	//
+	// Configs are cached masked by secret fields of their schemes, so change
+	// of scheme forgets cached configs of it, including last-known-good ones,
+	// and configs read before change of any scheme are not cached.
+	Cache struct {
+		client   *redis.Client
+		logger   *zap.Logger
+		ttl      time.Duration
+		staleTTL time.Duration
+	}
+
+	cachedSchemes struct {
+		Schemes
+		cache *Cache
+	}
+
+	cachedConfigs struct {
+		Configs
+		cache *Cache
+	}
+
+	cachedTransactions struct {
+		Transactions
+		cache *Cache
+	}
+
+	cachedArchive struct {
+		Archive
+		cache *Cache
+	}
+
+	// touchedSchemes / touchedConfigs collects ids of entities
+	// changed in transaction to invalidate them after commit:
+	touchedSchemes struct {
+		Schemes
+		ids     *[]int64
+		deleted *[]int64
+	}
+
+	touchedConfigs struct {
+		Configs
+		ids     *[]int64
+		deleted *[]int64
+	}
+)
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "store",
+	Name:      "cache_requests_total",
+	Help:      "Reads of schemes and configs from cache by result (hit, miss, error, stale)",
+}, []string{"entity", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+// NewCache with ttl from `cache.ttl` (1m by default)
+// and stale_ttl from `cache.stale_ttl` (7 days by default)
+func NewCache(v *viper.Viper, client *redis.Client, l *zap.Logger) *Cache {
+	var c = &Cache{
+		client:   client,
+		logger:   l,
+		ttl:      defaultCacheTTL,
+		staleTTL: defaultCacheStaleTTL,
+	}
+
+	if v.IsSet("cache.ttl") {
+		c.ttl = v.GetDuration("cache.ttl")
+	}
+
+	if v.IsSet("cache.stale_ttl") {
+		c.staleTTL = v.GetDuration("cache.stale_ttl")
+	}
+
+	return c
+}
+
+// NewCachedSchemeStore returns cached store, calls of database guarded by breaker
+func NewCachedSchemeStore(db *pg.DB, c *Cache, b *Breaker) Schemes {
+	return &cachedSchemes{Schemes: &guardedSchemes{Schemes: NewSchemeStore(db), breaker: b}, cache: c}
+}
+
+// NewCachedConfigStore returns cached store, calls of database guarded by breaker,
+// secret fields are encrypted by keyring, configs could refer only to defined variables
+func NewCachedConfigStore(db *pg.DB, c *Cache, b *Breaker, k *secrets.Keyring, vars interpolate.Variables) Configs {
+	return &cachedConfigs{Configs: &guardedConfigs{Configs: &configs{db: db, keys: k, vars: vars}, breaker: b}, cache: c}
+}
+
+// NewCachedTransactions invalidates cache after transactions, transactions guarded by breaker
+func NewCachedTransactions(db *pg.DB, c *Cache, b *Breaker, k *secrets.Keyring, vars interpolate.Variables) Transactions {
+	return &cachedTransactions{Transactions: &guardedTransactions{Transactions: &transactions{db: db, keys: k, vars: vars}, breaker: b}, cache: c}
+}
+
+// NewCachedArchive forgets imported entities after import
+func NewCachedArchive(db *pg.DB, c *Cache, vars interpolate.Variables) Archive {
+	return &cachedArchive{Archive: &archive{db: db, vars: vars}, cache: c}
+}
+
+func headKey(entity string, id int64) string {
+	return entity + ":" + strconv.FormatInt(id, 10)
+}
+
+func versionKey(entity string, id, version int64) string {
+	return headKey(entity, id) + ":" + strconv.FormatInt(version, 10)
+}
+
+func lastKey(entity string, id int64) string {
+	return headKey(entity, id) + ":last"
+}
+
+func genKey(entity string, id int64) string {
+	return headKey(entity, id) + ":gen"
+}
+
+func slugKey(entity, slug string) string {
+	return entity + ":slug:" + slug
+}
+
+func groupKey(scheme int64) string {
+	return headKey(schemesCacheKey, scheme) + ":configs"
+}
+
+// get reads latest version of entity into out, redis errors counted and treated as miss
+func (c *Cache) get(ctx context.Context, entity string, id int64, out interface{}) bool {
+	var (
+		err     error
+		data    []byte
+		version int64
+		client  = c.client.WithContext(ctx)
+	)
+
+	if version, err = client.Get(headKey(entity, id)).Int64(); err == nil {
+		if data, err = client.Get(versionKey(entity, id, version)).Bytes(); err == nil {
+			err = json.Unmarshal(data, out)
+		}
+	}
+
+	return c.result(entity, err)
+}
+
+// setScript stores version when generations are not changed and head is not newer,
+// adds id of entity to group when group is set:
+//
+//	KEYS: generation, generation of schemes, head, version, last-known-good, group (optional)
+//	ARGV: generation:generation of schemes, version, data, ttl (ms), stale ttl (ms), id,
+//	      0 ttl keeps key forever
+var setScript = redis.NewScript(`
+local gen = (redis.call('GET', KEYS[1]) or '0') .. ':' .. (redis.call('GET', KEYS[2]) or '0')
+if gen ~= ARGV[1] then
+	return 0
+end
+
+local head = redis.call('GET', KEYS[3])
+if head and tonumber(head) > tonumber(ARGV[2]) then
+	return 0
+end
+
+local function set(key, value, ttl)
+	if tonumber(ttl) > 0 then
+		redis.call('SET', key, value, 'PX', ttl)
+	else
+		redis.call('SET', key, value)
+	end
+end
+
+set(KEYS[4], ARGV[3], ARGV[4])
+set(KEYS[3], ARGV[2], ARGV[4])
+set(KEYS[5], ARGV[3], ARGV[5])
+
+if KEYS[6] then
+	redis.call('SADD', KEYS[6], ARGV[6])
+
+	if tonumber(ARGV[5]) > 0 then
+		redis.call('PEXPIRE', KEYS[6], ARGV[5])
+	end
+end
+
+return 1
+`)
+
+// generation of entity and of all schemes, should be taken before entity is read
+// from database, returns false when it could not be read and version should not be stored
+func (c *Cache) generation(ctx context.Context, entity string, id int64) (string, bool) {
+	values, err := c.client.WithContext(ctx).MGet(genKey(entity, id), schemesGenKey).Result()
+	if err != nil {
+		c.logger.Warn("could not read cache generation", zap.String("entity", entity), zap.Error(err))
+		return "", false
+	}
+
+	var gens = make([]string, 0, len(values))
+
+	for _, value := range values {
+		if gen, ok := value.(string); ok {
+			gens = append(gens, gen)
+		} else {
+			gens = append(gens, "0")
+		}
+	}
+
+	return strings.Join(gens, ":"), true
+}
+
+// set stores version of entity, marks it as latest and last-known-good,
+// version is skipped when entity or any scheme is invalidated after generation
+// is taken, group lists entity to forget it with other entities (see forgetGroups)
+func (c *Cache) set(ctx context.Context, entity string, id int64, gen string, version int64, item interface{}, group string) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		c.logger.Warn("could not encode cache item", zap.String("entity", entity), zap.Error(err))
+		return
+	}
+
+	keys := []string{genKey(entity, id), schemesGenKey, headKey(entity, id), versionKey(entity, id, version), lastKey(entity, id)}
+
+	if group != "" {
+		keys = append(keys, group)
+	}
+
+	if err = setScript.Run(c.client.WithContext(ctx), keys,
+		gen, version, data, milliseconds(c.ttl), milliseconds(c.staleTTL), id).Err(); err != nil && err != redis.Nil {
+		c.logger.Warn("could not store cache item", zap.String("entity", entity), zap.Error(err))
+	}
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// stale reads last-known-good version of entity into out
+func (c *Cache) stale(entity string, id int64, out interface{}) bool {
+	data, err := c.client.Get(lastKey(entity, id)).Bytes()
+	if err == nil {
+		err = json.Unmarshal(data, out)
+	}
+
+	if err != nil && err != redis.Nil {
+		c.logger.Warn("could not read stale cache", zap.String("entity", entity), zap.Error(err))
+	}
+
+	if err == nil {
+		cacheRequests.WithLabelValues(entity, "stale").Inc()
+	}
+
+	return err == nil
+}
+
+// lookup returns id of entity by slug
+func (c *Cache) lookup(ctx context.Context, entity, slug string) (int64, bool) {
+	id, err := c.client.WithContext(ctx).Get(slugKey(entity, slug)).Int64()
+	return id, c.result(entity, err)
+}
+
+// remember id of entity by slug, aliases are never reused by another entity
+func (c *Cache) remember(ctx context.Context, entity, slug string, id int64) {
+	if err := c.client.WithContext(ctx).Set(slugKey(entity, slug), id, c.staleTTL).Err(); err != nil {
+		c.logger.Warn("could not store cache item", zap.String("entity", entity), zap.Error(err))
+	}
+}
+
+// invalidate latest versions of entities, runs even when request is done,
+// because changes could be already committed
+func (c *Cache) invalidate(entity string, ids ...int64) {
+	c.del(entity, ids, headKey)
+}
+
+// forget deleted entities, last-known-good versions of them should not be served
+func (c *Cache) forget(entity string, ids ...int64) {
+	c.del(entity, ids, headKey, lastKey)
+}
+
+// invalidateSchemes invalidates changed schemes and forgets cached configs of them,
+// configs are masked by secret fields of previous version of scheme and could keep
+// plaintext of fields that become secret
+func (c *Cache) invalidateSchemes(ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	var (
+		configs []int64
+		groups  = make([]*redis.StringSliceCmd, 0, len(ids))
+	)
+
+	// configs that are read before are not stored, see generation:
+	if _, err := c.client.Pipelined(func(p redis.Pipeliner) error {
+		p.Incr(schemesGenKey)
+
+		for _, id := range ids {
+			groups = append(groups, p.SMembers(groupKey(id)))
+			p.Del(groupKey(id))
+		}
+
+		return nil
+	}); err != nil {
+		c.logger.Error("could not invalidate cache", zap.String("entity", schemesCacheKey), zap.Error(err))
+	}
+
+	for _, group := range groups {
+		for _, item := range group.Val() {
+			if id, err := strconv.ParseInt(item, 10, 64); err == nil {
+				configs = append(configs, id)
+			}
+		}
+	}
+
+	c.invalidate(schemesCacheKey, ids...)
+	c.forget(configsCacheKey, configs...)
+}
+
+// del removes keys of entities and increments their generations,
+// so versions that are read before are not stored
+func (c *Cache) del(entity string, ids []int64, keys ...func(string, int64) string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	if _, err := c.client.Pipelined(func(p redis.Pipeliner) error {
+		for _, id := range ids {
+			p.Incr(genKey(entity, id))
+			p.Expire(genKey(entity, id), c.staleTTL)
+
+			for _, key := range keys {
+				p.Del(key(entity, id))
+			}
+		}
+
+		return nil
+	}); err != nil {
+		c.logger.Error("could not invalidate cache", zap.String("entity", entity), zap.Error(err))
+	}
+}
+
+func (c *Cache) result(entity string, err error) bool {
+	switch {
+	case err == nil:
+		cacheRequests.WithLabelValues(entity, "hit").Inc()
+		return true
+	case err == redis.Nil:
+		cacheRequests.WithLabelValues(entity, "miss").Inc()
+	default:
+		cacheRequests.WithLabelValues(entity, "error").Inc()
+		c.logger.Warn("could not read cache", zap.String("entity", entity), zap.Error(err))
+	}
+
+	return false
+}
+
+func (s *cachedSchemes) Read(ctx context.Context, id int64) (*Scheme, error) {
+	var result = new(Scheme)
+
+	if s.cache.get(ctx, schemesCacheKey, id, result) {
+		return result, nil
+	}
+
+	gen, ok := s.cache.generation(ctx, schemesCacheKey, id)
+
+	result, err := s.Schemes.Read(ctx, id)
+	if err != nil {
+		return s.stale(id, err)
+	}
+
+	if ok {
+		s.cache.set(ctx, schemesCacheKey, result.ID, gen, result.Version, result, "")
+	}
+
+	return result, nil
+}
+
+// stale returns last-known-good version when database is unavailable
+func (s *cachedSchemes) stale(id int64, err error) (*Scheme, error) {
+	var result = new(Scheme)
+
+	if !IsUnavailable(err) || !s.cache.stale(schemesCacheKey, id, result) {
+		return nil, err
+	}
+
+	result.Stale = true
+
+	return result, nil
+}
+
+func (s *cachedSchemes) ReadBySlug(ctx context.Context, slug string) (*Scheme, error) {
+	if id, ok := s.cache.lookup(ctx, schemesCacheKey, slug); ok {
+		return s.Read(ctx, id)
+	}
+
+	result, err := s.Schemes.ReadBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	// generation of entity is unknown before read, so version is cached by next read by id:
+	s.cache.remember(ctx, schemesCacheKey, slug, result.ID)
+
+	return result, nil
+}
+
+func (s *cachedSchemes) Rename(ctx context.Context, id int64, slug string) error {
+	defer s.cache.invalidate(schemesCacheKey, id)
+	return s.Schemes.Rename(ctx, id, slug)
+}
+
+func (s *cachedSchemes) Update(ctx context.Context, scheme *Scheme) error {
+	defer s.cache.invalidateSchemes(scheme.ID)
+	return s.Schemes.Update(ctx, scheme)
+}
+
+func (s *cachedSchemes) Delete(ctx context.Context, id int64) error {
+	defer s.cache.forget(schemesCacheKey, id)
+	return s.Schemes.Delete(ctx, id)
+}
+
+func (s *cachedSchemes) Restore(ctx context.Context, id int64) error {
+	defer s.cache.invalidate(schemesCacheKey, id)
+	return s.Schemes.Restore(ctx, id)
+}
+
+func (s *cachedConfigs) Read(ctx context.Context, id int64) (*Config, error) {
+	var result = new(Config)
+
+	if s.cache.get(ctx, configsCacheKey, id, result) {
+		return result, nil
+	}
+
+	gen, ok := s.cache.generation(ctx, configsCacheKey, id)
+
+	result, err := s.Configs.Read(ctx, id)
+	if err != nil {
+		return s.stale(id, err)
+	}
+
+	if ok {
+		s.cache.set(ctx, configsCacheKey, result.ID, gen, result.Version, result, groupKey(result.SchemeID))
+	}
+
+	return result, nil
+}
+
+// stale returns last-known-good version when database is unavailable
+func (s *cachedConfigs) stale(id int64, err error) (*Config, error) {
+	var result = new(Config)
+
+	if !IsUnavailable(err) || !s.cache.stale(configsCacheKey, id, result) {
+		return nil, err
+	}
+
+	result.Stale = true
+
+	return result, nil
+}
+
+func (s *cachedConfigs) ReadBySlug(ctx context.Context, scheme, slug string) (*Config, error) {
+	if id, ok := s.cache.lookup(ctx, configsCacheKey, scheme+"/"+slug); ok {
+		return s.Read(ctx, id)
+	}
+
+	result, err := s.Configs.ReadBySlug(ctx, scheme, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	// generation of entity is unknown before read, so version is cached by next read by id:
+	s.cache.remember(ctx, configsCacheKey, scheme+"/"+slug, result.ID)
+
+	return result, nil
+}
+
+func (s *cachedConfigs) Rename(ctx context.Context, id int64, slug string) error {
+	defer s.cache.invalidate(configsCacheKey, id)
+	return s.Configs.Rename(ctx, id, slug)
+}
+
+func (s *cachedConfigs) Update(ctx context.Context, cfg *Config) error {
+	defer s.cache.invalidate(configsCacheKey, cfg.ID)
+	return s.Configs.Update(ctx, cfg)
+}
+
+func (s *cachedConfigs) Delete(ctx context.Context, id int64) error {
+	defer s.cache.forget(configsCacheKey, id)
+	return s.Configs.Delete(ctx, id)
+}
+
+func (s *cachedConfigs) Restore(ctx context.Context, id int64) error {
+	defer s.cache.invalidate(configsCacheKey, id)
+	return s.Configs.Restore(ctx, id)
+}
+
+// RunInTransaction reads and writes bypass cache in transaction,
+// changed entities are invalidated when transaction is finished
+func (t *cachedTransactions) RunInTransaction(ctx context.Context, fn func(Schemes, Configs) error) error {
+	var schemes, configs, deletedSchemes, deletedConfigs []int64
+
+	defer func() {
+		t.cache.invalidateSchemes(schemes...)
+		t.cache.invalidate(configsCacheKey, configs...)
+		t.cache.forget(schemesCacheKey, deletedSchemes...)
+		t.cache.forget(configsCacheKey, deletedConfigs...)
+	}()
+
+	return t.Transactions.RunInTransaction(ctx, func(s Schemes, c Configs) error {
+		return fn(
+			&touchedSchemes{Schemes: s, ids: &schemes, deleted: &deletedSchemes},
+			&touchedConfigs{Configs: c, ids: &configs, deleted: &deletedConfigs})
+	})
+}
+
+func (s *touchedSchemes) Rename(ctx context.Context, id int64, slug string) error {
+	*s.ids = append(*s.ids, id)
+	return s.Schemes.Rename(ctx, id, slug)
+}
+
+func (s *touchedSchemes) Update(ctx context.Context, scheme *Scheme) error {
+	*s.ids = append(*s.ids, scheme.ID)
+	return s.Schemes.Update(ctx, scheme)
+}
+
+func (s *touchedSchemes) Delete(ctx context.Context, id int64) error {
+	*s.deleted = append(*s.deleted, id)
+	return s.Schemes.Delete(ctx, id)
+}
+
+func (s *touchedSchemes) Restore(ctx context.Context, id int64) error {
+	*s.ids = append(*s.ids, id)
+	return s.Schemes.Restore(ctx, id)
+}
+
+func (s *touchedConfigs) Rename(ctx context.Context, id int64, slug string) error {
+	*s.ids = append(*s.ids, id)
+	return s.Configs.Rename(ctx, id, slug)
+}
+
+func (s *touchedConfigs) Update(ctx context.Context, cfg *Config) error {
+	*s.ids = append(*s.ids, cfg.ID)
+	return s.Configs.Update(ctx, cfg)
+}
+
+func (s *touchedConfigs) Delete(ctx context.Context, id int64) error {
+	*s.deleted = append(*s.deleted, id)
+	return s.Configs.Delete(ctx, id)
+}
+
+func (s *touchedConfigs) Restore(ctx context.Context, id int64) error {
+	*s.ids = append(*s.ids, id)
+	return s.Configs.Restore(ctx, id)
+}
+
+// Import forgets head and last-known-good versions of imported entities, merged
+// entities got new versions and kept ids could be cached before restore,
+// cached configs of imported schemes are forgotten too, see invalidateSchemes
+func (a *cachedArchive) Import(ctx context.Context, next func() (*Record, error), opts ImportOptions) (*ImportResult, error) {
+	result, err := a.Archive.Import(ctx, next, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids = make(map[string][]int64, 2)
+
+	for entity, items := range map[string]map[int64]int64{
+		schemesCacheKey: result.SchemeIDs,
+		configsCacheKey: result.ConfigIDs,
+	} {
+		for _, id := range items {
+			ids[entity] = append(ids[entity], id)
+		}
+	}
+
+	a.cache.invalidateSchemes(ids[schemesCacheKey]...)
+	a.cache.forget(schemesCacheKey, ids[schemesCacheKey]...)
+	a.cache.forget(configsCacheKey, ids[configsCacheKey]...)
+
+	return result, nil
+}
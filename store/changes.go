@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ChangesChannel is channel of postgres NOTIFY, payload is id of change
+	ChangesChannel = "changes"
+
+	// DefaultChangesLimit of changes returned by Since
+	DefaultChangesLimit = 100
+
+	// feedPollInterval wakes subscribers even without notifications,
+	// notifications could be lost while listener reconnects
+	feedPollInterval = 5 * time.Second
+)
+
+type (
+	// Change of scheme or config, written by triggers of database:
+	// create / update when new version stored, delete / restore when deleted_at changed.
+	Change struct {
+		tableName struct{}  `sql:"changes"`
+		ID        int64     `json:"id"`
+		TxID      int64     `sql:"txid" json:"-"`
+		Entity    string    `json:"entity"`
+		EntityID  int64     `json:"entity_id"`
+		SchemeID  int64     `json:"scheme_id"`
+		Version   int64     `json:"version,omitempty"`
+		Op        string    `json:"op"`
+		Tags      []string  `json:"tags"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// Cursor points to change, changes are ordered by transaction and id
+	Cursor struct {
+		TxID int64
+		ID   int64
+	}
+
+	ChangesRequest struct {
+		After    Cursor   // changes after cursor, zero cursor means from the beginning
+		Entity   string   // scheme / config
+		IDs      []int64  // ids of entities
+		SchemeID int64    // scheme and its configs
+		Tags     []string // changes of versions with all of tags
+		Ops      []string // create / update / delete / restore
+		Limit    int
+	}
+
+	Changes interface {
+		Since(ctx context.Context, req ChangesRequest) ([]*Change, error)
+		Last(ctx context.Context) (Cursor, error)
+		// Cleanup removes changes created before time, except changes with pending
+		// deliveries of webhooks, delivered and dead deliveries are removed with them
+		Cleanup(ctx context.Context, before time.Time) (int, error)
+	}
+
+	changes struct {
+		db orm.DB
+	}
+
+	// Feed wakes subscribers when changes are committed by any instance of service
+	Feed struct {
+		mu   sync.Mutex
+		subs map[chan struct{}]struct{}
+	}
+)
+
+// ErrInvalidCursor when cursor could not be parsed
+var ErrInvalidCursor = errors.New("cursor should be returned by previous request")
+
+// ParseCursor parses cursor in format `<txid>-<id>`, empty string is zero cursor
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	if s == "" {
+		return c, nil
+	}
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return c, ErrInvalidCursor
+	}
+
+	var err error
+
+	if c.TxID, err = strconv.ParseInt(parts[0], 10, 64); err != nil || c.TxID < 0 {
+		return c, ErrInvalidCursor
+	} else if c.ID, err = strconv.ParseInt(parts[1], 10, 64); err != nil || c.ID < 0 {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func (c Cursor) String() string {
+	if c == (Cursor{}) {
+		return ""
+	}
+
+	return strconv.FormatInt(c.TxID, 10) + "-" + strconv.FormatInt(c.ID, 10)
+}
+
+// Cursor points to change
+func (c *Change) Cursor() Cursor {
+	return Cursor{TxID: c.TxID, ID: c.ID}
+}
+
+// finished filters changes of transactions that are committed, and all
+// earlier transactions are finished, so changes are never added before cursor.
+//
+// Changes are not returned while any transaction that has written anything
+// (has txid) is running, so long write transaction (e.g. import of big archive)
+// delays feed until it is finished. Read-only transactions (export, backup) have
+// no txid and do not delay feed.
+func finished(q *orm.Query) {
+	q.Where("txid < txid_snapshot_xmin(txid_current_snapshot())")
+}
+
+func NewChangeStore(db *pg.DB) Changes {
+	return &changes{db: db}
+}
+
+func (s *changes) Since(ctx context.Context, req ChangesRequest) (result []*Change, err error) {
+	if req.Limit <= 0 {
+		req.Limit = DefaultChangesLimit
+	}
+
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		q := db.Model(&result).
+			Where("(txid, id) > (?, ?)", req.After.TxID, req.After.ID).
+			Order("txid ASC", "id ASC").
+			Limit(req.Limit)
+
+		finished(q)
+
+		if req.Entity != "" {
+			q.Where("entity = ?", req.Entity)
+		}
+
+		if len(req.IDs) > 0 {
+			q.Where("entity_id IN (?)", pg.In(req.IDs))
+		}
+
+		if req.SchemeID > 0 {
+			q.Where("scheme_id = ?", req.SchemeID)
+		}
+
+		if len(req.Tags) > 0 {
+			q.Where("tags @> ?", req.Tags)
+		}
+
+		if len(req.Ops) > 0 {
+			q.Where("op IN (?)", pg.In(req.Ops))
+		}
+
+		if err := q.Select(); err != nil {
+			return errors.Wrapf(err, "could not read changes after %q", req.After)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Last returns cursor of last change, zero cursor when there are no changes
+func (s *changes) Last(ctx context.Context) (cursor Cursor, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		q := db.Model((*Change)(nil)).
+			Column("txid", "id").
+			Order("txid DESC", "id DESC").
+			Limit(1)
+
+		finished(q)
+
+		if err := q.Select(pg.Scan(&cursor.TxID, &cursor.ID)); err != nil && err != pg.ErrNoRows {
+			return errors.Wrap(err, "could not read last change")
+		}
+
+		return nil
+	})
+
+	return cursor, err
+}
+
+func (s *changes) Cleanup(ctx context.Context, before time.Time) (count int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		res, err := db.Exec(`DELETE FROM changes c
+			WHERE c.created_at < ? AND NOT EXISTS (
+				SELECT 1 FROM webhook_deliveries d WHERE d.change_id = c.id AND d.status = ?)`,
+			before, DeliveryPending)
+		if err != nil {
+			return errors.Wrap(err, "could not remove changes")
+		}
+
+		count = res.RowsAffected()
+
+		return nil
+	})
+
+	return count, err
+}
+
+// NewFeed listens ChangesChannel until ctx is done
+func NewFeed(ctx context.Context, db *pg.DB, l *zap.Logger) *Feed {
+	var f = &Feed{subs: make(map[chan struct{}]struct{})}
+
+	go f.listen(ctx, db, l)
+
+	return f
+}
+
+func (f *Feed) listen(ctx context.Context, db *pg.DB, l *zap.Logger) {
+	var (
+		ln     = db.Listen(ChangesChannel)
+		ticker = time.NewTicker(feedPollInterval)
+	)
+
+	defer ticker.Stop()
+	defer func() {
+		if err := ln.Close(); err != nil {
+			l.Warn("could not close changes listener", zap.Error(err))
+		}
+	}()
+
+	ch := ln.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+		case <-ticker.C:
+		}
+
+		f.notify()
+	}
+}
+
+// Subscribe returns channel that receives signal when new changes could be read,
+// cancel should be called when subscriber is done
+func (f *Feed) Subscribe() (<-chan struct{}, func()) {
+	var ch = make(chan struct{}, 1)
+
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}
+}
+
+func (f *Feed) notify() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subs {
+		select {
+		case ch <- struct{}{}:
+		default: // subscriber already has pending signal
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/im-kulikov/simplinic-task/models"
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/im-kulikov/simplinic-task/secrets"
 	"github.com/pkg/errors"
 )
 
@@ -15,43 +20,188 @@ type Config struct {
 	Version   int64           `json:"version"`
 	Tags      []string        `json:"tags" validate:"required" message:"tags could not be empty"`
 	Data      json.RawMessage `json:"data" validate:"required" message:"data could not be empty"`
+	Author    string          `json:"author,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	// stored in configs table and unique in scheme, see ReadBySlug / Rename
+	Slug string `sql:"-" json:"slug,omitempty"`
+
+	// filled only by full-text search:
+	Rank    float64  `sql:"-" json:"rank,omitempty"`
+	Matched []string `sql:"-" json:"matched,omitempty"`
+
+	// Dependents are ids of configs that refer to config, filled by Create and Update
+	Dependents []int64 `sql:"-" json:"dependents,omitempty"`
+
+	// Stale is set when database is unavailable and last-known-good version is served
+	Stale bool `sql:"-" json:"-"`
+}
+
+func (s *configs) Create(ctx context.Context, cfg *Config) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return s.with(db).create(cfg)
+	})
+}
+
+func (s *configs) Read(ctx context.Context, id int64) (result *Config, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if result, err = s.with(db).read(id); err != nil {
+			return err
+		}
+
+		return s.with(db).mask(result)
+	})
+
+	return result, err
+}
+
+func (s *configs) ReadBySlug(ctx context.Context, scheme, slug string) (result *Config, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if result, err = s.with(db).readBySlug(scheme, slug); err != nil {
+			return err
+		}
+
+		return s.with(db).mask(result)
+	})
+
+	return result, err
+}
+
+func (s *configs) Rename(ctx context.Context, id int64, slug string) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return s.with(db).rename(id, slug)
+	})
+}
+
+func (s *configs) Update(ctx context.Context, cfg *Config) error {
+	return retryConflicts(ctx, s.db, func(db orm.DB) error {
+		return s.with(db).update(cfg)
+	})
+}
+
+func (s *configs) Delete(ctx context.Context, id int64) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return s.with(db).delete(id)
+	})
+}
+
+func (s *configs) Restore(ctx context.Context, id int64) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return s.with(db).restore(id)
+	})
+}
+
+func (s *configs) Search(ctx context.Context, req SearchRequest) (result []*Config, total int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if result, total, err = s.with(db).search(req); err != nil {
+			return err
+		}
+
+		return s.with(db).mask(result...)
+	})
+
+	return result, total, err
+}
+
+func (s *configs) History(ctx context.Context, id int64, limit, offset int) (result []*Config, total int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if result, total, err = s.with(db).history(id, limit, offset); err != nil {
+			return err
+		}
+
+		return s.with(db).mask(result...)
+	})
+
+	return result, total, err
+}
+
+func (s *configs) Dependents(ctx context.Context, id int64) (result []*Reference, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, err = s.with(db).dependents(id)
+		return err
+	})
+
+	return result, err
+}
+
+// with returns store bound to db (transaction) that keeps keyring
+func (s *configs) with(db orm.DB) *configs {
+	return &configs{db: db, keys: s.keys, vars: s.vars}
 }
 
-func (s *configs) Create(cfg *Config) error {
-	var model = models.Config{SchemeID: cfg.ID}
+func (s *configs) create(cfg *Config) error {
+	var model = models.Config{SchemeID: cfg.SchemeID, Slug: cfg.Slug}
+
+	if cfg.Slug != "" && !ValidSlug(cfg.Slug) {
+		return ErrInvalidSlug
+	}
 
 	if _, err := s.db.Model(&model).Insert(); err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugConflict
+		}
+
 		return errors.WithMessage(err, "could not create config")
 	}
 
+	if cfg.Slug != "" {
+		if err := s.alias(cfg.SchemeID, model.ID, cfg.Slug); err != nil {
+			return err
+		}
+	}
+
 	cfg.ID = model.ID
+	cfg.CreatedAt = time.Time{} // created_at of version always set by database
+
+	paths, err := s.seal(cfg, nil)
+	if err != nil {
+		return err
+	}
 
 	// create new config_versions..
 	if _, err := s.db.Model(cfg).Insert(); err != nil {
 		return errors.WithMessage(err, "could not store config_version data")
 	}
 
-	return nil
+	if err := s.references(cfg); err != nil {
+		return err
+	}
+
+	cfg.Data = secrets.MaskData(cfg.Data, paths) // secrets are not returned and not published
+
+	return emit(s.db, "config", cfg.ID, "create", cfg)
+}
+
+func (s *configs) read(id int64) (*Config, error) {
+	return s.readVersion(id, 0)
 }
 
-func (s *configs) Read(id int64) (*Config, error) {
+// readVersion reads version of config, latest version when version is zero
+func (s *configs) readVersion(id, version int64) (*Config, error) {
 	var result Config
 
-	if err := s.db.Model(&result).
+	q := s.db.Model(&result).
+		Column("cv.*").
+		ColumnExpr("c.slug").
 		Join("LEFT JOIN configs c"). // LEFT JOIN configs c ON c.id = cv.config_id
 		JoinOn("c.id = cv.config_id").
 		Where("c.id = ? AND c.deleted_at ISNULL", id).
-		Order("cv.created_at DESC", "cv.version DESC").
-		Limit(1).
-		Select(); err != nil {
+		Order("cv.version DESC"). // latest version, created_at is start time of transaction and could be out of order
+		Limit(1)
+
+	if version > 0 {
+		q.Where("cv.version = ?", version)
+	}
+
+	if err := q.Select(); err != nil {
 		return nil, errors.Wrapf(err, "could not read config #%d", id)
 	}
 
 	return &result, nil
 }
 
-func (s *configs) Update(cfg *Config) error {
-	var sid, version int64
+func (s *configs) update(cfg *Config) error {
+	var id, sid, version int64
 
 	// Example:
 	//   SELECT MAX(cv.version) as version
@@ -66,6 +216,15 @@ func (s *configs) Update(cfg *Config) error {
 	//      AND s.deleted_at ISNULL
 	// GROUP BY c.id;
 
+	// lock config row, concurrent updates of config wait for this transaction:
+	if err := s.db.Model((*models.Config)(nil)).
+		Column("id").
+		Where("id = ?", cfg.ID).
+		For("UPDATE").
+		Select(pg.Scan(&id)); err != nil {
+		return errors.Wrapf(err, "could not lock config #%d", cfg.ID)
+	}
+
 	if err := s.db.
 		Model((*Config)(nil)).
 		ColumnExpr("cv.scheme_id, MAX(cv.version) as version").
@@ -82,30 +241,68 @@ func (s *configs) Update(cfg *Config) error {
 	cfg.SchemeID = sid
 	cfg.Version = version + 1
 
+	cfg.CreatedAt = time.Time{} // created_at of version always set by database
+
+	var previous json.RawMessage
+
+	// masked secrets keep values of previous version:
+	if _, err := s.db.QueryOne(pg.Scan(&previous),
+		"SELECT data FROM config_versions WHERE config_id = ? AND version = ?", cfg.ID, version); err != nil {
+		return errors.Wrapf(err, "could not read version %d of config #%d", version, cfg.ID)
+	}
+
+	paths, err := s.seal(cfg, previous)
+	if err != nil {
+		return err
+	}
+
 	if _, err := s.db.Model(cfg).
 		Insert(); err != nil {
 		return errors.WithMessage(err, "could not store new version of config data")
 	}
 
-	return nil
+	if err := s.references(cfg); err != nil {
+		return err
+	}
+
+	cfg.Data = secrets.MaskData(cfg.Data, paths)
+
+	return emit(s.db, "config", cfg.ID, "update", cfg)
 }
 
-func (s *configs) Delete(id int64) error {
+func (s *configs) delete(id int64) error {
+	if err := s.unreferenced(id); err != nil {
+		return err
+	}
+
 	if err := s.db.Delete(&models.Config{ID: id}); err != nil {
 		return errors.Wrapf(err, "can't remove scheme #%d", id)
 	}
 
-	return nil
+	return emit(s.db, "config", id, "delete", entityRef{ID: id})
 }
 
-func (s *configs) Search(req SearchRequest) ([]*Config, error) {
+// restore deleted config, returns pg.ErrNoRows when config not found or not deleted
+func (s *configs) restore(id int64) error {
+	res, err := s.db.Exec("UPDATE configs SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return errors.Wrapf(err, "can't restore config #%d", id)
+	} else if res.RowsAffected() == 0 {
+		return errors.Wrapf(pg.ErrNoRows, "can't restore config #%d", id)
+	}
+
+	return emit(s.db, "config", id, "restore", entityRef{ID: id})
+}
+
+func (s *configs) search(req SearchRequest) ([]*Config, int, error) {
 	var result []*Config
 
 	q := s.db.Model(&result).
+		Column("cv.*").
+		ColumnExpr("c.slug").
 		Join("LEFT JOIN configs c").
 		JoinOn("c.id = cv.config_id").
-		Order("version DESC").
-		Group("cv.scheme_id", "cv.config_id", "cv.version")
+		Group("cv.scheme_id", "cv.config_id", "cv.version", "c.id")
 
 	if req.Version > 0 {
 		q.Where("version = ?", req.Version)
@@ -115,11 +312,94 @@ func (s *configs) Search(req SearchRequest) ([]*Config, error) {
 		q.Where(`tags @> ?`, req.Tags) // tags @> '["b", "c"]' : filter tags, that have "b" and "c"
 	}
 
+	if req.SchemeID > 0 {
+		q.Where("cv.scheme_id = ?", req.SchemeID)
+	}
+
+	// full-text query matches heads of configs, unless version is requested:
+	if req.Latest || (req.Query != "" && req.Version == 0) {
+		q.Where("cv.version = (SELECT MAX(l.version) FROM config_versions l WHERE l.config_id = cv.config_id)")
+	}
+
+	if req.Author != "" {
+		q.Where("cv.author = ?", req.Author)
+	}
+
+	timeRange(q, "c.created_at", req.CreatedFrom, req.CreatedTo)
+	timeRange(q, "cv.created_at", req.UpdatedFrom, req.UpdatedTo)
+
+	if req.Query != "" {
+		fullTextSearch(q, "cv", req.Query)
+	}
+
+	if req.Filter != nil {
+		cond, params, err := compileFilter(req.Filter, configFilterColumns)
+		if err != nil {
+			return nil, 0, errors.WithMessage(err, "can't apply filter")
+		}
+
+		q.Where(cond, params...)
+	}
+
 	q.Where("deleted_at ISNULL")
+	q.Order("version DESC", "cv.config_id DESC")
 
-	if err := q.Select(); err != nil {
-		return nil, errors.Wrapf(err, "can't find config by (version=%d | tags=%v)", req.Version, req.Tags)
+	paginate(q, req)
+
+	total, err := q.SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "can't find config by (scheme_id=%d | version=%d | tags=%v | q=%q)",
+			req.SchemeID, req.Version, req.Tags, req.Query)
+	}
+
+	return result, total, nil
+}
+
+// history returns versions of config, latest first
+func (s *configs) history(id int64, limit, offset int) ([]*Config, int, error) {
+	var result []*Config
+
+	// config must exists and not be deleted:
+	latest, err := s.read(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q := s.db.Model(&result).
+		Where("cv.config_id = ?", id).
+		Order("cv.version DESC")
+
+	paginate(q, SearchRequest{Limit: limit, Offset: offset})
+
+	total, err := q.SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "can't read history of config #%d", id)
 	}
 
-	return result, nil
+	for _, item := range result {
+		item.Slug = latest.Slug
+	}
+
+	return result, total, nil
+}
+
+// mask replaces values of secret fields of schemes and sealed values of configs
+// by secrets.Mask, so secrets are returned only by Reveal, even stored as is
+func (s *configs) mask(list ...*Config) error {
+	var paths = make(map[int64][]schema.Path)
+
+	for _, item := range list {
+		if _, ok := paths[item.SchemeID]; !ok {
+			found, err := (&schemes{db: s.db}).secrets(item.SchemeID)
+			if err != nil {
+				return err
+			}
+
+			paths[item.SchemeID] = found
+		}
+
+		item.Data = secrets.MaskData(item.Data, paths[item.SchemeID])
+	}
+
+	return nil
 }
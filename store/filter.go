@@ -0,0 +1,159 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/simplinic-task/filter"
+)
+
+type (
+	filterColumn struct {
+		Type   filter.Type
+		Column string
+	}
+
+	filterColumns map[string]filterColumn
+
+	// filterQuery is compiled filter expression with parameters
+	filterQuery struct {
+		buf    strings.Builder
+		params []interface{}
+	}
+)
+
+var (
+	schemeFilterColumns = filterColumns{
+		"id":         {Type: filter.Integer, Column: "sv.scheme_id"},
+		"version":    {Type: filter.Integer, Column: "sv.version"},
+		"tags":       {Type: filter.Set, Column: "sv.tags"},
+		"data":       {Type: filter.Document, Column: "sv.data"},
+		"created_at": {Type: filter.Time, Column: "s.created_at"},
+		"updated_at": {Type: filter.Time, Column: "sv.created_at"},
+	}
+
+	configFilterColumns = filterColumns{
+		"id":         {Type: filter.Integer, Column: "cv.config_id"},
+		"scheme_id":  {Type: filter.Integer, Column: "cv.scheme_id"},
+		"version":    {Type: filter.Integer, Column: "cv.version"},
+		"tags":       {Type: filter.Set, Column: "cv.tags"},
+		"data":       {Type: filter.Document, Column: "cv.data"},
+		"created_at": {Type: filter.Time, Column: "c.created_at"},
+		"updated_at": {Type: filter.Time, Column: "cv.created_at"},
+	}
+
+	filterOperators = map[filter.Token]string{
+		filter.EQ:  "=",
+		filter.NEQ: "<>",
+		filter.LT:  "<",
+		filter.LTE: "<=",
+		filter.GT:  ">",
+		filter.GTE: ">=",
+	}
+)
+
+func (c filterColumns) fields() filter.Fields {
+	var result = make(filter.Fields, len(c))
+
+	for name, col := range c {
+		result[name] = col.Type
+	}
+
+	return result
+}
+
+// compileFilter validates expression and compiles it to parameterized SQL condition
+func compileFilter(expr filter.Expr, columns filterColumns) (string, []interface{}, error) {
+	var q filterQuery
+
+	if err := filter.Validate(expr, columns.fields()); err != nil {
+		return "", nil, err
+	}
+
+	q.expr(expr, columns)
+
+	return q.buf.String(), q.params, nil
+}
+
+func (q *filterQuery) write(sql string, params ...interface{}) {
+	q.buf.WriteString(sql)
+	q.params = append(q.params, params...)
+}
+
+func (q *filterQuery) expr(expr filter.Expr, columns filterColumns) {
+	switch e := expr.(type) {
+	case *filter.Logical:
+		q.write("(")
+		q.expr(e.Left, columns)
+		q.write(" " + e.Op.String() + " ")
+		q.expr(e.Right, columns)
+		q.write(")")
+	case *filter.Not:
+		q.write("NOT (")
+		q.expr(e.X, columns)
+		q.write(")")
+	case *filter.Compare:
+		q.compare(e, columns[e.Field.Name])
+	}
+}
+
+func (q *filterQuery) compare(e *filter.Compare, col filterColumn) {
+	switch col.Type {
+	case filter.Set:
+		// tags @> '["prod"]'
+		q.write(col.Column+" @> ?::jsonb", jsonArray(e.Value))
+	case filter.Document:
+		q.document(e, col)
+	case filter.Integer:
+		num, _ := strconv.ParseInt(e.Value.Raw, 10, 64)
+		q.write(col.Column+" "+filterOperators[e.Op]+" ?", num)
+	default:
+		q.write(col.Column+" "+filterOperators[e.Op]+" ?", e.Value.Interface())
+	}
+}
+
+// document compiles comparison for value at path of JSON document, for example:
+//
+//	data.replicas >= 3 => CASE WHEN jsonb_typeof(cv.data #> '{replicas}') = 'number'
+//	                           THEN (cv.data #>> '{replicas}')::numeric >= 3 ELSE false END
+func (q *filterQuery) document(e *filter.Compare, col filterColumn) {
+	var path = pg.Array(e.Field.Path)
+
+	switch {
+	case e.Op == filter.HAS:
+		q.write(col.Column+" #> ? @> ?::jsonb", path, jsonArray(e.Value))
+	case e.Op == filter.EQ:
+		q.write(col.Column+" #> ? = ?::jsonb", path, jsonValue(e.Value))
+	case e.Op == filter.NEQ:
+		q.write(col.Column+" #> ? IS DISTINCT FROM ?::jsonb", path, jsonValue(e.Value))
+	case e.Value.Kind == filter.KindNumber:
+		q.write("CASE WHEN jsonb_typeof("+col.Column+" #> ?) = 'number' THEN ("+
+			col.Column+" #>> ?)::numeric "+filterOperators[e.Op]+" ? ELSE false END",
+			path, path, e.Value.Number)
+	default:
+		q.write("CASE WHEN jsonb_typeof("+col.Column+" #> ?) = 'string' THEN "+
+			col.Column+" #>> ? "+filterOperators[e.Op]+" ? ELSE false END",
+			path, path, documentValue(e.Value))
+	}
+}
+
+// documentValue returns value as it stored in JSON documents, dates stored as strings
+func documentValue(v filter.Value) interface{} {
+	if v.Kind == filter.KindDate {
+		return v.Raw
+	}
+
+	return v.Interface()
+}
+
+func jsonValue(v filter.Value) string {
+	data, _ := json.Marshal(documentValue(v))
+	return string(data)
+}
+
+func jsonArray(v filter.Value) string {
+	data, _ := json.Marshal([]interface{}{documentValue(v)})
+	return string(data)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Event is domain event, written to outbox in transaction of mutation
+	// of scheme or config and relayed to publisher in order of id.
+	//
+	// Payload of create / update is new version, of rename / delete / restore is entityRef.
+	Event struct {
+		tableName   struct{}        `sql:"outbox"`
+		ID          int64           `json:"id"`
+		Entity      string          `json:"entity"`
+		EntityID    int64           `json:"entity_id"`
+		Op          string          `json:"op"`
+		Payload     json.RawMessage `json:"payload"`
+		CreatedAt   time.Time       `json:"created_at"`
+		PublishedAt time.Time       `json:"-"`
+	}
+
+	Outbox interface {
+		// Pending returns events that are not published yet, ordered by id
+		Pending(ctx context.Context, limit int) ([]*Event, error)
+		// Published marks events as published
+		Published(ctx context.Context, ids []int64) error
+		// Cleanup removes events published before time
+		Cleanup(ctx context.Context, before time.Time) (int, error)
+	}
+
+	outbox struct {
+		db orm.DB
+	}
+
+	entityRef struct {
+		ID       int64  `json:"id"`
+		SchemeID int64  `json:"scheme_id,omitempty"`
+		Slug     string `json:"slug,omitempty"`
+	}
+)
+
+func NewOutboxStore(db *pg.DB) Outbox {
+	return &outbox{db: db}
+}
+
+// emit writes event to outbox, should be called after mutation statement,
+// so events of the same entity are ordered by row lock of entity
+func emit(db orm.DB, entity string, id int64, op string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode %s event of %s #%d", op, entity, id)
+	}
+
+	if _, err := db.Model(&Event{
+		Entity:   entity,
+		EntityID: id,
+		Op:       op,
+		Payload:  data,
+	}).Insert(); err != nil {
+		return errors.Wrapf(err, "could not write %s event of %s #%d", op, entity, id)
+	}
+
+	return nil
+}
+
+func (s *outbox) Pending(ctx context.Context, limit int) (result []*Event, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if err := db.Model(&result).
+			Where("published_at IS NULL").
+			Order("id ASC").
+			Limit(limit).
+			Select(); err != nil {
+			return errors.Wrap(err, "could not read pending events")
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *outbox) Published(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		if _, err := db.Model((*Event)(nil)).
+			Set("published_at = NOW()").
+			Where("id IN (?)", pg.In(ids)).
+			Update(); err != nil {
+			return errors.Wrapf(err, "could not mark %d events as published", len(ids))
+		}
+
+		return nil
+	})
+}
+
+func (s *outbox) Cleanup(ctx context.Context, before time.Time) (count int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		res, err := db.Model((*Event)(nil)).
+			Where("published_at < ?", before).
+			Delete()
+		if err != nil {
+			return errors.Wrap(err, "could not remove published events")
+		}
+
+		count = res.RowsAffected()
+
+		return nil
+	})
+
+	return count, err
+}
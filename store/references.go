@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/pkg/errors"
+)
+
+// Reference of latest version of config to field of target config,
+// `${config:<target_id>#<pointer>}` in data of config, see interpolate
+type Reference struct {
+	ConfigID int64  `json:"config_id"`
+	TargetID int64  `json:"target_id"`
+	Pointer  string `json:"pointer"`
+}
+
+// ErrReferenced when config could not be deleted because other configs refer to it
+var ErrReferenced = errors.New("config is referenced by other configs")
+
+// NewResolver returns resolver of references that reads latest versions of configs,
+// missing and deleted configs are invalid references
+func NewResolver(ctx context.Context, c Configs, vars interpolate.Variables) *interpolate.Resolver {
+	return resolver(func(id int64) (*Config, error) { return c.Read(ctx, id) }, vars)
+}
+
+func resolver(read func(id int64) (*Config, error), vars interpolate.Variables) *interpolate.Resolver {
+	return &interpolate.Resolver{
+		Variables: vars,
+		Config: func(id int64) (json.RawMessage, error) {
+			cfg, err := read(id)
+			if errors.Cause(err) == pg.ErrNoRows {
+				return nil, errors.Wrapf(interpolate.ErrInvalidReference, "config #%d not found", id)
+			} else if err != nil {
+				return nil, err
+			}
+
+			return cfg.Data, nil
+		},
+	}
+}
+
+// dependents returns references of other configs to config, config must exists and not be deleted
+func (s *configs) dependents(id int64) ([]*Reference, error) {
+	if _, err := s.read(id); err != nil {
+		return nil, err
+	}
+
+	return s.referencesTo(id)
+}
+
+// referencesTo returns references of configs that are not deleted to config
+func (s *configs) referencesTo(id int64) ([]*Reference, error) {
+	var result []*Reference
+
+	if _, err := s.db.Query(&result, `SELECT cr.config_id, cr.target_id, cr.pointer
+		  FROM config_references cr
+		  JOIN configs c ON c.id = cr.config_id
+		 WHERE cr.target_id = ? AND c.deleted_at ISNULL
+	  ORDER BY cr.config_id, cr.pointer`, id); err != nil {
+		return nil, errors.Wrapf(err, "could not read references to config #%d", id)
+	}
+
+	return result, nil
+}
+
+// references checks that references of new version of config are resolved and
+// variables are defined, stores them in index, configs that refer to config are
+// reported as dependents and should be still resolved with new version.
+func (s *configs) references(cfg *Config) error {
+	refs, err := interpolate.Parse(cfg.Data)
+	if err != nil {
+		return err
+	} else if err = s.vars.Check(refs); err != nil {
+		return err
+	}
+
+	// version is already stored, so dependents are resolved with it:
+	r := resolver(s.read, nil)
+
+	if _, err = r.Resolve(cfg.ID, cfg.Data); err != nil {
+		return err
+	}
+
+	if _, err = s.db.Exec("DELETE FROM config_references WHERE config_id = ?", cfg.ID); err != nil {
+		return errors.Wrapf(err, "could not clear references of config #%d", cfg.ID)
+	}
+
+	for _, ref := range refs {
+		if ref.ConfigID == 0 {
+			continue
+		}
+
+		if _, err = s.db.Exec("INSERT INTO config_references (config_id, target_id, pointer) VALUES (?, ?, ?)",
+			cfg.ID, ref.ConfigID, ref.Pointer); err != nil {
+			return errors.Wrapf(err, "could not store reference of config #%d", cfg.ID)
+		}
+	}
+
+	deps, err := s.referencesTo(cfg.ID)
+	if err != nil {
+		return err
+	}
+
+	cfg.Dependents = nil
+
+	for _, ref := range deps {
+		if n := len(cfg.Dependents); n > 0 && cfg.Dependents[n-1] == ref.ConfigID {
+			continue
+		}
+
+		cfg.Dependents = append(cfg.Dependents, ref.ConfigID)
+
+		dep, err := s.read(ref.ConfigID)
+		if err != nil {
+			return err
+		}
+
+		if _, err = r.Resolve(dep.ID, dep.Data); err != nil {
+			return errors.Wrapf(err, "config #%d refers to config #%d", dep.ID, cfg.ID)
+		}
+	}
+
+	return nil
+}
+
+// unreferenced returns ErrReferenced when other configs refer to config
+func (s *configs) unreferenced(id int64) error {
+	deps, err := s.referencesTo(id)
+	if err != nil || len(deps) == 0 {
+		return err
+	}
+
+	var ids = make([]string, 0, len(deps))
+
+	for i, ref := range deps {
+		if i > 0 && deps[i-1].ConfigID == ref.ConfigID {
+			continue
+		}
+
+		ids = append(ids, "#"+strconv.FormatInt(ref.ConfigID, 10))
+	}
+
+	return errors.Wrapf(ErrReferenced, "config #%d is referenced by %s", id, strings.Join(ids, ", "))
+}
@@ -1,9 +1,12 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/im-kulikov/simplinic-task/models"
 	"github.com/pkg/errors"
 )
@@ -15,33 +18,128 @@ type (
 		Version   int64           `json:"version"`
 		Tags      []string        `json:"tags" validate:"required" message:"tags could not be empty"`
 		Data      json.RawMessage `json:"data" validate:"required" message:"data could not be empty"`
+		Author    string          `json:"author,omitempty"`
+		CreatedAt time.Time       `json:"created_at"`
+
+		// stored in schemes table, see ReadBySlug / Rename
+		Slug string `sql:"-" json:"slug,omitempty"`
+
+		// filled only by full-text search:
+		Rank    float64  `sql:"-" json:"rank,omitempty"`
+		Matched []string `sql:"-" json:"matched,omitempty"`
+
+		// Stale is set when database is unavailable and last-known-good version is served
+		Stale bool `sql:"-" json:"-"`
 	}
 )
 
-func (s *schemes) Create(scheme *Scheme) error {
-	var model models.Scheme
+func (s *schemes) Create(ctx context.Context, scheme *Scheme) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return (&schemes{db: db}).create(scheme)
+	})
+}
+
+func (s *schemes) Read(ctx context.Context, id int64) (result *Scheme, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, err = (&schemes{db: db}).read(id)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *schemes) ReadBySlug(ctx context.Context, slug string) (result *Scheme, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, err = (&schemes{db: db}).readBySlug(slug)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *schemes) Rename(ctx context.Context, id int64, slug string) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return (&schemes{db: db}).rename(id, slug)
+	})
+}
+
+func (s *schemes) Update(ctx context.Context, scheme *Scheme) error {
+	return retryConflicts(ctx, s.db, func(db orm.DB) error {
+		return (&schemes{db: db}).update(scheme)
+	})
+}
+
+func (s *schemes) Delete(ctx context.Context, id int64) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return (&schemes{db: db}).delete(id)
+	})
+}
+
+func (s *schemes) Restore(ctx context.Context, id int64) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return (&schemes{db: db}).restore(id)
+	})
+}
+
+func (s *schemes) Search(ctx context.Context, req SearchRequest) (result []*Scheme, total int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, total, err = (&schemes{db: db}).search(req)
+		return err
+	})
+
+	return result, total, err
+}
+
+func (s *schemes) History(ctx context.Context, id int64, limit, offset int) (result []*Scheme, total int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, total, err = (&schemes{db: db}).history(id, limit, offset)
+		return err
+	})
+
+	return result, total, err
+}
+
+func (s *schemes) create(scheme *Scheme) error {
+	var model = models.Scheme{Slug: scheme.Slug}
+
+	if scheme.Slug != "" && !ValidSlug(scheme.Slug) {
+		return ErrInvalidSlug
+	}
 
 	if _, err := s.db.Model(&model).Insert(); err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugConflict
+		}
+
 		return errors.WithMessage(err, "could not create scheme")
 	}
 
+	if scheme.Slug != "" {
+		if err := s.alias(model.ID, scheme.Slug); err != nil {
+			return err
+		}
+	}
+
 	scheme.ID = model.ID
+	scheme.CreatedAt = time.Time{} // created_at of version always set by database
 
 	if _, err := s.db.Model(scheme).Insert(); err != nil {
 		return errors.WithMessage(err, "could not create scheme data")
 	}
 
-	return nil
+	return emit(s.db, "scheme", scheme.ID, "create", scheme)
 }
 
-func (s *schemes) Read(id int64) (*Scheme, error) {
+func (s *schemes) read(id int64) (*Scheme, error) {
 	var result Scheme
 
 	if err := s.db.Model(&result).
+		Column("sv.*").
+		ColumnExpr("s.slug").
 		Join("LEFT JOIN schemes s"). // LEFT JOIN configs c ON c.id = cv.config_id
 		JoinOn("s.id = sv.scheme_id").
 		Where("s.id = ? AND s.deleted_at ISNULL", id).
-		Order("sv.created_at DESC", "sv.version DESC").
+		Order("sv.version DESC"). // latest version, created_at is start time of transaction and could be out of order
 		Limit(1).
 		Select(); err != nil {
 		return nil, errors.Wrapf(err, "could not read scheme #%d", id)
@@ -50,7 +148,7 @@ func (s *schemes) Read(id int64) (*Scheme, error) {
 	return &result, nil
 }
 
-func (s *schemes) Update(scheme *Scheme) error {
+func (s *schemes) update(scheme *Scheme) error {
 	var id, version int64
 
 	// Example:
@@ -62,6 +160,15 @@ func (s *schemes) Update(scheme *Scheme) error {
 	//       AND s.deleted_at ISNULL
 	//  GROUP BY "sv"."scheme_id"
 
+	// lock scheme row, concurrent updates of scheme wait for this transaction:
+	if err := s.db.Model((*models.Scheme)(nil)).
+		Column("id").
+		Where("id = ?", scheme.ID).
+		For("UPDATE").
+		Select(pg.Scan(&id)); err != nil {
+		return errors.Wrapf(err, "could not lock scheme #%d", scheme.ID)
+	}
+
 	if err := s.db.Model((*Scheme)(nil)).
 		ColumnExpr("sv.scheme_id, MAX(sv.version)").
 		Join("LEFT JOIN schemes s").
@@ -76,30 +183,45 @@ func (s *schemes) Update(scheme *Scheme) error {
 
 	scheme.Version = version + 1
 
+	scheme.CreatedAt = time.Time{} // created_at of version always set by database
+
 	if _, err := s.db.Model(scheme).
 		Insert(); err != nil {
 		return errors.WithMessage(err, "can't create scheme")
 	}
 
-	return nil
+	return emit(s.db, "scheme", scheme.ID, "update", scheme)
 }
 
-func (s *schemes) Delete(id int64) error {
+func (s *schemes) delete(id int64) error {
 	if err := s.db.Delete(&models.Scheme{ID: id}); err != nil {
 		return errors.Wrapf(err, "can't remove scheme #%d", id)
 	}
 
-	return nil
+	return emit(s.db, "scheme", id, "delete", entityRef{ID: id})
+}
+
+// restore deleted scheme, returns pg.ErrNoRows when scheme not found or not deleted
+func (s *schemes) restore(id int64) error {
+	res, err := s.db.Exec("UPDATE schemes SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return errors.Wrapf(err, "can't restore scheme #%d", id)
+	} else if res.RowsAffected() == 0 {
+		return errors.Wrapf(pg.ErrNoRows, "can't restore scheme #%d", id)
+	}
+
+	return emit(s.db, "scheme", id, "restore", entityRef{ID: id})
 }
 
-func (s *schemes) Search(req SearchRequest) ([]*Scheme, error) {
+func (s *schemes) search(req SearchRequest) ([]*Scheme, int, error) {
 	var result []*Scheme
 
 	q := s.db.Model(&result).
+		Column("sv.*").
+		ColumnExpr("s.slug").
 		Join("LEFT JOIN schemes s").
 		JoinOn("s.id = sv.scheme_id").
-		Order("version DESC").
-		Group("scheme_id", "version")
+		Group("scheme_id", "version", "s.id")
 
 	if req.Version > 0 {
 		q.Where("version = ?", req.Version)
@@ -109,11 +231,68 @@ func (s *schemes) Search(req SearchRequest) ([]*Scheme, error) {
 		q.Where(`tags @> ?`, req.Tags) // tags @> '["b", "c"]' : filter tags, that have "b" and "c"
 	}
 
+	if req.Author != "" {
+		q.Where("sv.author = ?", req.Author)
+	}
+
+	timeRange(q, "s.created_at", req.CreatedFrom, req.CreatedTo)
+	timeRange(q, "sv.created_at", req.UpdatedFrom, req.UpdatedTo)
+
+	if req.Query != "" {
+		fullTextSearch(q, "sv", req.Query)
+	}
+
+	// full-text query matches heads of schemes, unless version is requested:
+	if req.Query != "" && req.Version == 0 {
+		q.Where("sv.version = (SELECT MAX(l.version) FROM scheme_versions l WHERE l.scheme_id = sv.scheme_id)")
+	}
+
+	if req.Filter != nil {
+		cond, params, err := compileFilter(req.Filter, schemeFilterColumns)
+		if err != nil {
+			return nil, 0, errors.WithMessage(err, "can't apply filter")
+		}
+
+		q.Where(cond, params...)
+	}
+
 	q.Where("deleted_at ISNULL")
+	q.Order("version DESC", "sv.scheme_id DESC")
+
+	paginate(q, req)
+
+	total, err := q.SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "can't find schemes by (version=%d | tags=%v | q=%q)", req.Version, req.Tags, req.Query)
+	}
+
+	return result, total, nil
+}
+
+// history returns versions of scheme, latest first
+func (s *schemes) history(id int64, limit, offset int) ([]*Scheme, int, error) {
+	var result []*Scheme
+
+	// scheme must exists and not be deleted:
+	latest, err := s.read(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q := s.db.Model(&result).
+		Where("sv.scheme_id = ?", id).
+		Order("sv.version DESC")
+
+	paginate(q, SearchRequest{Limit: limit, Offset: offset})
+
+	total, err := q.SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "can't read history of scheme #%d", id)
+	}
 
-	if err := q.Select(); err != nil {
-		return nil, errors.Wrapf(err, "can't find schemes by (version=%d | tags=%v)", req.Version, req.Tags)
+	for _, item := range result {
+		item.Slug = latest.Slug
 	}
 
-	return result, nil
+	return result, total, nil
 }
@@ -0,0 +1,339 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/im-kulikov/simplinic-task/schema"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Reveal is audit record of reading plaintext of secret fields of config,
+	// Actor is authenticated identity, ClaimedActor is name sent by client
+	Reveal struct {
+		tableName    struct{}  `sql:"config_reveals"`
+		ID           int64     `json:"id"`
+		ConfigID     int64     `json:"config_id"`
+		Version      int64     `json:"version"`
+		Actor        string    `json:"actor"`
+		ClaimedActor string    `json:"claimed_actor,omitempty"`
+		Reason       string    `json:"reason,omitempty"`
+		Address      string    `json:"address,omitempty"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+
+	// VersionCursor points to version of config, versions are ordered by config and version
+	VersionCursor struct {
+		ConfigID int64
+		Version  int64
+	}
+
+	// Secrets re-encrypts secret fields of stored versions of configs
+	Secrets interface {
+		// Reencrypt seals secret fields that are stored as is and rewraps values sealed
+		// by old keys in up to limit versions after cursor, returns cursor of last read
+		// version (zero when all versions are read) and count of changed versions.
+		// Secrets of webhooks are rewrapped with first batch (zero cursor) and counted too.
+		Reencrypt(ctx context.Context, after VersionCursor, limit int) (VersionCursor, int, error)
+	}
+
+	secretStore struct {
+		db   orm.DB
+		keys *secrets.Keyring
+	}
+)
+
+// NewSecretStore re-encrypts versions by keys of keyring, nil keyring does nothing
+func NewSecretStore(db *pg.DB, k *secrets.Keyring) Secrets {
+	return &secretStore{db: db, keys: k}
+}
+
+// Reveal returns version of config (audit.Version, latest when zero) with plaintext
+// of secret fields, reveal is written to audit log and outbox
+func (s *configs) Reveal(ctx context.Context, id int64, audit Reveal) (result *Config, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, err = s.with(db).reveal(id, audit)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *configs) reveal(id int64, audit Reveal) (*Config, error) {
+	cfg, err := s.readVersion(id, audit.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Data, err = s.keys.OpenData(cfg.Data); err != nil {
+		return nil, errors.Wrapf(err, "could not reveal config #%d", id)
+	}
+
+	audit.ID, audit.ConfigID, audit.Version, audit.CreatedAt = 0, cfg.ID, cfg.Version, time.Time{}
+
+	if _, err = s.db.Model(&audit).Returning("*").Insert(); err != nil {
+		return nil, errors.Wrapf(err, "could not write audit of config #%d", id)
+	}
+
+	return cfg, emit(s.db, "config", id, "reveal", audit)
+}
+
+// Unchanged compares data with opened latest version of config, plaintext is not returned
+// and reading is not audited
+func (s *configs) Unchanged(ctx context.Context, id int64, data json.RawMessage) (result bool, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result, err = s.with(db).unchanged(id, data)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *configs) unchanged(id int64, data json.RawMessage) (bool, error) {
+	cfg, err := s.read(id)
+	if err != nil {
+		return false, err
+	}
+
+	current, err := s.keys.OpenData(cfg.Data)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not open secrets of config #%d", id)
+	}
+
+	if data, err = secrets.Unmask(data, current); err != nil {
+		return false, err
+	}
+
+	var a, b interface{}
+
+	if err = json.Unmarshal(current, &a); err != nil {
+		return false, errors.Wrapf(err, "could not decode config #%d", id)
+	} else if json.Unmarshal(data, &b) != nil {
+		return false, nil
+	}
+
+	return reflect.DeepEqual(a, b), nil
+}
+
+// seal encrypts secret fields of scheme of config, masked fields keep values of previous data,
+// returns paths of secret fields. Secrets could not be written without keys, see secrets.ErrNoKeys.
+func (s *configs) seal(cfg *Config, previous json.RawMessage) ([]schema.Path, error) {
+	paths, err := (&schemes{db: s.db}).secrets(cfg.SchemeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Data, err = s.keys.SealData(cfg.Data, paths, previous); err != nil {
+		return nil, errors.Wrapf(err, "could not encrypt secrets of config #%d", cfg.ID)
+	}
+
+	return paths, nil
+}
+
+// secrets returns paths of secret fields of latest version of scheme, deleted scheme
+// still describes secrets of its configs, unknown scheme has no secrets
+func (s *schemes) secrets(id int64) ([]schema.Path, error) {
+	var data json.RawMessage
+
+	if _, err := s.db.QueryOne(pg.Scan(&data),
+		"SELECT data FROM scheme_versions WHERE scheme_id = ? ORDER BY version DESC LIMIT 1", id); err == pg.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read scheme #%d", id)
+	}
+
+	paths, err := schema.Secrets(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read secrets of scheme #%d", id)
+	}
+
+	return paths, nil
+}
+
+func (s *secretStore) Reencrypt(ctx context.Context, after VersionCursor, limit int) (next VersionCursor, count int, err error) {
+	// secrets could not be sealed without keys:
+	if s.keys == nil {
+		return VersionCursor{}, 0, nil
+	}
+
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		var (
+			hooks int
+			tx    = &secretStore{db: db, keys: s.keys}
+		)
+
+		// webhooks are few, so all of them are rewrapped at once:
+		if after == (VersionCursor{}) {
+			if hooks, err = tx.rewrapWebhooks(); err != nil {
+				return err
+			}
+		}
+
+		next, count, err = tx.reencrypt(after, limit)
+		count += hooks
+
+		return err
+	})
+
+	return next, count, err
+}
+
+func (s *secretStore) reencrypt(after VersionCursor, limit int) (VersionCursor, int, error) {
+	var (
+		count    int
+		versions []*Config
+	)
+
+	paths, err := s.secrets()
+	if err != nil {
+		return after, 0, err
+	} else if len(paths) == 0 && len(s.keys.Keys()) == 0 {
+		// nothing is stored as is and nothing is sealed by old keys:
+		return VersionCursor{}, 0, nil
+	}
+
+	// versions are locked, so concurrent reencrypt does not overwrite them:
+	if err := s.db.Model(&versions).
+		Column("cv.config_id", "cv.scheme_id", "cv.version", "cv.data").
+		Where("(cv.config_id, cv.version) > (?, ?)", after.ConfigID, after.Version).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return s.stale(q, paths), nil
+		}).
+		Order("cv.config_id", "cv.version").
+		Limit(limit).
+		For("UPDATE").
+		Select(); err != nil {
+		return after, 0, errors.Wrap(err, "could not read versions of configs")
+	}
+
+	for _, item := range versions {
+		data, changed, err := s.keys.Reseal(item.Data, paths[item.SchemeID])
+		if err != nil {
+			return after, count, errors.Wrapf(err, "could not reencrypt version %d of config #%d", item.Version, item.ID)
+		} else if !changed {
+			continue
+		}
+
+		if _, err = s.db.Exec("UPDATE config_versions SET data = ?::jsonb WHERE config_id = ? AND version = ?",
+			string(data), item.ID, item.Version); err != nil {
+			return after, count, errors.Wrapf(err, "could not store version %d of config #%d", item.Version, item.ID)
+		}
+
+		count++
+	}
+
+	if len(versions) < limit {
+		return VersionCursor{}, count, nil
+	}
+
+	last := versions[len(versions)-1]
+
+	return VersionCursor{ConfigID: last.ID, Version: last.Version}, count, nil
+}
+
+// rewrapWebhooks wraps data keys of secrets of webhooks sealed by old keys by primary key
+func (s *secretStore) rewrapWebhooks() (int, error) {
+	var (
+		count int
+		hooks []*Webhook
+	)
+
+	q := s.db.Model(&hooks).Column("id", "secret").Order("id ASC").For("UPDATE")
+
+	q.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+		for _, id := range s.keys.Keys() {
+			q.WhereOr("secret LIKE ?", "enc:v1:"+strings.Replace(id, "_", `\_`, -1)+":%")
+		}
+
+		return q, nil
+	})
+
+	if err := q.Select(); err != nil {
+		return 0, errors.Wrap(err, "could not read secrets of webhooks")
+	}
+
+	for _, hook := range hooks {
+		secret, changed, err := s.keys.Rewrap(hook.Secret)
+		if err != nil {
+			return count, errors.Wrapf(err, "could not reencrypt secret of webhook #%d", hook.ID)
+		} else if !changed {
+			continue
+		}
+
+		if _, err = s.db.Exec("UPDATE webhooks SET secret = ? WHERE id = ?", secret, hook.ID); err != nil {
+			return count, errors.Wrapf(err, "could not store secret of webhook #%d", hook.ID)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// secrets returns paths of secret fields of latest versions of schemes (deleted too)
+// that declare secrets
+func (s *secretStore) secrets() (map[int64][]schema.Path, error) {
+	var (
+		result = make(map[int64][]schema.Path)
+		latest []struct {
+			SchemeID int64
+			Data     json.RawMessage
+		}
+	)
+
+	if _, err := s.db.Query(&latest, `SELECT scheme_id, data FROM (
+		SELECT DISTINCT ON (scheme_id) scheme_id, data FROM scheme_versions ORDER BY scheme_id, version DESC
+	) sv WHERE data::text LIKE '%"x-secret"%' OR data::text LIKE '%"writeOnly"%'`); err != nil {
+		return nil, errors.Wrap(err, "could not read schemes with secrets")
+	}
+
+	for _, item := range latest {
+		paths, err := schema.Secrets(item.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read secrets of scheme #%d", item.SchemeID)
+		} else if len(paths) > 0 {
+			result[item.SchemeID] = paths
+		}
+	}
+
+	return result, nil
+}
+
+// stale selects versions that have values sealed by old keys or secret fields
+// stored as is, every version of scheme with wildcard path is selected
+func (s *secretStore) stale(q *orm.Query, paths map[int64][]schema.Path) *orm.Query {
+	// id of key could contain `_`, that is wildcard of LIKE:
+	for _, id := range s.keys.Keys() {
+		q.WhereOr("cv.data::text LIKE ?", `%"enc:v1:`+strings.Replace(id, "_", `\_`, -1)+`:%`)
+	}
+
+	for id, list := range paths {
+		var (
+			conds  []string
+			params = []interface{}{id}
+		)
+
+		for _, path := range list {
+			if path.Wildcard() {
+				conds = []string{"TRUE"}
+				params = params[:1]
+				break
+			}
+
+			// value exists and is not sealed, null of #>> is JSON null:
+			conds = append(conds, "(cv.data #> ?::text[] IS NOT NULL AND COALESCE(cv.data #>> ?::text[], '') NOT LIKE 'enc:v1:%')")
+			params = append(params, pg.Array([]string(path)), pg.Array([]string(path)))
+		}
+
+		q.WhereOr("cv.scheme_id = ? AND ("+strings.Join(conds, " OR ")+")", params...)
+	}
+
+	return q
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"regexp"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/simplinic-task/models"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidSlug when slug contains something except lowercase letters, digits and dashes
+	ErrInvalidSlug = errors.New("slug should contain only lowercase letters, digits and dashes")
+
+	// ErrSlugConflict when slug (or old alias) already used by another entity
+	ErrSlugConflict = errors.New("slug already used")
+
+	slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// ValidSlug checks that slug looks like `person` or `evgeniy-stage`
+func ValidSlug(slug string) bool {
+	return len(slug) <= 255 && slugRegexp.MatchString(slug)
+}
+
+// isUniqueViolation checks that postgres returns unique_violation error
+func isUniqueViolation(err error) bool {
+	perr, ok := errors.Cause(err).(pg.Error)
+	return ok && perr.Field('C') == "23505"
+}
+
+func (s *schemes) readBySlug(slug string) (*Scheme, error) {
+	var alias models.SchemeSlug
+
+	if err := s.db.Model(&alias).
+		Where("slug = ?", slug).
+		Select(); err != nil {
+		return nil, errors.Wrapf(err, "could not find scheme by slug %q", slug)
+	}
+
+	return s.read(alias.SchemeID)
+}
+
+func (s *schemes) rename(id int64, slug string) error {
+	if !ValidSlug(slug) {
+		return ErrInvalidSlug
+	}
+
+	// scheme must exists and not be deleted:
+	if _, err := s.read(id); err != nil {
+		return err
+	}
+
+	if err := s.alias(id, slug); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Model((*models.Scheme)(nil)).
+		Set("slug = ?", slug).
+		Where("id = ?", id).
+		Update(); err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugConflict
+		}
+
+		return errors.Wrapf(err, "could not rename scheme #%d", id)
+	}
+
+	return emit(s.db, "scheme", id, "rename", entityRef{ID: id, Slug: slug})
+}
+
+// alias stores slug of scheme, old slugs are kept and still resolve to the same scheme
+func (s *schemes) alias(id int64, slug string) error {
+	var alias = models.SchemeSlug{Slug: slug}
+
+	if err := s.db.Model(&alias).WherePK().Select(); err == nil {
+		if alias.SchemeID != id {
+			return ErrSlugConflict
+		}
+
+		return nil
+	} else if err != pg.ErrNoRows {
+		return errors.Wrapf(err, "could not check slug %q", slug)
+	}
+
+	alias.SchemeID = id
+
+	if _, err := s.db.Model(&alias).Insert(); err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugConflict
+		}
+
+		return errors.Wrapf(err, "could not store slug %q", slug)
+	}
+
+	return nil
+}
+
+func (s *configs) readBySlug(scheme, slug string) (*Config, error) {
+	var alias models.ConfigSlug
+
+	if err := s.db.Model(&alias).
+		Join("JOIN scheme_slugs ss").
+		JoinOn("ss.scheme_id = config_slug.scheme_id").
+		Where("ss.slug = ? AND config_slug.slug = ?", scheme, slug).
+		Select(); err != nil {
+		return nil, errors.Wrapf(err, "could not find config by slug %q", scheme+"/"+slug)
+	}
+
+	return s.read(alias.ConfigID)
+}
+
+func (s *configs) rename(id int64, slug string) error {
+	var (
+		err error
+		cfg *Config
+	)
+
+	if !ValidSlug(slug) {
+		return ErrInvalidSlug
+	}
+
+	// config must exists and not be deleted:
+	if cfg, err = s.read(id); err != nil {
+		return err
+	}
+
+	if err = s.alias(cfg.SchemeID, id, slug); err != nil {
+		return err
+	}
+
+	if _, err = s.db.Model((*models.Config)(nil)).
+		Set("slug = ?", slug).
+		Where("id = ?", id).
+		Update(); err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugConflict
+		}
+
+		return errors.Wrapf(err, "could not rename config #%d", id)
+	}
+
+	return emit(s.db, "config", id, "rename", entityRef{ID: id, SchemeID: cfg.SchemeID, Slug: slug})
+}
+
+// alias stores slug of config (unique in scheme), old slugs are kept and still resolve to the same config
+func (s *configs) alias(schemeID, id int64, slug string) error {
+	var alias = models.ConfigSlug{SchemeID: schemeID, Slug: slug}
+
+	if err := s.db.Model(&alias).WherePK().Select(); err == nil {
+		if alias.ConfigID != id {
+			return ErrSlugConflict
+		}
+
+		return nil
+	} else if err != pg.ErrNoRows {
+		return errors.Wrapf(err, "could not check slug %q", slug)
+	}
+
+	alias.ConfigID = id
+
+	if _, err := s.db.Model(&alias).Insert(); err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugConflict
+		}
+
+		return errors.Wrapf(err, "could not store slug %q", slug)
+	}
+
+	return nil
+}
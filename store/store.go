@@ -1,44 +1,88 @@
 package store
 
 import (
+	"context"
+	"encoding/json"
+	"time"
+
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/filter"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/secrets"
 )
 
 type (
 	SearchRequest struct {
-		Version int64    `json:"version"`
-		Tags    []string `json:"tags"`
+		Version  int64    `json:"version"`
+		Tags     []string `json:"tags"`
+		Query    string   `json:"q"`
+		SchemeID int64    `json:"scheme_id"` // used only for configs
+		Latest   bool     `json:"latest"`    // only latest versions, used only for configs, implied by query without version
+		Author   string   `json:"author"`
+
+		// CreatedFrom / CreatedTo filters by creation time of entity,
+		// UpdatedFrom / UpdatedTo filters by creation time of version.
+		CreatedFrom time.Time `json:"created_from"`
+		CreatedTo   time.Time `json:"created_to"`
+		UpdatedFrom time.Time `json:"updated_from"`
+		UpdatedTo   time.Time `json:"updated_to"`
+
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+
+		// Filter is parsed filter expression, see filter.Parse
+		Filter filter.Expr `json:"-"`
 	}
 
 	Schemes interface {
-		Create(scheme *Scheme) error
-		Read(id int64) (*Scheme, error)
-		Update(scheme *Scheme) error
-		Delete(id int64) error
-		Search(req SearchRequest) ([]*Scheme, error)
+		Create(ctx context.Context, scheme *Scheme) error
+		Read(ctx context.Context, id int64) (*Scheme, error)
+		ReadBySlug(ctx context.Context, slug string) (*Scheme, error)
+		Rename(ctx context.Context, id int64, slug string) error
+		Update(ctx context.Context, scheme *Scheme) error
+		Delete(ctx context.Context, id int64) error
+		Restore(ctx context.Context, id int64) error
+		Search(ctx context.Context, req SearchRequest) ([]*Scheme, int, error)
+		History(ctx context.Context, id int64, limit, offset int) ([]*Scheme, int, error)
 	}
 
 	Configs interface {
-		Create(cfg *Config) error
-		Read(id int64) (*Config, error)
-		Update(cfg *Config) error
-		Delete(id int64) error
-		Search(req SearchRequest) ([]*Config, error)
+		Create(ctx context.Context, cfg *Config) error
+		Read(ctx context.Context, id int64) (*Config, error)
+		ReadBySlug(ctx context.Context, scheme, slug string) (*Config, error)
+		Rename(ctx context.Context, id int64, slug string) error
+		Update(ctx context.Context, cfg *Config) error
+		Delete(ctx context.Context, id int64) error
+		Restore(ctx context.Context, id int64) error
+		Search(ctx context.Context, req SearchRequest) ([]*Config, int, error)
+		History(ctx context.Context, id int64, limit, offset int) ([]*Config, int, error)
+		Dependents(ctx context.Context, id int64) ([]*Reference, error)
+		Reveal(ctx context.Context, id int64, audit Reveal) (*Config, error)
+		// Unchanged compares data with latest version of config by plaintext of
+		// secret fields, masked values are the same as current values
+		Unchanged(ctx context.Context, id int64, data json.RawMessage) (bool, error)
 	}
 
+	// schemes / configs works with *pg.DB or with *pg.Tx,
+	// every method runs in transaction, see inTransaction:
 	schemes struct {
-		db *pg.DB
+		db orm.DB
 	}
 
 	configs struct {
-		db *pg.DB
+		db   orm.DB
+		keys *secrets.Keyring      // nil when secrets are stored as is
+		vars interpolate.Variables // referenced as ${env.name}, see references
 	}
 )
 
 var Module = module.Module{
 	{Constructor: NewSchemeStore},
 	{Constructor: NewConfigStore},
+	{Constructor: NewTransactions},
+	{Constructor: NewChangeStore},
 }
 
 func NewSchemeStore(db *pg.DB) Schemes {
@@ -48,3 +92,50 @@ func NewSchemeStore(db *pg.DB) Schemes {
 func NewConfigStore(db *pg.DB) Configs {
 	return &configs{db: db}
 }
+
+// fullTextSearch filters versions by full-text query over JSON string values,
+// ranks them by relevance and fills list of matched top-level fields.
+func fullTextSearch(q *orm.Query, alias, text string) {
+	var table = pg.F(alias)
+
+	// Example:
+	//   SELECT sv.*,
+	//          ts_rank(sv.search, plainto_tsquery('simple', 'person')) AS rank,
+	//          (SELECT jsonb_agg(e.key ORDER BY e.key)
+	//             FROM jsonb_each(sv.data) e
+	//            WHERE to_tsvector('simple', e.value) @@ plainto_tsquery('simple', 'person')) AS matched
+	//     FROM scheme_versions sv
+	//    WHERE sv.search @@ plainto_tsquery('simple', 'person')
+	// ORDER BY rank DESC
+
+	q.ColumnExpr("ts_rank(?.search, plainto_tsquery('simple', ?)) AS rank", table, text).
+		ColumnExpr(`CASE WHEN jsonb_typeof(?0.data) = 'object' THEN (
+			SELECT jsonb_agg(e.key ORDER BY e.key)
+			  FROM jsonb_each(?0.data) e
+			 WHERE to_tsvector('simple', e.value) @@ plainto_tsquery('simple', ?1)
+		) END AS matched`, table, text).
+		Where("?.search @@ plainto_tsquery('simple', ?)", table, text).
+		OrderExpr("rank DESC")
+}
+
+// timeRange filters column by [from, to), zero time means no bound
+func timeRange(q *orm.Query, column string, from, to time.Time) {
+	if !from.IsZero() {
+		q.Where("? >= ?", pg.F(column), from)
+	}
+
+	if !to.IsZero() {
+		q.Where("? < ?", pg.F(column), to)
+	}
+}
+
+// paginate applies limit and offset, zero limit means no limit
+func paginate(q *orm.Query, req SearchRequest) {
+	if req.Limit > 0 {
+		q.Limit(req.Limit)
+	}
+
+	if req.Offset > 0 {
+		q.Offset(req.Offset)
+	}
+}
@@ -1,9 +1,17 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-pg/pg"
+	pgorm "github.com/go-pg/pg/orm"
 	"github.com/im-kulikov/helium"
 	"github.com/im-kulikov/helium/grace"
 	"github.com/im-kulikov/helium/logger"
@@ -11,11 +19,42 @@ import (
 	"github.com/im-kulikov/helium/orm"
 	"github.com/im-kulikov/helium/redis"
 	"github.com/im-kulikov/helium/settings"
+	"github.com/im-kulikov/simplinic-task/filter"
+	"github.com/im-kulikov/simplinic-task/interpolate"
 	"github.com/im-kulikov/simplinic-task/models"
+	"github.com/im-kulikov/simplinic-task/secrets"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
+// pgError is error of postgres with SQLSTATE code and name of constraint
+type pgError struct {
+	code       string
+	constraint string
+}
+
+func (e pgError) Error() string {
+	return "ERROR #" + e.code
+}
+
+func (e pgError) Field(field byte) string {
+	switch field {
+	case 'C':
+		return e.code
+	case 'n':
+		return e.constraint
+	}
+
+	return ""
+}
+
+func (e pgError) IntegrityViolation() bool {
+	return strings.HasPrefix(e.code, "23")
+}
+
 var testModule = module.Module{}.Append(
 	grace.Module,
 	settings.Module,
@@ -25,7 +64,12 @@ var testModule = module.Module{}.Append(
 )
 
 var _ = Describe("Store Suite", func() {
-	var db *pg.DB
+	var (
+		db      *pg.DB
+		cache   *Cache
+		breaker *Breaker
+		ctx     = context.Background()
+	)
 
 	BeforeSuite(func() {
 		var err error
@@ -35,8 +79,10 @@ var _ = Describe("Store Suite", func() {
 		}, testModule)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(h.Invoke(func(pdb *pg.DB) {
+		Expect(h.Invoke(func(pdb *pg.DB, rc *redis.Client, v *viper.Viper, l *zap.Logger) {
 			db = pdb
+			cache = NewCache(v, rc, l)
+			breaker = NewBreaker(v, l)
 
 			// Cleanup all tables...
 			_, err := db.Exec("TRUNCATE schemes RESTART IDENTITY CASCADE;")
@@ -45,8 +91,10 @@ var _ = Describe("Store Suite", func() {
 	})
 
 	AfterSuite(func() {
-
-		Expect(db.Close()).NotTo(HaveOccurred())
+		// BeforeSuite could fail before database is opened:
+		if db != nil {
+			Expect(db.Close()).NotTo(HaveOccurred())
+		}
 	})
 
 	Context("try CRUD+S of schemes", func() {
@@ -66,7 +114,7 @@ var _ = Describe("Store Suite", func() {
 		})
 
 		It("should create scheme without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
 			var items []*models.SchemeVersion
@@ -82,20 +130,20 @@ var _ = Describe("Store Suite", func() {
 		})
 
 		It("should read created scheme without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
-			item, err := s.Read(fixture.ID)
+			item, err := s.Read(ctx, fixture.ID)
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(*item).To(Equal(fixture))
 		})
 
 		It("should update created scheme without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
-			err = s.Update(&fixture)
+			err = s.Update(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
 			var items []*Scheme
@@ -112,13 +160,13 @@ var _ = Describe("Store Suite", func() {
 		})
 
 		It("should delete created scheme without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
-			err = s.Delete(fixture.ID)
+			err = s.Delete(ctx, fixture.ID)
 			Expect(err).NotTo(HaveOccurred())
 
-			item, err := s.Read(fixture.ID)
+			item, err := s.Read(ctx, fixture.ID)
 			Expect(err).To(HaveOccurred()) // not found
 			Expect(item).To(BeNil())
 		})
@@ -142,13 +190,13 @@ var _ = Describe("Store Suite", func() {
 
 			for _, item := range fixtures {
 				item.Version = 1
-				err := s.Create(&item)
+				err := s.Create(ctx, &item)
 				Expect(err).NotTo(HaveOccurred())
 
 				ids = append(ids, item.ID)
 			}
 
-			items, err := s.Search(SearchRequest{
+			items, _, err := s.Search(ctx, SearchRequest{
 				Tags: []string{"c1"},
 			})
 
@@ -157,10 +205,10 @@ var _ = Describe("Store Suite", func() {
 
 			// Must ignore deleted schemes:
 			for i, id := range ids {
-				err = s.Delete(id)
+				err = s.Delete(ctx, id)
 				Expect(err).NotTo(HaveOccurred())
 
-				items, err := s.Search(SearchRequest{
+				items, _, err := s.Search(ctx, SearchRequest{
 					Tags: []string{"c1"},
 				})
 
@@ -168,6 +216,73 @@ var _ = Describe("Store Suite", func() {
 				Expect(items).To(HaveLen(len(fixtures) - 1 - i))
 			}
 		})
+
+		It("should resolve scheme by current slug and old aliases", func() {
+			fixture.Slug = "person"
+			err := s.Create(ctx, &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			item, err := s.ReadBySlug(ctx, "person")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(item.ID).To(Equal(fixture.ID))
+			Expect(item.Slug).To(Equal("person"))
+
+			err = s.Rename(ctx, fixture.ID, "human")
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, slug := range []string{"person", "human"} {
+				item, err = s.ReadBySlug(ctx, slug)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(item.ID).To(Equal(fixture.ID))
+				Expect(item.Slug).To(Equal("human"))
+			}
+
+			other := Scheme{Slug: "person", Tags: []string{"a"}, Data: json.RawMessage(`{}`)}
+			err = s.Create(ctx, &other)
+			Expect(err).To(Equal(ErrSlugConflict))
+
+			other.Slug = "Not A Slug"
+			err = s.Create(ctx, &other)
+			Expect(err).To(Equal(ErrInvalidSlug))
+
+			_, err = s.ReadBySlug(ctx, "unknown")
+			Expect(errors.Cause(err)).To(Equal(pg.ErrNoRows))
+		})
+
+		It("should search schemes by full-text query and rank title higher", func() {
+			fixtures := []Scheme{
+				{
+					Tags: []string{"fts"},
+					Data: json.RawMessage(`{"type": "object", "comment": "kangaroo"}`),
+				},
+
+				{
+					Tags: []string{"fts"},
+					Data: json.RawMessage(`{"title": "Kangaroo", "type": "object"}`),
+				},
+
+				{
+					Tags: []string{"fts"},
+					Data: json.RawMessage(`{"title": "Person", "type": "object"}`),
+				},
+			}
+
+			for _, item := range fixtures {
+				item.Version = 1
+				err := s.Create(ctx, &item)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			items, _, err := s.Search(ctx, SearchRequest{
+				Query: "kangaroo",
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(2))
+			Expect(items[0].Rank).To(BeNumerically(">=", items[1].Rank))
+			Expect(items[0].Matched).To(ConsistOf("title"))
+			Expect(items[1].Matched).To(ConsistOf("comment"))
+		})
 	})
 
 	Context("try CRUD+S of configs", func() {
@@ -185,7 +300,7 @@ var _ = Describe("Store Suite", func() {
 				Data:    json.RawMessage(`{"hello": "world"}`),
 			}
 
-			err := NewSchemeStore(db).Create(&scheme)
+			err := NewSchemeStore(db).Create(ctx, &scheme)
 			Expect(err).NotTo(HaveOccurred())
 
 			fixture = Config{
@@ -199,7 +314,7 @@ var _ = Describe("Store Suite", func() {
 		})
 
 		It("should create config without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
 			var items []*Config
@@ -214,10 +329,10 @@ var _ = Describe("Store Suite", func() {
 		})
 
 		It("should read created config without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
-			item, err := s.Read(fixture.ID)
+			item, err := s.Read(ctx, fixture.ID)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(item.Version).To(BeEquivalentTo(fixture.Version))
@@ -225,11 +340,271 @@ var _ = Describe("Store Suite", func() {
 			Expect(item.Data).To(BeEquivalentTo(fixture.Data))
 		})
 
+		It("should search only latest versions of configs", func() {
+			fixture.Tags = []string{"latest-a"}
+			err := s.Create(ctx, &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			fixture.Tags = []string{"latest-b"}
+			err = s.Update(ctx, &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			items, _, err := s.Search(ctx, SearchRequest{Tags: []string{"latest-a"}, Latest: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(BeEmpty())
+
+			items, _, err = s.Search(ctx, SearchRequest{SchemeID: scheme.ID, Latest: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Version).To(BeEquivalentTo(2))
+		})
+
+		It("should validate references and report dependents", func() {
+			var target, dependent Config
+
+			target, dependent = fixture, fixture
+			target.Data = json.RawMessage(`{"db": {"host": "db.local"}}`)
+
+			err := s.Create(ctx, &target)
+			Expect(err).NotTo(HaveOccurred())
+
+			dependent.Data = json.RawMessage(fmt.Sprintf(`{"host": "${config:%d#/db/host}"}`, target.ID))
+			err = s.Create(ctx, &dependent)
+			Expect(err).NotTo(HaveOccurred())
+
+			missing := fixture
+			missing.Data = json.RawMessage(fmt.Sprintf(`{"user": "${config:%d#/db/user}"}`, target.ID))
+			err = s.Create(ctx, &missing)
+			Expect(errors.Cause(err)).To(Equal(interpolate.ErrInvalidReference))
+
+			// every write path checks variables, not only API:
+			variable := fixture
+			variable.Data = json.RawMessage(`{"region": "${env.region}"}`)
+			err = NewTransactions(db).RunInTransaction(ctx, func(_ Schemes, c Configs) error {
+				return c.Create(ctx, &variable)
+			})
+			Expect(errors.Cause(err)).To(Equal(interpolate.ErrInvalidReference))
+
+			err = (&configs{db: db, vars: interpolate.Variables{"region": "eu"}}).Create(ctx, &variable)
+			Expect(err).NotTo(HaveOccurred())
+
+			target.Data = json.RawMessage(fmt.Sprintf(`{"db": {"host": "${config:%d#/host}"}}`, dependent.ID))
+			err = s.Update(ctx, &target)
+			Expect(errors.Cause(err)).To(Equal(interpolate.ErrCycle))
+
+			target.Data = json.RawMessage(`{"db": {"address": "db.local"}}`)
+			err = s.Update(ctx, &target)
+			Expect(errors.Cause(err)).To(Equal(interpolate.ErrInvalidReference))
+
+			target.Data = json.RawMessage(`{"db": {"host": "db2.local"}}`)
+			err = s.Update(ctx, &target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(target.Dependents).To(Equal([]int64{dependent.ID}))
+
+			refs, err := s.Dependents(ctx, target.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(refs).To(Equal([]*Reference{{ConfigID: dependent.ID, TargetID: target.ID, Pointer: "/db/host"}}))
+
+			err = s.Delete(ctx, target.ID)
+			Expect(errors.Cause(err)).To(Equal(ErrReferenced))
+
+			data, err := NewResolver(ctx, s, nil).Resolve(dependent.ID, dependent.Data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{"host": "db2.local"}`))
+		})
+
+		It("should encrypt secrets, mask reads and reveal them with audit", func() {
+			var (
+				old, key = make([]byte, 32), make([]byte, 32)
+				secret   Scheme
+				raw      json.RawMessage
+				reveals  int
+			)
+
+			key[0] = 1
+
+			secret = Scheme{Data: json.RawMessage(`{"type": "object", "properties": {"password": {"type": "string", "x-secret": true}}}`)}
+			Expect(NewSchemeStore(db).Create(ctx, &secret)).To(Succeed())
+
+			first, err := secrets.New("", map[string][]byte{"old": old})
+			Expect(err).NotTo(HaveOccurred())
+
+			cfg := Config{SchemeID: secret.ID, Tags: []string{"secret"}, Data: json.RawMessage(`{"user": "admin", "password": "qwerty"}`)}
+			Expect((&configs{db: db, keys: first}).Create(ctx, &cfg)).To(Succeed())
+			Expect(cfg.Data).To(MatchJSON(`{"user": "admin", "password": "********"}`))
+
+			_, err = db.QueryOne(pg.Scan(&raw), "SELECT data FROM config_versions WHERE config_id = ?", cfg.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(raw)).NotTo(ContainSubstring("qwerty"))
+
+			s = &configs{db: db, keys: first}
+
+			read, err := s.Read(ctx, cfg.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read.Data).To(MatchJSON(`{"user": "admin", "password": "********"}`))
+
+			// masked secret keeps previous value:
+			read.Data = json.RawMessage(`{"user": "root", "password": "********"}`)
+			Expect(s.Update(ctx, read)).To(Succeed())
+
+			// rotation of keys:
+			second, err := secrets.New("new", map[string][]byte{"old": old, "new": key})
+			Expect(err).NotTo(HaveOccurred())
+
+			var (
+				count  int
+				cursor VersionCursor
+			)
+
+			for {
+				var n int
+
+				cursor, n, err = NewSecretStore(db, second).Reencrypt(ctx, cursor, 1)
+				Expect(err).NotTo(HaveOccurred())
+
+				if count += n; cursor == (VersionCursor{}) {
+					break
+				}
+			}
+
+			Expect(count).To(BeNumerically(">=", 2))
+
+			_, err = (&configs{db: db, keys: first}).Reveal(ctx, cfg.ID, Reveal{Actor: "test"})
+			Expect(errors.Cause(err)).To(Equal(secrets.ErrUnknownKey))
+
+			revealed, err := (&configs{db: db, keys: second}).Reveal(ctx, cfg.ID, Reveal{Actor: "test", Reason: "check"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revealed.Version).To(BeEquivalentTo(2))
+			Expect(revealed.Data).To(MatchJSON(`{"user": "root", "password": "qwerty"}`))
+
+			revealed, err = (&configs{db: db, keys: second}).Reveal(ctx, cfg.ID, Reveal{Actor: "test", Version: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revealed.Version).To(BeEquivalentTo(1))
+			Expect(revealed.Data).To(MatchJSON(`{"user": "admin", "password": "qwerty"}`))
+
+			_, err = db.QueryOne(pg.Scan(&reveals), "SELECT COUNT(*) FROM config_reveals WHERE config_id = ? AND actor = 'test'", cfg.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reveals).To(Equal(2))
+
+			for data, expect := range map[string]bool{
+				`{"user": "root", "password": "qwerty"}`:    true,
+				`{"password": "********", "user": "root"}`:  true,
+				`{"user": "root", "password": "changed"}`:   false,
+				`{"user": "admin", "password": "********"}`: false,
+			} {
+				same, err := (&configs{db: db, keys: second}).Unchanged(ctx, cfg.ID, json.RawMessage(data))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(same).To(Equal(expect), data)
+			}
+
+			created := Config{SchemeID: secret.ID, Tags: []string{"secret"}, Data: json.RawMessage(`{"password": "********"}`)}
+			err = (&configs{db: db, keys: second}).Create(ctx, &created)
+			Expect(errors.Cause(err)).To(Equal(secrets.ErrInvalidSecret))
+		})
+
+		It("should seal secrets stored as is and rewrap only versions with old keys", func() {
+			var (
+				raw    = make(map[int64]string)
+				secret = Scheme{Data: json.RawMessage(`{"type": "object", "properties": {"password": {"type": "string", "x-secret": true}}}`)}
+			)
+
+			first, err := secrets.New("", map[string][]byte{"old_1": make([]byte, 32)})
+			Expect(err).NotTo(HaveOccurred())
+
+			second, err := secrets.New("new", map[string][]byte{"old_1": make([]byte, 32), "new": append(make([]byte, 31), 1)})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(NewSchemeStore(db).Create(ctx, &secret)).To(Succeed())
+
+			create := func(keys *secrets.Keyring, data string) int64 {
+				cfg := Config{SchemeID: secret.ID, Tags: []string{"secret"}, Data: json.RawMessage(data)}
+				Expect((&configs{db: db, keys: keys}).Create(ctx, &cfg)).To(Succeed())
+
+				return cfg.ID
+			}
+
+			read := func() {
+				var rows []struct {
+					ConfigID int64
+					Data     string
+				}
+
+				_, err := db.Query(&rows, "SELECT config_id, data::text FROM config_versions WHERE scheme_id = ?", secret.ID)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, row := range rows {
+					raw[row.ConfigID] = row.Data
+				}
+			}
+
+			sealed, current, plain := create(first, `{"password": "a"}`), create(second, `{"password": "b"}`), create(first, `{"user": "c"}`)
+
+			// secret field of scheme is stored as is:
+			_, err = db.Exec(`UPDATE config_versions SET data = '{"user": "c", "password": "c"}' WHERE config_id = ?`, plain)
+			Expect(err).NotTo(HaveOccurred())
+
+			// single key seals secrets stored as is, sealed values are kept:
+			_, count, err := NewSecretStore(db, first).Reencrypt(ctx, VersionCursor{ConfigID: sealed - 1}, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(1))
+
+			read()
+			before := raw[current]
+			Expect(raw[plain]).To(ContainSubstring("enc:v1:old_1:"))
+
+			_, count, err = NewSecretStore(db, second).Reencrypt(ctx, VersionCursor{ConfigID: sealed - 1}, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(2))
+
+			read()
+			Expect(raw[sealed]).To(ContainSubstring("enc:v1:new:"))
+			Expect(raw[plain]).To(ContainSubstring("enc:v1:new:"))
+			Expect(raw[current]).To(Equal(before))
+
+			// secrets of webhooks are rewrapped with first batch:
+			hook := Webhook{URL: "http://localhost/hook", Secret: "hook"}
+			Expect(NewWebhookStore(db, first).Create(ctx, &hook)).To(Succeed())
+
+			_, _, err = NewSecretStore(db, second).Reencrypt(ctx, VersionCursor{}, 100)
+			Expect(err).NotTo(HaveOccurred())
+
+			var stored string
+			_, err = db.QueryOne(pg.Scan(&stored), "SELECT secret FROM webhooks WHERE id = ?", hook.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored).To(HavePrefix("enc:v1:new:"))
+		})
+
+		It("should mask secrets stored as is and refuse secrets without keys", func() {
+			secret := Scheme{Data: json.RawMessage(`{"type": "object", "properties": {"password": {"type": "string", "x-secret": true}}}`)}
+			Expect(NewSchemeStore(db).Create(ctx, &secret)).To(Succeed())
+
+			cfg := Config{SchemeID: secret.ID, Tags: []string{"secret"}, Data: json.RawMessage(`{"user": "admin", "password": "qwerty"}`)}
+			err := NewConfigStore(db).Create(ctx, &cfg)
+			Expect(errors.Cause(err)).To(Equal(secrets.ErrNoKeys))
+
+			// config without values of secret fields is stored:
+			cfg.Data = json.RawMessage(`{"user": "admin"}`)
+			Expect(NewConfigStore(db).Create(ctx, &cfg)).To(Succeed())
+
+			// secret stored as is, e.g. before scheme declared it:
+			_, err = db.Exec(`UPDATE config_versions SET data = '{"user": "admin", "password": "qwerty"}' WHERE config_id = ?`, cfg.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			read, err := NewConfigStore(db).Read(ctx, cfg.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read.Data).To(MatchJSON(`{"user": "admin", "password": "********"}`))
+
+			list, _, err := NewConfigStore(db).History(ctx, cfg.ID, 0, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(HaveLen(1))
+			Expect(list[0].Data).To(MatchJSON(`{"user": "admin", "password": "********"}`))
+		})
+
 		It("should update created config without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
-			err = s.Update(&fixture)
+			err = s.Update(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
 			var items []*Config
@@ -249,13 +624,13 @@ var _ = Describe("Store Suite", func() {
 		})
 
 		It("should delete created config without errors", func() {
-			err := s.Create(&fixture)
+			err := s.Create(ctx, &fixture)
 			Expect(err).NotTo(HaveOccurred())
 
-			err = s.Delete(fixture.ID)
+			err = s.Delete(ctx, fixture.ID)
 			Expect(err).NotTo(HaveOccurred())
 
-			item, err := s.Read(fixture.ID)
+			item, err := s.Read(ctx, fixture.ID)
 			Expect(err).To(HaveOccurred()) // not found
 			Expect(item).To(BeNil())
 		})
@@ -270,13 +645,13 @@ var _ = Describe("Store Suite", func() {
 			var ids []int64
 
 			for _, item := range fixtures {
-				err := s.Create(item)
+				err := s.Create(ctx, item)
 				Expect(err).NotTo(HaveOccurred())
 
 				ids = append(ids, item.ID)
 			}
 
-			items, err := s.Search(SearchRequest{
+			items, _, err := s.Search(ctx, SearchRequest{
 				Tags: []string{"c2"},
 			})
 
@@ -285,10 +660,10 @@ var _ = Describe("Store Suite", func() {
 
 			// Must ignore deleted schemes:
 			for i, id := range ids {
-				err = s.Delete(id)
+				err = s.Delete(ctx, id)
 				Expect(err).NotTo(HaveOccurred())
 
-				items, err := s.Search(SearchRequest{
+				items, _, err := s.Search(ctx, SearchRequest{
 					Tags: []string{"c2"},
 				})
 
@@ -297,5 +672,671 @@ var _ = Describe("Store Suite", func() {
 			}
 		})
 
+		It("should search configs by full-text query", func() {
+			fixtures := []*Config{
+				{SchemeID: scheme.ID, Tags: []string{"fts"}, Data: json.RawMessage(`{"host": "wombat.local"}`)},
+				{SchemeID: scheme.ID, Tags: []string{"fts"}, Data: json.RawMessage(`{"host": "example.local"}`)},
+			}
+
+			for _, item := range fixtures {
+				err := s.Create(ctx, item)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			items, _, err := s.Search(ctx, SearchRequest{
+				Query: "wombat.local",
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].ID).To(Equal(fixtures[0].ID))
+			Expect(items[0].Matched).To(ConsistOf("host"))
+
+			// only latest version is matched:
+			fixtures[0].Data = json.RawMessage(`{"host": "wombat.local", "port": 80}`)
+			Expect(s.Update(ctx, fixtures[0])).To(Succeed())
+
+			items, _, err = s.Search(ctx, SearchRequest{Query: "wombat.local"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Version).To(BeEquivalentTo(2))
+
+			items, _, err = s.Search(ctx, SearchRequest{Query: "wombat.local", Version: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Version).To(BeEquivalentTo(1))
+		})
+
+		It("should resolve config by slug unique in scheme", func() {
+			schemes := NewSchemeStore(db)
+			err := schemes.Rename(ctx, scheme.ID, fmt.Sprintf("scheme-%d", scheme.ID))
+			Expect(err).NotTo(HaveOccurred())
+
+			fixture.Slug = "evgeniy-stage"
+			err = s.Create(ctx, &fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			item, err := s.ReadBySlug(ctx, fmt.Sprintf("scheme-%d", scheme.ID), "evgeniy-stage")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(item.ID).To(Equal(fixture.ID))
+			Expect(item.Slug).To(Equal("evgeniy-stage"))
+
+			err = s.Rename(ctx, fixture.ID, "evgeniy-prod")
+			Expect(err).NotTo(HaveOccurred())
+
+			item, err = s.ReadBySlug(ctx, fmt.Sprintf("scheme-%d", scheme.ID), "evgeniy-stage")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(item.ID).To(Equal(fixture.ID))
+			Expect(item.Slug).To(Equal("evgeniy-prod"))
+
+			other := Config{SchemeID: scheme.ID, Slug: "evgeniy-stage", Tags: []string{"a"}, Data: json.RawMessage(`{}`)}
+			err = s.Create(ctx, &other)
+			Expect(err).To(Equal(ErrSlugConflict))
+		})
+
+		It("should search configs of scheme with pagination", func() {
+			other := Scheme{Tags: []string{"other"}, Data: json.RawMessage(`{}`)}
+			err := NewSchemeStore(db).Create(ctx, &other)
+			Expect(err).NotTo(HaveOccurred())
+
+			fixtures := []*Config{
+				{SchemeID: scheme.ID, Tags: []string{"page"}, Author: "alice"},
+				{SchemeID: scheme.ID, Tags: []string{"page"}, Author: "bob"},
+				{SchemeID: scheme.ID, Tags: []string{"page"}, Author: "alice"},
+				{SchemeID: other.ID, Tags: []string{"page"}, Author: "alice"},
+			}
+
+			for _, item := range fixtures {
+				item.Data = json.RawMessage(`{}`)
+				err := s.Create(ctx, item)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			items, total, err := s.Search(ctx, SearchRequest{SchemeID: scheme.ID, Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(3))
+			Expect(items).To(HaveLen(2))
+			Expect(items[0].ID).To(Equal(fixtures[2].ID))
+			Expect(items[1].ID).To(Equal(fixtures[1].ID))
+
+			items, total, err = s.Search(ctx, SearchRequest{SchemeID: scheme.ID, Limit: 2, Offset: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(3))
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].ID).To(Equal(fixtures[0].ID))
+
+			items, total, err = s.Search(ctx, SearchRequest{SchemeID: scheme.ID, Author: "alice"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(2))
+			Expect(items).To(HaveLen(2))
+
+			items, _, err = s.Search(ctx, SearchRequest{
+				SchemeID:    scheme.ID,
+				CreatedFrom: fixtures[0].CreatedAt.Add(-time.Minute),
+				UpdatedTo:   fixtures[0].CreatedAt.Add(time.Minute),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(3))
+
+			items, _, err = s.Search(ctx, SearchRequest{
+				SchemeID:  scheme.ID,
+				CreatedTo: fixtures[0].CreatedAt.Add(-time.Minute),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(BeEmpty())
+		})
+
+		It("should search configs by filter expression", func() {
+			fixtures := []*Config{
+				{SchemeID: scheme.ID, Tags: []string{"filter", "prod"}, Data: json.RawMessage(`{"replicas": 5}`)},
+				{SchemeID: scheme.ID, Tags: []string{"filter", "prod"}, Data: json.RawMessage(`{"replicas": 1}`)},
+				{SchemeID: scheme.ID, Tags: []string{"filter", "dev"}, Data: json.RawMessage(`{"replicas": 5}`)},
+			}
+
+			for _, item := range fixtures {
+				err := s.Create(ctx, item)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			expr, err := filter.Parse(fmt.Sprintf(
+				`scheme_id = %d AND tags has "prod" AND data.replicas >= 3 AND created_at > 2018-01-01`,
+				scheme.ID))
+			Expect(err).NotTo(HaveOccurred())
+
+			items, _, err := s.Search(ctx, SearchRequest{Filter: expr})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].ID).To(Equal(fixtures[0].ID))
+
+			expr, err = filter.Parse(`tags has "filter" AND version = "1"`)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = s.Search(ctx, SearchRequest{Filter: expr})
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Cause(err)).To(BeAssignableToTypeOf(&filter.Error{}))
+		})
+
+	})
+
+	Context("transactions", func() {
+		It("should not create scheme with cancelled context", func() {
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			scheme := Scheme{
+				Tags: []string{"cancelled"},
+				Data: json.RawMessage(`{}`),
+				Slug: "cancelled-scheme",
+			}
+
+			err := NewSchemeStore(db).Create(cctx, &scheme)
+			Expect(errors.Cause(err)).To(Equal(context.Canceled))
+
+			_, err = NewSchemeStore(db).ReadBySlug(ctx, "cancelled-scheme")
+			Expect(errors.Cause(err)).To(Equal(pg.ErrNoRows))
+		})
+
+		It("should cancel running statement when deadline exceeded", func() {
+			tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+
+			err := runInTransaction(tctx, db, func(tx *pg.Tx) error {
+				_, err := tx.Exec("SELECT pg_sleep(5)")
+				return err
+			})
+			Expect(errors.Cause(err)).To(Equal(context.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("should roll back all operations when one of them fails", func() {
+			var scheme = Scheme{
+				Tags: []string{"tx"},
+				Data: json.RawMessage(`{}`),
+				Slug: "tx-rollback",
+			}
+
+			err := NewTransactions(db).RunInTransaction(ctx, func(s Schemes, c Configs) error {
+				if err := s.Create(ctx, &scheme); err != nil {
+					return err
+				}
+
+				return c.Update(ctx, &Config{ID: 10000000, Tags: []string{"tx"}, Data: json.RawMessage(`{}`)})
+			})
+			Expect(err).To(HaveOccurred())
+
+			_, err = NewSchemeStore(db).Read(ctx, scheme.ID)
+			Expect(errors.Cause(err)).To(Equal(pg.ErrNoRows))
+		})
+	})
+
+	Context("concurrent updates", func() {
+		It("should allocate unique versions when many writers update one config", func() {
+			const writers = 50
+
+			var (
+				wg       sync.WaitGroup
+				errs     = make(chan error, writers)
+				versions = make(chan int64, writers)
+				scheme   = Scheme{Tags: []string{"stress"}, Data: json.RawMessage(`{}`)}
+				config   = Config{Tags: []string{"stress"}, Data: json.RawMessage(`{}`)}
+			)
+
+			err := NewSchemeStore(db).Create(ctx, &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			config.SchemeID = scheme.ID
+
+			err = NewConfigStore(db).Create(ctx, &config)
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					item := &Config{
+						ID:   config.ID,
+						Tags: []string{"stress"},
+						Data: json.RawMessage(fmt.Sprintf(`{"writer": %d}`, i)),
+					}
+
+					if err := NewConfigStore(db).Update(ctx, item); err != nil {
+						errs <- err
+						return
+					}
+
+					versions <- item.Version
+				}(i)
+			}
+
+			wg.Wait()
+			close(errs)
+			close(versions)
+
+			for err := range errs {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			var seen = make(map[int64]struct{}, writers)
+			for version := range versions {
+				Expect(seen).NotTo(HaveKey(version))
+				seen[version] = struct{}{}
+			}
+
+			Expect(seen).To(HaveLen(writers))
+
+			last, err := NewConfigStore(db).Read(ctx, config.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(last.Version).To(BeEquivalentTo(writers + 1))
+		})
+
+		It("should retry serialization failures and deadlocks", func() {
+			for cause, attempts := range map[pgError]int{
+				{code: "40001"}: 3, // serialization_failure
+				{code: "40P01"}: 3, // deadlock_detected
+				{code: "23505", constraint: "config_versions_pkey"}: 3, // version is taken by concurrent writer
+				{code: "23505", constraint: "config_slugs_pkey"}:    1, // slug is used, fails again
+				{code: "23503"}: 1, // foreign_key_violation is not retried
+			} {
+				var calls int
+
+				err := retryConflicts(ctx, db, func(pgorm.DB) error {
+					if calls++; calls < 3 {
+						return errors.WithMessage(cause, "could not update")
+					}
+
+					return nil
+				})
+
+				Expect(calls).To(Equal(attempts), cause.Error())
+				Expect(err != nil).To(Equal(attempts == 1), cause.Error())
+			}
+
+			var calls int
+
+			err := retryConflicts(ctx, db, func(pgorm.DB) error {
+				calls++
+				return pgError{code: "40001"}
+			})
+
+			Expect(isConflict(err)).To(BeTrue())
+			Expect(calls).To(Equal(maxConflictRetries))
+		})
+	})
+
+	Context("cache", func() {
+		It("should read through cache and invalidate it on update", func() {
+			var (
+				s      = NewCachedSchemeStore(db, cache, breaker)
+				scheme = Scheme{Tags: []string{"cache"}, Data: json.RawMessage(`{"v": 1}`)}
+			)
+
+			err := s.Create(ctx, &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			first, err := s.Read(ctx, scheme.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			// read from cache even when database has another version:
+			_, err = db.Exec("UPDATE scheme_versions SET data = '{\"v\": 0}' WHERE scheme_id = ?", scheme.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			cached, err := s.Read(ctx, scheme.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cached.Data).To(MatchJSON(first.Data))
+
+			err = s.Update(ctx, &Scheme{ID: scheme.ID, Tags: []string{"cache"}, Data: json.RawMessage(`{"v": 2}`)})
+			Expect(err).NotTo(HaveOccurred())
+
+			latest, err := s.Read(ctx, scheme.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.Version).To(BeEquivalentTo(2))
+			Expect(latest.Data).To(MatchJSON(`{"v": 2}`))
+		})
+
+		It("should not cache version read before invalidation", func() {
+			var (
+				s      = NewCachedSchemeStore(db, cache, breaker)
+				scheme = Scheme{Tags: []string{"cache"}, Data: json.RawMessage(`{"v": 1}`)}
+			)
+
+			err := s.Create(ctx, &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			// reader takes generation and reads version 1, writer invalidates before reader stores it:
+			gen, ok := cache.generation(ctx, schemesCacheKey, scheme.ID)
+			Expect(ok).To(BeTrue())
+
+			cache.invalidate(schemesCacheKey, scheme.ID)
+			cache.set(ctx, schemesCacheKey, scheme.ID, gen, 1, &scheme, "")
+			Expect(cache.get(ctx, schemesCacheKey, scheme.ID, new(Scheme))).To(BeFalse())
+
+			// older version does not replace newer one:
+			gen, ok = cache.generation(ctx, schemesCacheKey, scheme.ID)
+			Expect(ok).To(BeTrue())
+
+			cache.set(ctx, schemesCacheKey, scheme.ID, gen, 2, &Scheme{ID: scheme.ID, Version: 2, Data: json.RawMessage(`{"v": 2}`)}, "")
+			cache.set(ctx, schemesCacheKey, scheme.ID, gen, 1, &scheme, "")
+
+			var cached Scheme
+			Expect(cache.get(ctx, schemesCacheKey, scheme.ID, &cached)).To(BeTrue())
+			Expect(cached.Version).To(BeEquivalentTo(2))
+		})
+
+		It("should forget cached configs when secret field is added to scheme", func() {
+			var (
+				schemes = NewCachedSchemeStore(db, cache, breaker)
+				configs = NewCachedConfigStore(db, cache, breaker, nil, nil)
+				scheme  = Scheme{Tags: []string{"cache"}, Data: json.RawMessage(`{"type": "object"}`)}
+				config  = Config{Tags: []string{"cache"}, Data: json.RawMessage(`{"password": "qwerty"}`)}
+			)
+
+			Expect(schemes.Create(ctx, &scheme)).To(Succeed())
+
+			config.SchemeID = scheme.ID
+			Expect(configs.Create(ctx, &config)).To(Succeed())
+
+			cached, err := configs.Read(ctx, config.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cached.Data).To(MatchJSON(`{"password": "qwerty"}`))
+
+			// config read before change of scheme is not cached after it:
+			gen, ok := cache.generation(ctx, configsCacheKey, config.ID)
+			Expect(ok).To(BeTrue())
+
+			err = schemes.Update(ctx, &Scheme{ID: scheme.ID, Tags: []string{"cache"},
+				Data: json.RawMessage(`{"type": "object", "properties": {"password": {"type": "string", "x-secret": true}}}`)})
+			Expect(err).NotTo(HaveOccurred())
+
+			cache.set(ctx, configsCacheKey, config.ID, gen, cached.Version, cached, groupKey(scheme.ID))
+			Expect(cache.get(ctx, configsCacheKey, config.ID, new(Config))).To(BeFalse())
+			Expect(cache.stale(configsCacheKey, config.ID, new(Config))).To(BeFalse())
+
+			masked, err := configs.Read(ctx, config.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(masked.Data).To(MatchJSON(`{"password": "********"}`))
+		})
+	})
+
+	Context("database unavailable", func() {
+		It("should serve last-known-good config and open breaker", func() {
+			var (
+				scheme = Scheme{Tags: []string{"stale"}, Data: json.RawMessage(`{}`)}
+				config = Config{Tags: []string{"stale"}, Data: json.RawMessage(`{"v": 1}`)}
+				down   = pg.Connect(&pg.Options{Addr: "127.0.0.1:1", MaxRetries: 0})
+				guard  = NewBreaker(viper.New(), zap.NewNop())
+			)
+
+			defer down.Close()
+
+			err := NewSchemeStore(db).Create(ctx, &scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			config.SchemeID = scheme.ID
+
+			err = NewConfigStore(db).Create(ctx, &config)
+			Expect(err).NotTo(HaveOccurred())
+
+			// store last-known-good version:
+			_, err = NewCachedConfigStore(db, cache, breaker, nil, nil).Read(ctx, config.ID)
+			Expect(err).NotTo(HaveOccurred())
+			cache.invalidate(configsCacheKey, config.ID)
+
+			s := NewCachedConfigStore(down, cache, guard, nil, nil)
+
+			for i := 0; i < defaultBreakerFailures+1; i++ {
+				item, err := s.Read(ctx, config.ID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(item.Stale).To(BeTrue())
+				Expect(item.Data).To(MatchJSON(`{"v": 1}`))
+			}
+
+			Expect(guard.allow()).To(BeFalse())
+
+			err = s.Update(ctx, &Config{ID: config.ID, Tags: []string{"stale"}, Data: json.RawMessage(`{}`)})
+			Expect(errors.Cause(err)).To(Equal(ErrUnavailable))
+		})
+
+		It("should not count errors of cancelled calls", func() {
+			var (
+				guard = NewBreaker(viper.New(), zap.NewNop())
+				down  = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+			)
+
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			Expect(IsUnavailable(context.DeadlineExceeded)).To(BeFalse())
+			Expect(IsUnavailable(down)).To(BeTrue())
+
+			for i := 0; i < defaultBreakerFailures+1; i++ {
+				Expect(guard.call(cctx, func() error { return down })).To(Equal(down))
+			}
+
+			Expect(guard.allow()).To(BeTrue())
+		})
+	})
+
+	Context("changes", func() {
+		It("should remove old changes except changes with pending deliveries", func() {
+			var (
+				hook, pending, left int64
+				first               = Scheme{Tags: []string{"changes"}, Data: json.RawMessage(`{}`)}
+				second              = Scheme{Tags: []string{"changes"}, Data: json.RawMessage(`{}`)}
+			)
+
+			Expect(NewSchemeStore(db).Create(ctx, &first)).To(Succeed())
+			Expect(NewSchemeStore(db).Create(ctx, &second)).To(Succeed())
+
+			_, err := db.QueryOne(pg.Scan(&hook), "INSERT INTO webhooks (url, secret) VALUES ('http://localhost', 'secret') RETURNING id")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = db.QueryOne(pg.Scan(&pending), "SELECT id FROM changes WHERE entity = 'scheme' AND entity_id = ?", first.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = db.Exec("INSERT INTO webhook_deliveries (webhook_id, change_id) VALUES (?, ?)", hook, pending)
+			Expect(err).NotTo(HaveOccurred())
+
+			count, err := NewChangeStore(db).Cleanup(ctx, time.Now().Add(-time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(BeZero())
+
+			_, err = NewChangeStore(db).Cleanup(ctx, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = db.QueryOne(pg.Scan(&left), "SELECT COUNT(*) FROM changes WHERE entity = 'scheme' AND entity_id IN (?, ?)", first.ID, second.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(left).To(BeEquivalentTo(1))
+
+			_, err = db.Exec("DELETE FROM webhooks WHERE id = ?", hook)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("archive", func() {
+		var (
+			scheme Scheme
+			config Config
+		)
+
+		// export returns header, records of fixtures and end record
+		export := func() []*Record {
+			var result []*Record
+
+			err := NewArchive(db).Export(ctx, func(rec *Record) error {
+				switch {
+				case rec.Type == RecordHeader:
+				case rec.Type == RecordEnd:
+					rec.Count = int64(len(result))
+				case rec.Type == RecordScheme || rec.Type == RecordSchemeVersion:
+					if rec.ID != scheme.ID {
+						return nil
+					}
+				case rec.SchemeID != scheme.ID:
+					return nil
+				}
+
+				result = append(result, rec)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			return result
+		}
+
+		reader := func(items []*Record) func() (*Record, error) {
+			return func() (*Record, error) {
+				if len(items) == 0 {
+					return nil, io.EOF
+				}
+
+				rec := *items[0]
+				items = items[1:]
+
+				return &rec, nil
+			}
+		}
+
+		BeforeEach(func() {
+			scheme = Scheme{Slug: "archive-person", Tags: []string{"archive"}, Data: json.RawMessage(`{"v": 1}`), Author: "john"}
+			Expect(NewSchemeStore(db).Create(ctx, &scheme)).To(Succeed())
+			Expect(NewSchemeStore(db).Rename(ctx, scheme.ID, "archive-human")).To(Succeed())
+			Expect(NewSchemeStore(db).Update(ctx, &Scheme{ID: scheme.ID, Tags: []string{"archive"}, Data: json.RawMessage(`{"v": 2}`), Author: "jane"})).To(Succeed())
+
+			config = Config{SchemeID: scheme.ID, Slug: "archive-john", Tags: []string{"archive"}, Data: json.RawMessage(`{"name": "John"}`)}
+			Expect(NewConfigStore(db).Create(ctx, &config)).To(Succeed())
+			Expect(NewConfigStore(db).Delete(ctx, config.ID)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			_, err := db.Exec("DELETE FROM configs WHERE scheme_id IN (SELECT scheme_id FROM scheme_slugs WHERE slug LIKE 'archive-%')")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = db.Exec("DELETE FROM schemes WHERE id IN (SELECT scheme_id FROM scheme_slugs WHERE slug LIKE 'archive-%')")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should export entities with old slugs, deletion state and every version", func() {
+			items := export()
+			Expect(items).To(HaveLen(6))
+
+			Expect(items[0].Type).To(Equal(RecordHeader))
+			Expect(items[0].Format).To(Equal(ArchiveFormat))
+
+			Expect(items[1].Type).To(Equal(RecordScheme))
+			Expect(items[1].Slug).To(Equal("archive-human"))
+			Expect(items[1].Aliases).To(Equal([]string{"archive-person"}))
+			Expect(items[1].DeletedAt).To(BeNil())
+
+			Expect(items[2].Type).To(Equal(RecordSchemeVersion))
+			Expect(items[2].Author).To(Equal("john"))
+			Expect(items[3].Version).To(Equal(int64(2)))
+			Expect(items[3].Data).To(MatchJSON(`{"v": 2}`))
+
+			Expect(items[4].Type).To(Equal(RecordConfig))
+			Expect(items[4].DeletedAt).NotTo(BeNil())
+			Expect(items[5].Type).To(Equal(RecordConfigVersion))
+		})
+
+		It("should import archive with new ids and resolve conflicts", func() {
+			items := export()
+
+			_, err := NewArchive(db).Import(ctx, reader(items), ImportOptions{})
+			Expect(errors.Cause(err)).To(Equal(ErrImportConflict))
+
+			result, err := NewArchive(db).Import(ctx, reader(items), ImportOptions{Conflict: ConflictSkip})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Schemes.Skipped).To(Equal(1))
+			Expect(result.Configs.Skipped).To(Equal(1))
+			Expect(result.Versions).To(BeZero())
+
+			result, err = NewArchive(db).Import(ctx, reader(items), ImportOptions{Conflict: ConflictMerge})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Schemes.Merged).To(Equal(1))
+			Expect(result.SchemeIDs[scheme.ID]).To(Equal(scheme.ID))
+
+			merged, err := NewSchemeStore(db).Read(ctx, scheme.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(merged.Version).To(Equal(int64(4)))
+			Expect(merged.Author).To(Equal("jane"))
+
+			// merged scheme is published, deleted config is not:
+			var (
+				event     Event
+				published Scheme
+			)
+
+			Expect(db.Model(&event).Where("entity = 'scheme' AND entity_id = ?", scheme.ID).Order("id DESC").Limit(1).Select()).To(Succeed())
+			Expect(event.Op).To(Equal("update"))
+			Expect(json.Unmarshal(event.Payload, &published)).To(Succeed())
+			Expect(published.Version).To(Equal(merged.Version))
+
+			Expect(db.Model(&event).Where("entity = 'config' AND entity_id = ?", config.ID).Order("id DESC").Limit(1).Select()).To(Succeed())
+			Expect(event.Op).To(Equal("delete"))
+
+			// the same archive with other slugs is imported as new entities:
+			items[1].Slug, items[1].Aliases, items[4].Slug = "archive-copy", nil, "archive-copy-john"
+
+			result, err = NewArchive(db).Import(ctx, reader(items), ImportOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Schemes.Created).To(Equal(1))
+			Expect(result.Versions).To(Equal(3))
+
+			copied, err := NewSchemeStore(db).ReadBySlug(ctx, "archive-copy")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(copied.ID).To(Equal(result.SchemeIDs[scheme.ID]))
+			Expect(copied.ID).NotTo(Equal(scheme.ID))
+			Expect(copied.Data).To(MatchJSON(`{"v": 2}`))
+
+			_, err = NewConfigStore(db).Read(ctx, result.ConfigIDs[config.ID])
+			Expect(errors.Cause(err)).To(Equal(pg.ErrNoRows))
+		})
+
+		It("should rewrite references of configs imported with new ids", func() {
+			target := Config{SchemeID: scheme.ID, Slug: "archive-target", Tags: []string{"archive"}, Data: json.RawMessage(`{"host": "db"}`)}
+			Expect(NewConfigStore(db).Create(ctx, &target)).To(Succeed())
+
+			dependent := Config{SchemeID: scheme.ID, Slug: "archive-dependent", Tags: []string{"archive"},
+				Data: json.RawMessage(fmt.Sprintf(`{"url": "${config:%d#/host}"}`, target.ID))}
+			Expect(NewConfigStore(db).Create(ctx, &dependent)).To(Succeed())
+
+			items := export()
+			items[1].Slug, items[1].Aliases = "archive-copy", nil
+
+			result, err := NewArchive(db).Import(ctx, reader(items), ImportOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			copied, err := NewConfigStore(db).Read(ctx, result.ConfigIDs[dependent.ID])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(copied.Data).To(MatchJSON(fmt.Sprintf(`{"url": "${config:%d#/host}"}`, result.ConfigIDs[target.ID])))
+
+			refs, err := NewConfigStore(db).Dependents(ctx, result.ConfigIDs[target.ID])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(refs).To(Equal([]*Reference{{ConfigID: copied.ID, TargetID: result.ConfigIDs[target.ID], Pointer: "/host"}}))
+
+			// config refers to config that is not in archive:
+			items[1].Slug = "archive-other"
+			for _, item := range items {
+				if item.Type == RecordConfigVersion && item.ID == dependent.ID {
+					item.Data = json.RawMessage(`{"url": "${config:100500}"}`)
+				}
+			}
+
+			_, err = NewArchive(db).Import(ctx, reader(items), ImportOptions{})
+			Expect(errors.Cause(err)).To(Equal(ErrInvalidArchive))
+		})
+
+		It("should reject truncated archive", func() {
+			items := export()
+
+			_, err := NewArchive(db).Import(ctx, reader(items[:len(items)-1]), ImportOptions{Conflict: ConflictSkip})
+			Expect(errors.Cause(err)).To(Equal(ErrInvalidArchive))
+
+			_, err = NewArchive(db).Import(ctx, reader(items[1:]), ImportOptions{Conflict: ConflictSkip})
+			Expect(errors.Cause(err)).To(Equal(ErrInvalidArchive))
+		})
 	})
 })
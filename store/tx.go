@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/im-kulikov/simplinic-task/interpolate"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxConflictRetries limits attempts of transaction that failed
+	// because of conflict with concurrent transaction, see isConflict
+	maxConflictRetries = 5
+
+	// conflictBackoff is base delay before next attempt, doubled by every attempt,
+	// random jitter up to delay is added, so concurrent writers do not collide again
+	conflictBackoff = 10 * time.Millisecond
+)
+
+// versionConstraints are unique constraints of versions, violated when
+// concurrent writer took the same version
+var versionConstraints = map[string]bool{
+	"scheme_versions_pkey": true,
+	"config_versions_pkey": true,
+}
+
+type (
+	// Transactions runs several store operations all-or-nothing
+	Transactions interface {
+		RunInTransaction(ctx context.Context, fn func(Schemes, Configs) error) error
+	}
+
+	transactions struct {
+		db   *pg.DB
+		keys *secrets.Keyring
+		vars interpolate.Variables
+	}
+)
+
+// jitter of backoff differs between instances of service
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+func NewTransactions(db *pg.DB) Transactions {
+	return &transactions{db: db}
+}
+
+// RunInTransaction calls fn with stores bound to one transaction,
+// transaction is rolled back when fn returns error or ctx is done
+func (t *transactions) RunInTransaction(ctx context.Context, fn func(Schemes, Configs) error) error {
+	return runInTransaction(ctx, t.db, func(tx *pg.Tx) error {
+		return fn(&schemes{db: tx}, &configs{db: tx, keys: t.keys, vars: t.vars})
+	})
+}
+
+// inTransaction runs fn in new transaction, when db is already
+// transaction (see Transactions) fn runs in it.
+func inTransaction(ctx context.Context, db orm.DB, fn func(db orm.DB) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if pdb, ok := db.(*pg.DB); ok {
+		return runInTransaction(ctx, pdb, func(tx *pg.Tx) error {
+			return fn(tx)
+		})
+	}
+
+	return fn(db)
+}
+
+// retryConflicts runs fn in transaction like inTransaction and starts it again
+// after jittered backoff on conflict with concurrent transaction (see isConflict),
+// when db is already transaction error returned as is.
+func retryConflicts(ctx context.Context, db orm.DB, fn func(db orm.DB) error) error {
+	for attempt := 1; ; attempt++ {
+		err := inTransaction(ctx, db, fn)
+		if _, ok := db.(*pg.DB); !ok || attempt >= maxConflictRetries || !isConflict(err) {
+			return err
+		}
+
+		delay := conflictBackoff << uint(attempt-1)
+		delay += time.Duration(rand.Int63n(int64(delay)))
+
+		select {
+		case <-ctx.Done():
+			return errors.WithMessage(ctx.Err(), err.Error())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isConflict checks that transaction failed because of concurrent transaction and
+// could succeed when started again: serialization_failure, deadlock_detected and
+// unique_violation of versions (concurrent writer took the same version). Other
+// unique violations (e.g. slug is already used) fail again, so they are not retried.
+func isConflict(err error) bool {
+	perr, ok := errors.Cause(err).(pg.Error)
+	if !ok {
+		return false
+	}
+
+	switch perr.Field('C') {
+	case "40001", "40P01":
+		return true
+	case "23505":
+		return versionConstraints[perr.Field('n')]
+	}
+
+	return false
+}
+
+// runInTransaction runs fn in transaction bound to ctx:
+// - deadline of ctx limits statement_timeout of transaction
+// - when ctx is done, running statement is cancelled by pg_cancel_backend
+// - transaction is committed only when fn succeed and ctx is not done
+func runInTransaction(ctx context.Context, db *pg.DB, fn func(tx *pg.Tx) error) error {
+	return runInTransactionMode(ctx, db, "", fn)
+}
+
+// runInTransactionMode is runInTransaction that sets mode of transaction
+// (e.g. `ISOLATION LEVEL REPEATABLE READ`) before first statement
+func runInTransactionMode(ctx context.Context, db *pg.DB, mode string, fn func(tx *pg.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).RunInTransaction(func(tx *pg.Tx) error {
+		var pid int
+
+		if mode != "" {
+			if _, err := tx.Exec("SET TRANSACTION " + mode); err != nil {
+				return errors.Wrap(err, "could not set transaction mode")
+			}
+		}
+
+		if _, err := tx.QueryOne(pg.Scan(&pid), "SELECT pg_backend_pid()"); err != nil {
+			return errors.Wrap(err, "could not start transaction")
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout := time.Until(deadline) / time.Millisecond
+			if timeout <= 0 {
+				return context.DeadlineExceeded
+			}
+
+			if _, err := tx.Exec("SET LOCAL statement_timeout = ?", int64(timeout)); err != nil {
+				return errors.Wrap(err, "could not set statement timeout")
+			}
+		}
+
+		stop, done := make(chan struct{}), make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			select {
+			case <-stop:
+			case <-ctx.Done():
+				// cancel running statement from another connection:
+				_, _ = db.Exec("SELECT pg_cancel_backend(?)", pid)
+			}
+		}()
+
+		err := fn(tx)
+
+		close(stop)
+		<-done
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err == nil {
+				return ctxErr
+			}
+
+			return errors.WithMessage(ctxErr, err.Error())
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,363 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/pkg/errors"
+)
+
+// Statuses of delivery
+const (
+	DeliveryPending   = "pending"   // waits for next attempt
+	DeliveryDelivered = "delivered" // receiver responded with 2xx
+	DeliveryDead      = "dead"      // all attempts failed
+)
+
+type (
+	// Webhook subscribes URL to changes, empty fields of filter match every change
+	Webhook struct {
+		tableName struct{}  `sql:"webhooks"`
+		ID        int64     `json:"id"`
+		URL       string    `json:"url"`
+		Secret    string    `json:"-"` // key of HMAC-SHA256 signature of payload, sealed by keyring
+		Entity    string    `json:"entity,omitempty"`
+		SchemeID  int64     `json:"scheme_id,omitempty"`
+		Tags      []string  `json:"tags,omitempty"`
+		Events    []string  `json:"events,omitempty"`
+		AfterTxID int64     `sql:"after_txid" json:"-"`
+		AfterID   int64     `json:"-"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// Delivery of change to webhook
+	Delivery struct {
+		tableName     struct{}  `sql:"webhook_deliveries"`
+		ID            int64     `json:"id"`
+		WebhookID     int64     `json:"webhook_id"`
+		ChangeID      int64     `json:"change_id"`
+		Status        string    `json:"status"`
+		Attempts      []Attempt `json:"attempts"`
+		NextAttemptAt time.Time `json:"next_attempt_at"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+
+		// filled only by Claim:
+		Webhook *Webhook `sql:"-" json:"-"`
+		Change  *Change  `sql:"-" json:"-"`
+	}
+
+	// Attempt to deliver change, StatusCode is zero when request failed
+	Attempt struct {
+		At         time.Time `json:"at"`
+		StatusCode int       `json:"status_code,omitempty"`
+		Error      string    `json:"error,omitempty"`
+		Duration   float64   `json:"duration"` // seconds
+	}
+
+	DeliveriesRequest struct {
+		WebhookID int64
+		Status    string
+		Limit     int
+		Offset    int
+	}
+
+	Webhooks interface {
+		Create(ctx context.Context, hook *Webhook) error
+		Read(ctx context.Context, id int64) (*Webhook, error)
+		List(ctx context.Context) ([]*Webhook, error)
+		Delete(ctx context.Context, id int64) error
+		Deliveries(ctx context.Context, req DeliveriesRequest) ([]*Delivery, int, error)
+
+		// Dispatch creates deliveries of new changes, at most limit changes per webhook
+		Dispatch(ctx context.Context, limit int) (int, error)
+		// Claim returns pending deliveries that are due, claimed deliveries
+		// are not returned again until lease expires. Deliveries of webhooks
+		// which secrets could not be opened are dead and are not returned.
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error)
+		// Record appends attempt and saves status and next attempt of delivery
+		Record(ctx context.Context, d *Delivery, a Attempt) error
+	}
+
+	webhooks struct {
+		db   orm.DB
+		keys *secrets.Keyring
+	}
+)
+
+// NewWebhookStore seals secrets of webhooks by keyring, webhooks could not be
+// created without keys, see secrets.ErrNoKeys
+func NewWebhookStore(db *pg.DB, k *secrets.Keyring) Webhooks {
+	return &webhooks{db: db, keys: k}
+}
+
+// changes request of changes that match filter of webhook
+func (w *Webhook) changes(limit int) ChangesRequest {
+	return ChangesRequest{
+		After:    Cursor{TxID: w.AfterTxID, ID: w.AfterID},
+		Entity:   w.Entity,
+		SchemeID: w.SchemeID,
+		Tags:     w.Tags,
+		Ops:      w.Events,
+		Limit:    limit,
+	}
+}
+
+// Create webhook, only changes after creation are delivered.
+// Secret is sealed and is not returned.
+func (s *webhooks) Create(ctx context.Context, hook *Webhook) error {
+	if s.keys == nil {
+		return errors.Wrap(secrets.ErrNoKeys, "could not seal secret of webhook")
+	}
+
+	secret, err := s.keys.Seal([]byte(hook.Secret))
+	if err != nil {
+		return errors.Wrap(err, "could not seal secret of webhook")
+	}
+
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		last, err := (&changes{db: db}).Last(ctx)
+		if err != nil {
+			return err
+		}
+
+		hook.Secret = secret
+		hook.AfterTxID, hook.AfterID = last.TxID, last.ID
+		hook.CreatedAt = time.Time{} // always set by database
+
+		if _, err := db.Model(hook).Returning("*").Insert(); err != nil {
+			return errors.WithMessage(err, "could not create webhook")
+		}
+
+		hook.Secret = ""
+
+		return nil
+	})
+}
+
+func (s *webhooks) Read(ctx context.Context, id int64) (result *Webhook, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		result = &Webhook{ID: id}
+
+		if err := db.Model(result).ExcludeColumn("secret").WherePK().Select(); err != nil {
+			return errors.Wrapf(err, "could not read webhook #%d", id)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *webhooks) List(ctx context.Context) (result []*Webhook, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if err := db.Model(&result).ExcludeColumn("secret").Order("id ASC").Select(); err != nil {
+			return errors.Wrap(err, "could not list webhooks")
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Delete webhook with its deliveries, returns pg.ErrNoRows when webhook not found
+func (s *webhooks) Delete(ctx context.Context, id int64) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		res, err := db.Model(&Webhook{ID: id}).WherePK().Delete()
+		if err != nil {
+			return errors.Wrapf(err, "can't remove webhook #%d", id)
+		} else if res.RowsAffected() == 0 {
+			return errors.Wrapf(pg.ErrNoRows, "can't remove webhook #%d", id)
+		}
+
+		return nil
+	})
+}
+
+// Deliveries of webhook, latest first
+func (s *webhooks) Deliveries(ctx context.Context, req DeliveriesRequest) (result []*Delivery, total int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		q := db.Model(&result).
+			Where("webhook_id = ?", req.WebhookID).
+			Order("id DESC")
+
+		if req.Status != "" {
+			q.Where("status = ?", req.Status)
+		}
+
+		paginate(q, SearchRequest{Limit: req.Limit, Offset: req.Offset})
+
+		if total, err = q.SelectAndCount(); err != nil {
+			return errors.Wrapf(err, "could not read deliveries of webhook #%d", req.WebhookID)
+		}
+
+		return nil
+	})
+
+	return result, total, err
+}
+
+func (s *webhooks) Dispatch(ctx context.Context, limit int) (total int, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		var hooks []*Webhook
+
+		// every instance of service dispatches webhooks that are not locked by others:
+		if err := db.Model(&hooks).
+			Order("id ASC").
+			For("UPDATE SKIP LOCKED").
+			Select(); err != nil {
+			return errors.Wrap(err, "could not lock webhooks")
+		}
+
+		for _, hook := range hooks {
+			items, err := (&changes{db: db}).Since(ctx, hook.changes(limit))
+			if err != nil {
+				return err
+			} else if len(items) == 0 {
+				continue
+			}
+
+			deliveries := make([]*Delivery, 0, len(items))
+			for _, item := range items {
+				deliveries = append(deliveries, &Delivery{WebhookID: hook.ID, ChangeID: item.ID})
+			}
+
+			if _, err := db.Model(&deliveries).
+				OnConflict("DO NOTHING").
+				Insert(); err != nil {
+				return errors.Wrapf(err, "could not create deliveries of webhook #%d", hook.ID)
+			}
+
+			last := items[len(items)-1].Cursor()
+			hook.AfterTxID, hook.AfterID = last.TxID, last.ID
+
+			if _, err := db.Model(hook).
+				Column("after_txid", "after_id").
+				WherePK().
+				Update(); err != nil {
+				return errors.Wrapf(err, "could not move cursor of webhook #%d", hook.ID)
+			}
+
+			total += len(items)
+		}
+
+		return nil
+	})
+
+	return total, err
+}
+
+func (s *webhooks) Claim(ctx context.Context, limit int, lease time.Duration) (result []*Delivery, err error) {
+	err = inTransaction(ctx, s.db, func(db orm.DB) error {
+		if _, err := db.Query(&result, `
+			UPDATE webhook_deliveries
+			   SET next_attempt_at = NOW() + ? * INTERVAL '1 millisecond', updated_at = NOW()
+			 WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				 WHERE status = ? AND next_attempt_at <= NOW()
+				 ORDER BY next_attempt_at
+				 LIMIT ?
+				   FOR UPDATE SKIP LOCKED
+			 )
+			RETURNING *`, int64(lease/time.Millisecond), DeliveryPending, limit); err != nil {
+			return errors.Wrap(err, "could not claim deliveries")
+		} else if len(result) == 0 {
+			return nil
+		}
+
+		var (
+			hooks   []*Webhook
+			items   []*Change
+			hookIDs = make([]int64, 0, len(result))
+			itemIDs = make([]int64, 0, len(result))
+		)
+
+		for _, d := range result {
+			hookIDs = append(hookIDs, d.WebhookID)
+			itemIDs = append(itemIDs, d.ChangeID)
+		}
+
+		if err := db.Model(&hooks).Where("id IN (?)", pg.In(hookIDs)).Select(); err != nil {
+			return errors.Wrap(err, "could not read webhooks of deliveries")
+		}
+
+		if err := db.Model(&items).Where("id IN (?)", pg.In(itemIDs)).Select(); err != nil {
+			return errors.Wrap(err, "could not read changes of deliveries")
+		}
+
+		var (
+			claimed  = result[:0]
+			hookByID = make(map[int64]*Webhook, len(hooks))
+			itemByID = make(map[int64]*Change, len(items))
+			sealed   = make(map[int64]error)
+		)
+
+		for _, hook := range hooks {
+			secret, err := s.keys.Open(hook.Secret)
+			if err != nil {
+				// e.g. key is removed from keyring, other webhooks are still delivered:
+				sealed[hook.ID] = err
+				continue
+			}
+
+			hook.Secret = string(secret)
+			hookByID[hook.ID] = hook
+		}
+
+		for _, item := range items {
+			itemByID[item.ID] = item
+		}
+
+		for _, d := range result {
+			if err, ok := sealed[d.WebhookID]; ok {
+				d.Status = DeliveryDead
+
+				if err = record(db, d, Attempt{At: time.Now(), Error: "could not open secret of webhook: " + err.Error()}); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			d.Webhook = hookByID[d.WebhookID]
+			d.Change = itemByID[d.ChangeID]
+			claimed = append(claimed, d)
+		}
+
+		result = claimed
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *webhooks) Record(ctx context.Context, d *Delivery, a Attempt) error {
+	return inTransaction(ctx, s.db, func(db orm.DB) error {
+		return record(db, d, a)
+	})
+}
+
+// record appends attempt and saves status and next attempt of delivery
+func record(db orm.DB, d *Delivery, a Attempt) error {
+	attempt, err := json.Marshal([]Attempt{a})
+	if err != nil {
+		return errors.Wrap(err, "could not encode attempt")
+	}
+
+	if _, err := db.Exec(`
+		UPDATE webhook_deliveries
+		   SET status = ?, attempts = attempts || ?::jsonb, next_attempt_at = ?, updated_at = NOW()
+		 WHERE id = ?`, d.Status, string(attempt), d.NextAttemptAt, d.ID); err != nil {
+		return errors.Wrapf(err, "could not record attempt of delivery #%d", d.ID)
+	}
+
+	d.Attempts = append(d.Attempts, a)
+
+	return nil
+}
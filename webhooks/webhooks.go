@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/simplinic-task/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultBatch       = 100
+	defaultMaxAttempts = 10
+	defaultBackoff     = 10 * time.Second
+	defaultMaxBackoff  = time.Hour
+
+	// maxResponseBody is read from receiver to reuse connection
+	maxResponseBody = 64 << 10
+
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type (
+	// Dispatcher delivers changes to webhooks, failed attempts are retried
+	// with exponential backoff (`webhooks.backoff` doubled on every attempt
+	// up to `webhooks.max_backoff`), after `webhooks.max_attempts` delivery is dead.
+	Dispatcher struct {
+		store       store.Webhooks
+		client      *http.Client
+		logger      *zap.Logger
+		batch       int
+		maxAttempts int
+		backoff     time.Duration
+		maxBackoff  time.Duration
+	}
+
+	// Payload is body of webhook request
+	Payload struct {
+		DeliveryID int64         `json:"delivery_id"`
+		WebhookID  int64         `json:"webhook_id"`
+		Event      string        `json:"event"`
+		Change     *store.Change `json:"change"`
+	}
+)
+
+var Module = module.Module{
+	{Constructor: NewDispatcher},
+}
+
+var attempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "webhooks",
+	Name:      "attempts_total",
+	Help:      "Attempts to deliver changes to webhooks by result (delivered, failed, dead)",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(attempts)
+}
+
+// NewDispatcher with settings:
+//
+//	webhooks.timeout      - timeout of request (10s by default)
+//	webhooks.batch        - deliveries handled at once (100 by default)
+//	webhooks.max_attempts - attempts before delivery is dead (10 by default)
+//	webhooks.backoff      - delay after first failed attempt (10s by default)
+//	webhooks.max_backoff  - max delay between attempts (1h by default)
+func NewDispatcher(v *viper.Viper, s store.Webhooks, l *zap.Logger) *Dispatcher {
+	var (
+		timeout = defaultTimeout
+		d       = &Dispatcher{
+			store:       s,
+			logger:      l,
+			batch:       defaultBatch,
+			maxAttempts: defaultMaxAttempts,
+			backoff:     defaultBackoff,
+			maxBackoff:  defaultMaxBackoff,
+		}
+	)
+
+	if v.IsSet("webhooks.timeout") {
+		timeout = v.GetDuration("webhooks.timeout")
+	}
+
+	if v.IsSet("webhooks.batch") {
+		d.batch = v.GetInt("webhooks.batch")
+	}
+
+	if v.IsSet("webhooks.max_attempts") {
+		d.maxAttempts = v.GetInt("webhooks.max_attempts")
+	}
+
+	if v.IsSet("webhooks.backoff") {
+		d.backoff = v.GetDuration("webhooks.backoff")
+	}
+
+	if v.IsSet("webhooks.max_backoff") {
+		d.maxBackoff = v.GetDuration("webhooks.max_backoff")
+	}
+
+	d.client = &http.Client{Timeout: timeout}
+
+	return d
+}
+
+// Sign returns signature of body, receiver should compare it
+// with X-Webhook-Signature header using hmac.Equal
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Job creates deliveries of new changes and delivers all pending deliveries that are due
+func (d *Dispatcher) Job(ctx context.Context) {
+	if _, err := d.store.Dispatch(ctx, d.batch); err != nil {
+		d.logger.Error("could not dispatch changes to webhooks", zap.Error(err))
+	}
+
+	for ctx.Err() == nil {
+		// lease should outlive request, otherwise delivery could be sent twice:
+		items, err := d.store.Claim(ctx, d.batch, 2*d.client.Timeout)
+		if err != nil {
+			d.logger.Error("could not claim deliveries", zap.Error(err))
+			return
+		}
+
+		var wg sync.WaitGroup
+
+		for _, item := range items {
+			wg.Add(1)
+
+			go func(item *store.Delivery) {
+				defer wg.Done()
+				d.deliver(ctx, item)
+			}(item)
+		}
+
+		wg.Wait()
+
+		if len(items) < d.batch {
+			return
+		}
+	}
+}
+
+// delay before attempt, attempts are counted from 1
+func (d *Dispatcher) delay(attempt int) time.Duration {
+	var delay = d.backoff
+
+	for i := 1; i < attempt && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > d.maxBackoff {
+		delay = d.maxBackoff
+	}
+
+	return delay
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, item *store.Delivery) {
+	var a = store.Attempt{At: time.Now()}
+
+	if item.Webhook == nil || item.Change == nil {
+		a.Error = "webhook or change not found"
+	} else if a.StatusCode, a.Error = d.send(ctx, item); ctx.Err() != nil {
+		// service is stopped, delivery is retried when lease expires
+		return
+	}
+
+	a.Duration = time.Since(a.At).Seconds()
+
+	switch number := len(item.Attempts) + 1; {
+	case a.Error == "":
+		item.Status = store.DeliveryDelivered
+	case number >= d.maxAttempts || item.Webhook == nil || item.Change == nil:
+		item.Status = store.DeliveryDead
+		d.logger.Warn("could not deliver change to webhook",
+			zap.Int64("delivery", item.ID),
+			zap.Int64("webhook", item.WebhookID),
+			zap.Int("attempts", number),
+			zap.String("error", a.Error))
+	default:
+		item.Status = store.DeliveryPending
+		item.NextAttemptAt = a.At.Add(d.delay(number))
+	}
+
+	if item.Status == store.DeliveryPending {
+		attempts.WithLabelValues("failed").Inc()
+	} else {
+		attempts.WithLabelValues(item.Status).Inc()
+	}
+
+	if err := d.store.Record(ctx, item, a); err != nil {
+		d.logger.Error("could not record attempt of delivery",
+			zap.Int64("delivery", item.ID),
+			zap.Error(err))
+	}
+}
+
+// send signed payload, returns status code of response and error of attempt
+func (d *Dispatcher) send(ctx context.Context, item *store.Delivery) (int, string) {
+	body, err := json.Marshal(Payload{
+		DeliveryID: item.ID,
+		WebhookID:  item.WebhookID,
+		Event:      item.Change.Op,
+		Change:     item.Change,
+	})
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, item.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, item.Change.Op)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(item.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(item.Webhook.Secret, body))
+
+	res, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	defer res.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxResponseBody))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, res.Status
+	}
+
+	return res.StatusCode, ""
+}
@@ -0,0 +1,226 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/im-kulikov/helium"
+	"github.com/im-kulikov/helium/grace"
+	"github.com/im-kulikov/helium/logger"
+	"github.com/im-kulikov/helium/module"
+	"github.com/im-kulikov/helium/orm"
+	"github.com/im-kulikov/helium/settings"
+	"github.com/im-kulikov/simplinic-task/secrets"
+	"github.com/im-kulikov/simplinic-task/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var testModule = module.Module{}.Append(
+	grace.Module,
+	settings.Module,
+	logger.Module,
+	orm.Module,
+)
+
+// receiver is local webhook that responds with status and collects requests
+type receiver struct {
+	sync.Mutex
+	status   int
+	headers  []http.Header
+	payloads []Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var p Payload
+
+	body, err := ioutil.ReadAll(req.Body)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(json.Unmarshal(body, &p)).NotTo(HaveOccurred())
+	Expect(req.Header.Get(HeaderSignature)).To(Equal(Sign("secret", body)))
+
+	r.Lock()
+	r.headers = append(r.headers, req.Header)
+	r.payloads = append(r.payloads, p)
+	r.Unlock()
+
+	w.WriteHeader(r.status)
+}
+
+var _ = Describe("Webhooks Suite", func() {
+	var (
+		db      *pg.DB
+		v       *viper.Viper
+		ctx     = context.Background()
+		hooks   store.Webhooks
+		schemes store.Schemes
+	)
+
+	BeforeSuite(func() {
+		keys, err := secrets.New("", map[string][]byte{"test": make([]byte, 32)})
+		Expect(err).NotTo(HaveOccurred())
+
+		h, err := helium.New(&helium.Settings{
+			File:   "../config.yml",
+			Prefix: "TEST",
+		}, testModule)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(h.Invoke(func(pdb *pg.DB) {
+			db = pdb
+			hooks = store.NewWebhookStore(db, keys)
+			schemes = store.NewSchemeStore(db)
+
+			// Cleanup all tables...
+			_, err := db.Exec("TRUNCATE schemes, webhooks RESTART IDENTITY CASCADE;")
+			Expect(err).NotTo(HaveOccurred())
+		})).NotTo(HaveOccurred())
+	})
+
+	AfterSuite(func() {
+		// BeforeSuite could fail before database is opened:
+		if db != nil {
+			Expect(db.Close()).NotTo(HaveOccurred())
+		}
+	})
+
+	BeforeEach(func() {
+		v = viper.New()
+		v.Set("webhooks.backoff", time.Millisecond)
+		v.Set("webhooks.max_attempts", 3)
+
+		_, err := db.Exec("TRUNCATE webhooks RESTART IDENTITY CASCADE;")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should double backoff up to max_backoff", func() {
+		v.Set("webhooks.backoff", time.Second)
+		v.Set("webhooks.max_backoff", 5*time.Second)
+
+		d := NewDispatcher(v, hooks, zap.NewNop())
+		Expect(d.delay(1)).To(Equal(time.Second))
+		Expect(d.delay(2)).To(Equal(2 * time.Second))
+		Expect(d.delay(3)).To(Equal(4 * time.Second))
+		Expect(d.delay(4)).To(Equal(5 * time.Second))
+		Expect(d.delay(64)).To(Equal(5 * time.Second))
+	})
+
+	It("should deliver signed changes that match filter", func() {
+		rcv := &receiver{status: http.StatusOK}
+		srv := httptest.NewServer(rcv)
+		defer srv.Close()
+
+		hook := store.Webhook{
+			URL:    srv.URL,
+			Secret: "secret",
+			Tags:   []string{"deploy"},
+			Events: []string{"create", "update"},
+		}
+		Expect(hooks.Create(ctx, &hook)).NotTo(HaveOccurred())
+
+		matched := store.Scheme{Tags: []string{"deploy", "prod"}, Data: json.RawMessage(`{"a":1}`)}
+		Expect(schemes.Create(ctx, &matched)).NotTo(HaveOccurred())
+
+		other := store.Scheme{Tags: []string{"prod"}, Data: json.RawMessage(`{"a":1}`)}
+		Expect(schemes.Create(ctx, &other)).NotTo(HaveOccurred())
+
+		Expect(schemes.Delete(ctx, matched.ID)).NotTo(HaveOccurred())
+
+		d := NewDispatcher(v, hooks, zap.NewNop())
+		d.Job(ctx)
+
+		Expect(rcv.payloads).To(HaveLen(1))
+		Expect(rcv.payloads[0].WebhookID).To(Equal(hook.ID))
+		Expect(rcv.payloads[0].Event).To(Equal("create"))
+		Expect(rcv.payloads[0].Change.EntityID).To(Equal(matched.ID))
+		Expect(rcv.headers[0].Get(HeaderEvent)).To(Equal("create"))
+
+		items, total, err := hooks.Deliveries(ctx, store.DeliveriesRequest{WebhookID: hook.ID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(1))
+		Expect(items[0].Status).To(Equal(store.DeliveryDelivered))
+		Expect(items[0].Attempts).To(HaveLen(1))
+		Expect(items[0].Attempts[0].StatusCode).To(Equal(http.StatusOK))
+
+		// nothing is delivered twice:
+		d.Job(ctx)
+		Expect(rcv.payloads).To(HaveLen(1))
+	})
+
+	It("should mark deliveries dead when secret of webhook could not be opened", func() {
+		rcv := &receiver{status: http.StatusOK}
+		srv := httptest.NewServer(rcv)
+		defer srv.Close()
+
+		gone, err := secrets.New("", map[string][]byte{"gone": append(make([]byte, 31), 1)})
+		Expect(err).NotTo(HaveOccurred())
+
+		// secret is sealed by key that is not in keyring of dispatcher:
+		lost := store.Webhook{URL: srv.URL, Secret: "secret"}
+		Expect(store.NewWebhookStore(db, gone).Create(ctx, &lost)).NotTo(HaveOccurred())
+
+		hook := store.Webhook{URL: srv.URL, Secret: "secret"}
+		Expect(hooks.Create(ctx, &hook)).NotTo(HaveOccurred())
+
+		scheme := store.Scheme{Tags: []string{"lost"}, Data: json.RawMessage(`{"a":1}`)}
+		Expect(schemes.Create(ctx, &scheme)).NotTo(HaveOccurred())
+
+		d := NewDispatcher(v, hooks, zap.NewNop())
+		d.Job(ctx)
+
+		Expect(rcv.payloads).To(HaveLen(1))
+		Expect(rcv.payloads[0].WebhookID).To(Equal(hook.ID))
+
+		items, _, err := hooks.Deliveries(ctx, store.DeliveriesRequest{WebhookID: lost.ID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Status).To(Equal(store.DeliveryDead))
+		Expect(items[0].Attempts[0].Error).To(ContainSubstring("could not open secret"))
+	})
+
+	It("should retry failed delivery and mark it dead after max_attempts", func() {
+		rcv := &receiver{status: http.StatusInternalServerError}
+		srv := httptest.NewServer(rcv)
+		defer srv.Close()
+
+		hook := store.Webhook{URL: srv.URL, Secret: "secret"}
+		Expect(hooks.Create(ctx, &hook)).NotTo(HaveOccurred())
+
+		scheme := store.Scheme{Tags: []string{"retry"}, Data: json.RawMessage(`{"a":1}`)}
+		Expect(schemes.Create(ctx, &scheme)).NotTo(HaveOccurred())
+
+		d := NewDispatcher(v, hooks, zap.NewNop())
+		d.Job(ctx)
+
+		items, _, err := hooks.Deliveries(ctx, store.DeliveriesRequest{WebhookID: hook.ID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Status).To(Equal(store.DeliveryPending))
+		Expect(items[0].Attempts).To(HaveLen(1))
+		Expect(items[0].Attempts[0].StatusCode).To(Equal(http.StatusInternalServerError))
+
+		for i := 0; i < 10 && len(rcv.payloads) < 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+			d.Job(ctx)
+		}
+
+		items, _, err = hooks.Deliveries(ctx, store.DeliveriesRequest{WebhookID: hook.ID, Status: store.DeliveryDead})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Attempts).To(HaveLen(3))
+		Expect(rcv.payloads).To(HaveLen(3))
+
+		// dead delivery is not retried:
+		time.Sleep(10 * time.Millisecond)
+		d.Job(ctx)
+		Expect(rcv.payloads).To(HaveLen(3))
+	})
+})